@@ -0,0 +1,51 @@
+// Package contexttest 提供测试 contextx 用法的辅助函数
+package contexttest
+
+import (
+	"gopractice/contextx/source"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// AssertNoPropagationGoroutines 检查测试结束之前没有派生出需要 goroutine 才能级联取消的 Context，
+// 这通常说明在自定义的 Context 下调用了 WithCancel、WithTimeout 等，每个这样的子节点都会多一个等待 goroutine。
+// 它在测试开始时开启 source.TrackPropagation，测试结束时关闭并检查，失败时输出每个子节点的派生位置。
+// 记录是全局的，不能在并行的测试中使用。
+func AssertNoPropagationGoroutines(t testing.TB) {
+	t.Helper()
+	source.TrackPropagation(true)
+	t.Cleanup(func() {
+		records := source.Propagations()
+		source.TrackPropagation(false)
+		if len(records) == 0 {
+			return
+		}
+		var b strings.Builder
+		for _, p := range records {
+			b.WriteString("\n" + p.Child + " under " + p.Parent + "\n" + p.Stack)
+		}
+		t.Errorf("%d contexts needed a propagation goroutine:%s", len(records), b.String())
+	})
+}
+
+// AssertStrict 在测试期间开启 source.StrictLog，测试结束时触发几次 GC，
+// 检查期间是否有 WithValue 的误用或者没有调用就被丢弃的 CancelFunc。
+// 严格模式是全局的，不能在并行的测试中使用。
+func AssertStrict(t testing.TB) {
+	t.Helper()
+	source.SetStrict(source.StrictLog)
+	t.Cleanup(func() {
+		// finalizer 在单独的 goroutine 中运行，多等几轮
+		for i := 0; i < 3; i++ {
+			runtime.GC()
+			time.Sleep(10 * time.Millisecond)
+		}
+		violations := source.StrictViolations()
+		source.SetStrict(source.StrictOff)
+		if len(violations) != 0 {
+			t.Errorf("%d context misuses in strict mode:\n%s", len(violations), strings.Join(violations, "\n"))
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package contexttest
+
+import (
+	"gopractice/contextx/source"
+	"strings"
+	"testing"
+	"time"
+)
+
+// customCtx 是自定义的 Context，向上找不到 cancelCtx
+type customCtx struct {
+	source.Context
+}
+
+func (customCtx) Value(key any) any { return nil }
+
+// fakeT 记录 Errorf，在 Cleanup 时手动运行清理函数
+type fakeT struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (t *fakeT) Helper()          {}
+func (t *fakeT) Cleanup(f func()) { t.cleanups = append(t.cleanups, f) }
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, format)
+}
+
+func (t *fakeT) finish() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func TestAssertNoPropagationGoroutines(t *testing.T) {
+	ft := new(fakeT)
+	AssertNoPropagationGoroutines(ft)
+	ctx, cancel := source.WithCancel(source.Background())
+	_, childCancel := source.WithTimeout(ctx, time.Hour)
+	childCancel()
+	cancel()
+	ft.finish()
+	if len(ft.errors) != 0 {
+		t.Errorf("errors = %v, want none", ft.errors)
+	}
+
+	ft = new(fakeT)
+	AssertNoPropagationGoroutines(ft)
+	parent, cancel := source.WithCancel(source.Background())
+	defer cancel()
+	before := source.PropagationGoroutines()
+	_, childCancel = source.WithCancel(customCtx{parent})
+	if n := source.PropagationGoroutines() - before; n != 1 {
+		t.Errorf("PropagationGoroutines() increased by %d, want 1", n)
+	}
+	childCancel()
+	ft.finish()
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], "propagation goroutine") {
+		t.Errorf("errors = %v, want one", ft.errors)
+	}
+	if len(source.Propagations()) != 0 {
+		t.Error("records were kept after the test")
+	}
+}
+
+func TestAssertStrict(t *testing.T) {
+	ft := new(fakeT)
+	AssertStrict(ft)
+	ctx, cancel := source.WithCancel(source.Background())
+	source.WithValue(ctx, source.NewKey[int]("n"), 1)
+	cancel()
+	ft.finish()
+	if len(ft.errors) != 0 {
+		t.Errorf("errors = %v, want none", ft.errors)
+	}
+
+	ft = new(fakeT)
+	AssertStrict(ft)
+	source.WithValue(source.Background(), "user", "a")
+	func() { source.WithCancel(source.Background()) }()
+	ft.finish()
+	if len(ft.errors) != 1 || !strings.Contains(ft.errors[0], "misuses") {
+		t.Errorf("errors = %v, want one", ft.errors)
+	}
+}
@@ -0,0 +1,83 @@
+// Package ctxio 让 io.Reader、io.Writer 可以被 contextx 的 Context 取消
+// 底层是 net.Conn 这类支持 SetReadDeadline、SetWriteDeadline 的值时，Context 取消会把截止时间设为过去，
+// 阻塞中的 Read、Write 立即返回；其他的 Reader、Writer 只能在每次调用前后检查 Context。
+package ctxio
+
+import (
+	"gopractice/contextx/source"
+	"io"
+	"time"
+)
+
+// aLongTimeAgo 用来让阻塞的读写立即超时的截止时间
+var aLongTimeAgo = time.Unix(1, 0)
+
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// NewReader 返回在 ctx 取消后立即返回 ctx.Err() 的 Reader
+// 因为取消而中断时，底层连接的读截止时间停留在过去，这个连接之后不能再读，通常应当关闭。
+func NewReader(ctx source.Context, r io.Reader) io.Reader {
+	cr := &reader{ctx: ctx, r: r}
+	cr.d, _ = r.(readDeadliner)
+	return cr
+}
+
+type reader struct {
+	ctx source.Context
+	r   io.Reader
+	d   readDeadliner
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if r.d == nil {
+		return r.r.Read(p)
+	}
+	stop := source.AfterFunc(r.ctx, func() {
+		r.d.SetReadDeadline(aLongTimeAgo)
+	})
+	n, err := r.r.Read(p)
+	if !stop() && err != nil {
+		// 回调已经执行，错误是设置的截止时间造成的
+		err = r.ctx.Err()
+	}
+	return n, err
+}
+
+// NewWriter 返回在 ctx 取消后立即返回 ctx.Err() 的 Writer，底层连接的处理与 NewReader 相同
+func NewWriter(ctx source.Context, w io.Writer) io.Writer {
+	cw := &writer{ctx: ctx, w: w}
+	cw.d, _ = w.(writeDeadliner)
+	return cw
+}
+
+type writer struct {
+	ctx source.Context
+	w   io.Writer
+	d   writeDeadliner
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if w.d == nil {
+		return w.w.Write(p)
+	}
+	stop := source.AfterFunc(w.ctx, func() {
+		w.d.SetWriteDeadline(aLongTimeAgo)
+	})
+	n, err := w.w.Write(p)
+	if !stop() && err != nil {
+		err = w.ctx.Err()
+	}
+	return n, err
+}
@@ -0,0 +1,57 @@
+package ctxio
+
+import (
+	"bytes"
+	"gopractice/contextx/source"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReaderConn(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	ctx, cancel := source.WithCancel(source.Background())
+	r := NewReader(ctx, a)
+	go b.Write([]byte("hi"))
+	buf := make([]byte, 8)
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "hi" {
+		t.Fatalf("Read() = %q, %v", buf[:n], err)
+	}
+
+	// 阻塞中的 Read 在取消后立即返回
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := r.Read(buf); err != source.Canceled || time.Since(start) > time.Second {
+		t.Errorf("Read() = %v after %v, want Canceled", err, time.Since(start))
+	}
+}
+
+func TestWriterConn(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	ctx, cancel := source.WithTimeout(source.Background(), 10*time.Millisecond)
+	defer cancel()
+	// 没有人读，net.Pipe 的 Write 会一直阻塞
+	if _, err := NewWriter(ctx, a).Write([]byte("hi")); err != source.DeadlineExceeded {
+		t.Errorf("Write() = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestPlainReaderWriter(t *testing.T) {
+	ctx, cancel := source.WithCancel(source.Background())
+	var out bytes.Buffer
+	w := NewWriter(ctx, &out)
+	if _, err := io.Copy(w, NewReader(ctx, bytes.NewReader([]byte("data")))); err != nil || out.String() != "data" {
+		t.Fatalf("Copy() = %v, wrote %q", err, out.String())
+	}
+	cancel()
+	if _, err := w.Write([]byte("x")); err != source.Canceled {
+		t.Errorf("Write() after cancel = %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+// Package group 基于 contextx 的 Context 实现 errgroup：
+// 一组 goroutine 中第一个返回错误的会取消派生出的 Context，并把这个错误作为取消原因。
+package group
+
+import (
+	"fmt"
+	"gopractice/contextx/source"
+	"sync"
+)
+
+// Group 一组 goroutine，零值可以直接使用，此时出错不会取消任何 Context
+type Group struct {
+	cancel source.CancelCauseFunc
+
+	wg  sync.WaitGroup
+	sem chan struct{} // SetLimit 设置的并发限制，nil 表示不限制
+
+	errOnce sync.Once
+	err     error
+}
+
+// WithContext 返回一个新的 Group 和从 ctx 派生的 Context，
+// 派生的 Context 在第一个 goroutine 返回错误或者 Wait 返回时被取消，source.Cause 返回那个错误。
+func WithContext(ctx source.Context) (*Group, source.Context) {
+	ctx, cancel := source.WithCancelCause(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// Go 在新的 goroutine 中调用 f，设置了并发限制时会阻塞到有空位为止
+// 第一个返回的错误会被 Wait 返回，并取消 WithContext 派生的 Context。
+func (g *Group) Go(f func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.start(f)
+}
+
+// TryGo 与 Go 相同，但已经达到并发限制时不会阻塞，直接返回 false
+func (g *Group) TryGo(f func() error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.start(f)
+	return true
+}
+
+func (g *Group) start(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+		if err := f(); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+				if g.cancel != nil {
+					g.cancel(err)
+				}
+			})
+		}
+	}()
+}
+
+func (g *Group) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
+
+// SetLimit 限制同时运行的 goroutine 数，n 小于 0 表示不限制。
+// 有 goroutine 正在运行时不能修改限制，否则 panic。
+func (g *Group) SetLimit(n int) {
+	if n < 0 {
+		g.sem = nil
+		return
+	}
+	if len(g.sem) != 0 {
+		panic(fmt.Errorf("group: modify limit while %v goroutines in the group are still active", len(g.sem)))
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// Wait 等待所有 goroutine 返回，返回第一个错误，然后取消 WithContext 派生的 Context
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel(g.err)
+	}
+	return g.err
+}
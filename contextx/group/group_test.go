@@ -0,0 +1,71 @@
+package group
+
+import (
+	"errors"
+	"gopractice/contextx/source"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupFirstError(t *testing.T) {
+	errFirst := errors.New("first")
+	g, ctx := WithContext(source.Background())
+	g.Go(func() error { return errFirst })
+	g.Go(func() error {
+		<-ctx.Done()
+		return errors.New("second")
+	})
+	if err := g.Wait(); err != errFirst {
+		t.Errorf("Wait() = %v, want the first error", err)
+	}
+	if ctx.Err() != source.Canceled || source.Cause(ctx) != errFirst {
+		t.Errorf("Err() = %v, Cause() = %v", ctx.Err(), source.Cause(ctx))
+	}
+
+	// 没有错误时 Wait 返回之后也会取消
+	g, ctx = WithContext(source.Background())
+	g.Go(func() error { return nil })
+	if err := g.Wait(); err != nil || ctx.Err() != source.Canceled || source.Cause(ctx) != source.Canceled {
+		t.Errorf("Wait() = %v, Err() = %v, Cause() = %v", err, ctx.Err(), source.Cause(ctx))
+	}
+}
+
+func TestGroupLimit(t *testing.T) {
+	var g Group
+	g.SetLimit(2)
+	var running, peak int32
+	for i := 0; i < 10; i++ {
+		g.Go(func() error {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if peak > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2", peak)
+	}
+
+	block := make(chan struct{})
+	g.SetLimit(1)
+	g.Go(func() error { <-block; return nil })
+	if g.TryGo(func() error { return nil }) {
+		t.Error("TryGo() at the limit = true")
+	}
+	close(block)
+	g.Wait()
+	if !g.TryGo(func() error { return nil }) {
+		t.Error("TryGo() below the limit = false")
+	}
+	g.Wait()
+}
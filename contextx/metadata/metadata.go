@@ -0,0 +1,133 @@
+// Package metadata 在 contextx 的 Context 中保存 gRPC 风格的元数据，
+// 元数据是 key 不区分大小写的 map[string][]string，分为发出的（outgoing）和收到的（incoming）两份，
+// 每份只使用一个 context key：追加时复制合并成新的 MD 再保存，查找时不需要遍历多个节点合并。
+package metadata
+
+import (
+	"gopractice/contextx/source"
+	"strings"
+)
+
+// MD 元数据，key 统一保存为小写
+type MD map[string][]string
+
+// New 用 m 创建 MD，key 转为小写
+func New(m map[string]string) MD {
+	md := make(MD, len(m))
+	for k, v := range m {
+		md.Append(k, v)
+	}
+	return md
+}
+
+// Pairs 用 key1, val1, key2, val2... 创建 MD，相同的 key 的值合并在一起，参数个数为奇数时 panic
+func Pairs(kv ...string) MD {
+	if len(kv)%2 == 1 {
+		panic("metadata: Pairs got an odd number of arguments")
+	}
+	md := make(MD, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		md.Append(kv[i], kv[i+1])
+	}
+	return md
+}
+
+// Get 返回 k 的所有值，k 不区分大小写
+func (md MD) Get(k string) []string {
+	return md[strings.ToLower(k)]
+}
+
+// Set 把 k 的值替换为 vals，vals 为空时什么也不做
+func (md MD) Set(k string, vals ...string) {
+	if len(vals) == 0 {
+		return
+	}
+	md[strings.ToLower(k)] = vals
+}
+
+// Append 把 vals 追加到 k 已有的值之后，vals 为空时什么也不做
+func (md MD) Append(k string, vals ...string) {
+	if len(vals) == 0 {
+		return
+	}
+	k = strings.ToLower(k)
+	md[k] = append(md[k], vals...)
+}
+
+// Delete 删除 k 的所有值
+func (md MD) Delete(k string) {
+	delete(md, strings.ToLower(k))
+}
+
+// Len 返回 key 的个数
+func (md MD) Len() int {
+	return len(md)
+}
+
+// Copy 返回 md 的深拷贝，修改拷贝不会影响 md
+func (md MD) Copy() MD {
+	out := make(MD, len(md))
+	for k, v := range md {
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
+
+// Join 按顺序合并多个 MD，相同 key 的值依次追加
+func Join(mds ...MD) MD {
+	out := MD{}
+	for _, md := range mds {
+		for k, v := range md {
+			out[k] = append(out[k], v...)
+		}
+	}
+	return out
+}
+
+type (
+	incomingKey struct{}
+	outgoingKey struct{}
+)
+
+// NewOutgoingContext 返回保存了发出的元数据 md 的 Context，替换 ctx 中已有的
+// md 之后不应该再修改，需要修改时先 Copy。
+func NewOutgoingContext(ctx source.Context, md MD) source.Context {
+	return source.WithValue(ctx, outgoingKey{}, md)
+}
+
+// AppendToOutgoing 把 key1, val1, key2, val2... 追加到 ctx 中发出的元数据，
+// 原有的元数据不会被修改，合并后的结果保存在同一个 key 下，参数个数为奇数时 panic。
+func AppendToOutgoing(ctx source.Context, kv ...string) source.Context {
+	if len(kv)%2 == 1 {
+		panic("metadata: AppendToOutgoing got an odd number of arguments")
+	}
+	md, _ := ctx.Value(outgoingKey{}).(MD)
+	md = md.Copy()
+	for i := 0; i < len(kv); i += 2 {
+		md.Append(kv[i], kv[i+1])
+	}
+	return source.WithValue(ctx, outgoingKey{}, md)
+}
+
+// FromOutgoing 返回 ctx 中发出的元数据的拷贝
+func FromOutgoing(ctx source.Context) (MD, bool) {
+	return from(ctx, outgoingKey{})
+}
+
+// NewIncomingContext 返回保存了收到的元数据 md 的 Context，通常由服务端在收到请求时调用
+func NewIncomingContext(ctx source.Context, md MD) source.Context {
+	return source.WithValue(ctx, incomingKey{}, md)
+}
+
+// FromIncoming 返回 ctx 中收到的元数据的拷贝
+func FromIncoming(ctx source.Context) (MD, bool) {
+	return from(ctx, incomingKey{})
+}
+
+func from(ctx source.Context, key any) (MD, bool) {
+	md, ok := ctx.Value(key).(MD)
+	if !ok {
+		return nil, false
+	}
+	return md.Copy(), true
+}
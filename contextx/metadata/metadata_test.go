@@ -0,0 +1,50 @@
+package metadata
+
+import (
+	"gopractice/contextx/source"
+	"reflect"
+	"testing"
+)
+
+func TestMD(t *testing.T) {
+	md := Pairs("Trace-ID", "a", "trace-id", "b", "User", "gopher")
+	if got := md.Get("TRACE-ID"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Get(TRACE-ID) = %v", got)
+	}
+	md.Set("user", "root")
+	md.Delete("Trace-Id")
+	if !reflect.DeepEqual(md, MD{"user": {"root"}}) {
+		t.Errorf("md = %v", md)
+	}
+	if got := Join(New(map[string]string{"K": "1"}), Pairs("k", "2")); !reflect.DeepEqual(got, MD{"k": {"1", "2"}}) {
+		t.Errorf("Join() = %v", got)
+	}
+}
+
+func TestOutgoing(t *testing.T) {
+	base := NewOutgoingContext(source.Background(), Pairs("k", "1"))
+	ctx := AppendToOutgoing(base, "K", "2", "other", "x")
+	ctx = AppendToOutgoing(ctx, "k", "3")
+
+	md, ok := FromOutgoing(ctx)
+	if !ok || !reflect.DeepEqual(md, MD{"k": {"1", "2", "3"}, "other": {"x"}}) {
+		t.Errorf("FromOutgoing() = %v, %v", md, ok)
+	}
+	// 追加不会修改父节点中的元数据，返回的是拷贝
+	md["k"][0] = "changed"
+	if md, _ := FromOutgoing(base); !reflect.DeepEqual(md, MD{"k": {"1"}}) {
+		t.Errorf("FromOutgoing(base) = %v", md)
+	}
+	if got, _ := FromOutgoing(ctx); got.Get("k")[0] != "1" {
+		t.Error("modifying the returned MD changed the context")
+	}
+
+	// 发出和收到的元数据互不影响
+	if _, ok := FromIncoming(ctx); ok {
+		t.Error("FromIncoming() found outgoing metadata")
+	}
+	in := NewIncomingContext(ctx, Pairs("from", "client"))
+	if md, ok := FromIncoming(in); !ok || md.Get("from")[0] != "client" {
+		t.Errorf("FromIncoming() = %v, %v", md, ok)
+	}
+}
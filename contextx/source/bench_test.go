@@ -0,0 +1,177 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// 与标准库 context 对比的基准测试，每个用例都分为 contextx 和 std 两个子测试
+// 运行 go test -bench . -benchmem 查看 ns/op 和 allocs/op
+
+type benchKey int
+
+func BenchmarkWithCancel(b *testing.B) {
+	b.Run("contextx", func(b *testing.B) {
+		parent, cancelParent := WithCancel(Background())
+		defer cancelParent()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, cancel := WithCancel(parent)
+			cancel()
+		}
+	})
+	b.Run("std", func(b *testing.B) {
+		parent, cancelParent := context.WithCancel(context.Background())
+		defer cancelParent()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, cancel := context.WithCancel(parent)
+			cancel()
+		}
+	})
+}
+
+func BenchmarkWithValue(b *testing.B) {
+	for _, depth := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("contextx/depth=%d", depth), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ctx := Background()
+				for d := 0; d < depth; d++ {
+					ctx = WithValue(ctx, benchKey(d), d)
+				}
+				if ctx.Value(benchKey(0)) != 0 {
+					b.Fatal("wrong value")
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("std/depth=%d", depth), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ctx := context.Background()
+				for d := 0; d < depth; d++ {
+					ctx = context.WithValue(ctx, benchKey(d), d)
+				}
+				if ctx.Value(benchKey(0)) != 0 {
+					b.Fatal("wrong value")
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDeepValueLookup 只测量在很深的链上查找最顶层 key 的开销，链在计时前构建好
+func BenchmarkDeepValueLookup(b *testing.B) {
+	for _, depth := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("contextx/depth=%d", depth), func(b *testing.B) {
+			ctx := WithValue(Background(), benchKey(-1), -1)
+			for d := 0; d < depth; d++ {
+				var cancel CancelFunc
+				ctx, cancel = WithCancel(WithValue(ctx, benchKey(d), d))
+				defer cancel()
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if ctx.Value(benchKey(-1)) != -1 {
+					b.Fatal("wrong value")
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("std/depth=%d", depth), func(b *testing.B) {
+			ctx := context.WithValue(context.Background(), benchKey(-1), -1)
+			for d := 0; d < depth; d++ {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithCancel(context.WithValue(ctx, benchKey(d), d))
+				defer cancel()
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if ctx.Value(benchKey(-1)) != -1 {
+					b.Fatal("wrong value")
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkWithTimeoutFire 测量定时器到期自动取消的整个过程
+func BenchmarkWithTimeoutFire(b *testing.B) {
+	b.Run("contextx", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ctx, cancel := WithTimeout(Background(), time.Microsecond)
+			<-ctx.Done()
+			cancel()
+		}
+	})
+	b.Run("std", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Microsecond)
+			<-ctx.Done()
+			cancel()
+		}
+	})
+}
+
+// BenchmarkIndexedValueLookup 对比在很深的链上查找最顶层的 key 时，普通的 value() 遍历和 WithIndexedValues 的索引
+// 链的每一层都有 WithValue 和 WithCancel，索引版本每 10 层用 WithIndexedValues 代替一次 WithValue
+func BenchmarkIndexedValueLookup(b *testing.B) {
+	for _, depth := range []int{10, 100, 1000} {
+		for _, indexed := range []bool{false, true} {
+			name := fmt.Sprintf("walk/depth=%d", depth)
+			if indexed {
+				name = fmt.Sprintf("indexed/depth=%d", depth)
+			}
+			b.Run(name, func(b *testing.B) {
+				ctx := WithValue(Background(), benchKey(-1), -1)
+				for d := 0; d < depth; d++ {
+					if indexed && d%10 == 9 {
+						ctx = WithIndexedValues(ctx, benchKey(d), d)
+					} else {
+						ctx = WithValue(ctx, benchKey(d), d)
+					}
+					var cancel CancelFunc
+					ctx, cancel = WithCancel(ctx)
+					defer cancel()
+				}
+				if indexed {
+					ctx = WithIndexedValues(ctx)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if ctx.Value(benchKey(-1)) != -1 {
+						b.Fatal("wrong value")
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkReleasableWithCancel 对比每个请求派生一个可取消的 Context 时，WithCancel 和回收节点的分配次数
+func BenchmarkReleasableWithCancel(b *testing.B) {
+	parent, cancelParent := WithCancel(Background())
+	defer cancelParent()
+	b.Run("WithCancel", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ctx, cancel := WithCancel(parent)
+			_ = ctx.Done()
+			cancel()
+		}
+	})
+	b.Run("Releasable", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ctx, _, release := ReleasableWithCancel(parent)
+			_ = ctx.Done()
+			release()
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package source
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// BudgetExceeded WithBudget 创建的 Context 用完时间预算时的取消原因，此时 Err 返回 DeadlineExceeded
+var BudgetExceeded = errors.New("context budget exceeded")
+
+// maxTimeout SetMaxTimeout 设置的全局时间预算，单位纳秒
+var maxTimeout int64
+
+// SetMaxTimeout 设置全局的时间预算上限，WithBudget 创建的 Context 最多存活 d，d 不大于 0 表示不限制
+func SetMaxTimeout(d time.Duration) {
+	atomic.StoreInt64(&maxTimeout, int64(d))
+}
+
+// MaxTimeout 返回 SetMaxTimeout 设置的上限，没有设置时返回 0
+func MaxTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&maxTimeout))
+}
+
+// WithBudget 返回时间预算的根节点，在 MaxTimeout 之后以 BudgetExceeded 为原因取消。
+// 由于子节点的截止时间不会晚于父节点，它下面的 WithTimeout、WithDeadline 都会被限制在剩余的预算内，
+// 调用方传入再长的超时也不会超过服务的上限。通常在服务端收到请求时调用。
+// 没有设置上限时与 WithCancel 相同，parent 的截止时间更早时以 parent 的为准。
+func WithBudget(parent Context) (Context, CancelFunc) {
+	d := MaxTimeout()
+	if d <= 0 {
+		return WithCancel(parent)
+	}
+	return WithDeadlineCause(parent, clock().Now().Add(d), BudgetExceeded)
+}
@@ -0,0 +1,32 @@
+package source
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithBudget(t *testing.T) {
+	clk := useFakeClock(t)
+	SetMaxTimeout(time.Minute)
+	defer SetMaxTimeout(0)
+
+	root, cancel := WithBudget(Background())
+	defer cancel()
+	// 调用方传入很长的超时，被限制在预算内
+	ctx, ctxCancel := WithTimeout(root, 24*time.Hour)
+	defer ctxCancel()
+	if d, _ := ctx.Deadline(); !d.Equal(clk.Now().Add(time.Minute)) {
+		t.Errorf("Deadline() = %v, want the budget deadline", d)
+	}
+	clk.Advance(time.Minute)
+	if ctx.Err() != DeadlineExceeded || Cause(ctx) != BudgetExceeded {
+		t.Errorf("Err() = %v, Cause() = %v", ctx.Err(), Cause(ctx))
+	}
+
+	SetMaxTimeout(0)
+	root, cancel = WithBudget(Background())
+	defer cancel()
+	if _, ok := root.Deadline(); ok {
+		t.Error("WithBudget without a limit set a deadline")
+	}
+}
@@ -0,0 +1,38 @@
+package source
+
+import "errors"
+
+// ErrChanClosed Recv 的 channel 已经关闭
+var ErrChanClosed = errors.New("context: receive from closed channel")
+
+// Send 向 ch 发送 v，ctx 先取消时放弃发送并返回 ctx.Err()
+// ctx 已经取消时不会尝试发送，即使 ch 还有空位。
+func Send[T any](ctx Context, ch chan<- T, v T) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case ch <- v:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Recv 从 ch 接收一个值，ctx 先取消时返回 ctx.Err()，ch 已经关闭时返回 ErrChanClosed
+// ctx 已经取消时不会尝试接收，即使 ch 中还有数据。
+func Recv[T any](ctx Context, ch <-chan T) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	select {
+	case v, ok := <-ch:
+		if !ok {
+			return zero, ErrChanClosed
+		}
+		return v, nil
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
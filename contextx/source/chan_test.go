@@ -0,0 +1,31 @@
+package source
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSendRecv(t *testing.T) {
+	ch := make(chan int, 1)
+	if err := Send(Background(), ch, 1); err != nil {
+		t.Fatalf("Send() = %v", err)
+	}
+	if v, err := Recv(Background(), ch); v != 1 || err != nil {
+		t.Fatalf("Recv() = %v, %v", v, err)
+	}
+
+	ctx, cancel := WithTimeout(Background(), 10*time.Millisecond)
+	defer cancel()
+	if v, err := Recv(ctx, ch); v != 0 || err != DeadlineExceeded {
+		t.Errorf("Recv() on an empty channel = %v, %v", v, err)
+	}
+	// ctx 已经取消时即使有空位也不发送
+	if err := Send(ctx, ch, 2); err != DeadlineExceeded || len(ch) != 0 {
+		t.Errorf("Send() on a done ctx = %v, len = %d", err, len(ch))
+	}
+
+	close(ch)
+	if _, err := Recv(Background(), ch); err != ErrChanClosed {
+		t.Errorf("Recv() on a closed channel = %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package source
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock WithDeadline、WithTimeout 等带有定时器的 Context 使用的时钟，
+// 默认使用 time 包，测试时可以通过 SetClock 换成 FakeClock，手动推进时间，不需要真正等待。
+type Clock interface {
+	Now() time.Time
+	// AfterFunc 与 time.AfterFunc 相同，d 之后调用 f
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer Clock.AfterFunc 返回的定时器，方法与 *time.Timer 相同
+type Timer interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+// clockValue 当前使用的时钟，保存的是 clockBox，没有设置过时使用 time 包
+// 定时器的回调在其他 goroutine 中读取时钟，所以和 logger 一样用 atomic.Value 保存。
+var clockValue atomic.Value
+
+// clockBox atomic.Value 要求每次保存的类型相同
+type clockBox struct {
+	c Clock
+}
+
+// SetClock 设置创建 Context 时使用的时钟，传入 nil 时恢复为 time 包。
+// 已经创建的 Context 继续使用创建时的定时器，所以只应该在创建 Context 之前设置，通常只在测试中使用。
+func SetClock(c Clock) {
+	if c == nil {
+		c = realClock{}
+	}
+	clockValue.Store(clockBox{c})
+}
+
+// clock 返回当前使用的时钟
+func clock() Clock {
+	if b, ok := clockValue.Load().(clockBox); ok {
+		return b.c
+	}
+	return realClock{}
+}
+
+// until 与 time.Until 相同，使用 clock
+func until(t time.Time) time.Duration {
+	return t.Sub(clock().Now())
+}
+
+// FakeClock 手动推进的时钟，Advance 时同步调用到期的定时器的回调
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock 返回从 now 开始的 FakeClock
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc d 不大于 0 时与 time.AfterFunc 一样在新的 goroutine 中立即调用 f，
+// 否则在 Advance 到 d 之后时调用
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &fakeTimer{clock: c, f: f}
+	t.Reset(d)
+	return t
+}
+
+// Advance 把时间推进 d，按到期的先后顺序调用到期的定时器的回调，返回时回调都已经执行完
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due, rest []*fakeTimer
+	for _, t := range c.timers {
+		if t.when.After(c.now) {
+			rest = append(rest, t)
+		} else {
+			due = append(due, t)
+		}
+	}
+	c.timers = rest
+	c.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].when.Before(due[j].when) })
+	for _, t := range due {
+		t.f()
+	}
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	f     func()
+	when  time.Time
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	active := t.Stop()
+	if d <= 0 {
+		go t.f()
+		return active
+	}
+	c := t.clock
+	c.mu.Lock()
+	t.when = c.now.Add(d)
+	c.timers = append(c.timers, t)
+	c.mu.Unlock()
+	return active
+}
@@ -0,0 +1,83 @@
+package source
+
+import (
+	"testing"
+	"time"
+)
+
+func useFakeClock(t *testing.T) *FakeClock {
+	c := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	SetClock(c)
+	t.Cleanup(func() { SetClock(nil) })
+	return c
+}
+
+func TestFakeClockTimeout(t *testing.T) {
+	clk := useFakeClock(t)
+	ctx, cancel := WithTimeout(Background(), time.Hour)
+	defer cancel()
+	if d, _ := ctx.Deadline(); !d.Equal(clk.Now().Add(time.Hour)) {
+		t.Errorf("Deadline() = %v", d)
+	}
+
+	clk.Advance(59 * time.Minute)
+	if ctx.Err() != nil {
+		t.Fatalf("Err() before the deadline = %v", ctx.Err())
+	}
+	clk.Advance(time.Minute)
+	if ctx.Err() != DeadlineExceeded {
+		t.Errorf("Err() at the deadline = %v", ctx.Err())
+	}
+
+	// 停止的定时器不会再触发
+	ctx, cancel = WithTimeout(Background(), time.Second)
+	cancel()
+	clk.Advance(time.Second)
+	if ctx.Err() != Canceled {
+		t.Errorf("Err() = %v, want Canceled", ctx.Err())
+	}
+}
+
+func TestFakeClockExtendAndPause(t *testing.T) {
+	clk := useFakeClock(t)
+	ext, extend, cancel := WithExtendableDeadline(Background(), clk.Now().Add(time.Minute))
+	defer cancel()
+	p, pCancel := WithPausableTimeout(Background(), time.Minute)
+	defer pCancel()
+
+	clk.Advance(30 * time.Second)
+	extend(clk.Now().Add(time.Minute))
+	p.Pause()
+	clk.Advance(time.Minute - time.Second)
+	if ext.Err() != nil || p.Err() != nil {
+		t.Fatalf("Err() = %v, %v, want both alive", ext.Err(), p.Err())
+	}
+	clk.Advance(time.Second)
+	if ext.Err() != DeadlineExceeded {
+		t.Errorf("extended ctx Err() = %v", ext.Err())
+	}
+
+	p.Resume()
+	clk.Advance(30 * time.Second)
+	if p.Err() != DeadlineExceeded {
+		t.Errorf("resumed ctx Err() = %v", p.Err())
+	}
+}
+
+// SetClock 可能和其他 goroutine 中的 WithTimeout、定时器回调同时发生，用 -race 检查
+func TestSetClockConcurrent(t *testing.T) {
+	t.Cleanup(func() { SetClock(nil) })
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_, cancel := WithTimeout(Background(), time.Microsecond)
+			cancel()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		SetClock(NewFakeClock(time.Now()))
+		SetClock(nil)
+	}
+	<-done
+}
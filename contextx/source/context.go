@@ -3,6 +3,7 @@ package source
 import (
 	"errors"
 	"gopractice/reflectlite"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -26,7 +27,7 @@ type Context interface {
 
 // 如果一个 Context 类型实现了上面定义的两个方法，该 Context 就是一个可取消的 Context。
 type canceler interface {
-	cancel(removeFromParent bool, err error)
+	cancel(removeFromParent bool, err, cause error)
 	Done() <-chan struct{}
 }
 
@@ -78,6 +79,13 @@ type cancelCtx struct {
 	// 当 done 被关闭时，err 返回非空值，内容是被关闭的原因，是主动 cancel 还是 timeout 取消，
 	// 这些错误信息都是 context 包内部定义的
 	err error
+	// cause 取消的具体原因，由 WithCancelCause 返回的函数设置，没有设置时与 err 相同
+	cause error
+	// trace 开启 SetCancelTracing 时记录的取消位置
+	trace *CancelTrace
+	// observer 创建时设置的 Observer，取消时通知它，self 是通知时使用的对外的 Context，为 nil 时是 c 自己
+	observer Observer
+	self     Context
 }
 
 var cancelCtxKey int
@@ -111,6 +119,22 @@ func value(c Context, key any) any {
 				return &ctx.cancelCtx
 			}
 			c = ctx.Context
+		case *valuesCtx:
+			if v, ok := ctx.vals[key]; ok {
+				return v
+			}
+			c = ctx.Context
+		case *indexCtx:
+			if key != &cancelCtxKey {
+				return ctx.lookup(key)
+			}
+			c = ctx.Context
+		case withoutCancelCtx:
+			if key == &cancelCtxKey {
+				// 与父节点的取消断开，向上找到的 *cancelCtx 不属于它
+				return nil
+			}
+			c = ctx.c
 		case *emptyCtx:
 			return nil
 		default:
@@ -162,18 +186,30 @@ func init() {
 	close(closedchan)
 }
 
-func (c *cancelCtx) cancel(removeFromParent bool, err error) {
+func (c *cancelCtx) cancel(removeFromParent bool, err, cause error) {
+	c.tryCancel(removeFromParent, err, cause)
+}
+
+// tryCancel 与 cancel 相同，返回这次调用是否完成了取消，已经被取消过时返回 false
+func (c *cancelCtx) tryCancel(removeFromParent bool, err, cause error) bool {
 	if err == nil {
 		panic("context: internal error: missing cancel error")
 	}
+	if cause == nil {
+		cause = err
+	}
 
 	c.mu.Lock()
 	// 再次判断，防止重复取消
 	if c.err != nil {
 		c.mu.Unlock()
-		return // already canceled
+		return false // already canceled
 	}
 	c.err = err
+	c.cause = cause
+	if atomic.LoadInt32(&cancelTracing) != 0 {
+		c.trace = newCancelTrace(err, cause)
+	}
 
 	// 如果 c.done 还未初始化，说明 Done() 方法还未被调用，这时候直接将 c.done 赋值一个已关闭的 channel
 	// 此时Done() 方法被调用的时候不会阻塞直接返回 struct{}
@@ -186,8 +222,8 @@ func (c *cancelCtx) cancel(removeFromParent bool, err error) {
 
 	// 如果有子节点，递归对子节点进行 cancel 操作
 	for child := range c.children {
-		// 在父锁的范围内，递归调用子节点的cancel
-		child.cancel(false, err)
+		// 在父锁的范围内，递归调用子节点的cancel，子节点的 cause 与父节点相同
+		child.cancel(false, err, cause)
 	}
 	c.children = nil
 	c.mu.Unlock()
@@ -196,6 +232,14 @@ func (c *cancelCtx) cancel(removeFromParent bool, err error) {
 		// 将本节点从它的父节点中删除
 		removeChild(c.Context, c)
 	}
+	if c.observer != nil {
+		self := c.self
+		if self == nil {
+			self = c
+		}
+		c.observer.OnCancel(self, err, cause)
+	}
+	return true
 }
 
 func removeChild(parent Context, child canceler) {
@@ -251,6 +295,9 @@ func WithValue(parent Context, key, val any) Context {
 	if !reflectlite.TypeOf(key).Comparable() {
 		panic("key is not comparable")
 	}
+	if strict() != StrictOff {
+		checkValue(parent, key, val)
+	}
 
 	return &valueCtx{parent, key, val}
 }
@@ -282,6 +329,112 @@ func (c *valueCtx) Value(key any) any {
 	return value(c.Context, key)
 }
 
+// WithValues 与按顺序多次调用 WithValue 相同，但所有的键值对保存在同一个节点的 map 中，
+// 一组请求相关的值不会使 Context 链变长，Value 的查找也不会因此变慢。
+// pairs 依次是 key1, val1, key2, val2...，key 的要求与 WithValue 相同，重复的 key 以后面的为准。
+func WithValues(parent Context, pairs ...any) Context {
+	return withValues(parent, strict() != StrictOff, pairs)
+}
+
+// withValues 实现 WithValues，check 为 false 时跳过严格模式的检查，
+// 用于包内按约定使用 string key 的地方，例如 Unmarshal
+func withValues(parent Context, check bool, pairs []any) Context {
+	if parent == nil {
+		panic("cannot create context from nil parent")
+	}
+	if len(pairs)%2 != 0 {
+		panic("odd number of key-value pairs")
+	}
+
+	c := &valuesCtx{Context: parent, vals: make(map[any]any, len(pairs)/2)}
+	for i := 0; i < len(pairs); i += 2 {
+		key := pairs[i]
+		if key == nil {
+			panic("nil key")
+		}
+		if !reflectlite.TypeOf(key).Comparable() {
+			panic("key is not comparable")
+		}
+		if check {
+			checkValue(parent, key, pairs[i+1])
+		}
+		if _, ok := c.vals[key]; !ok {
+			c.keys = append(c.keys, key)
+		}
+		c.vals[key] = pairs[i+1]
+	}
+	return c
+}
+
+// valuesCtx 保存多个键值对的 valueCtx，keys 记录 key 的添加顺序，用于 String
+type valuesCtx struct {
+	Context
+	keys []any
+	vals map[any]any
+}
+
+func (c *valuesCtx) String() string {
+	var b strings.Builder
+	b.WriteString(contextName(c.Context) + ".WithValues(")
+	for i, key := range c.keys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("type " + reflectlite.TypeOf(key).String() + ", val " + stringify(c.vals[key]))
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+func (c *valuesCtx) Value(key any) any {
+	if v, ok := c.vals[key]; ok {
+		return v
+	}
+	return value(c.Context, key)
+}
+
+// WithoutCancel 返回一个保留 parent 的值，但不会随 parent 取消的 Context，
+// 它的 Done 返回 nil，Err 返回 nil，没有截止时间，Cause 返回 nil。
+// 适用于请求结束之后还需要继续执行，又需要请求中的值（例如 trace id）的任务。
+func WithoutCancel(parent Context) Context {
+	if parent == nil {
+		panic("cannot create context from nil parent")
+	}
+	return withoutCancelCtx{parent}
+}
+
+// Detach 返回保留 parent 的值、不随 parent 取消，但 timeout 之后自动取消的 Context，
+// 即 WithTimeout(WithoutCancel(parent), timeout)。
+// 用于请求中启动、需要在请求结束后继续执行但仍然要有时间上限的后台任务。
+func Detach(parent Context, timeout time.Duration) (Context, CancelFunc) {
+	return WithTimeout(WithoutCancel(parent), timeout)
+}
+
+// withoutCancelCtx 不嵌入父节点，只在 Value 中使用它，其他方法与 emptyCtx 相同
+type withoutCancelCtx struct {
+	c Context
+}
+
+func (withoutCancelCtx) Deadline() (deadline time.Time, ok bool) {
+	return
+}
+
+func (withoutCancelCtx) Done() <-chan struct{} {
+	return nil
+}
+
+func (withoutCancelCtx) Err() error {
+	return nil
+}
+
+func (c withoutCancelCtx) Value(key any) any {
+	return value(c, key)
+}
+
+func (c withoutCancelCtx) String() string {
+	return contextName(c.c) + ".WithoutCancel"
+}
+
 type CancelFunc func()
 
 func WithCancel(parent Context) (ctx Context, cancel CancelFunc) {
@@ -290,10 +443,50 @@ func WithCancel(parent Context) (ctx Context, cancel CancelFunc) {
 	}
 
 	c := newCancelCtx(parent)
+	c.observeNew(nil)
 	propagateCancel(parent, &c)
-	return &c, func() {
-		c.cancel(true, Canceled)
+	return &c, wrapCancel(&c, func() {
+		c.cancel(true, Canceled, nil)
+	})
+}
+
+// CancelCauseFunc 与 CancelFunc 相同，另外设置取消的原因，通过 Cause 获取。
+// 已经取消的 Context 再次调用不会修改原因，cause 为 nil 时原因为 Canceled。
+type CancelCauseFunc func(cause error)
+
+// WithCancelCause 与 WithCancel 相同，但返回 CancelCauseFunc。
+// 调用 cancel(cause) 之后 ctx.Err() 仍然返回 Canceled，Cause(ctx) 返回 cause，
+// 它派生出的所有 Context 也会以同样的 cause 被取消。
+func WithCancelCause(parent Context) (ctx Context, cancel CancelCauseFunc) {
+	if parent == nil {
+		panic("cannot create context from nil parent")
+	}
+
+	c := newCancelCtx(parent)
+	c.observeNew(nil)
+	propagateCancel(parent, &c)
+	if g := guardCancel(&c); g != nil {
+		return &c, func(cause error) {
+			g.done()
+			c.cancel(true, Canceled, cause)
+		}
 	}
+	return &c, func(cause error) {
+		c.cancel(true, Canceled, cause)
+	}
+}
+
+// Cause 返回 c 被取消的原因：
+// 由 WithCancelCause 返回的函数取消时为传入的 cause，其他情况下与 c.Err() 相同，还没有取消时返回 nil。
+// 通过 Value(&cancelCtxKey) 向上查找最近的 *cancelCtx，原因保存在它里面。
+func Cause(c Context) error {
+	if cc, ok := c.Value(&cancelCtxKey).(*cancelCtx); ok {
+		cc.mu.Lock()
+		defer cc.mu.Unlock()
+		return cc.cause
+	}
+	// 找不到 *cancelCtx 说明 c 是自定义的 Context，没有记录原因
+	return c.Err()
 }
 
 func propagateCancel(parent Context, child canceler) {
@@ -308,7 +501,7 @@ func propagateCancel(parent Context, child canceler) {
 	select {
 	case <-done:
 		// parent is already canceled
-		child.cancel(false, parent.Err())
+		child.cancel(false, parent.Err(), Cause(parent))
 		return
 	default:
 	}
@@ -317,7 +510,7 @@ func propagateCancel(parent Context, child canceler) {
 		p.mu.Lock()
 		if p.err != nil {
 			// parent has already been canceled
-			child.cancel(false, p.err)
+			child.cancel(false, p.err, p.cause)
 		} else {
 			if p.children == nil {
 				p.children = make(map[canceler]struct{})
@@ -328,14 +521,17 @@ func propagateCancel(parent Context, child canceler) {
 		p.mu.Unlock()
 	} else {
 		atomic.AddInt32(&goroutines, +1)
+		atomic.AddInt32(&runningGoroutines, +1)
+		trackPropagation(parent, child)
 		// 代码走到这里，说明向上无法找到可取消的 *cancelCtx，这种情况可能是自定义实现的 Context 类型
 		// 这种情况下无法通过 parent Context 的 children map 建立关联，只能通过创建一个 goroutine 来完成及联取消的操作
 		go func() {
+			defer atomic.AddInt32(&runningGoroutines, -1)
 			select {
 			// 这里的 parent.Done() 不能省略，当 parent context 取消时，需要取消下面的 child cotext
 			// 如果省略了就不能级联取消 child context
 			case <-parent.Done():
-				child.cancel(false, parent.Err())
+				child.cancel(false, parent.Err(), Cause(parent))
 			case <-child.Done():
 				// 当 child 取消时，goroutine 退出，防止泄露
 			}
@@ -343,6 +539,91 @@ func propagateCancel(parent Context, child canceler) {
 	}
 }
 
+// AfterFunc 在 ctx 取消之后，在一个新的 goroutine 中调用 f，ctx 已经取消时立即调用。
+// 返回的 stop 用来取消调用：f 还没有开始时阻止调用并返回 true，f 已经开始或者已经 stop 过时返回 false。
+// stop 不会等待 f 执行完，需要的话由调用方自己同步。
+// ctx 是 cancelCtx 时通过 propagateCancel 挂到它的 children 上，和子节点一样被取消，不需要额外的 goroutine。
+func AfterFunc(ctx Context, f func()) (stop func() bool) {
+	a := &afterFuncCtx{f: f}
+	a.cancelCtx.Context = ctx
+	propagateCancel(ctx, a)
+	return func() bool {
+		stopped := false
+		a.once.Do(func() {
+			stopped = true
+		})
+		if stopped {
+			// 从父节点的 children 中删除，同时结束 propagateCancel 可能启动的 goroutine
+			a.cancel(true, Canceled, nil)
+		}
+		return stopped
+	}
+}
+
+// afterFuncCtx 借用 cancelCtx 挂到父节点上，被取消时调用 f
+// 它不会返回给调用方，只作为 canceler 使用。
+type afterFuncCtx struct {
+	cancelCtx
+	once sync.Once // 保证 f 只执行一次，或者被 stop 阻止
+	f    func()
+}
+
+func (a *afterFuncCtx) cancel(removeFromParent bool, err, cause error) {
+	a.cancelCtx.cancel(false, err, cause)
+	if removeFromParent {
+		// 父节点的 children 中保存的是 a，不是 &a.cancelCtx
+		removeChild(a.Context, a)
+	}
+	a.once.Do(func() {
+		go a.f()
+	})
+}
+
+// OnCancel 注册 ctx 取消时调用的 f，ctx 已经取消时立即调用，返回的 stop 与 AfterFunc 相同。
+// 与 AfterFunc 不同，f 在执行取消的 goroutine 中同步调用，不会启动新的 goroutine：
+// ctx 可以一直向上找到 cancelCtx 时，f 由 cancel 在遍历 children 时调用，此时 ctx 及其祖先的锁还没有释放，
+// 所以 f 必须很快返回，并且不能调用 ctx 及其祖先的 Err、Cause，也不能取消它们，否则会死锁，
+// 需要等待或者做复杂清理的话应当使用 AfterFunc。
+// 自定义的 Context 与 propagateCancel 一样需要一个 goroutine 等待 Done，f 在这个 goroutine 中调用。
+func OnCancel(ctx Context, f func()) (stop func() bool) {
+	c := &onCancelCtx{parent: ctx, f: f, done: make(chan struct{})}
+	propagateCancel(ctx, c)
+	return func() bool {
+		stopped := false
+		c.once.Do(func() {
+			stopped = true
+			close(c.done)
+		})
+		if stopped {
+			removeChild(ctx, c)
+		}
+		return stopped
+	}
+}
+
+// onCancelCtx 只实现 canceler 接口的轻量节点，挂在父节点的 children 上，被取消时调用 f
+type onCancelCtx struct {
+	parent Context
+	f      func()
+	once   sync.Once     // 保证 f 只执行一次，或者被 stop 阻止
+	done   chan struct{} // 被取消或者 stop 之后关闭，结束 propagateCancel 可能启动的 goroutine
+}
+
+func (c *onCancelCtx) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *onCancelCtx) cancel(removeFromParent bool, err, cause error) {
+	run := false
+	c.once.Do(func() {
+		run = true
+		close(c.done)
+	})
+	if run {
+		c.f()
+	}
+}
+
 // goroutines counts the number of goroutines ever created; for testing.
 var goroutines int32
 
@@ -351,10 +632,15 @@ func newCancelCtx(parent Context) cancelCtx {
 }
 
 func WithTimeout(parent Context, timeout time.Duration) (Context, CancelFunc) {
-	return WithDeadline(parent, time.Now().Add(timeout))
+	return WithDeadline(parent, clock().Now().Add(timeout))
 }
 
 func WithDeadline(parent Context, d time.Time) (Context, CancelFunc) {
+	return WithDeadlineCause(parent, d, nil)
+}
+
+// WithDeadlineCause 与 WithDeadline 相同，截止时间到了取消时 Cause 返回 cause，Err 仍然是 DeadlineExceeded
+func WithDeadlineCause(parent Context, d time.Time, cause error) (Context, CancelFunc) {
 	if parent == nil {
 		panic("cannot create context from nil parent")
 	}
@@ -367,25 +653,29 @@ func WithDeadline(parent Context, d time.Time) (Context, CancelFunc) {
 		cancelCtx: newCancelCtx(parent),
 		deadline:  d,
 	}
+	c.observeNew(c)
+	if o := c.observer; o != nil {
+		o.OnDeadlineSet(c, d)
+	}
 	propagateCancel(parent, c)
-	dur := time.Until(d)
+	dur := until(d)
 	if dur <= 0 {
-		c.cancel(true, DeadlineExceeded)
-		return c, func() {
-			c.cancel(false, Canceled)
-		}
+		c.cancel(true, DeadlineExceeded, cause)
+		return c, wrapCancel(c, func() {
+			c.cancel(false, Canceled, nil)
+		})
 	}
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.err == nil {
-		c.timer = time.AfterFunc(dur, func() {
-			c.cancel(true, DeadlineExceeded)
+		c.timer = clock().AfterFunc(dur, func() {
+			c.cancel(true, DeadlineExceeded, cause)
 		})
 	}
 
-	return c, func() {
-		c.cancel(true, Canceled)
-	}
+	return c, wrapCancel(c, func() {
+		c.cancel(true, Canceled, nil)
+	})
 }
 
 var DeadlineExceeded error = deadlineExceededError{}
@@ -399,7 +689,7 @@ func (deadlineExceededError) Temporary() bool { return true }
 
 type timerCtx struct {
 	cancelCtx
-	timer *time.Timer
+	timer Timer
 
 	deadline time.Time
 }
@@ -410,11 +700,11 @@ func (c *timerCtx) Deadline() (deadline time.Time, ok bool) {
 func (c *timerCtx) String() string {
 	return contextName(c.cancelCtx.Context) + ".WithDeadline(" +
 		c.deadline.String() + " [" +
-		time.Until(c.deadline).String() + "])"
+		until(c.deadline).String() + "])"
 }
-func (c *timerCtx) cancel(removeFromParent bool, err error) {
+func (c *timerCtx) cancel(removeFromParent bool, err, cause error) {
 	// 调用cancelCtx的取消方法，取消子节点
-	c.cancelCtx.cancel(false, err)
+	c.cancelCtx.cancel(false, err, cause)
 	if removeFromParent {
 		// 将当前的 *timerCtx 从父节点移除掉
 		removeChild(c.cancelCtx.Context, c)
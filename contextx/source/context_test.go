@@ -0,0 +1,234 @@
+package source
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithCancelCause(t *testing.T) {
+	errCause := errors.New("my cause")
+	parent, cancel := WithCancelCause(Background())
+	child, childCancel := WithTimeout(parent, time.Hour)
+	defer childCancel()
+	grandchild := WithValue(child, "k", "v")
+
+	if err := Cause(grandchild); err != nil {
+		t.Fatalf("Cause() before cancel = %v", err)
+	}
+	cancel(errCause)
+	// 再次取消不会修改原因
+	cancel(errors.New("second cause"))
+	for _, ctx := range []Context{parent, child, grandchild} {
+		if ctx.Err() != Canceled || Cause(ctx) != errCause {
+			t.Errorf("%v: Err() = %v, Cause() = %v", ctx, ctx.Err(), Cause(ctx))
+		}
+	}
+
+	// 在已经取消的父节点下创建，子节点的原因与父节点相同
+	late, lateCancel := WithCancel(parent)
+	defer lateCancel()
+	if Cause(late) != errCause {
+		t.Errorf("Cause() of child created after cancel = %v", Cause(late))
+	}
+
+	// cause 为 nil 时原因为 Canceled
+	ctx, cancel := WithCancelCause(Background())
+	cancel(nil)
+	if Cause(ctx) != Canceled {
+		t.Errorf("Cause() after cancel(nil) = %v", Cause(ctx))
+	}
+
+	// 没有使用 WithCancelCause 时原因与 Err() 相同
+	ctx, timeoutCancel := WithTimeout(Background(), time.Millisecond)
+	defer timeoutCancel()
+	<-ctx.Done()
+	if Cause(ctx) != DeadlineExceeded {
+		t.Errorf("Cause() after timeout = %v", Cause(ctx))
+	}
+}
+
+func TestWithoutCancel(t *testing.T) {
+	parent, cancel := WithTimeout(WithValue(Background(), "k", "v"), time.Hour)
+	ctx := WithoutCancel(WithValue(parent, "k2", "v2"))
+	child, childCancel := WithCancel(ctx)
+	defer childCancel()
+	cancel()
+
+	for _, c := range []Context{ctx, child} {
+		if c.Value("k") != "v" || c.Value("k2") != "v2" {
+			t.Errorf("%v: values = %v, %v", c, c.Value("k"), c.Value("k2"))
+		}
+		if _, ok := c.Deadline(); ok {
+			t.Errorf("%v: has a deadline", c)
+		}
+	}
+	if ctx.Done() != nil || ctx.Err() != nil || Cause(ctx) != nil {
+		t.Errorf("WithoutCancel: Done() = %v, Err() = %v, Cause() = %v", ctx.Done(), ctx.Err(), Cause(ctx))
+	}
+	select {
+	case <-child.Done():
+		t.Error("child of WithoutCancel was cancelled with the original parent")
+	default:
+	}
+	if Cause(child) != nil {
+		t.Errorf("Cause(child) = %v", Cause(child))
+	}
+	childCancel()
+	if child.Err() != Canceled {
+		t.Errorf("child.Err() after its own cancel = %v", child.Err())
+	}
+}
+
+func TestAfterFunc(t *testing.T) {
+	ctx, cancel := WithCancel(Background())
+	called := make(chan struct{}, 2)
+	before := atomic.LoadInt32(&goroutines)
+	AfterFunc(ctx, func() { called <- struct{}{} })
+	stop := AfterFunc(ctx, func() { called <- struct{}{} })
+	if n := atomic.LoadInt32(&goroutines) - before; n != 0 {
+		t.Errorf("AfterFunc on a cancelCtx started %d goroutines", n)
+	}
+	if !stop() {
+		t.Error("first stop() = false, want true")
+	}
+	if stop() {
+		t.Error("second stop() = true, want false")
+	}
+	if len(ctx.(*cancelCtx).children) != 1 {
+		t.Errorf("children after stop = %d, want 1", len(ctx.(*cancelCtx).children))
+	}
+
+	cancel()
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("f was not called after cancel")
+	}
+	select {
+	case <-called:
+		t.Error("stopped f was called")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// ctx 已经取消时立即调用，stop 返回 false
+	stop = AfterFunc(ctx, func() { called <- struct{}{} })
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("f was not called for a cancelled ctx")
+	}
+	if stop() {
+		t.Error("stop() after f started = true, want false")
+	}
+}
+
+func TestWithValues(t *testing.T) {
+	type key string
+	parent := WithValue(Background(), key("a"), "parent")
+	ctx := WithValues(parent, key("a"), 1, key("b"), 2, key("a"), 3)
+	if got := ctx.Value(key("a")); got != 3 {
+		t.Errorf("Value(a) = %v, want the last value 3", got)
+	}
+	child, cancel := WithCancel(ctx)
+	defer cancel()
+	if got := child.Value(key("b")); got != 2 {
+		t.Errorf("child.Value(b) = %v", got)
+	}
+	if got := child.Value(key("c")); got != nil {
+		t.Errorf("Value(c) = %v, want nil", got)
+	}
+	if got, want := ctx.(*valuesCtx).String(), ".WithValues(type source.key, val <not Stringer>, type source.key, val <not Stringer>)"; !strings.HasSuffix(got, want) {
+		t.Errorf("String() = %q", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("WithValues with an odd number of arguments did not panic")
+		}
+	}()
+	WithValues(parent, key("a"))
+}
+
+// customCtx 是自定义的可取消 Context，propagateCancel 无法找到 cancelCtx
+type customCtx struct {
+	Context
+}
+
+func (c customCtx) Value(key any) any {
+	if key == &cancelCtxKey {
+		return nil
+	}
+	return c.Context.Value(key)
+}
+
+func TestOnCancel(t *testing.T) {
+	ctx, cancel := WithCancel(Background())
+	child := WithValue(ctx, "k", "v")
+	before := atomic.LoadInt32(&goroutines)
+	var calls []string
+	OnCancel(child, func() { calls = append(calls, "a") })
+	stop := OnCancel(child, func() { calls = append(calls, "b") })
+	if n := atomic.LoadInt32(&goroutines) - before; n != 0 {
+		t.Errorf("OnCancel on a cancelCtx started %d goroutines", n)
+	}
+	if !stop() || stop() {
+		t.Error("stop() should return true only the first time")
+	}
+	if n := childCount(ctx); n != 1 {
+		t.Errorf("children after stop = %d, want 1", n)
+	}
+	cancel()
+	// f 在 cancel 返回之前已经同步调用
+	if len(calls) != 1 || calls[0] != "a" {
+		t.Errorf("calls after cancel = %v, want [a]", calls)
+	}
+	OnCancel(ctx, func() { calls = append(calls, "c") })
+	if len(calls) != 2 {
+		t.Errorf("f was not called immediately for a cancelled ctx, calls = %v", calls)
+	}
+
+	// 自定义的 Context 通过 goroutine 调用
+	parent, parentCancel := WithCancel(Background())
+	called := make(chan struct{})
+	before = atomic.LoadInt32(&goroutines)
+	OnCancel(customCtx{parent}, func() { close(called) })
+	if n := atomic.LoadInt32(&goroutines) - before; n != 1 {
+		t.Errorf("OnCancel on a custom Context started %d goroutines, want 1", n)
+	}
+	parentCancel()
+	select {
+	case <-called:
+	case <-time.After(time.Second):
+		t.Fatal("f was not called for a custom Context")
+	}
+}
+
+func TestDetach(t *testing.T) {
+	parent, cancel := WithTimeout(WithValue(Background(), "k", "v"), time.Millisecond)
+	defer cancel()
+	ctx, detachCancel := Detach(parent, 50*time.Millisecond)
+	defer detachCancel()
+
+	<-parent.Done()
+	if ctx.Err() != nil {
+		t.Fatalf("detached ctx was cancelled with its parent: %v", ctx.Err())
+	}
+	if ctx.Value("k") != "v" {
+		t.Errorf("Value(k) = %v", ctx.Value("k"))
+	}
+	d, ok := ctx.Deadline()
+	if pd, _ := parent.Deadline(); !ok || !d.After(pd) {
+		t.Errorf("Deadline() = %v, %v, want a new deadline after the parent's %v", d, ok, pd)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("detached ctx did not time out")
+	}
+	if ctx.Err() != DeadlineExceeded {
+		t.Errorf("Err() = %v, want DeadlineExceeded", ctx.Err())
+	}
+}
@@ -0,0 +1,53 @@
+package source
+
+// Copy 把 src 中 keys 对应的值复制到 dst 下，返回新的 Context，src 中没有的 key 会被跳过。
+// 复制的值放在同一个 WithValues 节点中，没有可复制的值时原样返回 dst。
+// 与 WithoutCancel 不同，只有列出的值会带到 dst，适合从请求中启动后台任务时只传递 trace id、用户 id 等。
+func Copy(dst, src Context, keys ...any) Context {
+	pairs := make([]any, 0, 2*len(keys))
+	for _, key := range keys {
+		if v := src.Value(key); v != nil {
+			pairs = append(pairs, key, v)
+		}
+	}
+	if len(pairs) == 0 {
+		return dst
+	}
+	return WithValues(dst, pairs...)
+}
+
+// CopyPolicy 启动后台任务时需要从请求带过去的 key 的白名单，
+// 通常在服务中定义一次，替代各处手写的“复制这几个值”的函数：
+//
+//	var handoff = source.NewCopyPolicy(traceIDKey, userIDKey)
+//
+//	go job(handoff.Background(reqCtx))
+type CopyPolicy struct {
+	keys []any
+}
+
+// NewCopyPolicy 返回复制 keys 的 CopyPolicy
+func NewCopyPolicy(keys ...any) *CopyPolicy {
+	return &CopyPolicy{keys: append([]any(nil), keys...)}
+}
+
+// With 返回在 p 的基础上再复制 keys 的新 CopyPolicy
+func (p *CopyPolicy) With(keys ...any) *CopyPolicy {
+	return NewCopyPolicy(append(append([]any(nil), p.keys...), keys...)...)
+}
+
+// Keys 返回 p 复制的 key
+func (p *CopyPolicy) Keys() []any {
+	return append([]any(nil), p.keys...)
+}
+
+// Copy 把 src 中白名单内的值复制到 dst 下
+func (p *CopyPolicy) Copy(dst, src Context) Context {
+	return Copy(dst, src, p.keys...)
+}
+
+// Background 返回以 Background() 为根、只带有 src 中白名单内的值的 Context，
+// 不会随 src 取消，也没有截止时间，需要超时的话再用 WithTimeout 派生。
+func (p *CopyPolicy) Background(src Context) Context {
+	return p.Copy(Background(), src)
+}
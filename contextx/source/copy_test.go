@@ -0,0 +1,30 @@
+package source
+
+import "testing"
+
+func TestCopy(t *testing.T) {
+	userID := NewKey[int]("user-id")
+	req, cancel := WithCancel(userID.WithValue(WithValues(Background(), "trace-id", "abc", "token", "secret"), 42))
+	defer cancel()
+
+	p := NewCopyPolicy("trace-id").With(userID)
+	bg := p.Background(req)
+	cancel()
+	if bg.Err() != nil {
+		t.Errorf("background ctx was cancelled with the request: %v", bg.Err())
+	}
+	if id, _ := userID.From(bg); id != 42 || bg.Value("trace-id") != "abc" {
+		t.Errorf("copied values = %v, %v", id, bg.Value("trace-id"))
+	}
+	if bg.Value("token") != nil {
+		t.Error("a key outside the policy was copied")
+	}
+
+	dst := WithValue(Background(), "k", "v")
+	if got := Copy(dst, req, "missing"); got != dst {
+		t.Error("Copy() without values to copy created a new context")
+	}
+	if got := Copy(dst, req, "token"); got.Value("token") != "secret" || got.Value("k") != "v" {
+		t.Errorf("Copy() values = %v, %v", got.Value("token"), got.Value("k"))
+	}
+}
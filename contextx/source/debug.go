@@ -0,0 +1,252 @@
+package source
+
+import (
+	"gopractice/logx"
+	"gopractice/reflectlite"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// loggerValue contextx 调试信息输出的位置，保存的是 loggerBox，没有设置过时不输出
+// 严格模式的检查会在 finalizer 的 goroutine 中写日志，所以和 observer 一样用 atomic.Value 保存。
+var loggerValue atomic.Value
+
+// loggerBox atomic.Value 要求每次保存的类型相同
+type loggerBox struct {
+	l logx.Logger
+}
+
+// SetLogger 设置 contextx 输出调试信息使用的 Logger，传入 nil 时恢复为不输出
+func SetLogger(l logx.Logger) {
+	if l == nil {
+		l = logx.Nop
+	}
+	loggerValue.Store(loggerBox{l})
+}
+
+// logger 返回当前的 Logger
+func logger() logx.Logger {
+	if b, ok := loggerValue.Load().(loggerBox); ok {
+		return b.l
+	}
+	return logx.Nop
+}
+
+// DebugString 返回 Context 的描述信息，例如 "context.Background.WithCancel"，
+// 描述信息同时会以 Debug 级别写入 logger，方便排查 Context 是怎样一层层派生出来的。
+func DebugString(c Context) string {
+	s := contextName(c)
+	logger().Debugf("context: %s", s)
+	return s
+}
+
+// TreeNode Dump 返回的 Context 树中的一个节点
+type TreeNode struct {
+	// Type 节点的类型，例如 "Background"、"WithCancel"、"WithDeadline"、"WithValue"，
+	// 自定义的 Context 为它的类型名
+	Type string
+	// Deadline 节点自己设置的截止时间，没有时为零值
+	Deadline time.Time
+	// Keys WithValue、WithValues 节点中保存的 key 的类型
+	Keys []string
+	// Err 和 Cause 可取消节点的取消原因，还没有取消或者不可取消时为 nil
+	Err, Cause error
+	// Current 是否为传给 Dump 的 Context
+	Current  bool
+	Children []TreeNode
+}
+
+// Dump 返回 c 所在的 Context 树：从 c 沿父节点向上找到根节点，
+// 再从根节点向下展开，可取消节点下除了通往 c 的那条链，还包括 children 中登记的其他子节点，
+// 用来排查“是谁取消了我的 Context”。
+// 自定义的 Context 无法继续向上查找，会作为根节点。
+func Dump(c Context) TreeNode {
+	var chain []Context
+	for p := c; p != nil; p = parentOf(p) {
+		chain = append(chain, p)
+	}
+	onChain := make(map[Context]bool, len(chain))
+	for _, p := range chain {
+		onChain[p] = true
+	}
+
+	// 从 c 开始向上组装，每一层把下面一层作为第一个子节点
+	var below *TreeNode
+	for _, p := range chain {
+		n := dumpNode(p, onChain)
+		if below != nil {
+			n.Children = append([]TreeNode{*below}, n.Children...)
+		} else {
+			n.Current = true
+		}
+		below = &n
+	}
+	return *below
+}
+
+// dumpNode 返回 c 的节点，子节点只包括 children 中登记的、不在 skip 中的节点
+func dumpNode(c Context, skip map[Context]bool) TreeNode {
+	n := TreeNode{Type: typeName(c)}
+	switch ctx := c.(type) {
+	case *timerCtx:
+		n.Deadline = ctx.deadline
+	case *mergeCtx:
+		n.Deadline = ctx.deadline
+	case *extendCtx:
+		n.Deadline, _ = ctx.Deadline()
+	case *pauseCtx:
+		n.Deadline, _ = ctx.Deadline()
+	case *valueCtx:
+		n.Keys = []string{reflectlite.TypeOf(ctx.key).String()}
+	case *valuesCtx:
+		for _, key := range ctx.keys {
+			n.Keys = append(n.Keys, reflectlite.TypeOf(key).String())
+		}
+	case *indexCtx:
+		for _, key := range ctx.keys {
+			n.Keys = append(n.Keys, reflectlite.TypeOf(key).String())
+		}
+	}
+
+	cc := cancelCtxOf(c)
+	if cc == nil {
+		return n
+	}
+	cc.mu.Lock()
+	n.Err, n.Cause = cc.err, cc.cause
+	children := make([]Context, 0, len(cc.children))
+	for child := range cc.children {
+		ctx, ok := child.(Context)
+		switch {
+		case !ok:
+			// OnCancel 注册的节点只实现了 canceler
+			n.Children = append(n.Children, TreeNode{Type: "OnCancel"})
+		case !skip[ctx]:
+			children = append(children, ctx)
+		}
+	}
+	cc.mu.Unlock()
+	for _, child := range children {
+		n.Children = append(n.Children, dumpNode(child, skip))
+	}
+	sort.SliceStable(n.Children, func(i, j int) bool { return n.Children[i].Type < n.Children[j].Type })
+	return n
+}
+
+// parentOf 返回 c 的父节点，c 是根节点或者自定义的 Context 时返回 nil
+// Merge 有多个父节点，只返回第一个。
+func parentOf(c Context) Context {
+	switch ctx := c.(type) {
+	case *cancelCtx:
+		return ctx.Context
+	case *timerCtx:
+		return ctx.Context
+	case *afterFuncCtx:
+		return ctx.Context
+	case *extendCtx:
+		return ctx.Context
+	case *pauseCtx:
+		return ctx.Context
+	case *mergeCtx:
+		return ctx.parents[0]
+	case *valueCtx:
+		return ctx.Context
+	case *valuesCtx:
+		return ctx.Context
+	case *indexCtx:
+		return ctx.Context
+	case withoutCancelCtx:
+		return ctx.c
+	case *softCtx:
+		return ctx.Context
+	}
+	return nil
+}
+
+// cancelCtxOf 返回 c 自己的 *cancelCtx，c 不可取消时返回 nil
+func cancelCtxOf(c Context) *cancelCtx {
+	switch ctx := c.(type) {
+	case *cancelCtx:
+		return ctx
+	case *timerCtx:
+		return &ctx.cancelCtx
+	case *afterFuncCtx:
+		return &ctx.cancelCtx
+	case *extendCtx:
+		return &ctx.cancelCtx
+	case *pauseCtx:
+		return &ctx.cancelCtx
+	case *mergeCtx:
+		return &ctx.cancelCtx
+	}
+	return nil
+}
+
+func typeName(c Context) string {
+	switch c.(type) {
+	case *emptyCtx:
+		if c == todo {
+			return "TODO"
+		}
+		return "Background"
+	case *cancelCtx:
+		return "WithCancel"
+	case *timerCtx:
+		return "WithDeadline"
+	case *afterFuncCtx:
+		return "AfterFunc"
+	case *extendCtx:
+		if c.(*extendCtx).ttl > 0 {
+			return "WithHeartbeat"
+		}
+		return "WithExtendableDeadline"
+	case *pauseCtx:
+		return "WithPausableTimeout"
+	case *mergeCtx:
+		return "Merge"
+	case *valueCtx:
+		return "WithValue"
+	case *valuesCtx:
+		return "WithValues"
+	case *indexCtx:
+		return "WithIndexedValues"
+	case withoutCancelCtx:
+		return "WithoutCancel"
+	case *softCtx:
+		return "WithSoftDeadline"
+	}
+	return reflectlite.TypeOf(c).String()
+}
+
+// String 以缩进的形式返回整棵树，每个节点一行，传给 Dump 的节点以 "<-" 标出
+func (n TreeNode) String() string {
+	var b strings.Builder
+	n.write(&b, 0)
+	return b.String()
+}
+
+func (n TreeNode) write(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(n.Type)
+	if !n.Deadline.IsZero() {
+		b.WriteString(" deadline=" + n.Deadline.Format(time.RFC3339Nano))
+	}
+	if len(n.Keys) > 0 {
+		b.WriteString(" keys=[" + strings.Join(n.Keys, " ") + "]")
+	}
+	if n.Err != nil {
+		b.WriteString(" err=" + n.Err.Error())
+		if n.Cause != n.Err {
+			b.WriteString(" cause=" + n.Cause.Error())
+		}
+	}
+	if n.Current {
+		b.WriteString(" <-")
+	}
+	b.WriteString("\n")
+	for _, child := range n.Children {
+		child.write(b, depth+1)
+	}
+}
@@ -0,0 +1,65 @@
+package source
+
+import (
+	"errors"
+	"gopractice/logx"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugStringLogger(t *testing.T) {
+	rec := new(logx.Recorder)
+	SetLogger(rec)
+	defer SetLogger(nil)
+
+	ctx, cancel := WithCancel(Background())
+	defer cancel()
+	ctx = WithValue(ctx, "k", "v")
+
+	s := DebugString(ctx)
+	if !strings.HasSuffix(s, ".WithCancel.WithValue(type string, val v)") {
+		t.Errorf("DebugString() = %q", s)
+	}
+
+	entries := rec.Entries()
+	if len(entries) != 1 || entries[0].Level != logx.LevelDebug || !strings.Contains(entries[0].Msg, s) {
+		t.Errorf("logged entries = %v, want one debug entry containing %q", entries, s)
+	}
+}
+
+func TestDump(t *testing.T) {
+	type key string
+	root, cancel := WithCancel(Background())
+	defer cancel()
+	errCause := errors.New("shutdown")
+	mid, midCancel := WithCancelCause(WithValue(root, key("k"), "v"))
+	leaf, leafCancel := WithTimeout(mid, time.Hour)
+	defer leafCancel()
+	sibling, siblingCancel := WithCancel(root)
+	defer siblingCancel()
+	AfterFunc(sibling, func() {})
+	OnCancel(sibling, func() {})
+	midCancel(errCause)
+
+	tree := Dump(leaf)
+	want := `Background
+  WithCancel
+    WithValue keys=[source.key]
+      WithCancel err=context canceled cause=shutdown
+        WithDeadline err=context canceled cause=shutdown <-
+    WithCancel
+      AfterFunc
+      OnCancel
+`
+	// deadline 每次都不同，比较之前去掉
+	got := regexp.MustCompile(` deadline=\S+`).ReplaceAllString(tree.String(), "")
+	if got != want {
+		t.Errorf("Dump().String() =\n%s\nwant\n%s", got, want)
+	}
+	n := tree.Children[0].Children[0].Children[0].Children[0]
+	if !n.Current || n.Deadline.IsZero() || n.Cause != errCause {
+		t.Errorf("leaf node = %+v", n)
+	}
+}
@@ -0,0 +1,134 @@
+package source
+
+import (
+	"errors"
+	"time"
+)
+
+// ExtendFunc 把截止时间推迟到 d，返回是否生效：
+// d 不晚于当前的截止时间，或者 Context 已经取消、已经到期时不做任何修改，返回 false。
+type ExtendFunc func(d time.Time) bool
+
+// WithExtendableDeadline 与 WithDeadline 相同，但截止时间可以通过返回的 ExtendFunc 推迟，
+// 适用于长轮询、租约续期这类需要不断延长超时的场景。
+// 截止时间不能超过 parent 的截止时间，推迟到 parent 之后只会随 parent 取消。
+func WithExtendableDeadline(parent Context, d time.Time) (Context, ExtendFunc, CancelFunc) {
+	c := new(extendCtx)
+	cancel := c.init(parent, d)
+	return c, c.extend, cancel
+}
+
+func (c *extendCtx) init(parent Context, d time.Time) CancelFunc {
+	if parent == nil {
+		panic("cannot create context from nil parent")
+	}
+
+	c.cancelCtx = newCancelCtx(parent)
+	c.deadline = d
+	propagateCancel(parent, c)
+	c.mu.Lock()
+	if c.err == nil {
+		c.timer = clock().AfterFunc(until(d), c.expire)
+	}
+	c.mu.Unlock()
+	return func() {
+		c.cancel(true, Canceled, nil)
+	}
+}
+
+// HeartbeatMissed WithHeartbeat 返回的 Context 在 ttl 内没有收到心跳时的取消原因，通过 Cause 获取，
+// 此时 Err 返回 DeadlineExceeded。
+var HeartbeatMissed = errors.New("context heartbeat missed")
+
+// BeatFunc 表示收到一次心跳，把截止时间重置为 ttl 之后，Context 已经取消或者已经到期时返回 false
+type BeatFunc func() bool
+
+// WithHeartbeat 返回需要不断心跳才能存活的 Context：每次调用 BeatFunc 把截止时间重置为 ttl 之后，
+// ttl 内没有心跳时以 HeartbeatMissed 为原因取消。
+// 例如每个连接收到数据或者 PONG 时心跳一次，用它控制这个连接上的所有任务。
+func WithHeartbeat(parent Context, ttl time.Duration) (Context, BeatFunc, CancelFunc) {
+	c := &extendCtx{ttl: ttl, expireCause: HeartbeatMissed}
+	cancel := c.init(parent, clock().Now().Add(ttl))
+	return c, c.beat, cancel
+}
+
+// extendCtx 截止时间可以修改的 timerCtx，deadline 和 timer 由 cancelCtx.mu 保护
+// WithHeartbeat 也使用它，ttl 不为 0。
+type extendCtx struct {
+	cancelCtx
+	timer    Timer
+	deadline time.Time
+
+	ttl         time.Duration
+	expireCause error // 到期时的取消原因
+}
+
+// Deadline 返回自己和 parent 的截止时间中较早的一个
+func (c *extendCtx) Deadline() (deadline time.Time, ok bool) {
+	c.mu.Lock()
+	deadline = c.deadline
+	c.mu.Unlock()
+	if cur, ok := c.cancelCtx.Context.Deadline(); ok && cur.Before(deadline) {
+		return cur, true
+	}
+	return deadline, true
+}
+
+func (c *extendCtx) String() string {
+	if c.ttl > 0 {
+		return contextName(c.cancelCtx.Context) + ".WithHeartbeat(" + c.ttl.String() + ")"
+	}
+	d, _ := c.Deadline()
+	return contextName(c.cancelCtx.Context) + ".WithExtendableDeadline(" +
+		d.String() + " [" +
+		until(d).String() + "])"
+}
+
+func (c *extendCtx) extend(d time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !d.After(c.deadline) {
+		return false
+	}
+	return c.resetLocked(d)
+}
+
+func (c *extendCtx) beat() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resetLocked(clock().Now().Add(c.ttl))
+}
+
+// resetLocked 把截止时间改为 d，调用时必须持有 c.mu
+// 已经到期的不能再修改，否则可能和正在执行的 expire 冲突。
+func (c *extendCtx) resetLocked(d time.Time) bool {
+	if c.err != nil || !clock().Now().Before(c.deadline) {
+		return false
+	}
+	c.deadline = d
+	c.timer.Reset(until(d))
+	return true
+}
+
+// expire 定时器到期时调用，截止时间已经被推迟时什么都不做，等待重新设置的定时器
+func (c *extendCtx) expire() {
+	c.mu.Lock()
+	extended := clock().Now().Before(c.deadline)
+	c.mu.Unlock()
+	if !extended {
+		c.cancel(true, DeadlineExceeded, c.expireCause)
+	}
+}
+
+func (c *extendCtx) cancel(removeFromParent bool, err, cause error) {
+	c.cancelCtx.cancel(false, err, cause)
+	if removeFromParent {
+		removeChild(c.cancelCtx.Context, c)
+	}
+	c.mu.Lock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.mu.Unlock()
+}
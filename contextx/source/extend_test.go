@@ -0,0 +1,76 @@
+package source
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithExtendableDeadline(t *testing.T) {
+	start := time.Now()
+	ctx, extend, cancel := WithExtendableDeadline(Background(), start.Add(30*time.Millisecond))
+	defer cancel()
+
+	// 到期之前不断续期
+	for i := 1; i <= 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if !extend(time.Now().Add(30 * time.Millisecond)) {
+			t.Fatalf("extend #%d failed, Err() = %v", i, ctx.Err())
+		}
+	}
+	d, _ := ctx.Deadline()
+	if extend(d.Add(-time.Millisecond)) {
+		t.Error("extend to an earlier deadline = true")
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("ctx expired despite extensions: %v", ctx.Err())
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("ctx did not expire after the last extension")
+	}
+	if ctx.Err() != DeadlineExceeded || time.Since(start) < 60*time.Millisecond {
+		t.Errorf("Err() = %v after %v", ctx.Err(), time.Since(start))
+	}
+	if extend(time.Now().Add(time.Hour)) {
+		t.Error("extend after expiry = true")
+	}
+}
+
+func TestWithExtendableDeadlineParent(t *testing.T) {
+	parent, parentCancel := WithTimeout(Background(), time.Hour)
+	defer parentCancel()
+	ctx, extend, cancel := WithExtendableDeadline(parent, time.Now().Add(time.Minute))
+	// 截止时间不会超过 parent
+	extend(time.Now().Add(2 * time.Hour))
+	if d, _ := ctx.Deadline(); d.After(time.Now().Add(time.Hour)) {
+		t.Errorf("Deadline() = %v, later than the parent's", d)
+	}
+	cancel()
+	if ctx.Err() != Canceled || childCount(parent) != 0 {
+		t.Errorf("Err() = %v, parent children = %d", ctx.Err(), childCount(parent))
+	}
+}
+
+func TestWithHeartbeat(t *testing.T) {
+	ctx, beat, cancel := WithHeartbeat(Background(), 30*time.Millisecond)
+	defer cancel()
+	for i := 0; i < 4; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if !beat() {
+			t.Fatalf("beat #%d failed, Err() = %v", i, ctx.Err())
+		}
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("ctx was not cancelled after heartbeats stopped")
+	}
+	if ctx.Err() != DeadlineExceeded || Cause(ctx) != HeartbeatMissed {
+		t.Errorf("Err() = %v, Cause() = %v", ctx.Err(), Cause(ctx))
+	}
+	if beat() {
+		t.Error("beat after expiry = true")
+	}
+}
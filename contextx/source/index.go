@@ -0,0 +1,89 @@
+package source
+
+import (
+	"gopractice/reflectlite"
+	"strings"
+)
+
+// WithIndexedValues 与 WithValues 相同，但节点中还保存一份索引：
+// 创建时沿父节点向上收集 WithValue、WithValues 保存的值，直到遇到上一个 WithIndexedValues 节点，合并它的索引。
+// 之后的查找只需要一次 map 访问，不再随 Context 链的深度变慢。
+// 索引在创建时复制，不会修改，代价是创建的开销与上一个索引节点之间的距离和索引的大小成正比，
+// 适合在很深的链上频繁查找少量 key 的场景。
+// 向上收集时遇到 Merge、自定义的 Context 等无法展开的节点会停下来，索引中找不到的 key 再到这个节点中查找。
+func WithIndexedValues(parent Context, pairs ...any) Context {
+	vc := WithValues(parent, pairs...).(*valuesCtx)
+	c := &indexCtx{Context: parent, keys: vc.keys, index: make(map[any]any, len(vc.vals))}
+	for k, v := range vc.vals {
+		c.index[k] = v
+	}
+
+	// 子节点的值优先，已经在索引中的 key 不再覆盖
+	add := func(k, v any) {
+		if _, ok := c.index[k]; !ok {
+			c.index[k] = v
+		}
+	}
+	for p := parent; p != nil; {
+		switch ctx := p.(type) {
+		case *indexCtx:
+			for k, v := range ctx.index {
+				add(k, v)
+			}
+			c.rest = ctx.rest
+			return c
+		case *valueCtx:
+			add(ctx.key, ctx.val)
+			p = ctx.Context
+		case *valuesCtx:
+			for k, v := range ctx.vals {
+				add(k, v)
+			}
+			p = ctx.Context
+		case *emptyCtx:
+			return c
+		case *cancelCtx, *timerCtx, *extendCtx, *pauseCtx, *softCtx, withoutCancelCtx:
+			// 这些节点自己不保存值
+			p = parentOf(p)
+		default:
+			c.rest = p
+			return c
+		}
+	}
+	return c
+}
+
+// indexCtx 带有索引的值节点，index 包含自己和上面能够展开的所有值，
+// rest 是向上收集时停下来的节点，为 nil 时表示索引已经完整
+type indexCtx struct {
+	Context
+	keys  []any
+	index map[any]any
+	rest  Context
+}
+
+func (c *indexCtx) lookup(key any) any {
+	if v, ok := c.index[key]; ok {
+		return v
+	}
+	if c.rest == nil {
+		return nil
+	}
+	return c.rest.Value(key)
+}
+
+func (c *indexCtx) Value(key any) any {
+	if key == &cancelCtxKey {
+		// 索引中只有用户的值，cancelCtx 仍然沿着链查找
+		return value(c.Context, key)
+	}
+	return c.lookup(key)
+}
+
+func (c *indexCtx) String() string {
+	names := make([]string, len(c.keys))
+	for i, key := range c.keys {
+		names[i] = "type " + reflectlite.TypeOf(key).String() + ", val " + stringify(c.index[key])
+	}
+	return contextName(c.Context) + ".WithIndexedValues(" + strings.Join(names, ", ") + ")"
+}
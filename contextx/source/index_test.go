@@ -0,0 +1,37 @@
+package source
+
+import "testing"
+
+func TestWithIndexedValues(t *testing.T) {
+	type key int
+	parent, cancel := WithCancel(WithValue(Background(), key(0), "root"))
+	defer cancel()
+	ctx := WithIndexedValues(WithValues(parent, key(1), "a", key(0), "shadow"), key(2), "b")
+	// 普通的 WithValue 在索引节点之间，下一个索引节点要把它收进来
+	ctx = WithIndexedValues(WithValue(ctx, key(2), "c"), key(3), "d")
+
+	for k, want := range map[key]any{0: "shadow", 1: "a", 2: "c", 3: "d", 4: nil} {
+		if got := ctx.Value(k); got != want {
+			t.Errorf("Value(%d) = %v, want %v", k, got, want)
+		}
+	}
+	if ic := ctx.(*indexCtx); ic.rest != nil || len(ic.index) != 4 {
+		t.Errorf("index = %v, rest = %v, want a complete index of 4 keys", ic.index, ic.rest)
+	}
+
+	// 子节点仍然能找到 cancelCtx，一起取消
+	child, childCancel := WithCancel(ctx)
+	defer childCancel()
+	cancel()
+	if child.Err() != Canceled || childCount(parent) != 0 {
+		t.Errorf("child Err() = %v after the parent was cancelled", child.Err())
+	}
+
+	// 无法展开的节点之后的值在查找时再到这个节点中找
+	merged, mergeCancel := Merge(Background(), WithValue(Background(), key(5), "merged"))
+	defer mergeCancel()
+	ctx = WithIndexedValues(merged, key(6), "e")
+	if ctx.Value(key(5)) != "merged" || ctx.Value(key(6)) != "e" {
+		t.Errorf("values through Merge = %v, %v", ctx.Value(key(5)), ctx.Value(key(6)))
+	}
+}
@@ -0,0 +1,44 @@
+package source
+
+// Key 是类型安全的 Context key，值的类型为 T，
+// 使用方不需要再定义未导出的 key 类型，也不需要自己做类型断言。
+// 每个 *Key 都是不同的 key，即使 name 相同，通常定义为包级变量：
+//
+//	var requestID = NewKey[string]("request-id")
+//
+//	ctx = requestID.WithValue(ctx, "abc")
+//	id, ok := requestID.From(ctx)
+type Key[T any] struct {
+	// name 只用于 String，同时保证 Key 不是零大小的类型，不同的 *Key 不会相等
+	name string
+}
+
+// NewKey 创建一个 Key，name 用于调试输出
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+// WithValue 返回保存了 v 的子 Context，与 WithValue(ctx, k, v) 相同
+func (k *Key[T]) WithValue(ctx Context, v T) Context {
+	return WithValue(ctx, k, v)
+}
+
+// From 返回 ctx 中保存的值，没有保存时返回 T 的零值和 false
+func (k *Key[T]) From(ctx Context) (T, bool) {
+	v, ok := ctx.Value(k).(T)
+	return v, ok
+}
+
+// MustFrom 与 From 相同，没有保存时 panic
+func (k *Key[T]) MustFrom(ctx Context) T {
+	v, ok := k.From(ctx)
+	if !ok {
+		panic("context: no value for key " + k.name)
+	}
+	return v
+}
+
+// String 返回创建时的 name
+func (k *Key[T]) String() string {
+	return k.name
+}
@@ -0,0 +1,34 @@
+package source
+
+import "testing"
+
+func TestKey(t *testing.T) {
+	id := NewKey[int]("id")
+	other := NewKey[int]("id")
+	name := NewKey[string]("name")
+
+	ctx := name.WithValue(id.WithValue(Background(), 42), "gopher")
+	if v, ok := id.From(ctx); !ok || v != 42 {
+		t.Errorf("id.From() = %v, %v", v, ok)
+	}
+	if v := name.MustFrom(ctx); v != "gopher" {
+		t.Errorf("name.MustFrom() = %q", v)
+	}
+	// 同名的 key 是不同的 key
+	if v, ok := other.From(ctx); ok || v != 0 {
+		t.Errorf("other.From() = %v, %v, want 0, false", v, ok)
+	}
+	// 与普通的 Value 查找一样能穿过其他节点
+	child, cancel := WithCancel(WithValues(ctx, "k", "v"))
+	defer cancel()
+	if v, ok := id.From(child); !ok || v != 42 {
+		t.Errorf("id.From(child) = %v, %v", v, ok)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustFrom without a value did not panic")
+		}
+	}()
+	other.MustFrom(ctx)
+}
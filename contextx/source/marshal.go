@@ -0,0 +1,123 @@
+package source
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// marshalVersion Marshal 输出的格式版本，格式改变时加一
+const marshalVersion = 1
+
+var (
+	// ErrUnsupportedValue Marshal 的 key 或者对应的值不是 string
+	ErrUnsupportedValue = errors.New("context: only string keys and values can be marshaled")
+	// ErrInvalidData Unmarshal 的数据不是 Marshal 输出的格式
+	ErrInvalidData = errors.New("context: invalid marshaled data")
+)
+
+// Marshal 把 ctx 剩余的超时时间和 keys 对应的值编码，用于通过 netx 等跨进程传递，
+// 对方用 Unmarshal 还原之后，服务端的处理就会继承调用方剩下的时间预算。
+// 只编码剩余的时间而不是截止时间本身，两端的时钟不需要同步。
+// keys 是允许传递的值的白名单，key 和值都必须是 string，ctx 中没有的 key 会被跳过。
+//
+// 格式：版本(1 字节) | 是否有截止时间(1 字节) | 剩余纳秒(8 字节小端，有截止时间时) |
+// 值的个数(uvarint) | 每个值的 key 和 value，都是 uvarint 长度加内容。
+func Marshal(ctx Context, keys ...any) ([]byte, error) {
+	b := []byte{marshalVersion, 0}
+	if d, ok := ctx.Deadline(); ok {
+		b[1] = 1
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], uint64(until(d)))
+		b = append(b, buf[:]...)
+	}
+
+	var pairs []string
+	for _, key := range keys {
+		k, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: key %v", ErrUnsupportedValue, key)
+		}
+		v := ctx.Value(key)
+		if v == nil {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: value of key %q", ErrUnsupportedValue, k)
+		}
+		pairs = append(pairs, k, s)
+	}
+	b = appendUvarint(b, uint64(len(pairs)/2))
+	for _, s := range pairs {
+		b = appendUvarint(b, uint64(len(s)))
+		b = append(b, s...)
+	}
+	return b, nil
+}
+
+// Unmarshal 在 parent 下还原 Marshal 编码的 Context：
+// 值通过一个 WithValues 节点保存，有截止时间时以剩余的时间调用 WithTimeout，否则调用 WithCancel，
+// 调用方已经超时的话返回的 Context 已经是 DeadlineExceeded。
+// data 的格式不正确时返回 ErrInvalidData。
+func Unmarshal(parent Context, data []byte) (Context, CancelFunc, error) {
+	if len(data) < 2 || data[0] != marshalVersion || data[1] > 1 {
+		return nil, nil, ErrInvalidData
+	}
+	hasDeadline := data[1] == 1
+	data = data[2:]
+	var remaining time.Duration
+	if hasDeadline {
+		if len(data) < 8 {
+			return nil, nil, ErrInvalidData
+		}
+		remaining = time.Duration(binary.LittleEndian.Uint64(data))
+		data = data[8:]
+	}
+
+	n, data, ok := readUvarint(data)
+	// 每个值至少占 2 字节，防止伪造的个数导致分配过大的内存
+	if !ok || n > uint64(len(data))/2 {
+		return nil, nil, ErrInvalidData
+	}
+	pairs := make([]any, 0, 2*n)
+	for i := uint64(0); i < 2*n; i++ {
+		var l uint64
+		l, data, ok = readUvarint(data)
+		if !ok || l > uint64(len(data)) {
+			return nil, nil, ErrInvalidData
+		}
+		pairs = append(pairs, string(data[:l]))
+		data = data[l:]
+	}
+	if len(data) != 0 {
+		return nil, nil, ErrInvalidData
+	}
+
+	ctx := parent
+	if len(pairs) > 0 {
+		// Marshal 的 key 都是 string，这是格式的约定，不是误用，不需要严格模式的检查
+		ctx = withValues(parent, false, pairs)
+	}
+	if hasDeadline {
+		c, cancel := WithTimeout(ctx, remaining)
+		return c, cancel, nil
+	}
+	c, cancel := WithCancel(ctx)
+	return c, cancel, nil
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func readUvarint(b []byte) (uint64, []byte, bool) {
+	v, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, b, false
+	}
+	return v, b[n:], true
+}
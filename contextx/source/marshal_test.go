@@ -0,0 +1,81 @@
+package source
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMarshal(t *testing.T) {
+	ctx, cancel := WithTimeout(WithValues(Background(), "trace-id", "abc", "user", "gopher", "secret", "x"), time.Minute)
+	defer cancel()
+	data, err := Marshal(ctx, "trace-id", "user", "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, gotCancel, err := Unmarshal(Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gotCancel()
+	if got.Value("trace-id") != "abc" || got.Value("user") != "gopher" {
+		t.Errorf("values = %v, %v", got.Value("trace-id"), got.Value("user"))
+	}
+	if got.Value("secret") != nil || got.Value("missing") != nil {
+		t.Error("values outside the allowlist were carried")
+	}
+	d, ok := got.Deadline()
+	// 剩余时间是在 Marshal 时计算的，还原出来的截止时间会稍晚一点
+	if want, _ := ctx.Deadline(); !ok || d.Before(want) || d.Sub(want) > time.Second {
+		t.Errorf("Deadline() = %v, %v, want about %v", d, ok, want)
+	}
+
+	// 没有截止时间
+	data, _ = Marshal(Background())
+	got, gotCancel, err = Unmarshal(Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got.Deadline(); ok {
+		t.Error("Unmarshal created a deadline")
+	}
+	gotCancel()
+	if got.Err() != Canceled {
+		t.Errorf("Err() after cancel = %v", got.Err())
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	type key struct{}
+	if _, err := Marshal(Background(), key{}); !errors.Is(err, ErrUnsupportedValue) {
+		t.Errorf("Marshal(non-string key) = %v", err)
+	}
+	if _, err := Marshal(WithValue(Background(), "n", 1), "n"); !errors.Is(err, ErrUnsupportedValue) {
+		t.Errorf("Marshal(non-string value) = %v", err)
+	}
+
+	data, _ := Marshal(WithValue(Background(), "k", "v"), "k")
+	for _, bad := range [][]byte{nil, {2, 0, 0}, data[:len(data)-1], append(data, 0), {1, 0, 0xff}} {
+		if _, _, err := Unmarshal(Background(), bad); err != ErrInvalidData {
+			t.Errorf("Unmarshal(%v) = %v, want ErrInvalidData", bad, err)
+		}
+	}
+}
+
+// TestUnmarshalStrict Marshal 的 key 按格式都是 string，严格模式下 Unmarshal 不应该被当作误用
+func TestUnmarshalStrict(t *testing.T) {
+	data, err := Marshal(WithValues(Background(), "trace-id", "abc"), "trace-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useStrict(t, StrictPanic)
+	ctx, cancel, err := Unmarshal(Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cancel()
+	if ctx.Value("trace-id") != "abc" || len(StrictViolations()) != 0 {
+		t.Errorf("Value(trace-id) = %v, violations %q", ctx.Value("trace-id"), StrictViolations())
+	}
+}
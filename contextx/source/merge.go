@@ -0,0 +1,91 @@
+package source
+
+import (
+	"strings"
+	"time"
+)
+
+// Merge 返回同时受 ctxs 控制的 Context：
+// 任意一个父节点取消时它也被取消，Err 和 Cause 与最先取消的父节点相同；
+// Deadline 是所有父节点中最早的截止时间；Value 按顺序在父节点中查找，返回第一个不为 nil 的值。
+// 常见的用法是让一个任务同时受请求的 ctx 和服务生命周期的 ctx 控制。
+// 和 WithCancel 一样，不再使用时要调用返回的 CancelFunc，释放父节点上的关联。
+func Merge(ctxs ...Context) (Context, CancelFunc) {
+	if len(ctxs) == 0 {
+		panic("cannot merge zero contexts")
+	}
+	for _, parent := range ctxs {
+		if parent == nil {
+			panic("cannot create context from nil parent")
+		}
+	}
+
+	c := &mergeCtx{parents: append([]Context(nil), ctxs...)}
+	c.cancelCtx.Context = ctxs[0]
+	for _, parent := range ctxs {
+		if d, ok := parent.Deadline(); ok && (!c.hasDeadline || d.Before(c.deadline)) {
+			c.deadline, c.hasDeadline = d, true
+		}
+	}
+
+	// 一个 canceler 不能同时挂到多个父节点的 children 上：父节点取消时不会把它从其他父节点中删除，
+	// 所以每个父节点通过 AfterFunc 关联，取消时 stop 掉其他的。
+	// 注册期间持有 c.mu，已经取消的父节点的回调会等到注册完成后才执行。
+	c.mu.Lock()
+	for _, parent := range ctxs {
+		parent := parent
+		c.stops = append(c.stops, AfterFunc(parent, func() {
+			c.cancel(false, parent.Err(), Cause(parent))
+		}))
+	}
+	c.mu.Unlock()
+	return c, func() {
+		c.cancel(false, Canceled, nil)
+	}
+}
+
+type mergeCtx struct {
+	cancelCtx
+
+	parents     []Context
+	deadline    time.Time
+	hasDeadline bool
+	stops       []func() bool // 各个父节点上的 AfterFunc，由 mu 保护，取消后为 nil
+}
+
+func (c *mergeCtx) Deadline() (deadline time.Time, ok bool) {
+	return c.deadline, c.hasDeadline
+}
+
+func (c *mergeCtx) Value(key any) any {
+	if key == &cancelCtxKey {
+		return &c.cancelCtx
+	}
+	for _, parent := range c.parents {
+		if v := parent.Value(key); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func (c *mergeCtx) cancel(removeFromParent bool, err, cause error) {
+	// 先和父节点断开再关闭 done，Done 返回之后父节点上不再有关联
+	c.mu.Lock()
+	stops := c.stops
+	c.stops = nil
+	c.mu.Unlock()
+	for _, stop := range stops {
+		stop()
+	}
+
+	c.cancelCtx.cancel(false, err, cause)
+}
+
+func (c *mergeCtx) String() string {
+	names := make([]string, len(c.parents))
+	for i, parent := range c.parents {
+		names[i] = contextName(parent)
+	}
+	return "Merge(" + strings.Join(names, ", ") + ")"
+}
@@ -0,0 +1,71 @@
+package source
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMerge(t *testing.T) {
+	req, reqCancel := WithCancelCause(WithValue(Background(), "k", "req"))
+	srv, srvCancel := WithTimeout(WithValue(WithValue(Background(), "k", "srv"), "k2", "srv"), time.Hour)
+	defer srvCancel()
+	ctx, cancel := Merge(req, srv)
+	defer cancel()
+	child, childCancel := WithCancel(ctx)
+	defer childCancel()
+
+	if v, v2 := child.Value("k"), child.Value("k2"); v != "req" || v2 != "srv" {
+		t.Errorf("Value() = %v, %v, want req, srv", v, v2)
+	}
+	want, _ := srv.Deadline()
+	if d, ok := ctx.Deadline(); !ok || !d.Equal(want) {
+		t.Errorf("Deadline() = %v, %v, want %v", d, ok, want)
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("Err() before cancel = %v", ctx.Err())
+	}
+
+	errCause := errors.New("request done")
+	reqCancel(errCause)
+	for _, c := range []Context{ctx, child} {
+		select {
+		case <-c.Done():
+		case <-time.After(time.Second):
+			t.Fatalf("%v was not cancelled with a parent", c)
+		}
+		if c.Err() != Canceled || Cause(c) != errCause {
+			t.Errorf("%v: Err() = %v, Cause() = %v", c, c.Err(), Cause(c))
+		}
+	}
+	// 取消之后不再挂在其他父节点上
+	if n := childCount(srv); n != 0 {
+		t.Errorf("srv still has %d children", n)
+	}
+}
+
+func TestMergeCancel(t *testing.T) {
+	a, aCancel := WithCancel(Background())
+	defer aCancel()
+	b, bCancel := WithTimeout(Background(), time.Millisecond)
+	defer bCancel()
+	ctx, cancel := Merge(a, b)
+	defer cancel()
+	<-ctx.Done()
+	if ctx.Err() != DeadlineExceeded {
+		t.Errorf("Err() after a parent timed out = %v", ctx.Err())
+	}
+
+	ctx, cancel = Merge(a, Background())
+	cancel()
+	if ctx.Err() != Canceled || childCount(a) != 0 {
+		t.Errorf("Err() = %v, parent children = %d", ctx.Err(), childCount(a))
+	}
+}
+
+func childCount(c Context) int {
+	p := c.Value(&cancelCtxKey).(*cancelCtx)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.children)
+}
@@ -0,0 +1,49 @@
+package source
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Observer 观察 Context 的创建和取消，用于追踪系统记录 Context 的生命周期，不需要包装每个构造函数。
+// 目前 WithCancel、WithCancelCause、WithDeadline、WithTimeout 和 ReleasableWithCancel 创建的 Context 会通知 Observer。
+// 子节点随父节点一起取消时，OnCancel 在父节点的锁内调用，所以方法要尽快返回，也不能调用祖先节点的方法。
+type Observer interface {
+	// OnNew 创建了 ctx，parent 是它的父节点
+	OnNew(ctx, parent Context)
+	// OnCancel ctx 被取消，每个 Context 只通知一次
+	OnCancel(ctx Context, err, cause error)
+	// OnDeadlineSet ctx 设置了截止时间，在 OnNew 之后调用
+	OnDeadlineSet(ctx Context, deadline time.Time)
+}
+
+// observer 当前的 Observer，保存的是 observerBox，没有设置过或者为 nil 时不通知
+// 创建 Context 和 SetObserver 可能发生在不同的 goroutine，所以用 atomic.Value 保存。
+var observer atomic.Value
+
+// observerBox atomic.Value 不能保存 nil，也要求每次保存的类型相同
+type observerBox struct {
+	o Observer
+}
+
+// SetObserver 设置 Observer，传入 nil 时不再通知。
+// Context 在创建时记下当时的 Observer，之后的取消也通知同一个，所以通常在程序启动时设置一次。
+func SetObserver(o Observer) {
+	observer.Store(observerBox{o})
+}
+
+// observeNew 记下当前的 Observer 并通知 OnNew，self 是对外的 Context，为 nil 时是 c 自己
+// 必须在 propagateCancel 之前调用，此时 c 还没有被其他 goroutine 看到，不需要加锁，
+// 也保证 OnNew 在 OnCancel 之前。
+func (c *cancelCtx) observeNew(self Context) {
+	b, _ := observer.Load().(observerBox)
+	o := b.o
+	if o == nil {
+		return
+	}
+	c.observer, c.self = o, self
+	if self == nil {
+		self = c
+	}
+	o.OnNew(self, c.Context)
+}
@@ -0,0 +1,72 @@
+package source
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordObserver) add(format string, args ...any) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, fmt.Sprintf(format, args...))
+}
+
+func (o *recordObserver) OnNew(ctx, parent Context) {
+	o.add("new %s", typeName(ctx))
+}
+
+func (o *recordObserver) OnCancel(ctx Context, err, cause error) {
+	o.add("cancel %s %v", typeName(ctx), err)
+}
+
+func (o *recordObserver) OnDeadlineSet(ctx Context, deadline time.Time) {
+	o.add("deadline %s", typeName(ctx))
+}
+
+func TestObserver(t *testing.T) {
+	o := new(recordObserver)
+	SetObserver(o)
+	ctx, cancel := WithCancel(Background())
+	_, childCancel := WithTimeout(ctx, time.Hour)
+	defer childCancel()
+	SetObserver(nil)
+	WithValue(ctx, "k", "v")
+	cancel()
+
+	want := []string{
+		"new WithCancel",
+		"new WithDeadline",
+		"deadline WithDeadline",
+		"cancel WithDeadline context canceled",
+		"cancel WithCancel context canceled",
+	}
+	if !reflect.DeepEqual(o.events, want) {
+		t.Errorf("events = %q, want %q", o.events, want)
+	}
+}
+
+// TestSetObserverConcurrent 创建 Context 的同时设置 Observer，go test -race 下不能有数据竞争
+func TestSetObserverConcurrent(t *testing.T) {
+	defer SetObserver(nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_, cancel := WithCancel(Background())
+			cancel()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		SetObserver(new(recordObserver))
+		SetObserver(nil)
+	}
+	<-done
+}
@@ -0,0 +1,122 @@
+package source
+
+import "time"
+
+// Pausable 可以暂停倒计时的 Context，由 WithPausableTimeout 创建
+type Pausable interface {
+	Context
+	// Pause 暂停倒计时并记录剩余的时间，已经暂停、已经取消或者已经到期时返回 false
+	Pause() bool
+	// Resume 以暂停时剩余的时间继续倒计时，没有暂停或者已经取消时返回 false
+	Resume() bool
+}
+
+// WithPausableTimeout 与 WithTimeout 相同，但倒计时可以暂停和继续，
+// 用于任务调度中会被挂起的任务：挂起的时间不计入超时。
+// 暂停时 Deadline 只返回 parent 的截止时间，parent 取消时仍然会随之取消。
+func WithPausableTimeout(parent Context, timeout time.Duration) (Pausable, CancelFunc) {
+	if parent == nil {
+		panic("cannot create context from nil parent")
+	}
+
+	c := &pauseCtx{
+		cancelCtx: newCancelCtx(parent),
+		deadline:  clock().Now().Add(timeout),
+	}
+	propagateCancel(parent, c)
+	c.mu.Lock()
+	if c.err == nil {
+		c.timer = clock().AfterFunc(timeout, c.expire)
+	}
+	c.mu.Unlock()
+	return c, func() {
+		c.cancel(true, Canceled, nil)
+	}
+}
+
+// pauseCtx 可以暂停的 timerCtx，timer、deadline、remaining 和 paused 由 cancelCtx.mu 保护
+type pauseCtx struct {
+	cancelCtx
+	timer     Timer
+	deadline  time.Time     // 没有暂停时的截止时间
+	remaining time.Duration // 暂停时剩余的时间
+	paused    bool
+}
+
+func (c *pauseCtx) Deadline() (deadline time.Time, ok bool) {
+	c.mu.Lock()
+	if !c.paused {
+		deadline, ok = c.deadline, true
+	}
+	c.mu.Unlock()
+	if cur, pok := c.cancelCtx.Context.Deadline(); pok && (!ok || cur.Before(deadline)) {
+		return cur, true
+	}
+	return deadline, ok
+}
+
+func (c *pauseCtx) String() string {
+	c.mu.Lock()
+	paused, remaining := c.paused, c.remaining
+	if !paused {
+		remaining = until(c.deadline)
+	}
+	c.mu.Unlock()
+	s := contextName(c.cancelCtx.Context) + ".WithPausableTimeout(" + remaining.String()
+	if paused {
+		s += " paused"
+	}
+	return s + ")"
+}
+
+func (c *pauseCtx) Pause() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil || c.paused {
+		return false
+	}
+	remaining := until(c.deadline)
+	if remaining <= 0 {
+		// 定时器已经触发，expire 正在等待锁
+		return false
+	}
+	c.timer.Stop()
+	c.remaining = remaining
+	c.paused = true
+	return true
+}
+
+func (c *pauseCtx) Resume() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil || !c.paused {
+		return false
+	}
+	c.deadline = clock().Now().Add(c.remaining)
+	c.paused = false
+	c.timer.Reset(c.remaining)
+	return true
+}
+
+// expire 定时器到期时调用，Pause 时 Stop 失败的定时器仍然可能触发，暂停中或者还没有到期时忽略
+func (c *pauseCtx) expire() {
+	c.mu.Lock()
+	expired := !c.paused && !clock().Now().Before(c.deadline)
+	c.mu.Unlock()
+	if expired {
+		c.cancel(true, DeadlineExceeded, nil)
+	}
+}
+
+func (c *pauseCtx) cancel(removeFromParent bool, err, cause error) {
+	c.cancelCtx.cancel(false, err, cause)
+	if removeFromParent {
+		removeChild(c.cancelCtx.Context, c)
+	}
+	c.mu.Lock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.mu.Unlock()
+}
@@ -0,0 +1,57 @@
+package source
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithPausableTimeout(t *testing.T) {
+	start := time.Now()
+	ctx, cancel := WithPausableTimeout(Background(), 40*time.Millisecond)
+	defer cancel()
+
+	time.Sleep(10 * time.Millisecond)
+	if !ctx.Pause() || ctx.Pause() {
+		t.Fatal("Pause() should succeed only once")
+	}
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("paused ctx reports a deadline")
+	}
+	// 暂停的时间超过了超时时间
+	time.Sleep(60 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatalf("paused ctx expired: %v", ctx.Err())
+	}
+	if !ctx.Resume() || ctx.Resume() {
+		t.Fatal("Resume() should succeed only once")
+	}
+	if d, ok := ctx.Deadline(); !ok || time.Until(d) > 35*time.Millisecond {
+		t.Errorf("Deadline() after Resume = %v, %v, want about 30ms left", time.Until(d), ok)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("ctx did not expire after Resume")
+	}
+	if ctx.Err() != DeadlineExceeded || time.Since(start) < 100*time.Millisecond {
+		t.Errorf("Err() = %v after %v", ctx.Err(), time.Since(start))
+	}
+	if ctx.Pause() {
+		t.Error("Pause() after expiry = true")
+	}
+}
+
+func TestWithPausableTimeoutParent(t *testing.T) {
+	parent, parentCancel := WithCancel(Background())
+	ctx, cancel := WithPausableTimeout(parent, time.Hour)
+	defer cancel()
+	ctx.Pause()
+	parentCancel()
+	if ctx.Err() != Canceled {
+		t.Errorf("paused ctx Err() after parent cancel = %v", ctx.Err())
+	}
+	if ctx.Resume() {
+		t.Error("Resume() after cancel = true")
+	}
+}
@@ -0,0 +1,101 @@
+package source
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// pooledCtx 可以回收的 cancelCtx，cancel 和 release 在创建时生成一次，之后随节点一起复用，不再分配闭包
+type pooledCtx struct {
+	cancelCtx
+	parent  *cancelCtx // 挂靠的父节点，parent 不会被取消时为 nil
+	owned   bool       // 是 cancel 或者 release 完成了取消，只有调用方会访问
+	cancel  CancelFunc
+	release func()
+}
+
+// cancelCtxPool 的 New 在 init 中设置，release 引用了 cancelCtxPool，直接初始化会形成初始化循环
+var cancelCtxPool sync.Pool
+
+func init() {
+	cancelCtxPool.New = func() any { return newPooledCtx() }
+}
+
+func newPooledCtx() *pooledCtx {
+	p := new(pooledCtx)
+	p.cancel = func() {
+		if p.cancelCtx.tryCancel(true, Canceled, nil) {
+			p.owned = true
+		}
+	}
+	p.release = func() {
+		p.cancel()
+		if !p.owned {
+			// 被父节点级联取消时，父节点的 goroutine 在解锁节点之后还会读取它的字段，不能复用
+			return
+		}
+		if p.parent != nil {
+			// 父节点级联取消时持有自己的锁遍历 children，这里等它结束，之后不会再有别的 goroutine 引用这个节点
+			p.parent.mu.Lock()
+			delete(p.parent.children, &p.cancelCtx)
+			p.parent.mu.Unlock()
+		}
+		p.reset()
+		cancelCtxPool.Put(p)
+	}
+	return p
+}
+
+// reset 清空节点的状态，保留 cancel 和 release
+func (p *pooledCtx) reset() {
+	c := &p.cancelCtx
+	c.mu.Lock()
+	c.Context = nil
+	c.done = atomic.Value{}
+	c.children = nil
+	c.err, c.cause, c.trace = nil, nil, nil
+	c.observer, c.self = nil, nil
+	c.mu.Unlock()
+	p.parent, p.owned = nil, false
+}
+
+// ReleasableWithCancel 与 WithCancel 相同，但节点从 sync.Pool 中分配，
+// 调用 release 之后放回池中，高 QPS 的服务端每个请求都派生 Context 时可以减少分配。
+// 同样会通知 Observer，严格模式下 cancel 和 release 都没有调用时同样报告。
+// release 会先取消 ctx，调用之后 ctx、cancel、release 以及从 ctx 派生出的所有 Context 都不能再使用，
+// 因为这个节点随时可能被别的请求复用；release 也只能调用一次，不能和 cancel 同时调用。
+// 节点先被父节点取消时 release 不回收它，交给 GC。
+// parent 是自定义的 Context、需要 goroutine 级联取消时，那个 goroutine 可能在 release 之后还引用这个节点，
+// 这时返回的 release 只取消，不回收。
+func ReleasableWithCancel(parent Context) (ctx Context, cancel CancelFunc, release func()) {
+	if parent == nil {
+		panic("cannot create context from nil parent")
+	}
+	var pc *cancelCtx
+	if parent.Done() != nil {
+		var ok bool
+		if pc, ok = parentCancelCtx(parent); !ok {
+			c, cancel := WithCancel(parent)
+			return c, cancel, cancel
+		}
+	}
+
+	p := cancelCtxPool.Get().(*pooledCtx)
+	p.cancelCtx.Context = parent
+	p.parent = pc
+	p.cancelCtx.observeNew(nil)
+	propagateCancel(parent, &p.cancelCtx)
+	cancel, release = p.cancel, p.release
+	if g := guardCancel(&p.cancelCtx); g != nil {
+		// 严格模式下 cancel 和 release 都算调用过 CancelFunc
+		cancel = func() {
+			g.done()
+			p.cancel()
+		}
+		release = func() {
+			g.done()
+			p.release()
+		}
+	}
+	return &p.cancelCtx, cancel, release
+}
@@ -0,0 +1,111 @@
+package source
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReleasableWithCancel(t *testing.T) {
+	parent, cancelParent := WithCancel(WithValue(Background(), "k", "v"))
+	defer cancelParent()
+
+	ctx, cancel, release := ReleasableWithCancel(parent)
+	child, childCancel := WithCancel(ctx)
+	if ctx.Value("k") != "v" || childCount(parent) != 1 {
+		t.Fatalf("Value(k) = %v, parent children = %d", ctx.Value("k"), childCount(parent))
+	}
+	cancel()
+	if ctx.Err() != Canceled || child.Err() != Canceled || childCount(parent) != 0 {
+		t.Errorf("after cancel: Err() = %v, child Err() = %v, parent children = %d", ctx.Err(), child.Err(), childCount(parent))
+	}
+	childCancel()
+	release()
+
+	// 复用的节点是全新的状态
+	ctx, _, release = ReleasableWithCancel(parent)
+	defer release()
+	if ctx.Err() != nil || Cause(ctx) != nil || childCount(ctx) != 0 {
+		t.Errorf("reused ctx: Err() = %v, Cause() = %v", ctx.Err(), Cause(ctx))
+	}
+	cancelParent()
+	if ctx.Err() != Canceled {
+		t.Errorf("reused ctx was not cancelled with its parent: %v", ctx.Err())
+	}
+
+	// 需要 goroutine 级联取消时不回收，release 只取消
+	custom, customCancel := WithCancel(Background())
+	defer customCancel()
+	ctx, _, release = ReleasableWithCancel(customCtx{custom})
+	if _, ok := ctx.(*timerCtx); ok {
+		t.Fatal("unexpected context type")
+	}
+	release()
+	if ctx.Err() != Canceled {
+		t.Errorf("Err() after release = %v", ctx.Err())
+	}
+}
+
+// TestReleaseRacesParentCancel 父节点的取消和 release 同时发生，go test -race 下不能有数据竞争，
+// 被父节点取消的节点也不能在父节点还在访问时被复用
+func TestReleaseRacesParentCancel(t *testing.T) {
+	SetObserver(new(recordObserver))
+	defer SetObserver(nil)
+	for i := 0; i < 1000; i++ {
+		parent, pcancel := WithCancel(Background())
+		ctx, _, release := ReleasableWithCancel(parent)
+		child, _ := WithCancel(ctx)
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			<-start
+			pcancel()
+		}()
+		go func() {
+			defer wg.Done()
+			<-start
+			release()
+		}()
+		close(start)
+		wg.Wait()
+		if child.Err() == nil {
+			t.Fatal("child was not cancelled")
+		}
+	}
+}
+
+// TestReleasableHooks 与 WithCancel 一样通知 Observer，严格模式下检查 cancel 和 release 是否被调用
+func TestReleasableHooks(t *testing.T) {
+	o := new(recordObserver)
+	SetObserver(o)
+	_, _, release := ReleasableWithCancel(Background())
+	SetObserver(nil)
+	release()
+	want := []string{"new WithCancel", "cancel WithCancel context canceled"}
+	if !reflect.DeepEqual(o.events, want) {
+		t.Errorf("events = %q, want %q", o.events, want)
+	}
+
+	useStrict(t, StrictLog)
+	func() {
+		_, _, release := ReleasableWithCancel(Background())
+		release()
+		_, cancel, release := ReleasableWithCancel(Background())
+		cancel()
+		release()
+		ReleasableWithCancel(Background())
+	}()
+	for i := 0; i < 3 && len(StrictViolations()) < 1; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	v := StrictViolations()
+	if len(v) != 1 || !strings.Contains(v[0], "pool_test.go") {
+		t.Fatalf("StrictViolations() = %q, want one lost CancelFunc", v)
+	}
+}
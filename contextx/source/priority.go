@@ -0,0 +1,43 @@
+package source
+
+// Priority 请求的优先级，数值越大越优先，没有设置时为 PriorityNormal
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+var priorityKey = NewKey[Priority]("priority")
+
+// WithPriority 返回优先级为 p 的子 Context，派生出的 Context 都继承这个优先级，直到再次设置。
+// 调度方通过 PriorityFrom 取出优先级，例如：
+//
+//	pool.SubmitPriority(int(source.PriorityFrom(ctx)), task)
+func WithPriority(ctx Context, p Priority) Context {
+	return priorityKey.WithValue(ctx, p)
+}
+
+// PriorityFrom 返回 ctx 的优先级，没有设置时返回 PriorityNormal
+func PriorityFrom(ctx Context) Priority {
+	p, _ := priorityKey.From(ctx)
+	return p
+}
+
+// InheritPriority 把 src 的优先级设置到 dst 上，src 没有设置时原样返回 dst。
+// 用于 dst 不是从 src 派生的情况，例如从服务的 Context 启动处理请求的后台任务。
+func InheritPriority(dst, src Context) Context {
+	if p, ok := priorityKey.From(src); ok {
+		return WithPriority(dst, p)
+	}
+	return dst
+}
+
+// RaisePriority 把 ctx 的优先级提高到至少 p，已经不低于 p 时原样返回 ctx
+func RaisePriority(ctx Context, p Priority) Context {
+	if PriorityFrom(ctx) >= p {
+		return ctx
+	}
+	return WithPriority(ctx, p)
+}
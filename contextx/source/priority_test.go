@@ -0,0 +1,28 @@
+package source
+
+import "testing"
+
+func TestPriority(t *testing.T) {
+	if p := PriorityFrom(Background()); p != PriorityNormal {
+		t.Errorf("default priority = %v", p)
+	}
+	ctx, cancel := WithCancel(WithPriority(Background(), PriorityHigh))
+	defer cancel()
+	if p := PriorityFrom(WithValue(ctx, "k", "v")); p != PriorityHigh {
+		t.Errorf("inherited priority = %v", p)
+	}
+	if got := RaisePriority(ctx, PriorityNormal); got != ctx {
+		t.Error("RaisePriority to a lower priority created a new context")
+	}
+
+	bg := WithoutCancel(Background())
+	if p := PriorityFrom(InheritPriority(bg, ctx)); p != PriorityHigh {
+		t.Errorf("InheritPriority() = %v", p)
+	}
+	if got := InheritPriority(bg, Background()); got != bg {
+		t.Error("InheritPriority from a context without priority created a new context")
+	}
+	if p := PriorityFrom(RaisePriority(WithPriority(bg, PriorityLow), PriorityNormal)); p != PriorityNormal {
+		t.Errorf("RaisePriority() = %v", p)
+	}
+}
@@ -0,0 +1,64 @@
+package source
+
+import (
+	"gopractice/reflectlite"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// runningGoroutines propagateCancel 启动的、还没有退出的 goroutine 数
+var runningGoroutines int32
+
+// PropagationGoroutines 返回 propagateCancel 启动的、还在运行的 goroutine 数。
+// 父节点是自定义的 Context、向上找不到 cancelCtx 时，每派生一个可取消的子节点就需要一个 goroutine 等待父节点取消，
+// 子节点一直不取消的话这个 goroutine 也不会退出。
+func PropagationGoroutines() int {
+	return int(atomic.LoadInt32(&runningGoroutines))
+}
+
+// Propagation 一次需要启动 goroutine 的级联取消，由 TrackPropagation 开启记录
+type Propagation struct {
+	Parent string // 父节点的描述，与 DebugString 相同
+	Child  string // 子节点的描述
+	Time   time.Time
+	Stack  string // 派生子节点时的调用栈
+}
+
+var tracking struct {
+	sync.Mutex
+	on      bool
+	records []Propagation
+}
+
+// TrackPropagation 开启或关闭记录，开启时清空之前的记录。
+// 记录会保存调用栈，开销较大，通常只在测试或者排查问题时开启。
+func TrackPropagation(on bool) {
+	tracking.Lock()
+	defer tracking.Unlock()
+	tracking.on = on
+	tracking.records = nil
+}
+
+// Propagations 返回开启记录以来所有需要启动 goroutine 的级联取消
+func Propagations() []Propagation {
+	tracking.Lock()
+	defer tracking.Unlock()
+	return append([]Propagation(nil), tracking.records...)
+}
+
+func trackPropagation(parent Context, child canceler) {
+	tracking.Lock()
+	defer tracking.Unlock()
+	if !tracking.on {
+		return
+	}
+	p := Propagation{Parent: contextName(parent), Time: time.Now(), Stack: string(debug.Stack())}
+	if c, ok := child.(Context); ok {
+		p.Child = contextName(c)
+	} else {
+		p.Child = reflectlite.TypeOf(child).String()
+	}
+	tracking.records = append(tracking.records, p)
+}
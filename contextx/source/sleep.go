@@ -0,0 +1,39 @@
+package source
+
+import "time"
+
+// Sleep 等待 d 或者 ctx 取消，先取消时返回 ctx.Err()，否则返回 nil
+// 计时使用 clock，测试中可以用 FakeClock 推进。
+func Sleep(ctx Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	fired := make(chan struct{})
+	t := clock().AfterFunc(d, func() { close(fired) })
+	select {
+	case <-fired:
+		return nil
+	case <-ctx.Done():
+		t.Stop()
+		return ctx.Err()
+	}
+}
+
+// Tick 每隔 d 向返回的 channel 发送当前时间，ctx 取消时停止并关闭 channel，可以直接 range。
+// 与 time.Ticker 一样，接收方来不及接收时丢弃多余的 tick。d 不大于 0 时 panic。
+func Tick(ctx Context, d time.Duration) <-chan time.Time {
+	if d <= 0 {
+		panic("non-positive interval for Tick")
+	}
+	ch := make(chan time.Time, 1)
+	go func() {
+		defer close(ch)
+		for Sleep(ctx, d) == nil {
+			select {
+			case ch <- clock().Now():
+			default:
+			}
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,35 @@
+package source
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSleep(t *testing.T) {
+	if err := Sleep(Background(), time.Millisecond); err != nil {
+		t.Errorf("Sleep() = %v", err)
+	}
+	ctx, cancel := WithTimeout(Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := Sleep(ctx, time.Hour); err != DeadlineExceeded || time.Since(start) > time.Second {
+		t.Errorf("Sleep() = %v after %v, want DeadlineExceeded right after the timeout", err, time.Since(start))
+	}
+	if err := Sleep(ctx, 0); err != DeadlineExceeded {
+		t.Errorf("Sleep(0) on a done ctx = %v", err)
+	}
+}
+
+func TestTick(t *testing.T) {
+	ctx, cancel := WithCancel(Background())
+	n := 0
+	for range Tick(ctx, time.Millisecond) {
+		if n++; n == 3 {
+			cancel()
+		}
+	}
+	// range 在 ctx 取消后结束
+	if n < 3 {
+		t.Errorf("got %d ticks", n)
+	}
+}
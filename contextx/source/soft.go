@@ -0,0 +1,53 @@
+package source
+
+import (
+	"sync"
+	"time"
+)
+
+// SoftDeadline 带有提前警告的 Context，由 WithSoftDeadline 创建
+type SoftDeadline interface {
+	Context
+	// Warn 返回的 channel 在截止时间之前 warnBefore 关闭，调用方可以借此返回部分结果或者申请延期。
+	// Context 提前取消时也会关闭。
+	Warn() <-chan struct{}
+}
+
+// WithSoftDeadline 与 WithDeadline(parent, hard) 相同，截止时间到了以 DeadlineExceeded 取消，
+// 另外在截止时间之前 warnBefore 关闭 Warn 返回的 channel。
+// parent 的截止时间更早时以 parent 的为准。
+func WithSoftDeadline(parent Context, hard time.Time, warnBefore time.Duration) (SoftDeadline, CancelFunc) {
+	ctx, cancel := WithDeadline(parent, hard)
+	c := &softCtx{Context: ctx, warn: make(chan struct{})}
+	// 取消时同步关闭 warn，不需要额外的 goroutine
+	stop := OnCancel(ctx, c.closeWarn)
+	d, _ := ctx.Deadline()
+	timer := clock().AfterFunc(until(d)-warnBefore, c.closeWarn)
+	return c, func() {
+		stop()
+		timer.Stop()
+		cancel()
+		c.closeWarn()
+	}
+}
+
+// softCtx 包装 WithDeadline 返回的 Context，其他方法都由它实现
+type softCtx struct {
+	Context
+	warn chan struct{}
+	once sync.Once
+}
+
+func (c *softCtx) Warn() <-chan struct{} {
+	return c.warn
+}
+
+func (c *softCtx) closeWarn() {
+	c.once.Do(func() {
+		close(c.warn)
+	})
+}
+
+func (c *softCtx) String() string {
+	return contextName(c.Context) + ".WithSoftDeadline"
+}
@@ -0,0 +1,43 @@
+package source
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithSoftDeadline(t *testing.T) {
+	clk := useFakeClock(t)
+	ctx, cancel := WithSoftDeadline(Background(), clk.Now().Add(time.Minute), 10*time.Second)
+	defer cancel()
+
+	clk.Advance(49 * time.Second)
+	select {
+	case <-ctx.Warn():
+		t.Fatal("warned too early")
+	default:
+	}
+	clk.Advance(time.Second)
+	select {
+	case <-ctx.Warn():
+	default:
+		t.Fatal("no warning 10s before the deadline")
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("Err() after the warning = %v", ctx.Err())
+	}
+	clk.Advance(10 * time.Second)
+	if ctx.Err() != DeadlineExceeded {
+		t.Errorf("Err() at the hard deadline = %v", ctx.Err())
+	}
+
+	// 提前取消时也会关闭 Warn
+	parent, parentCancel := WithCancel(Background())
+	ctx, cancel = WithSoftDeadline(parent, clk.Now().Add(time.Hour), time.Minute)
+	defer cancel()
+	parentCancel()
+	select {
+	case <-ctx.Warn():
+	default:
+		t.Error("Warn() not closed after the parent was cancelled")
+	}
+}
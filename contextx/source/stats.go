@@ -0,0 +1,87 @@
+package source
+
+// ChildCount 返回 ctx 自己或者最近的可取消祖先节点当前登记的子节点数，
+// 取消之后或者 ctx 不可取消时返回 0。一直增长说明有 CancelFunc 没有被调用。
+func ChildCount(ctx Context) int {
+	cc := cancelCtxOf(ctx)
+	if cc == nil {
+		var ok bool
+		if cc, ok = ctx.Value(&cancelCtxKey).(*cancelCtx); !ok {
+			return 0
+		}
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return len(cc.children)
+}
+
+// TreeStats Stats 返回的统计
+type TreeStats struct {
+	Cancel    int // WithCancel、WithCancelCause 等只能手动取消的节点
+	Timer     int // 带有定时器的节点，WithDeadline、WithTimeout、WithExtendableDeadline 等
+	Callbacks int // AfterFunc、OnCancel 注册的回调
+	Depth     int // 子树中可取消节点的最大层数，ctx 本身为 0
+	// Values ctx 到根节点的路径上保存的值的个数，值节点不会登记到父节点中，无法向下统计
+	Values int
+}
+
+// Total 返回子树中还没有取消的节点总数
+func (s TreeStats) Total() int {
+	return s.Cancel + s.Timer + s.Callbacks
+}
+
+// Stats 统计 ctx 下面还没有取消、仍然登记在 children 中的所有节点，不包括 ctx 本身，
+// 服务可以定期上报，用来发现 Context 树的增长和没有调用 CancelFunc 造成的泄漏。
+// ctx 不可取消时从最近的可取消祖先节点开始统计。
+func Stats(ctx Context) TreeStats {
+	var s TreeStats
+	for p := ctx; p != nil; p = parentOf(p) {
+		switch c := p.(type) {
+		case *valueCtx:
+			s.Values++
+		case *valuesCtx:
+			s.Values += len(c.keys)
+		case *indexCtx:
+			s.Values += len(c.keys)
+		}
+	}
+
+	cc := cancelCtxOf(ctx)
+	if cc == nil {
+		var ok bool
+		if cc, ok = ctx.Value(&cancelCtxKey).(*cancelCtx); !ok {
+			return s
+		}
+	}
+	s.walk(cc, 0)
+	return s
+}
+
+func (s *TreeStats) walk(cc *cancelCtx, depth int) {
+	cc.mu.Lock()
+	children := make([]canceler, 0, len(cc.children))
+	for child := range cc.children {
+		children = append(children, child)
+	}
+	cc.mu.Unlock()
+
+	for _, child := range children {
+		switch child.(type) {
+		case *afterFuncCtx, *onCancelCtx:
+			s.Callbacks++
+			continue
+		case *timerCtx, *extendCtx, *pauseCtx:
+			s.Timer++
+		default:
+			s.Cancel++
+		}
+		if depth+1 > s.Depth {
+			s.Depth = depth + 1
+		}
+		if ctx, ok := child.(Context); ok {
+			if c := cancelCtxOf(ctx); c != nil {
+				s.walk(c, depth+1)
+			}
+		}
+	}
+}
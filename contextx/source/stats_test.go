@@ -0,0 +1,34 @@
+package source
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	root, cancel := WithCancel(WithValues(Background(), "a", 1, "b", 2))
+	defer cancel()
+	mid, midCancel := WithTimeout(WithValue(root, "c", 3), time.Hour)
+	leaf, leafCancel := WithCancel(mid)
+	defer leafCancel()
+	AfterFunc(leaf, func() {})
+	_, c2 := WithCancel(root)
+	defer c2()
+
+	if n := ChildCount(root); n != 2 {
+		t.Errorf("ChildCount(root) = %d, want 2", n)
+	}
+	want := TreeStats{Cancel: 2, Timer: 1, Callbacks: 1, Depth: 2, Values: 2}
+	if s := Stats(root); s != want || s.Total() != 4 {
+		t.Errorf("Stats(root) = %+v, want %+v", s, want)
+	}
+	if s := Stats(WithValue(leaf, "d", 4)); s.Values != 4 || s.Total() != 1 {
+		t.Errorf("Stats(value under leaf) = %+v", s)
+	}
+
+	// 取消的子树不再计入
+	midCancel()
+	if s := Stats(root); s.Total() != 1 || ChildCount(mid) != 0 {
+		t.Errorf("Stats(root) after cancelling mid = %+v", s)
+	}
+}
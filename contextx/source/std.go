@@ -0,0 +1,97 @@
+package source
+
+import (
+	"context"
+	"time"
+)
+
+// FromStd 把标准库的 context.Context 转换为 Context，Done、Deadline 和 Value 直接使用 c 的，
+// Err 返回的 context.Canceled 和 context.DeadlineExceeded 换成本包的 Canceled 和 DeadlineExceeded。
+// c 被取消时，从返回值派生的子节点也会被取消，c 是 ToStd 的返回值时直接返回原来的 Context。
+func FromStd(c context.Context) Context {
+	if c == nil {
+		panic("cannot create context from nil parent")
+	}
+	if s, ok := c.(toStdCtx); ok {
+		return s.c
+	}
+	return fromStdCtx{c}
+}
+
+// ToStd 把 Context 转换为标准库的 context.Context，与 FromStd 相反，
+// 用于把本包的 Context 传给使用标准库的代码，c 被取消时标准库派生的子节点也会被取消。
+func ToStd(c Context) context.Context {
+	if c == nil {
+		panic("cannot create context from nil parent")
+	}
+	if s, ok := c.(fromStdCtx); ok {
+		return s.c
+	}
+	return toStdCtx{c}
+}
+
+type fromStdCtx struct {
+	c context.Context
+}
+
+func (c fromStdCtx) Deadline() (deadline time.Time, ok bool) {
+	return c.c.Deadline()
+}
+
+func (c fromStdCtx) Done() <-chan struct{} {
+	return c.c.Done()
+}
+
+func (c fromStdCtx) Err() error {
+	switch err := c.c.Err(); err {
+	case context.Canceled:
+		return Canceled
+	case context.DeadlineExceeded:
+		return DeadlineExceeded
+	default:
+		return err
+	}
+}
+
+func (c fromStdCtx) Value(key any) any {
+	// 标准库中间可能还有 ToStd 包装的节点，不能把它们的 cancelCtx 当作 c 的
+	if key == &cancelCtxKey {
+		return nil
+	}
+	return c.c.Value(key)
+}
+
+func (c fromStdCtx) String() string {
+	return contextName(c.c) + ".FromStd"
+}
+
+type toStdCtx struct {
+	c Context
+}
+
+func (c toStdCtx) Deadline() (deadline time.Time, ok bool) {
+	return c.c.Deadline()
+}
+
+func (c toStdCtx) Done() <-chan struct{} {
+	return c.c.Done()
+}
+
+func (c toStdCtx) Err() error {
+	switch err := c.c.Err(); err {
+	case Canceled:
+		return context.Canceled
+	case DeadlineExceeded:
+		return context.DeadlineExceeded
+	default:
+		return err
+	}
+}
+
+func (c toStdCtx) Value(key any) any {
+	return c.c.Value(key)
+}
+
+func (c toStdCtx) String() string {
+	return contextName(c.c) + ".ToStd"
+}
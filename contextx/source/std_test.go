@@ -0,0 +1,67 @@
+package source
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type stdKey struct{}
+
+func TestFromStd(t *testing.T) {
+	std, cancel := context.WithTimeout(context.WithValue(context.Background(), stdKey{}, 1), time.Hour)
+	ctx := FromStd(std)
+	child, childCancel := WithCancel(ctx)
+	defer childCancel()
+	if d, ok := child.Deadline(); !ok || time.Until(d) < 59*time.Minute {
+		t.Errorf("Deadline() = %v, %v", d, ok)
+	}
+	if v := child.Value(stdKey{}); v != 1 {
+		t.Errorf("Value() = %v, want 1", v)
+	}
+	cancel()
+	<-child.Done()
+	if child.Err() != Canceled || ctx.Err() != Canceled {
+		t.Errorf("Err() = %v, %v, want Canceled", child.Err(), ctx.Err())
+	}
+
+	std, cancel = context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+	if err := FromStd(std).Err(); err != DeadlineExceeded {
+		t.Errorf("Err() = %v, want DeadlineExceeded", err)
+	}
+	if FromStd(ToStd(ctx)) != ctx {
+		t.Error("FromStd(ToStd(ctx)) != ctx")
+	}
+}
+
+func TestToStd(t *testing.T) {
+	ctx, cancel := WithCancel(WithValue(Background(), stdKey{}, 1))
+	std := ToStd(ctx)
+	child, childCancel := context.WithCancel(std)
+	defer childCancel()
+	if v := child.Value(stdKey{}); v != 1 {
+		t.Errorf("Value() = %v, want 1", v)
+	}
+	cancel()
+	<-child.Done()
+	if child.Err() != context.Canceled || std.Err() != context.Canceled {
+		t.Errorf("Err() = %v, %v, want context.Canceled", child.Err(), std.Err())
+	}
+
+	// 来回转换之后取消仍然能传到最下层
+	ctx, cancel = WithCancel(Background())
+	leaf, leafCancel := WithCancel(FromStd(context.WithValue(ToStd(ctx), stdKey{}, 2)))
+	defer leafCancel()
+	if Cause(leaf) != nil {
+		t.Errorf("Cause() = %v before cancel", Cause(leaf))
+	}
+	cancel()
+	<-leaf.Done()
+	if leaf.Err() != Canceled {
+		t.Errorf("Err() = %v, want Canceled", leaf.Err())
+	}
+	if ToStd(FromStd(std)) != std {
+		t.Error("ToStd(FromStd(std)) != std")
+	}
+}
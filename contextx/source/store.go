@@ -0,0 +1,74 @@
+package source
+
+import "sync"
+
+// Store 请求范围内可以修改的键值存储，并发安全
+// WithValue 保存的值不能修改，需要在处理过程中写入、在别处读取的数据（例如请求级的缓存）可以放在这里。
+type Store struct {
+	mu sync.RWMutex
+	m  map[any]any
+}
+
+var storeKey = NewKey[*Store]("store")
+
+// WithStore 返回带有一个空 Store 的子 Context，派生出的 Context 通过 StoreFrom 取到的是同一个 Store。
+// 再次调用 WithStore 会在下面创建新的 Store，遮住上层的。
+func WithStore(parent Context) Context {
+	return storeKey.WithValue(parent, &Store{m: make(map[any]any)})
+}
+
+// StoreFrom 返回 ctx 中最近的 Store，没有时返回 nil 和 false
+func StoreFrom(ctx Context) (*Store, bool) {
+	return storeKey.From(ctx)
+}
+
+// Get 返回 key 对应的值
+func (s *Store) Get(key any) (any, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.m[key]
+	return v, ok
+}
+
+// Set 设置 key 对应的值，key 必须是可比较的
+func (s *Store) Set(key, val any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[key] = val
+}
+
+// LoadOrStore key 已经存在时返回已有的值和 true，否则保存 val 并返回 val 和 false
+func (s *Store) LoadOrStore(key, val any) (actual any, loaded bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.m[key]; ok {
+		return v, true
+	}
+	s.m[key] = val
+	return val, false
+}
+
+// Delete 删除 key
+func (s *Store) Delete(key any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, key)
+}
+
+// Len 返回保存的键值对个数
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.m)
+}
+
+// Range 对每个键值对调用 f，f 返回 false 时停止，f 中不能修改 Store
+func (s *Store) Range(f func(key, val any) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for k, v := range s.m {
+		if !f(k, v) {
+			return
+		}
+	}
+}
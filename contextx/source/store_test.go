@@ -0,0 +1,52 @@
+package source
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	if _, ok := StoreFrom(Background()); ok {
+		t.Fatal("StoreFrom(Background()) found a store")
+	}
+	ctx := WithStore(Background())
+	child, cancel := WithCancel(WithValue(ctx, "k", "v"))
+	defer cancel()
+
+	// 在子节点中写入，在父节点中读取
+	s, _ := StoreFrom(child)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.Set(i, i*i)
+			s.LoadOrStore("shared", i)
+		}(i)
+	}
+	wg.Wait()
+
+	s, ok := StoreFrom(ctx)
+	if !ok || s.Len() != 11 {
+		t.Fatalf("StoreFrom(parent) = %v, %v with %d entries", s, ok, s.Len())
+	}
+	if v, ok := s.Get(3); !ok || v != 9 {
+		t.Errorf("Get(3) = %v, %v", v, ok)
+	}
+	first, _ := s.Get("shared")
+	if v, loaded := s.LoadOrStore("shared", -1); !loaded || v != first {
+		t.Errorf("LoadOrStore() = %v, %v, want %v, true", v, loaded, first)
+	}
+	s.Delete(3)
+	n := 0
+	s.Range(func(key, val any) bool { n++; return true })
+	if n != 10 {
+		t.Errorf("Range visited %d entries, want 10", n)
+	}
+
+	// 新的 Store 遮住上层的
+	inner, _ := StoreFrom(WithStore(child))
+	if inner == s || inner.Len() != 0 {
+		t.Error("nested WithStore did not create a new store")
+	}
+}
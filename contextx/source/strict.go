@@ -0,0 +1,134 @@
+package source
+
+import (
+	"fmt"
+	"gopractice/reflectlite"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// StrictMode 严格模式的级别，开启后检查两类最常见的 Context 误用：
+// WithValue 使用内置类型的 key，或者遮住上层同一个 key 的不同值；CancelFunc 没有调用就被丢弃。
+type StrictMode int32
+
+const (
+	StrictOff   StrictMode = iota // 不检查
+	StrictLog                     // 记录下来并以 Error 级别写入 logger
+	StrictPanic                   // 同 StrictLog，WithValue 的误用直接 panic，CancelFunc 的泄漏在 finalizer 中发现，只记录
+)
+
+var strictMode int32
+
+var violations struct {
+	sync.Mutex
+	list []string
+}
+
+// SetStrict 设置严格模式，同时清空之前记录的问题。
+// CancelFunc 的检查通过 runtime.SetFinalizer 实现，只有 GC 之后才能发现，开销也较大，通常只在测试中开启。
+func SetStrict(m StrictMode) {
+	violations.Lock()
+	violations.list = nil
+	violations.Unlock()
+	atomic.StoreInt32(&strictMode, int32(m))
+}
+
+// StrictViolations 返回上次 SetStrict 以来发现的问题
+func StrictViolations() []string {
+	violations.Lock()
+	defer violations.Unlock()
+	return append([]string(nil), violations.list...)
+}
+
+func strict() StrictMode {
+	return StrictMode(atomic.LoadInt32(&strictMode))
+}
+
+func reportViolation(msg string, canPanic bool) {
+	violations.Lock()
+	violations.list = append(violations.list, msg)
+	violations.Unlock()
+	logger().Errorf("context: %s", msg)
+	if canPanic && strict() == StrictPanic {
+		panic("context: " + msg)
+	}
+}
+
+// checkValue 检查 WithValue 的 key：内置类型的 key 很容易和别的包冲突，
+// 上层已经有同一个 key 的不同值时，通常是两个包无意中用了同一个 key。
+func checkValue(parent Context, key, val any) {
+	t := reflectlite.TypeOf(key)
+	if t.PkgPath() == "" && t.Name() != "" {
+		reportViolation(fmt.Sprintf("WithValue at %s uses a key of built-in type %s", callerSite(), t), true)
+	}
+	old := parent.Value(key)
+	if old == nil {
+		return
+	}
+	same := false
+	if reflectlite.TypeOf(old) == reflectlite.TypeOf(val) && reflectlite.TypeOf(val).Comparable() {
+		same = old == val
+	}
+	if !same {
+		reportViolation(fmt.Sprintf("WithValue at %s shadows key %v (type %s) with a different value", callerSite(), key, t), true)
+	}
+}
+
+// cancelGuard 被返回给调用方的 CancelFunc 引用，CancelFunc 被回收时检查它是否被调用过
+type cancelGuard struct {
+	ctx    Context
+	site   string
+	called int32
+}
+
+// guardCancel 严格模式下返回 ctx 的 cancelGuard，否则返回 nil
+func guardCancel(ctx Context) *cancelGuard {
+	if strict() == StrictOff {
+		return nil
+	}
+	g := &cancelGuard{ctx: ctx, site: callerSite()}
+	runtime.SetFinalizer(g, (*cancelGuard).check)
+	return g
+}
+
+func (g *cancelGuard) done() {
+	atomic.StoreInt32(&g.called, 1)
+}
+
+// check 在 finalizer 中调用，ctx 被父节点取消之后不会再泄漏，不算问题
+func (g *cancelGuard) check() {
+	if atomic.LoadInt32(&g.called) == 0 && g.ctx.Err() == nil {
+		reportViolation("the CancelFunc of the context created at "+g.site+" was dropped without being called", false)
+	}
+}
+
+// wrapCancel 严格模式下包装 cancel，调用时标记 cancelGuard
+func wrapCancel(ctx Context, cancel CancelFunc) CancelFunc {
+	g := guardCancel(ctx)
+	if g == nil {
+		return cancel
+	}
+	return func() {
+		g.done()
+		cancel()
+	}
+}
+
+// callerSite 返回调用栈中第一个 contextx 以外的位置，包内的测试文件也算作外部
+func callerSite() string {
+	pcs := make([]uintptr, 16)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, "gopractice/contextx/source.") || strings.HasSuffix(f.File, "_test.go") {
+			return f.File + ":" + strconv.Itoa(f.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package source
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+type strictKey struct{}
+
+func useStrict(t *testing.T, m StrictMode) {
+	SetStrict(m)
+	t.Cleanup(func() { SetStrict(StrictOff) })
+}
+
+func TestStrictValue(t *testing.T) {
+	useStrict(t, StrictLog)
+	ctx := WithValue(Background(), strictKey{}, 1)
+	WithValue(ctx, strictKey{}, 1)
+	WithValue(ctx, "user", "a")
+	WithValue(ctx, strictKey{}, 2)
+	WithValue(ctx, strictKey{}, []int{1})
+	WithValues(ctx, strictKey{}, 2)
+	v := StrictViolations()
+	if len(v) != 4 {
+		t.Fatalf("StrictViolations() = %q, want 4", v)
+	}
+	if !strings.Contains(v[0], "built-in type string") || !strings.Contains(v[0], "strict_test.go") {
+		t.Errorf("violation = %q", v[0])
+	}
+	if !strings.Contains(v[1], "shadows") {
+		t.Errorf("violation = %q", v[1])
+	}
+
+	useStrict(t, StrictPanic)
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("WithValue with a string key did not panic")
+		}
+	}()
+	WithValue(ctx, "user", "a")
+}
+
+func TestStrictLostCancel(t *testing.T) {
+	useStrict(t, StrictPanic)
+	func() {
+		_, cancel := WithCancel(Background())
+		cancel()
+		WithTimeout(Background(), time.Hour)
+		parent, cancel := WithCancel(Background())
+		WithCancelCause(parent)
+		cancel()
+	}()
+	// 父节点取消之后子节点的 CancelFunc 丢了也不算泄漏
+	for i := 0; i < 3 && len(StrictViolations()) < 2; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	v := StrictViolations()
+	if len(v) != 1 || !strings.Contains(v[0], "without being called") {
+		t.Fatalf("StrictViolations() = %q, want one lost CancelFunc", v)
+	}
+	if !strings.Contains(v[0], "strict_test.go") {
+		t.Errorf("violation = %q, want the creation site", v[0])
+	}
+}
+
+func TestStrictOff(t *testing.T) {
+	WithValue(Background(), "user", "a")
+	if v := StrictViolations(); len(v) != 0 {
+		t.Errorf("StrictViolations() = %q with strict mode off", v)
+	}
+}
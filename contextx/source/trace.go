@@ -0,0 +1,64 @@
+package source
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// cancelTracing 不为 0 时 cancel 记录调用栈
+var cancelTracing int32
+
+// SetCancelTracing 开启或关闭取消位置的记录，开启后每次取消都会记录时间和调用栈，
+// 通过 CancelInfo 取出，用来查找线上的 "context canceled" 是哪里取消的。
+// 记录调用栈有额外的开销，默认关闭。
+func SetCancelTracing(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&cancelTracing, v)
+}
+
+// CancelTrace 一次取消的记录
+type CancelTrace struct {
+	Time  time.Time
+	Err   error
+	Cause error
+	// Stack 调用 cancel 的调用栈，每帧两行：函数名和文件位置
+	// 子节点随父节点一起取消时，调用栈中包含父节点被取消的位置；定时器到期时是定时器的 goroutine。
+	Stack string
+}
+
+// CancelInfo 返回 ctx 最近的可取消节点的取消记录，
+// 还没有取消、取消时没有开启 SetCancelTracing，或者 ctx 不可取消时返回 false。
+func CancelInfo(ctx Context) (CancelTrace, bool) {
+	cc, ok := ctx.Value(&cancelCtxKey).(*cancelCtx)
+	if !ok {
+		return CancelTrace{}, false
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.trace == nil {
+		return CancelTrace{}, false
+	}
+	return *cc.trace, true
+}
+
+func newCancelTrace(err, cause error) *CancelTrace {
+	pcs := make([]uintptr, 32)
+	// 跳过 runtime.Callers、newCancelTrace、cancelCtx.tryCancel 和调用它的 cancelCtx.cancel
+	n := runtime.Callers(4, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var b strings.Builder
+	for {
+		f, more := frames.Next()
+		b.WriteString(f.Function + "\n\t" + f.File + ":" + strconv.Itoa(f.Line) + "\n")
+		if !more {
+			break
+		}
+	}
+	return &CancelTrace{Time: clock().Now(), Err: err, Cause: cause, Stack: b.String()}
+}
@@ -0,0 +1,40 @@
+package source
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func cancelFromHere(cancel CancelFunc) {
+	cancel()
+}
+
+func TestCancelInfo(t *testing.T) {
+	ctx, cancel := WithCancel(Background())
+	cancel()
+	if _, ok := CancelInfo(ctx); ok {
+		t.Error("CancelInfo() without tracing = true")
+	}
+
+	SetCancelTracing(true)
+	defer SetCancelTracing(false)
+	parent, cancel := WithCancel(Background())
+	child, childCancel := WithTimeout(WithValue(parent, "k", "v"), time.Hour)
+	defer childCancel()
+	if _, ok := CancelInfo(child); ok {
+		t.Error("CancelInfo() before cancel = true")
+	}
+	cancelFromHere(cancel)
+
+	for _, c := range []Context{parent, child} {
+		info, ok := CancelInfo(c)
+		if !ok || info.Err != Canceled || info.Time.IsZero() {
+			t.Fatalf("CancelInfo(%v) = %+v, %v", c, info, ok)
+		}
+		// 子节点的调用栈里也能看到父节点被取消的位置
+		if !strings.Contains(info.Stack, "source.cancelFromHere") {
+			t.Errorf("stack of %v does not contain the cancel site:\n%s", c, info.Stack)
+		}
+	}
+}
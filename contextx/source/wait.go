@@ -0,0 +1,46 @@
+package source
+
+import "reflect"
+
+// WaitAny 阻塞到 ctxs 中任意一个完成，返回它的下标和 Err。
+// ctx 先完成时返回 -1 和 ctx.Err()，可以用来设置等待的超时。
+// 使用 reflect.Select 同时等待所有的 Done，不需要为每个 Context 启动 goroutine；
+// Done 返回 nil 的 Context 永远不会完成，会被忽略。
+func WaitAny(ctx Context, ctxs ...Context) (int, error) {
+	cases := make([]reflect.SelectCase, 0, len(ctxs)+1)
+	index := make([]int, 0, len(ctxs)+1) // cases 中每一项对应的 ctxs 下标，-1 是 ctx
+	add := func(i int, c Context) {
+		if done := c.Done(); done != nil {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)})
+			index = append(index, i)
+		}
+	}
+	add(-1, ctx)
+	for i, c := range ctxs {
+		add(i, c)
+	}
+	if len(cases) == 0 {
+		// 都不会完成，与标准库对 nil channel 的 select 一致，永远阻塞
+		select {}
+	}
+
+	chosen, _, _ := reflect.Select(cases)
+	if i := index[chosen]; i >= 0 {
+		return i, ctxs[i].Err()
+	}
+	return -1, ctx.Err()
+}
+
+// WaitAll 阻塞到 ctxs 全部完成，返回 nil；ctx 先完成时返回 ctx.Err()。
+// Done 返回 nil 的 Context 永远不会完成，此时只能等到 ctx 完成。
+func WaitAll(ctx Context, ctxs ...Context) error {
+	rest := append([]Context(nil), ctxs...)
+	for len(rest) > 0 {
+		i, err := WaitAny(ctx, rest...)
+		if i < 0 {
+			return err
+		}
+		rest = append(rest[:i], rest[i+1:]...)
+	}
+	return nil
+}
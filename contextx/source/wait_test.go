@@ -0,0 +1,42 @@
+package source
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitAny(t *testing.T) {
+	a, cancelA := WithCancel(Background())
+	defer cancelA()
+	b, cancelB := WithTimeout(Background(), 10*time.Millisecond)
+	defer cancelB()
+
+	if i, err := WaitAny(Background(), a, Background(), b); i != 2 || err != DeadlineExceeded {
+		t.Errorf("WaitAny() = %d, %v, want 2, DeadlineExceeded", i, err)
+	}
+
+	outer, cancelOuter := WithTimeout(Background(), 10*time.Millisecond)
+	defer cancelOuter()
+	if i, err := WaitAny(outer, a); i != -1 || err != DeadlineExceeded {
+		t.Errorf("WaitAny() with an expired outer ctx = %d, %v", i, err)
+	}
+}
+
+func TestWaitAll(t *testing.T) {
+	a, cancelA := WithCancel(Background())
+	b, cancelB := WithTimeout(Background(), 10*time.Millisecond)
+	defer cancelB()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancelA()
+	}()
+	if err := WaitAll(Background(), a, b); err != nil || a.Err() == nil || b.Err() == nil {
+		t.Errorf("WaitAll() = %v, Err() = %v, %v", err, a.Err(), b.Err())
+	}
+
+	outer, cancelOuter := WithTimeout(Background(), 10*time.Millisecond)
+	defer cancelOuter()
+	if err := WaitAll(outer, b, Background()); err != DeadlineExceeded || outer.Err() == nil {
+		t.Errorf("WaitAll() with a never-done ctx = %v", err)
+	}
+}
@@ -1,3 +1,8 @@
 module gopractice
 
 go 1.18
+
+require (
+	github.com/rivo/uniseg v0.4.7
+	google.golang.org/protobuf v1.33.0
+)
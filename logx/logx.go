@@ -0,0 +1,68 @@
+// Package logx 各个模块共用的日志接口
+// netx、contextx 等包都通过 Logger 输出日志，默认使用 Nop 什么都不输出，
+// 使用者只需要实现 Logger 接口就可以把日志接入自己的日志系统。
+package logx
+
+import (
+	"fmt"
+	"io"
+	"log"
+)
+
+// Logger 分级别的日志接口
+type Logger interface {
+	Debugf(format string, args ...any)
+	Infof(format string, args ...any)
+	Errorf(format string, args ...any)
+}
+
+// Level 日志级别
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelError:
+		return "ERROR"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// Nop 丢弃所有日志，是各模块的默认 Logger
+var Nop Logger = nop{}
+
+type nop struct{}
+
+func (nop) Debugf(format string, args ...any) {}
+func (nop) Infof(format string, args ...any)  {}
+func (nop) Errorf(format string, args ...any) {}
+
+// New 返回一个把日志写到 w 的 Logger，低于 min 级别的日志会被丢弃
+func New(w io.Writer, min Level) Logger {
+	return &stdLogger{l: log.New(w, "", log.LstdFlags), min: min}
+}
+
+type stdLogger struct {
+	l   *log.Logger
+	min Level
+}
+
+func (s *stdLogger) Debugf(format string, args ...any) { s.output(LevelDebug, format, args) }
+func (s *stdLogger) Infof(format string, args ...any)  { s.output(LevelInfo, format, args) }
+func (s *stdLogger) Errorf(format string, args ...any) { s.output(LevelError, format, args) }
+
+func (s *stdLogger) output(level Level, format string, args []any) {
+	if level < s.min {
+		return
+	}
+	s.l.Printf("[%s] %s", level, fmt.Sprintf(format, args...))
+}
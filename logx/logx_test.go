@@ -0,0 +1,40 @@
+package logx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, LevelInfo)
+	l.Debugf("debug %d", 1)
+	l.Infof("info %d", 2)
+	l.Errorf("error %d", 3)
+
+	out := buf.String()
+	if strings.Contains(out, "debug 1") {
+		t.Errorf("debug log should be dropped, got %q", out)
+	}
+	if !strings.Contains(out, "[INFO] info 2") || !strings.Contains(out, "[ERROR] error 3") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	var r Recorder
+	r.Debugf("a=%d", 1)
+	r.Errorf("b=%s", "x")
+
+	want := []Entry{{LevelDebug, "a=1"}, {LevelError, "b=x"}}
+	got := r.Entries()
+	if len(got) != len(want) {
+		t.Fatalf("Entries() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Entries()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package logx
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Entry 一条日志记录
+type Entry struct {
+	Level Level
+	Msg   string
+}
+
+// Recorder 把日志保存在内存中的 Logger，主要用于测试中断言输出了哪些日志
+type Recorder struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func (r *Recorder) Debugf(format string, args ...any) { r.record(LevelDebug, format, args) }
+func (r *Recorder) Infof(format string, args ...any)  { r.record(LevelInfo, format, args) }
+func (r *Recorder) Errorf(format string, args ...any) { r.record(LevelError, format, args) }
+
+func (r *Recorder) record(level Level, format string, args []any) {
+	r.mu.Lock()
+	r.entries = append(r.entries, Entry{Level: level, Msg: fmt.Sprintf(format, args...)})
+	r.mu.Unlock()
+}
+
+// Entries 返回目前为止记录的所有日志
+func (r *Recorder) Entries() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Entry(nil), r.entries...)
+}
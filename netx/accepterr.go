@@ -0,0 +1,14 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || windows
+
+package netx
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isTemporaryErrno 文件描述符用完或者连接在 Accept 之前被对方放弃，稍后重试可能成功
+func isTemporaryErrno(err error) bool {
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE) ||
+		errors.Is(err, syscall.ECONNABORTED)
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package netx
+
+// isTemporaryErrno 当前平台没有对应的错误码，只按超时判断
+func isTemporaryErrno(err error) bool {
+	return false
+}
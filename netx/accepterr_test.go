@@ -0,0 +1,20 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || windows
+
+package netx
+
+import (
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestIsTemporary(t *testing.T) {
+	for _, err := range []error{syscall.EMFILE, &net.OpError{Op: "accept", Err: syscall.ENFILE}} {
+		if !isTemporary(err) {
+			t.Errorf("isTemporary(%v) = false", err)
+		}
+	}
+	if isTemporary(net.ErrClosed) {
+		t.Error("isTemporary(net.ErrClosed) = true")
+	}
+}
@@ -0,0 +1,178 @@
+package netx
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBatchSize 缓存的数据达到这个大小时立即发送
+	DefaultBatchSize = 64 << 10
+	// DefaultBatchDelay 第一个消息最多等待多久就发送
+	DefaultBatchDelay = time.Millisecond
+
+	// largeWriteSize 不小于这个大小的数据不复制到缓冲区，和已经缓存的数据一起用一次 writev 发送
+	largeWriteSize = 4 << 10
+)
+
+// BatchedWriter 把多个小消息合并起来一次发送，减少频繁发送小消息时的系统调用次数。
+// 小消息先复制到缓冲区中，缓存的数据达到 BatchSize 或者第一个消息等待超过 BatchDelay 时发送；
+// 大消息不复制，和缓冲区中的数据组成 net.Buffers，对 *net.TCPConn 只需要一次 writev。
+// 可以在多个 goroutine 中同时调用，Write 返回 nil 不代表数据已经发送，需要时调用 Flush。
+type BatchedWriter struct {
+	w     io.Writer
+	codec FrameCodec
+	size  int
+	delay time.Duration
+
+	wmu   sync.Mutex // 保证一次只有一个 goroutine 写 w，数据按 Write 的顺序发送
+	mu    sync.Mutex
+	buf   []byte // 等待发送的数据
+	spare []byte // 发送中的缓冲区，发送完后重复使用
+	timer *time.Timer
+	armed bool // timer 已经启动，还没有触发
+	err   error
+}
+
+// BatchOption 创建 BatchedWriter 时的可选配置
+type BatchOption func(bw *BatchedWriter)
+
+// WithBatchSize 设置缓存的数据达到多少字节时立即发送，默认为 DefaultBatchSize
+func WithBatchSize(n int) BatchOption {
+	return func(bw *BatchedWriter) {
+		bw.size = n
+	}
+}
+
+// WithBatchDelay 设置第一个消息最多等待多久就发送，默认为 DefaultBatchDelay。
+// d 为 0 时不自动发送，只在达到 BatchSize 或者调用 Flush 时发送。
+func WithBatchDelay(d time.Duration) BatchOption {
+	return func(bw *BatchedWriter) {
+		bw.delay = d
+	}
+}
+
+// WithBatchFrameCodec 设置 WriteFrame 使用的封包方式，默认与 Encode 相同
+func WithBatchFrameCodec(fc FrameCodec) BatchOption {
+	return func(bw *BatchedWriter) {
+		bw.codec = fc
+	}
+}
+
+// NewBatchedWriter 创建一个向 w 批量写入的 BatchedWriter，w 一般是 net.Conn
+func NewBatchedWriter(w io.Writer, opts ...BatchOption) *BatchedWriter {
+	bw := &BatchedWriter{
+		w:     w,
+		codec: defaultFramer,
+		size:  DefaultBatchSize,
+		delay: DefaultBatchDelay,
+	}
+	for _, opt := range opts {
+		opt(bw)
+	}
+	return bw
+}
+
+// WriteFrame 把 payload 封包后加入等待发送的数据
+func (bw *BatchedWriter) WriteFrame(payload []byte) error {
+	return bw.codec.WriteFrame(bw, payload)
+}
+
+// Write 把 p 加入等待发送的数据，p 在返回后就可以修改。
+// 之前的发送失败时返回同一个错误，之后的数据都不会再发送。
+func (bw *BatchedWriter) Write(p []byte) (int, error) {
+	if len(p) >= largeWriteSize {
+		if err := bw.flush(p); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	bw.mu.Lock()
+	if bw.err != nil {
+		bw.mu.Unlock()
+		return 0, bw.err
+	}
+	bw.buf = append(bw.buf, p...)
+	full := len(bw.buf) >= bw.size
+	if !full && !bw.armed && bw.delay > 0 {
+		bw.armed = true
+		if bw.timer == nil {
+			bw.timer = time.AfterFunc(bw.delay, func() { bw.flush(nil) })
+		} else {
+			bw.timer.Reset(bw.delay)
+		}
+	}
+	bw.mu.Unlock()
+
+	if full {
+		if err := bw.flush(nil); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush 立即发送缓存的数据，返回时数据已经写入 w
+func (bw *BatchedWriter) Flush() error {
+	return bw.flush(nil)
+}
+
+// Close 发送缓存的数据并停止定时发送，不会关闭 w。之后的 Write 返回 net.ErrClosed。
+func (bw *BatchedWriter) Close() error {
+	err := bw.flush(nil)
+	if err == net.ErrClosed {
+		return nil
+	}
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	if bw.timer != nil {
+		bw.timer.Stop()
+	}
+	if bw.err == nil {
+		bw.err = net.ErrClosed
+	}
+	return err
+}
+
+// flush 把缓存的数据和 extra 一起发送，写 w 的时候不持有 mu，不会阻塞其他 goroutine 的 Write
+func (bw *BatchedWriter) flush(extra []byte) error {
+	bw.wmu.Lock()
+	defer bw.wmu.Unlock()
+
+	bw.mu.Lock()
+	if bw.err != nil {
+		bw.mu.Unlock()
+		return bw.err
+	}
+	buf := bw.buf
+	bw.buf, bw.spare = bw.spare[:0], nil
+	if bw.armed {
+		bw.armed = false
+		bw.timer.Stop()
+	}
+	bw.mu.Unlock()
+
+	var err error
+	switch {
+	case len(extra) == 0:
+		if len(buf) > 0 {
+			_, err = bw.w.Write(buf)
+		}
+	case len(buf) == 0:
+		_, err = bw.w.Write(extra)
+	default:
+		bufs := net.Buffers{buf, extra}
+		_, err = bufs.WriteTo(bw.w)
+	}
+
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	bw.spare = buf
+	if err != nil && bw.err == nil {
+		bw.err = err
+	}
+	return err
+}
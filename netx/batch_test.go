@@ -0,0 +1,189 @@
+package netx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countWriter 记录每次 Write 写入的数据
+type countWriter struct {
+	mu     sync.Mutex
+	writes [][]byte
+	err    error
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.writes = append(w.writes, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func (w *countWriter) result() (int, []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.writes), bytes.Join(w.writes, nil)
+}
+
+func TestBatchedWriter(t *testing.T) {
+	w := &countWriter{}
+	bw := NewBatchedWriter(w, WithBatchSize(100), WithBatchDelay(0))
+	for i := 0; i < 9; i++ {
+		bw.Write([]byte("0123456789"))
+	}
+	if n, _ := w.result(); n != 0 {
+		t.Fatalf("%d writes before batch is full, want 0", n)
+	}
+	bw.Write([]byte("0123456789"))
+	if n, data := w.result(); n != 1 || len(data) != 100 {
+		t.Fatalf("got %d writes of %d bytes after batch is full, want 1 write of 100 bytes", n, len(data))
+	}
+
+	// 大消息和缓存的数据一起发送，顺序不变
+	bw.Write([]byte("head"))
+	big := bytes.Repeat([]byte{'x'}, largeWriteSize)
+	bw.Write(big)
+	bw.Write([]byte("tail"))
+	bw.Flush()
+	want := bytes.Repeat([]byte("0123456789"), 10)
+	want = append(append(append(want, "head"...), big...), "tail"...)
+	if _, data := w.result(); !bytes.Equal(data, want) {
+		t.Errorf("written data = %d bytes, want %d bytes in order", len(data), len(want))
+	}
+
+	bw.Close()
+	if _, err := bw.Write([]byte("a")); err != net.ErrClosed {
+		t.Errorf("Write() after Close = %v, want %v", err, net.ErrClosed)
+	}
+}
+
+func TestBatchedWriterDelay(t *testing.T) {
+	w := &countWriter{}
+	bw := NewBatchedWriter(w, WithBatchDelay(10*time.Millisecond))
+	defer bw.Close()
+	for round := 1; round <= 2; round++ {
+		bw.Write([]byte("a"))
+		bw.Write([]byte("b"))
+		deadline := time.Now().Add(time.Second)
+		for n, _ := w.result(); n < round; n, _ = w.result() {
+			if time.Now().After(deadline) {
+				t.Fatalf("round %d: pending data was not flushed after delay", round)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if n, data := w.result(); n != 2 || string(data) != "abab" {
+		t.Errorf("got %d writes %q, want 2 writes %q", n, data, "abab")
+	}
+}
+
+func TestBatchedWriterError(t *testing.T) {
+	errWrite := errors.New("write failed")
+	w := &countWriter{err: errWrite}
+	bw := NewBatchedWriter(w, WithBatchDelay(0))
+	bw.Write([]byte("a"))
+	if err := bw.Flush(); err != errWrite {
+		t.Fatalf("Flush() = %v, want %v", err, errWrite)
+	}
+	if _, err := bw.Write([]byte("b")); err != errWrite {
+		t.Errorf("Write() after failure = %v, want %v", err, errWrite)
+	}
+}
+
+func TestBatchedWriterFrames(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	bw := NewBatchedWriter(client, WithBatchDelay(time.Millisecond))
+
+	const n = 100
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				bw.WriteFrame([]byte(strconv.Itoa(g*n + i)))
+			}
+		}(g)
+	}
+	go func() {
+		wg.Wait()
+		bw.Close()
+		client.Close()
+	}()
+
+	seen := make(map[string]bool)
+	r := bufio.NewReader(server)
+	for {
+		msg, err := Decode(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[string(msg)] = true
+	}
+	if len(seen) != 4*n {
+		t.Errorf("received %d distinct frames, want %d", len(seen), 4*n)
+	}
+}
+
+// BenchmarkBatchedWriter 比较逐个写入和合并写入 tcp 连接的耗时，每次写入一个 32 字节的消息
+func BenchmarkBatchedWriter(b *testing.B) {
+	msg := make([]byte, 32)
+	b.Run("direct", func(b *testing.B) {
+		conn := discardConn(b)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := defaultFramer.WriteFrame(conn, msg); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("batched", func(b *testing.B) {
+		bw := NewBatchedWriter(discardConn(b))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := bw.WriteFrame(msg); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := bw.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
+
+// discardConn 建立一个 tcp 连接，对方读取并丢弃所有数据
+func discardConn(b *testing.B) net.Conn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		io.Copy(io.Discard, c)
+	}()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { conn.Close() })
+	return conn
+}
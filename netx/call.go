@@ -0,0 +1,286 @@
+package netx
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"gopractice/netx/workerpool"
+	"io"
+	"sync"
+)
+
+// 请求和响应的消息前面加上 8 字节的请求 ID（小端序）和 1 字节的状态，
+// 客户端按请求 ID 匹配响应，所以一个连接上可以同时有多个请求，服务端可以按任意顺序返回。
+// 客户端放弃等待时可以发送状态为 callCancel、没有内容的消息，服务端取消对应请求的 ctx，不再返回响应。
+const (
+	callHeaderSize = 9
+
+	callOK     byte = 0
+	callError  byte = 1
+	callCancel byte = 2
+)
+
+var (
+	// ErrClientClosed CallClient 已经关闭，或者连接已经断开
+	ErrClientClosed = errors.New("netx: client closed")
+	// errInvalidCall 消息长度不足以放下请求 ID 和状态
+	errInvalidCall = errors.New("netx: invalid call frame")
+)
+
+// RemoteError 服务端的 FrameHandler 返回的错误
+type RemoteError struct {
+	Msg string
+}
+
+func (e *RemoteError) Error() string {
+	return "netx: remote error: " + e.Msg
+}
+
+type callResult struct {
+	resp []byte
+	err  error
+}
+
+// CallClient 在一个连接上并发地发送请求并等待各自的响应，服务端需要使用 HandleCalls
+// 可以在多个 goroutine 中同时调用 Call。
+type CallClient struct {
+	c *Client
+
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan callResult
+	err     error // 连接断开的原因，不为 nil 之后不再接受新的请求
+
+	sendCancel bool
+}
+
+// CallOption 创建 CallClient 时的可选配置
+type CallOption func(cc *CallClient)
+
+// WithCallCancel Call 的 ctx 结束时通知服务端取消这个请求，服务端处理函数的 ctx 随之结束。
+// 服务端需要使用这个版本的 HandleCalls，旧版本会把取消消息当作一个新的请求。
+func WithCallCancel() CallOption {
+	return func(cc *CallClient) {
+		cc.sendCancel = true
+	}
+}
+
+// NewCallClient 在 c 上创建 CallClient，之后 c 上的消息都由 CallClient 读取，不要再直接调用 c.Recv
+func NewCallClient(c *Client, opts ...CallOption) *CallClient {
+	cc := &CallClient{
+		c:       c,
+		pending: make(map[uint64]chan callResult),
+	}
+	for _, opt := range opts {
+		opt(cc)
+	}
+	go cc.readLoop()
+	return cc
+}
+
+// Call 发送请求并等待响应。ctx 结束（超时或者取消）时立即返回 ctx.Err()，
+// 例如超时返回 context.DeadlineExceeded，之后到达的响应会被丢弃，连接和其他请求不受影响。
+// 设置了 WithCallCancel 时还会通知服务端取消这个请求。
+func (cc *CallClient) Call(ctx context.Context, payload []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ch := make(chan callResult, 1)
+	cc.mu.Lock()
+	if cc.err != nil {
+		cc.mu.Unlock()
+		return nil, cc.err
+	}
+	cc.nextID++
+	id := cc.nextID
+	cc.pending[id] = ch
+	cc.mu.Unlock()
+
+	if err := cc.c.Send(appendCallHeader(make([]byte, 0, callHeaderSize+len(payload)), id, callOK, payload)); err != nil {
+		cc.forget(id)
+		return nil, err
+	}
+
+	select {
+	case r := <-ch:
+		return r.resp, r.err
+	case <-ctx.Done():
+		if cc.forget(id) && cc.sendCancel {
+			// 只是通知，发送失败时连接已经断开，readLoop 会处理
+			cc.c.Send(appendCallHeader(make([]byte, 0, callHeaderSize), id, callCancel, nil))
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Close 关闭连接，等待中的 Call 返回 ErrClientClosed
+func (cc *CallClient) Close() error {
+	cc.fail(ErrClientClosed)
+	return cc.c.Close()
+}
+
+func (cc *CallClient) readLoop() {
+	for {
+		msg, err := cc.c.Recv()
+		if err == io.EOF {
+			// 对方正常关闭了连接
+			cc.fail(ErrClientClosed)
+			return
+		}
+		if err != nil {
+			cc.fail(err)
+			return
+		}
+		id, status, body, err := parseCallHeader(msg)
+		if err != nil {
+			cc.fail(err)
+			cc.c.Close()
+			return
+		}
+
+		cc.mu.Lock()
+		ch, ok := cc.pending[id]
+		delete(cc.pending, id)
+		cc.mu.Unlock()
+		if !ok {
+			// 请求已经超时或者被取消
+			continue
+		}
+		if status == callError {
+			ch <- callResult{err: &RemoteError{Msg: string(body)}}
+		} else {
+			ch <- callResult{resp: body}
+		}
+	}
+}
+
+// fail 让所有等待中的 Call 返回 err，之后的 Call 也直接返回 err
+func (cc *CallClient) fail(err error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.err == nil {
+		cc.err = err
+	}
+	for id, ch := range cc.pending {
+		ch <- callResult{err: cc.err}
+		delete(cc.pending, id)
+	}
+}
+
+// forget 不再等待 id 的响应，返回 id 是否还在等待中
+func (cc *CallClient) forget(id uint64) bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	_, ok := cc.pending[id]
+	delete(cc.pending, id)
+	return ok
+}
+
+// HandleCalls 返回一个处理 CallClient 请求的 Handler，每个请求在单独的 goroutine 中交给 h 处理，
+// h 返回的错误会作为 RemoteError 返回给客户端，不会关闭连接。
+// 客户端取消请求时（见 WithCallCancel）h 的 ctx 结束，h 返回后不再发送响应。
+func HandleCalls(h FrameHandler) Handler {
+	return handleCalls(h, func(task func()) error {
+		go task()
+		return nil
+	})
+}
+
+// HandleCallsPool 与 HandleCalls 相同，但请求交给 pool 中的 worker 处理，
+// pool 的队列满时停止读取新的请求，见 HandleFramesPool
+func HandleCallsPool(h FrameHandler, pool *workerpool.Pool) Handler {
+	return handleCalls(h, pool.Submit)
+}
+
+// handleCalls 读取请求并通过 submit 并发处理，submit 失败时关闭连接
+func handleCalls(h FrameHandler, submit func(task func()) error) Handler {
+	return HandlerFunc(func(ctx context.Context, conn *Conn) {
+		ctx = withCodec(ctx, conn.client.values)
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		var calls activeCalls
+		for {
+			msg, err := conn.Recv(ctx)
+			if err != nil {
+				return
+			}
+			id, status, req, err := parseCallHeader(msg)
+			if err != nil {
+				return
+			}
+			if status == callCancel {
+				calls.cancel(id)
+				continue
+			}
+
+			reqCtx, cancel := context.WithCancel(ctx)
+			calls.add(id, cancel)
+			wg.Add(1)
+			err = submit(func() {
+				defer wg.Done()
+				defer calls.done(id, cancel)
+				status := callOK
+				resp, err := h.HandleFrame(reqCtx, req)
+				if reqCtx.Err() != nil && ctx.Err() == nil {
+					// 客户端已经放弃等待
+					return
+				}
+				if err != nil {
+					status, resp = callError, []byte(err.Error())
+				}
+				conn.Send(appendCallHeader(make([]byte, 0, callHeaderSize+len(resp)), id, status, resp))
+			})
+			if err != nil {
+				calls.done(id, cancel)
+				wg.Done()
+				return
+			}
+		}
+	})
+}
+
+// activeCalls 服务端正在处理的请求，用来响应客户端的取消消息
+type activeCalls struct {
+	mu      sync.Mutex
+	cancels map[uint64]context.CancelFunc
+}
+
+func (a *activeCalls) add(id uint64, cancel context.CancelFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cancels == nil {
+		a.cancels = make(map[uint64]context.CancelFunc)
+	}
+	a.cancels[id] = cancel
+}
+
+func (a *activeCalls) cancel(id uint64) {
+	a.mu.Lock()
+	cancel := a.cancels[id]
+	a.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+func (a *activeCalls) done(id uint64, cancel context.CancelFunc) {
+	cancel()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.cancels, id)
+}
+
+func appendCallHeader(dst []byte, id uint64, status byte, payload []byte) []byte {
+	var h [callHeaderSize]byte
+	binary.LittleEndian.PutUint64(h[:], id)
+	h[8] = status
+	dst = append(dst, h[:]...)
+	return append(dst, payload...)
+}
+
+func parseCallHeader(msg []byte) (id uint64, status byte, body []byte, err error) {
+	if len(msg) < callHeaderSize {
+		return 0, 0, nil, errInvalidCall
+	}
+	return binary.LittleEndian.Uint64(msg), msg[8], msg[callHeaderSize:], nil
+}
@@ -0,0 +1,130 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func startCallServer(t *testing.T) *CallClient {
+	t.Helper()
+	h := FrameHandlerFunc(func(ctx context.Context, req []byte) ([]byte, error) {
+		switch s := string(req); {
+		case s == "fail":
+			return nil, errors.New("bad request")
+		case s == "block":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		default:
+			// 数字越小处理得越慢，让响应乱序返回
+			n, _ := strconv.Atoi(s)
+			time.Sleep(time.Duration(10-n%10) * time.Millisecond)
+			return []byte("re:" + s), nil
+		}
+	})
+	addr := startTCPServer(t, NewTCPServer("", HandleCalls(h)))
+	c, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := NewCallClient(c)
+	t.Cleanup(func() { cc.Close() })
+	return cc
+}
+
+func TestCallClientConcurrent(t *testing.T) {
+	cc := startCallServer(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := strconv.Itoa(i)
+			resp, err := cc.Call(context.Background(), []byte(req))
+			if err != nil || string(resp) != "re:"+req {
+				t.Errorf("Call(%s) = %q, %v", req, resp, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCallClientErrors(t *testing.T) {
+	cc := startCallServer(t)
+
+	_, err := cc.Call(context.Background(), []byte("fail"))
+	var re *RemoteError
+	if !errors.As(err, &re) || re.Msg != "bad request" {
+		t.Errorf("Call(fail) = %v, want RemoteError", err)
+	}
+
+	// 超时的请求不影响之后的请求
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := cc.Call(ctx, []byte("block")); err != context.DeadlineExceeded {
+		t.Errorf("Call(block) = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if resp, err := cc.Call(context.Background(), []byte("7")); err != nil || string(resp) != "re:7" {
+		t.Errorf("Call(7) = %q, %v", resp, err)
+	}
+
+	// 关闭之后等待中的请求和新的请求都返回 ErrClientClosed
+	errc := make(chan error, 1)
+	go func() {
+		_, err := cc.Call(context.Background(), []byte("block"))
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cc.Close()
+	for i, err := range []error{<-errc, callErr(cc)} {
+		if err != ErrClientClosed {
+			t.Errorf("%d: Call() after Close = %v, want %v", i, err, ErrClientClosed)
+		}
+	}
+}
+
+func callErr(cc *CallClient) error {
+	_, err := cc.Call(context.Background(), []byte("1"))
+	return err
+}
+
+// 设置 WithCallCancel 时超时的请求会通知服务端，服务端处理函数的 ctx 结束
+func TestCallClientCancel(t *testing.T) {
+	canceled := make(chan string, 1)
+	h := FrameHandlerFunc(func(ctx context.Context, req []byte) ([]byte, error) {
+		if string(req) != "block" {
+			return req, nil
+		}
+		<-ctx.Done()
+		canceled <- string(req)
+		return nil, ctx.Err()
+	})
+	addr := startTCPServer(t, NewTCPServer("", HandleCalls(h)))
+	c, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := NewCallClient(c, WithCallCancel())
+	defer cc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := cc.Call(ctx, []byte("block")); err != context.DeadlineExceeded {
+		t.Errorf("Call(block) = %v, want %v", err, context.DeadlineExceeded)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("server handler was not canceled")
+	}
+	if resp, err := cc.Call(context.Background(), []byte("ok")); err != nil || string(resp) != "ok" {
+		t.Errorf("Call(ok) = %q, %v", resp, err)
+	}
+	// ctx 已经结束时不发送请求
+	if _, err := cc.Call(ctx, []byte("ok")); err != context.DeadlineExceeded {
+		t.Errorf("Call() with expired ctx = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,320 @@
+package netx
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"gopractice/logx"
+	"gopractice/netx/codec"
+	"gopractice/netx/metrics"
+	"gopractice/netx/ratelimit"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrCloseWriteUnsupported 连接不支持半关闭，例如 net.Pipe 返回的连接
+	ErrCloseWriteUnsupported = errors.New("netx: connection does not support CloseWrite")
+	// ErrWriteClosed CloseWrite 之后继续发送消息
+	ErrWriteClosed = errors.New("netx: write after CloseWrite")
+)
+
+// Client tcp 客户端，按 FrameCodec 的格式收发消息
+// Send 可以在多个 goroutine 中同时调用，Recv 同一时间只能有一个 goroutine 调用。
+type Client struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	codec       FrameCodec
+	metrics     metrics.Collector
+	tracer      logx.Logger
+	frameTracer Tracer
+	timeouts    timeouts
+	heartbeat   heartbeat
+	frames      *ratelimit.Limiter
+	values      codec.Codec
+	events      *connEvents
+
+	wmu       sync.Mutex // 保证一个消息完整地写入，不会和心跳消息交错
+	wclosed   bool       // 已经调用过 CloseWrite，由 wmu 保护
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// ClientOption 创建 Client 时的可选配置
+type ClientOption func(c *Client)
+
+// WithFrameCodec 设置封包方式，默认使用小端序 4 字节长度头的 Framer，需要与服务端一致
+func WithFrameCodec(fc FrameCodec) ClientOption {
+	return func(c *Client) {
+		c.codec = fc
+	}
+}
+
+// WithCodec 设置 SendValue、RecvValue 和 SendMsg 使用的消息内容编码，需要与服务端一致。
+// 默认为 codec.JSON，在 Server 的 Handler 中使用时默认为 Server.Codec。
+func WithCodec(c codec.Codec) ClientOption {
+	return func(cl *Client) {
+		cl.values = c
+	}
+}
+
+// Dial 连接 tcp 服务端
+func Dial(addr string, opts ...ClientOption) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, opts...), nil
+}
+
+// DialContext 与 Dial 相同，ctx 结束时放弃连接
+func DialContext(ctx context.Context, addr string, opts ...ClientOption) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, opts...), nil
+}
+
+// ContextDialer 建立连接的方式，net.Dialer 和 socks5.Dialer 都实现了它
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// DialVia 与 DialContext 相同，通过 d 建立连接，例如经过 SOCKS5 代理
+func DialVia(ctx context.Context, d ContextDialer, addr string, opts ...ClientOption) (*Client, error) {
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, opts...), nil
+}
+
+// NewClient 使用已经建立的连接创建客户端
+// 在 Server 的 Handler 中使用时，收发的消息数会上报到 Server.Metrics，
+// 设置了 Server.Trace 时还会记录每个消息的跟踪日志，Server 的超时配置也会在收发消息时生效。
+func NewClient(conn net.Conn, opts ...ClientOption) *Client {
+	c := &Client{
+		conn:        conn,
+		reader:      bufio.NewReader(conn),
+		codec:       defaultFramer,
+		metrics:     collectorOf(conn),
+		tracer:      tracerOf(conn),
+		frameTracer: frameTracerOf(conn),
+		timeouts:    timeoutsOf(conn),
+		heartbeat:   heartbeatOf(conn),
+		frames:      frameLimiterOf(conn),
+		values:      codecOf(conn),
+		events:      eventsOf(conn),
+		done:        make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.heartbeat.ping {
+		go c.pingLoop()
+	}
+	return c
+}
+
+// Send 发送一个消息
+func (c *Client) Send(msg []byte) error {
+	frame := msg
+	if c.heartbeat.enabled() {
+		frame = append([]byte{frameData}, msg...)
+	}
+	if err := c.writeFrame(frame); err != nil {
+		return err
+	}
+	c.metrics.IncCounter(metrics.FramesWritten)
+	traceFrame(c.tracer, "send", msg)
+	if c.frameTracer != nil {
+		c.frameTracer.TraceFrame(c.conn, DirSend, msg)
+	}
+	return nil
+}
+
+// Recv 读取一个完整的消息
+// 对方关闭了写方向（CloseWrite 或者 Close）并且已经读完所有消息时返回 io.EOF；
+// 在消息中途断开时返回 io.ErrUnexpectedEOF，其他错误表示连接出了问题。
+func (c *Client) Recv() ([]byte, error) {
+	fr, err := c.recv(context.Background(), false)
+	return fr.B, err
+}
+
+// RecvContext 与 Recv 相同，ctx 结束时立即返回 ctx.Err()，之后连接无法再读取，见 DecodeContext
+func (c *Client) RecvContext(ctx context.Context) ([]byte, error) {
+	fr, err := readContext(ctx, c.conn, func() (Frame, error) {
+		return c.recv(ctx, false)
+	})
+	return fr.B, err
+}
+
+// RecvFrame 与 RecvContext 相同，但 FrameCodec 为 *Framer 时消息的内存从池中租借，
+// 可以减少大量收发消息时的内存分配。处理完消息后必须调用 Frame.Release，之后不能再访问 Frame.B。
+func (c *Client) RecvFrame(ctx context.Context) (Frame, error) {
+	return readContext(ctx, c.conn, func() (Frame, error) {
+		return c.recv(ctx, true)
+	})
+}
+
+// frameLeaser 可以从池中租借消息内存的 FrameCodec
+type frameLeaser interface {
+	ReadFrameLease(r *bufio.Reader) (Frame, error)
+}
+
+func (c *Client) readFrame(pooled bool) (Frame, error) {
+	if fl, ok := c.codec.(frameLeaser); ok && pooled {
+		return fl.ReadFrameLease(c.reader)
+	}
+	b, err := c.codec.ReadFrame(c.reader)
+	return Frame{B: b}, err
+}
+
+func (c *Client) recv(ctx context.Context, pooled bool) (Frame, error) {
+	var fr Frame
+	for fr.B == nil {
+		if err := c.waitFrame(ctx); err != nil {
+			return Frame{}, err
+		}
+		var err error
+		fr, err = c.readFrame(pooled)
+		if err != nil {
+			if isDecodeError(err) {
+				c.metrics.IncCounter(metrics.DecodeErrors)
+			}
+			return Frame{}, c.timeoutError(ctx, err, ErrReadTimeout)
+		}
+		if !c.heartbeat.enabled() {
+			break
+		}
+		msg, err := c.unwrapFrame(fr.B)
+		if err != nil || msg == nil {
+			fr.Release()
+			if err != nil {
+				return Frame{}, err
+			}
+		}
+		fr.B = msg
+	}
+	if c.frames != nil && !c.frames.Allow(1) {
+		fr.Release()
+		return Frame{}, ErrFrameRateExceeded
+	}
+	c.metrics.IncCounter(metrics.FramesRead)
+	traceFrame(c.tracer, "recv", fr.B)
+	if c.frameTracer != nil {
+		c.frameTracer.TraceFrame(c.conn, DirRecv, fr.B)
+	}
+	c.events.frameReceived(len(fr.B))
+	return fr, nil
+}
+
+// RecvAll 读取消息直到对方关闭写方向，对方正常结束时返回收到的所有消息和 nil，
+// 出错时返回已经收到的消息和错误。ctx 结束时返回 ctx.Err()，见 RecvContext。
+func (c *Client) RecvAll(ctx context.Context) ([][]byte, error) {
+	var msgs [][]byte
+	for {
+		msg, err := c.RecvContext(ctx)
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+// SendValue 用 Codec 编码 v 后发送
+func (c *Client) SendValue(v any) error {
+	b, err := c.values.Encode(v)
+	if err != nil {
+		return err
+	}
+	return c.Send(b)
+}
+
+// RecvValue 读取一个消息，并用 Codec 解码到 v 中
+func (c *Client) RecvValue(v any) error {
+	b, err := c.Recv()
+	if err != nil {
+		return err
+	}
+	return c.values.Decode(b, v)
+}
+
+// SendMsg 用 Codec 编码 v，加上消息类型 typ 后发送，由服务端的 Router 分发
+func (c *Client) SendMsg(typ uint16, v any) error {
+	b, err := c.values.Encode(v)
+	if err != nil {
+		return err
+	}
+	return c.Send(AppendMsg(make([]byte, 0, MsgTypeSize+len(b)), typ, b))
+}
+
+// RecvMsg 读取一个 Router 回复的消息，返回消息类型，并用 Codec 把内容解码到 v 中
+func (c *Client) RecvMsg(v any) (uint16, error) {
+	b, err := c.Recv()
+	if err != nil {
+		return 0, err
+	}
+	typ, payload, err := SplitMsg(b)
+	if err != nil {
+		return 0, err
+	}
+	return typ, c.values.Decode(payload, v)
+}
+
+// Conn 返回底层的连接
+func (c *Client) Conn() net.Conn {
+	return c.conn
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.conn.Close()
+}
+
+// CloseWrite 关闭连接的写方向，对方读完已经发送的消息后 Recv 返回 io.EOF，本端仍然可以继续 Recv。
+// 先发送一批请求、再读取全部回复时，用它告诉对方请求已经发完，不需要约定结束消息或者等待固定的时间。
+// 之后 Send 返回 ErrWriteClosed，开启心跳时也不再发送 PING。
+func (c *Client) CloseWrite() error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.wclosed {
+		return nil
+	}
+	if err := closeWrite(c.conn); err != nil {
+		return err
+	}
+	c.wclosed = true
+	return nil
+}
+
+// closeWrite 关闭 conn 的写方向，Server 包装过的连接会转给底层的连接
+func closeWrite(conn net.Conn) error {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return ErrCloseWriteUnsupported
+}
+
+func (c *Client) writeFrame(frame []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.wclosed {
+		return ErrWriteClosed
+	}
+	if c.timeouts.write > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.timeouts.write))
+	}
+	if err := c.codec.WriteFrame(c.conn, frame); err != nil {
+		return c.timeoutError(context.Background(), err, ErrWriteTimeout)
+	}
+	return nil
+}
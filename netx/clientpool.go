@@ -0,0 +1,243 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed ClientPool 已经关闭
+var ErrPoolClosed = errors.New("netx: client pool closed")
+
+// DefaultMaxIdle ClientPool.MaxIdle 为 0 时最多保留的空闲连接数
+const DefaultMaxIdle = 2
+
+// ClientPool 客户端连接池，复用已经建立的连接，避免每个请求都重新建立连接
+// Get 取出的 Client 用完之后要调用 Put 归还；连接出错时调用 Discard 丢弃，不要再归还。
+type ClientPool struct {
+	// Dial 建立新连接
+	Dial func(ctx context.Context) (*Client, error)
+
+	// MaxIdle 最多保留的空闲连接数，为 0 时使用 DefaultMaxIdle，小于 0 时不保留空闲连接
+	MaxIdle int
+
+	// MaxOpen 最多同时打开的连接数（包括空闲的连接），达到上限时 Get 等待其他连接归还，0 表示不限制
+	MaxOpen int
+
+	// IdleTimeout 连接空闲超过这个时间后不再复用，0 表示不限制
+	IdleTimeout time.Duration
+
+	// HealthCheck 复用空闲连接之前调用，返回错误时丢弃这个连接，为 nil 时使用 CheckConn
+	HealthCheck func(c *Client) error
+
+	mu      sync.Mutex
+	idle    []idleClient
+	open    int
+	waiters []chan struct{}
+	closed  bool
+}
+
+type idleClient struct {
+	c     *Client
+	since time.Time
+}
+
+// NewClientPool 返回一个连接 addr 的连接池，opts 用于创建每个 Client
+func NewClientPool(addr string, opts ...ClientOption) *ClientPool {
+	return &ClientPool{
+		Dial: func(ctx context.Context) (*Client, error) {
+			return DialContext(ctx, addr, opts...)
+		},
+	}
+}
+
+// Get 取出一个连接，优先复用空闲的连接
+// 连接数达到 MaxOpen 时等待其他连接归还，ctx 结束时返回 ctx.Err()。
+func (p *ClientPool) Get(ctx context.Context) (*Client, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrPoolClosed
+		}
+
+		if c, ok := p.popIdle(); ok {
+			p.mu.Unlock()
+			if err := p.check(c); err != nil {
+				p.Discard(c)
+				continue
+			}
+			return c, nil
+		}
+
+		if p.MaxOpen <= 0 || p.open < p.MaxOpen {
+			p.open++
+			p.mu.Unlock()
+			c, err := p.Dial(ctx)
+			if err != nil {
+				p.release()
+				return nil, err
+			}
+			return c, nil
+		}
+
+		// 等待 Put 或者 Discard 唤醒
+		wait := make(chan struct{})
+		p.waiters = append(p.waiters, wait)
+		p.mu.Unlock()
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			p.mu.Lock()
+			if !p.removeWaiter(wait) {
+				// 已经被唤醒了，把机会让给下一个等待者
+				p.notify()
+			}
+			p.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Put 归还一个连接，空闲连接数超过 MaxIdle 或者连接池已关闭时直接关闭连接
+func (p *ClientPool) Put(c *Client) {
+	p.mu.Lock()
+	if p.closed || len(p.idle) >= p.maxIdle() {
+		p.open--
+		p.notify()
+		p.mu.Unlock()
+		c.Close()
+		return
+	}
+	p.idle = append(p.idle, idleClient{c: c, since: time.Now()})
+	p.notify()
+	p.mu.Unlock()
+}
+
+// Discard 关闭一个出错的连接，让出它占用的 MaxOpen 名额
+func (p *ClientPool) Discard(c *Client) {
+	c.Close()
+	p.release()
+}
+
+// Close 关闭连接池和所有空闲连接，正在使用的连接在 Put 时关闭
+func (p *ClientPool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.open -= len(idle)
+	for _, w := range p.waiters {
+		close(w)
+	}
+	p.waiters = nil
+	p.mu.Unlock()
+
+	for _, ic := range idle {
+		ic.c.Close()
+	}
+	return nil
+}
+
+// Stats 返回打开的连接数和其中空闲的连接数
+func (p *ClientPool) Stats() (open, idle int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.open, len(p.idle)
+}
+
+// CheckConn 检查空闲连接是否还可用：对方已经关闭连接，或者连接上有未读取的数据时返回错误
+// 开启心跳时空闲期间收到的 PING、PONG 会被读取并处理，不算未读取的数据。
+// 只会等待 1 毫秒，不需要服务端配合；需要真正的请求往返时设置 ClientPool.HealthCheck。
+func CheckConn(c *Client) error {
+	c.conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	defer c.conn.SetReadDeadline(time.Time{})
+	for {
+		_, err := c.reader.Peek(1)
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return nil
+			}
+			return err
+		}
+		if !c.heartbeat.enabled() {
+			return errUnexpectedData
+		}
+		// 读到一半超时的消息已经无法恢复，和其他读取错误一样让连接池丢弃连接
+		b, err := c.codec.ReadFrame(c.reader)
+		if err != nil {
+			return err
+		}
+		msg, err := c.unwrapFrame(b)
+		if err != nil {
+			return err
+		}
+		if msg != nil {
+			return errUnexpectedData
+		}
+	}
+}
+
+var errUnexpectedData = errors.New("netx: unexpected data on idle connection")
+
+// popIdle 取出最近归还的空闲连接，顺便关闭已经过期的连接，调用时需要持有 p.mu
+func (p *ClientPool) popIdle() (*Client, bool) {
+	for len(p.idle) > 0 {
+		ic := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if p.IdleTimeout > 0 && time.Since(ic.since) > p.IdleTimeout {
+			p.open--
+			ic.c.Close()
+			continue
+		}
+		return ic.c, true
+	}
+	return nil, false
+}
+
+func (p *ClientPool) check(c *Client) error {
+	if p.HealthCheck != nil {
+		return p.HealthCheck(c)
+	}
+	return CheckConn(c)
+}
+
+// release 减少打开的连接数并唤醒一个等待者
+func (p *ClientPool) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.open--
+	p.notify()
+}
+
+// notify 唤醒最早的等待者，调用时需要持有 p.mu
+func (p *ClientPool) notify() {
+	if len(p.waiters) == 0 {
+		return
+	}
+	close(p.waiters[0])
+	p.waiters = p.waiters[1:]
+}
+
+func (p *ClientPool) removeWaiter(wait chan struct{}) bool {
+	for i, w := range p.waiters {
+		if w == wait {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *ClientPool) maxIdle() int {
+	if p.MaxIdle == 0 {
+		return DefaultMaxIdle
+	}
+	if p.MaxIdle < 0 {
+		return 0
+	}
+	return p.MaxIdle
+}
@@ -0,0 +1,194 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingPool 返回一个统计 Dial 次数的连接池，服务端回显消息
+func countingPool(t *testing.T) (*ClientPool, *int32) {
+	t.Helper()
+	addr := startTCPServer(t, NewTCPServer("", HandleFrames(FrameHandlerFunc(
+		func(ctx context.Context, req []byte) ([]byte, error) {
+			if string(req) == "close" {
+				return nil, errors.New("close")
+			}
+			return req, nil
+		}))))
+	var dials int32
+	p := NewClientPool(addr)
+	dial := p.Dial
+	p.Dial = func(ctx context.Context) (*Client, error) {
+		atomic.AddInt32(&dials, 1)
+		return dial(ctx)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p, &dials
+}
+
+func TestClientPoolReuse(t *testing.T) {
+	p, dials := countingPool(t)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		c, err := p.Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := echo(c, "hello"); err != nil || got != "hello" {
+			t.Fatalf("echo = %q, %v", got, err)
+		}
+		p.Put(c)
+	}
+	if n := atomic.LoadInt32(dials); n != 1 {
+		t.Errorf("dialed %d times, want 1", n)
+	}
+
+	// 超过 MaxIdle 的连接在归还时关闭
+	p.MaxIdle = 1
+	a, _ := p.Get(ctx)
+	b, _ := p.Get(ctx)
+	p.Put(a)
+	p.Put(b)
+	if open, idle := p.Stats(); open != 1 || idle != 1 {
+		t.Errorf("Stats() = %d, %d, want 1, 1", open, idle)
+	}
+}
+
+func TestClientPoolHealthCheck(t *testing.T) {
+	p, dials := countingPool(t)
+	ctx := context.Background()
+	c, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 服务端关闭了连接，归还之后不应该再被复用
+	c.Send([]byte("close"))
+	time.Sleep(20 * time.Millisecond)
+	p.Put(c)
+
+	c, err = p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Put(c)
+	if got, err := echo(c, "fresh"); err != nil || got != "fresh" {
+		t.Errorf("echo = %q, %v", got, err)
+	}
+	if n := atomic.LoadInt32(dials); n != 2 {
+		t.Errorf("dialed %d times, want 2", n)
+	}
+}
+
+// 空闲期间收到的心跳消息不影响连接的复用
+func TestClientPoolHeartbeat(t *testing.T) {
+	interval := 5 * time.Millisecond
+	addr := startHeartbeatEcho(t, interval, make(chan error, 10))
+	var dials int32
+	p := NewClientPool(addr, WithHeartbeat(interval, 0))
+	dial := p.Dial
+	p.Dial = func(ctx context.Context) (*Client, error) {
+		atomic.AddInt32(&dials, 1)
+		return dial(ctx)
+	}
+	defer p.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		c, err := p.Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := echo(c, "hello"); err != nil || got != "hello" {
+			t.Fatalf("echo = %q, %v", got, err)
+		}
+		p.Put(c)
+		time.Sleep(4 * interval)
+	}
+	if n := atomic.LoadInt32(&dials); n != 1 {
+		t.Errorf("dialed %d times, want 1", n)
+	}
+}
+
+func TestClientPoolIdleTimeout(t *testing.T) {
+	p, dials := countingPool(t)
+	p.IdleTimeout = 10 * time.Millisecond
+	ctx := context.Background()
+	c, _ := p.Get(ctx)
+	p.Put(c)
+	time.Sleep(20 * time.Millisecond)
+	c, err := p.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(c)
+	if n := atomic.LoadInt32(dials); n != 2 {
+		t.Errorf("dialed %d times, want 2", n)
+	}
+}
+
+func TestClientPoolMaxOpen(t *testing.T) {
+	p, _ := countingPool(t)
+	p.MaxOpen = 1
+	c, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Get(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Get() = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// 归还之后等待中的 Get 拿到同一个连接
+	got := make(chan *Client, 1)
+	go func() {
+		c, _ := p.Get(context.Background())
+		got <- c
+	}()
+	time.Sleep(10 * time.Millisecond)
+	p.Put(c)
+	select {
+	case c2 := <-got:
+		if c2 != c {
+			t.Error("waiting Get() did not reuse the returned connection")
+		}
+		p.Discard(c2)
+	case <-time.After(time.Second):
+		t.Fatal("waiting Get() was not woken up")
+	}
+	if open, _ := p.Stats(); open != 0 {
+		t.Errorf("open = %d after Discard, want 0", open)
+	}
+}
+
+func TestClientPoolClose(t *testing.T) {
+	p := &ClientPool{
+		MaxOpen: 1,
+		Dial: func(ctx context.Context) (*Client, error) {
+			server, client := net.Pipe()
+			t.Cleanup(func() { server.Close() })
+			return NewClient(client), nil
+		},
+		HealthCheck: func(c *Client) error { return nil },
+	}
+	c, _ := p.Get(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := p.Get(context.Background())
+		errc <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	p.Close()
+	if err := <-errc; err != ErrPoolClosed {
+		t.Errorf("waiting Get() = %v, want %v", err, ErrPoolClosed)
+	}
+	p.Put(c)
+	if err := c.Send([]byte("x")); err == nil {
+		t.Error("Put after Close did not close the connection")
+	}
+}
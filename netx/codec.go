@@ -0,0 +1,104 @@
+package netx
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// 解决粘包问题
+// 出现”粘包”的关键在于接收方不确定将要传输的数据包的大小，因此我们可以对数据包进行封包和拆包的操作。
+// 封包和拆包由 Framer 完成，这里的函数使用默认的 Framer，TCP 和 UDP 共用同一套编码：
+// TCP 通过 bufio.Reader 按流读取，UDP 则在一个数据报中打包一个或多个消息。
+
+// HeaderSize 消息头（长度）占用的字节数
+const HeaderSize = 4
+
+var (
+	// ErrTruncated 数据报中的消息不完整
+	ErrTruncated = errors.New("netx: truncated frame")
+	// ErrInvalidLength 消息头中的长度不合法
+	ErrInvalidLength = errors.New("netx: invalid frame length")
+)
+
+// Encode 编码，返回带长度头的消息
+func Encode(msg []byte) ([]byte, error) {
+	if int64(len(msg)) > maxFrameLen {
+		return nil, ErrInvalidLength
+	}
+	return AppendFrame(make([]byte, 0, HeaderSize+len(msg)), msg), nil
+}
+
+// AppendFrame 把 msg 编码后追加到 dst，可以用来把多个消息打包进同一个 UDP 数据报
+func AppendFrame(dst, msg []byte) []byte {
+	return defaultFramer.AppendFrame(dst, msg)
+}
+
+// Decode 从 reader 中读取一个完整的消息，消息不完整时会一直等待后续的数据
+func Decode(reader *bufio.Reader) ([]byte, error) {
+	return defaultFramer.ReadFrame(reader)
+}
+
+// DecodePacket 解出一个 UDP 数据报中的所有消息
+// 返回的消息引用 b 的内存，不会复制。
+func DecodePacket(b []byte) ([][]byte, error) {
+	return defaultFramer.DecodePacket(b)
+}
+
+// aLongTimeAgo 设置为读超时时间可以让阻塞中的 Read 立即返回
+var aLongTimeAgo = time.Unix(1, 0)
+
+// DecodeContext 与 Decode 相同，reader 需要是 conn 的 bufio.Reader。
+// ctx 结束时把 conn 的读超时设置为过去的时间，让阻塞在读取上的 Decode 立即返回 ctx.Err()，
+// 之后 conn 无法再读取，调用者应当关闭连接。
+func DecodeContext(ctx context.Context, conn net.Conn, reader *bufio.Reader) ([]byte, error) {
+	return readContext(ctx, conn, func() ([]byte, error) {
+		return Decode(reader)
+	})
+}
+
+// readContext 在 ctx 结束时打断阻塞在 conn 上的 read
+func readContext[T any](ctx context.Context, conn net.Conn, read func() (T, error)) (T, error) {
+	if ctx.Done() == nil {
+		return read()
+	}
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	stop := make(chan struct{})
+	watcherDone := make(chan struct{})
+	go func() {
+		defer close(watcherDone)
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(aLongTimeAgo)
+		case <-stop:
+		}
+	}()
+
+	msg, err := read()
+	close(stop)
+	<-watcherDone
+	if err != nil && ctx.Err() != nil {
+		return zero, ctx.Err()
+	}
+	return msg, err
+}
+
+// isDecodeError 判断 err 是否是收到的数据格式不正确引起的，而不是连接本身出错
+func isDecodeError(err error) bool {
+	for _, target := range []error{
+		ErrInvalidLength, ErrTruncated, ErrChecksumMismatch, ErrUnknownCompression,
+		ErrFrameTooLarge, io.ErrUnexpectedEOF,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
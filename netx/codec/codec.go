@@ -0,0 +1,83 @@
+// Package codec 消息内容的编码方式
+// netx 负责把字节流拆分成消息，codec 负责把消息内容转换为 Go 的值，
+// 两者互相独立，同一套封包方式可以搭配 JSON、protobuf 等不同的编码。
+package codec
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrNotProtoMessage Protobuf 编码的值没有实现 proto.Message
+var ErrNotProtoMessage = errors.New("codec: value is not a proto.Message")
+
+// Marshaler 把值编码为消息内容
+type Marshaler interface {
+	Encode(v any) ([]byte, error)
+}
+
+// Unmarshaler 把消息内容解码到 v 中，v 一般是指针
+type Unmarshaler interface {
+	Decode(data []byte, v any) error
+}
+
+// Codec 同时支持编码和解码，收发双方需要使用相同的 Codec
+type Codec interface {
+	Marshaler
+	Unmarshaler
+}
+
+var (
+	// JSON 使用 encoding/json 编码
+	JSON Codec = jsonCodec{}
+	// Protobuf 使用 protobuf 的二进制格式编码，值需要实现 proto.Message
+	Protobuf Codec = protoCodec{}
+	// Gob 使用 encoding/gob 编码，每个消息都带有完整的类型信息，只适合收发双方都是 Go 程序的场景
+	Gob Codec = gobCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Decode(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Decode(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) Encode(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrNotProtoMessage
+	}
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Decode(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return ErrNotProtoMessage
+	}
+	return proto.Unmarshal(data, m)
+}
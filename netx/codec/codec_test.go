@@ -0,0 +1,64 @@
+package codec
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestJSON(t *testing.T) {
+	type dataReq struct {
+		Name string `json:"name"`
+	}
+	b, err := JSON.Encode(dataReq{Name: "kwok"})
+	if err != nil || string(b) != `{"name":"kwok"}` {
+		t.Fatalf("Encode() = %s, %v", b, err)
+	}
+	var got dataReq
+	if err := JSON.Decode(b, &got); err != nil || got.Name != "kwok" {
+		t.Errorf("Decode() = %+v, %v", got, err)
+	}
+}
+
+func TestProtobuf(t *testing.T) {
+	in, _ := structpb.NewStruct(map[string]any{"name": "kwok", "age": 18})
+	b, err := Protobuf.Encode(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(structpb.Struct)
+	if err := Protobuf.Decode(b, out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Fields["name"].GetStringValue() != "kwok" || out.Fields["age"].GetNumberValue() != 18 {
+		t.Errorf("Decode() = %v", out)
+	}
+
+	// 与 JSON 相比更省空间
+	s := wrapperspb.String("kwok")
+	pb, _ := Protobuf.Encode(s)
+	js, _ := JSON.Encode(map[string]string{"value": "kwok"})
+	if len(pb) >= len(js) {
+		t.Errorf("protobuf %d bytes, json %d bytes", len(pb), len(js))
+	}
+
+	if _, err := Protobuf.Encode(struct{}{}); err != ErrNotProtoMessage {
+		t.Errorf("Encode(non-proto) = %v, want %v", err, ErrNotProtoMessage)
+	}
+	if err := Protobuf.Decode(pb, new(string)); err != ErrNotProtoMessage {
+		t.Errorf("Decode(non-proto) = %v, want %v", err, ErrNotProtoMessage)
+	}
+}
+
+func TestGob(t *testing.T) {
+	in := map[string][]int{"kwok": {1, 2, 3}}
+	b, err := Gob.Encode(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string][]int
+	if err := Gob.Decode(b, &out); err != nil || len(out["kwok"]) != 3 || out["kwok"][2] != 3 {
+		t.Errorf("Decode() = %v, %v", out, err)
+	}
+}
@@ -0,0 +1,645 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrUnsupportedType MsgPack 不支持编码或者解码的类型，例如 chan、func 和有方法的接口
+	ErrUnsupportedType = errors.New("codec: unsupported type")
+	// ErrInvalidMsgPack 数据不是合法的 MessagePack，或者与解码的目标类型不匹配
+	ErrInvalidMsgPack = errors.New("codec: invalid msgpack data")
+)
+
+// MsgPack 使用 MessagePack 编码，不依赖第三方库，只实现了常用的类型：
+// nil、bool、整数、浮点数、string、[]byte、slice、array、map 和 struct。
+// struct 按字段名编码为 map，字段名依次取 msgpack tag、json tag，tag 为 "-" 时忽略该字段，
+// 所以为 JSON 定义的结构体可以直接使用。解码到 any 时，整数为 int64（超出范围时为 uint64），
+// 浮点数为 float64，数组为 []any，map 为 map[string]any。
+var MsgPack Codec = msgpackCodec{}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Encode(v any) ([]byte, error) {
+	return appendValue(nil, reflect.ValueOf(v))
+}
+
+func (msgpackCodec) Decode(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("%w: decode into non-pointer %T", ErrUnsupportedType, v)
+	}
+	d := &decoder{b: data}
+	if err := d.decodeValue(rv.Elem()); err != nil {
+		return err
+	}
+	if d.off != len(d.b) {
+		return fmt.Errorf("%w: %d bytes of trailing data", ErrInvalidMsgPack, len(d.b)-d.off)
+	}
+	return nil
+}
+
+// 格式定义见 https://github.com/msgpack/msgpack/blob/master/spec.md
+const (
+	mpNil     = 0xc0
+	mpFalse   = 0xc2
+	mpTrue    = 0xc3
+	mpBin8    = 0xc4
+	mpBin16   = 0xc5
+	mpBin32   = 0xc6
+	mpFloat32 = 0xca
+	mpFloat64 = 0xcb
+	mpUint8   = 0xcc
+	mpUint16  = 0xcd
+	mpUint32  = 0xce
+	mpUint64  = 0xcf
+	mpInt8    = 0xd0
+	mpInt16   = 0xd1
+	mpInt32   = 0xd2
+	mpInt64   = 0xd3
+	mpStr8    = 0xd9
+	mpStr16   = 0xda
+	mpStr32   = 0xdb
+	mpArray16 = 0xdc
+	mpArray32 = 0xdd
+	mpMap16   = 0xde
+	mpMap32   = 0xdf
+
+	mpFixMap   = 0x80
+	mpFixArray = 0x90
+	mpFixStr   = 0xa0
+)
+
+func appendValue(b []byte, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return append(b, mpNil), nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return append(b, mpNil), nil
+		}
+		return appendValue(b, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, mpTrue), nil
+		}
+		return append(b, mpFalse), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendInt(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendUint(b, v.Uint()), nil
+	case reflect.Float32:
+		b = append(b, mpFloat32)
+		return appendUint32(b, math.Float32bits(float32(v.Float()))), nil
+	case reflect.Float64:
+		b = append(b, mpFloat64)
+		return appendUint64(b, math.Float64bits(v.Float())), nil
+	case reflect.String:
+		return appendString(b, v.String()), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(b, mpNil), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendBytes(b, v.Bytes()), nil
+		}
+		return appendArray(b, v)
+	case reflect.Array:
+		return appendArray(b, v)
+	case reflect.Map:
+		if v.IsNil() {
+			return append(b, mpNil), nil
+		}
+		b = appendHeader(b, mpFixMap, 16, mpMap16, mpMap32, v.Len())
+		var err error
+		for it := v.MapRange(); it.Next(); {
+			if b, err = appendValue(b, it.Key()); err != nil {
+				return nil, err
+			}
+			if b, err = appendValue(b, it.Value()); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case reflect.Struct:
+		fields := fieldsOf(v.Type())
+		b = appendHeader(b, mpFixMap, 16, mpMap16, mpMap32, len(fields))
+		var err error
+		for _, f := range fields {
+			b = appendString(b, f.name)
+			if b, err = appendValue(b, v.Field(f.index)); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+}
+
+func appendInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return appendUint(b, uint64(i))
+	case i >= -32:
+		return append(b, byte(i)) // negative fixint
+	case i >= math.MinInt8:
+		return append(b, mpInt8, byte(i))
+	case i >= math.MinInt16:
+		return appendUint16(append(b, mpInt16), uint16(i))
+	case i >= math.MinInt32:
+		return appendUint32(append(b, mpInt32), uint32(i))
+	}
+	return appendUint64(append(b, mpInt64), uint64(i))
+}
+
+func appendUint(b []byte, u uint64) []byte {
+	switch {
+	case u <= 0x7f:
+		return append(b, byte(u)) // positive fixint
+	case u <= math.MaxUint8:
+		return append(b, mpUint8, byte(u))
+	case u <= math.MaxUint16:
+		return appendUint16(append(b, mpUint16), uint16(u))
+	case u <= math.MaxUint32:
+		return appendUint32(append(b, mpUint32), uint32(u))
+	}
+	return appendUint64(append(b, mpUint64), u)
+}
+
+func appendString(b []byte, s string) []byte {
+	if len(s) < 32 {
+		b = append(b, mpFixStr|byte(len(s)))
+	} else {
+		b = appendLen(b, mpStr8, mpStr16, mpStr32, len(s))
+	}
+	return append(b, s...)
+}
+
+func appendBytes(b, p []byte) []byte {
+	return append(appendLen(b, mpBin8, mpBin16, mpBin32, len(p)), p...)
+}
+
+func appendArray(b []byte, v reflect.Value) ([]byte, error) {
+	b = appendHeader(b, mpFixArray, 16, mpArray16, mpArray32, v.Len())
+	var err error
+	for i := 0; i < v.Len(); i++ {
+		if b, err = appendValue(b, v.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendUint16 等按大端序追加整数，binary.BigEndian.AppendUint16 需要 Go 1.19
+func appendUint16(b []byte, u uint16) []byte {
+	return append(b, byte(u>>8), byte(u))
+}
+
+func appendUint32(b []byte, u uint32) []byte {
+	return append(b, byte(u>>24), byte(u>>16), byte(u>>8), byte(u))
+}
+
+func appendUint64(b []byte, u uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(u>>32)), uint32(u))
+}
+
+// appendHeader 写入 array 或者 map 的长度，小于 fixMax 时长度直接放在 fix 的低位
+func appendHeader(b []byte, fix byte, fixMax int, c16, c32 byte, n int) []byte {
+	if n < fixMax {
+		return append(b, fix|byte(n))
+	}
+	if n <= math.MaxUint16 {
+		return appendUint16(append(b, c16), uint16(n))
+	}
+	return appendUint32(append(b, c32), uint32(n))
+}
+
+// appendLen 写入 str 或者 bin 的长度
+func appendLen(b []byte, c8, c16, c32 byte, n int) []byte {
+	switch {
+	case n <= math.MaxUint8:
+		return append(b, c8, byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, c16), uint16(n))
+	}
+	return appendUint32(append(b, c32), uint32(n))
+}
+
+// field struct 中参与编码的字段
+type field struct {
+	name  string
+	index int
+}
+
+// structFields 缓存每个 struct 类型的字段，reflect.Type -> []field
+var structFields sync.Map
+
+func fieldsOf(t reflect.Type) []field {
+	if fs, ok := structFields.Load(t); ok {
+		return fs.([]field)
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag, ok := f.Tag.Lookup("msgpack")
+		if !ok {
+			tag = f.Tag.Get("json")
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, field{name: name, index: i})
+	}
+	structFields.Store(t, fields)
+	return fields
+}
+
+// 解码时把类型字节归为以下几类
+const (
+	kindNil = iota
+	kindBool
+	kindInt
+	kindUint
+	kindFloat
+	kindStr
+	kindBin
+	kindArray
+	kindMap
+)
+
+var kindNames = [...]string{"nil", "bool", "int", "uint", "float", "str", "bin", "array", "map"}
+
+// token 一个值的头部，str 和 bin 的内容在 data 中，array 和 map 的元素在后面
+type token struct {
+	kind int
+	b    bool
+	i    int64
+	u    uint64
+	f    float64
+	n    int // array 的元素个数，map 的键值对个数
+	data []byte
+}
+
+// maxDepth array 和 map 允许嵌套的最大层数，与 encoding/json 相同，
+// 避免恶意数据（例如一百万个 0x91）递归太深导致无法恢复的栈溢出
+const maxDepth = 10000
+
+type decoder struct {
+	b     []byte
+	off   int
+	depth int // 当前嵌套的层数，decodeValue 和 skip 每进入一层加一
+}
+
+// enter 进入一层嵌套，超过 maxDepth 时返回错误，返回 nil 时调用方需要 defer d.leave()
+func (d *decoder) enter() error {
+	d.depth++
+	if d.depth > maxDepth {
+		d.depth--
+		return fmt.Errorf("%w: exceeded max depth %d", ErrInvalidMsgPack, maxDepth)
+	}
+	return nil
+}
+
+func (d *decoder) leave() {
+	d.depth--
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if n < 0 || len(d.b)-d.off < n {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMsgPack, io.ErrUnexpectedEOF)
+	}
+	p := d.b[d.off : d.off+n]
+	d.off += n
+	return p, nil
+}
+
+// readUint 读取 n 字节的大端序无符号整数
+func (d *decoder) readUint(n int) (uint64, error) {
+	p, err := d.read(n)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range p {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+func (d *decoder) next() (token, error) {
+	p, err := d.read(1)
+	if err != nil {
+		return token{}, err
+	}
+	c := p[0]
+	switch {
+	case c <= 0x7f:
+		return token{kind: kindUint, u: uint64(c)}, nil
+	case c >= 0xe0:
+		return token{kind: kindInt, i: int64(int8(c))}, nil
+	case c&0xf0 == mpFixMap:
+		return token{kind: kindMap, n: int(c & 0x0f)}, nil
+	case c&0xf0 == mpFixArray:
+		return token{kind: kindArray, n: int(c & 0x0f)}, nil
+	case c&0xe0 == mpFixStr:
+		return d.readData(kindStr, int(c&0x1f))
+	}
+
+	switch c {
+	case mpNil:
+		return token{kind: kindNil}, nil
+	case mpFalse, mpTrue:
+		return token{kind: kindBool, b: c == mpTrue}, nil
+	case mpUint8, mpUint16, mpUint32, mpUint64:
+		u, err := d.readUint(1 << (c - mpUint8))
+		return token{kind: kindUint, u: u}, err
+	case mpInt8, mpInt16, mpInt32, mpInt64:
+		size := 1 << (c - mpInt8)
+		u, err := d.readUint(size)
+		// 符号扩展
+		shift := 64 - 8*size
+		return token{kind: kindInt, i: int64(u<<shift) >> shift}, err
+	case mpFloat32:
+		u, err := d.readUint(4)
+		return token{kind: kindFloat, f: float64(math.Float32frombits(uint32(u)))}, err
+	case mpFloat64:
+		u, err := d.readUint(8)
+		return token{kind: kindFloat, f: math.Float64frombits(u)}, err
+	case mpStr8, mpStr16, mpStr32:
+		n, err := d.readUint(1 << (c - mpStr8))
+		if err != nil {
+			return token{}, err
+		}
+		return d.readData(kindStr, int(n))
+	case mpBin8, mpBin16, mpBin32:
+		n, err := d.readUint(1 << (c - mpBin8))
+		if err != nil {
+			return token{}, err
+		}
+		return d.readData(kindBin, int(n))
+	case mpArray16, mpArray32:
+		n, err := d.readUint(2 << (c - mpArray16))
+		if err != nil {
+			return token{}, err
+		}
+		return d.readHeader(kindArray, n)
+	case mpMap16, mpMap32:
+		n, err := d.readUint(2 << (c - mpMap16))
+		if err != nil {
+			return token{}, err
+		}
+		return d.readHeader(kindMap, n)
+	}
+	return token{}, fmt.Errorf("%w: unsupported type byte 0x%02x", ErrInvalidMsgPack, c)
+}
+
+// readHeader 检查 array 或者 map 的长度，每个元素至少占一个字节，避免按照伪造的长度分配大量内存
+func (d *decoder) readHeader(kind int, n uint64) (token, error) {
+	if n > uint64(len(d.b)-d.off) {
+		return token{}, fmt.Errorf("%w: %v", ErrInvalidMsgPack, io.ErrUnexpectedEOF)
+	}
+	return token{kind: kind, n: int(n)}, nil
+}
+
+func (d *decoder) readData(kind, n int) (token, error) {
+	p, err := d.read(n)
+	return token{kind: kind, data: p, n: n}, err
+}
+
+func (d *decoder) decodeValue(v reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+	tok, err := d.next()
+	if err != nil {
+		return err
+	}
+	return d.decodeToken(tok, v)
+}
+
+func (d *decoder) decodeToken(tok token, v reflect.Value) error {
+	if tok.kind == kindNil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.decodeToken(tok, v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+		}
+		x, err := d.decodeAny(tok)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(x))
+		return nil
+	case reflect.Bool:
+		if tok.kind == kindBool {
+			v.SetBool(tok.b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := tok.i
+		if tok.kind == kindUint {
+			if tok.u > math.MaxInt64 {
+				break
+			}
+			i = int64(tok.u)
+		}
+		if (tok.kind == kindInt || tok.kind == kindUint) && !v.OverflowInt(i) {
+			v.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := tok.u
+		if tok.kind == kindInt {
+			if tok.i < 0 {
+				break
+			}
+			u = uint64(tok.i)
+		}
+		if (tok.kind == kindInt || tok.kind == kindUint) && !v.OverflowUint(u) {
+			v.SetUint(u)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch tok.kind {
+		case kindFloat:
+			v.SetFloat(tok.f)
+			return nil
+		case kindInt:
+			v.SetFloat(float64(tok.i))
+			return nil
+		case kindUint:
+			v.SetFloat(float64(tok.u))
+			return nil
+		}
+	case reflect.String:
+		if tok.kind == kindStr || tok.kind == kindBin {
+			v.SetString(string(tok.data))
+			return nil
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 && (tok.kind == kindBin || tok.kind == kindStr) {
+			v.SetBytes(append([]byte(nil), tok.data...))
+			return nil
+		}
+		if tok.kind == kindArray {
+			s := reflect.MakeSlice(v.Type(), tok.n, tok.n)
+			for i := 0; i < tok.n; i++ {
+				if err := d.decodeValue(s.Index(i)); err != nil {
+					return err
+				}
+			}
+			v.Set(s)
+			return nil
+		}
+	case reflect.Array:
+		if tok.kind == kindArray && tok.n <= v.Len() {
+			v.Set(reflect.Zero(v.Type()))
+			for i := 0; i < tok.n; i++ {
+				if err := d.decodeValue(v.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case reflect.Map:
+		if tok.kind == kindMap {
+			return d.decodeMap(tok.n, v)
+		}
+	case reflect.Struct:
+		if tok.kind == kindMap {
+			return d.decodeStruct(tok.n, v)
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+	return fmt.Errorf("%w: cannot decode %s into %s", ErrInvalidMsgPack, kindNames[tok.kind], v.Type())
+}
+
+func (d *decoder) decodeMap(n int, v reflect.Value) error {
+	t := v.Type()
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(t, n))
+	}
+	for i := 0; i < n; i++ {
+		key := reflect.New(t.Key()).Elem()
+		if err := d.decodeValue(key); err != nil {
+			return err
+		}
+		elem := reflect.New(t.Elem()).Elem()
+		if err := d.decodeValue(elem); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+// decodeStruct 按字段名解码，忽略 struct 中没有的字段
+func (d *decoder) decodeStruct(n int, v reflect.Value) error {
+	fields := fieldsOf(v.Type())
+	for i := 0; i < n; i++ {
+		tok, err := d.next()
+		if err != nil {
+			return err
+		}
+		if tok.kind != kindStr {
+			return fmt.Errorf("%w: %s key for struct %s", ErrInvalidMsgPack, kindNames[tok.kind], v.Type())
+		}
+		index := -1
+		for _, f := range fields {
+			if f.name == string(tok.data) {
+				index = f.index
+				break
+			}
+		}
+		if index < 0 {
+			err = d.skip()
+		} else {
+			err = d.decodeValue(v.Field(index))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *decoder) decodeAny(tok token) (any, error) {
+	switch tok.kind {
+	case kindBool:
+		return tok.b, nil
+	case kindInt:
+		return tok.i, nil
+	case kindUint:
+		if tok.u > math.MaxInt64 {
+			return tok.u, nil
+		}
+		return int64(tok.u), nil
+	case kindFloat:
+		return tok.f, nil
+	case kindStr:
+		return string(tok.data), nil
+	case kindBin:
+		return append([]byte(nil), tok.data...), nil
+	case kindArray:
+		a := make([]any, tok.n)
+		for i := range a {
+			if err := d.decodeValue(reflect.ValueOf(&a[i]).Elem()); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	case kindMap:
+		m := make(map[string]any, tok.n)
+		return m, d.decodeMap(tok.n, reflect.ValueOf(m))
+	}
+	return nil, nil
+}
+
+// skip 跳过一个完整的值
+func (d *decoder) skip() error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+	tok, err := d.next()
+	if err != nil {
+		return err
+	}
+	n := 0
+	switch tok.kind {
+	case kindArray:
+		n = tok.n
+	case kindMap:
+		n = 2 * tok.n
+	}
+	for i := 0; i < n; i++ {
+		if err := d.skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// dataReq 与 example 中的消息相同
+type dataReq struct {
+	Name string `json:"name"`
+}
+
+type user struct {
+	ID      int64             `msgpack:"id"`
+	Name    string            `json:"name"`
+	Tags    []string          `msgpack:"tags"`
+	Scores  map[string]uint16 `msgpack:"scores"`
+	Avatar  []byte            `msgpack:"avatar"`
+	Ratio   float32           `msgpack:"ratio"`
+	Admin   bool              `msgpack:"admin"`
+	Parent  *user             `msgpack:"parent"`
+	Extra   any               `msgpack:"extra"`
+	Ignored string            `msgpack:"-"`
+	private int
+}
+
+func TestMsgPackEncoding(t *testing.T) {
+	// 与规范中的编码结果比较
+	tests := []struct {
+		v    any
+		want []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{7, []byte{0x07}},
+		{-1, []byte{0xff}},
+		{200, []byte{0xcc, 0xc8}},
+		{-200, []byte{0xd1, 0xff, 0x38}},
+		{uint64(1) << 40, []byte{0xcf, 0, 0, 1, 0, 0, 0, 0, 0}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"abc", []byte{0xa3, 'a', 'b', 'c'}},
+		{[]byte{1, 2}, []byte{0xc4, 2, 1, 2}},
+		{[]int{1, 2}, []byte{0x92, 1, 2}},
+		{dataReq{"a"}, []byte{0x81, 0xa4, 'n', 'a', 'm', 'e', 0xa1, 'a'}},
+	}
+	for _, tt := range tests {
+		got, err := MsgPack.Encode(tt.v)
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("Encode(%v) = %x, %v, want %x", tt.v, got, err, tt.want)
+		}
+	}
+}
+
+func TestMsgPackRoundTrip(t *testing.T) {
+	in := user{
+		ID:      -1 << 40,
+		Name:    string(bytes.Repeat([]byte("kwok"), 100)),
+		Tags:    []string{"a", "b"},
+		Scores:  map[string]uint16{"go": 100, "rust": 60000},
+		Avatar:  []byte{0, 1, 2},
+		Ratio:   0.5,
+		Admin:   true,
+		Parent:  &user{ID: 1, Name: "root"},
+		Extra:   []any{int64(1), "x", map[string]any{"k": 1.5}, nil},
+		Ignored: "x",
+		private: 1,
+	}
+	b, err := MsgPack.Encode(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out user
+	if err := MsgPack.Decode(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	in.Ignored, in.private = "", 0
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("Decode() = %+v, want %+v", out, in)
+	}
+
+	// 整数可以解码到范围足够的其他整数和浮点数类型
+	var f float64
+	if err := MsgPack.Decode([]byte{0xd1, 0xff, 0x38}, &f); err != nil || f != -200 {
+		t.Errorf("Decode(-200) into float64 = %v, %v", f, err)
+	}
+	b, _ = MsgPack.Encode(uint64(math.MaxUint64))
+	if err := MsgPack.Decode(b, new(int64)); !errors.Is(err, ErrInvalidMsgPack) {
+		t.Errorf("Decode(MaxUint64) into int64 = %v, want %v", err, ErrInvalidMsgPack)
+	}
+}
+
+func TestMsgPackErrors(t *testing.T) {
+	if _, err := MsgPack.Encode(make(chan int)); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Encode(chan) = %v, want %v", err, ErrUnsupportedType)
+	}
+	if err := MsgPack.Decode([]byte{0x01}, dataReq{}); !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Decode(non-pointer) = %v, want %v", err, ErrUnsupportedType)
+	}
+	tests := []struct {
+		name string
+		data []byte
+		v    any
+	}{
+		{"truncated", []byte{0xa3, 'a'}, new(string)},
+		{"trailing", []byte{0x01, 0x02}, new(int)},
+		{"type mismatch", []byte{0xa1, 'a'}, new(int)},
+		{"overflow", []byte{0xcd, 0x01, 0x00}, new(int8)},
+		{"huge array", []byte{0xdd, 0xff, 0xff, 0xff, 0xff}, new([]int)},
+		{"reserved", []byte{0xc1}, new(any)},
+	}
+	for _, tt := range tests {
+		if err := MsgPack.Decode(tt.data, tt.v); !errors.Is(err, ErrInvalidMsgPack) {
+			t.Errorf("%s: Decode() = %v, want %v", tt.name, err, ErrInvalidMsgPack)
+		}
+	}
+}
+
+// 嵌套太深的数据返回错误，而不是栈溢出
+func TestMsgPackDepth(t *testing.T) {
+	nested := func(n int) []byte {
+		return append(bytes.Repeat([]byte{0x91}, n), 0xc0)
+	}
+	deep := nested(1_000_000)
+	if err := MsgPack.Decode(deep, new(any)); !errors.Is(err, ErrInvalidMsgPack) {
+		t.Errorf("Decode(any) = %v, want %v", err, ErrInvalidMsgPack)
+	}
+	if err := MsgPack.Decode(deep, new([]any)); !errors.Is(err, ErrInvalidMsgPack) {
+		t.Errorf("Decode([]any) = %v, want %v", err, ErrInvalidMsgPack)
+	}
+	// 结构体中未知的字段通过 skip 跳过
+	unknown := append([]byte{0x81, 0xa1, 'x'}, deep...)
+	if err := MsgPack.Decode(unknown, new(dataReq)); !errors.Is(err, ErrInvalidMsgPack) {
+		t.Errorf("Decode(struct) = %v, want %v", err, ErrInvalidMsgPack)
+	}
+
+	var v any
+	if err := MsgPack.Decode(nested(100), &v); err != nil {
+		t.Errorf("Decode() of 100 levels = %v", err)
+	}
+	if err := MsgPack.Decode(append([]byte{0x81, 0xa1, 'x'}, nested(100)...), new(dataReq)); err != nil {
+		t.Errorf("skipping 100 levels = %v", err)
+	}
+}
+
+// 比较 example 中 dataReq 的编码方式，以及字段更多的 user
+func benchmarkCodec(b *testing.B, c Codec, v any, newV func() any) {
+	data, err := c.Encode(v)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("Encode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			c.Encode(v)
+		}
+		b.ReportMetric(float64(len(data)), "bytes")
+	})
+	b.Run("Decode", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := c.Decode(data, newV()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+var benchUser = &user{
+	ID:     42,
+	Name:   "kwok",
+	Tags:   []string{"go", "net"},
+	Scores: map[string]uint16{"go": 100},
+	Ratio:  0.5,
+}
+
+func BenchmarkJSON(b *testing.B) {
+	b.Run("dataReq", func(b *testing.B) {
+		benchmarkCodec(b, JSON, dataReq{Name: "kwok"}, func() any { return new(dataReq) })
+	})
+	b.Run("user", func(b *testing.B) {
+		benchmarkCodec(b, JSON, benchUser, func() any { return new(user) })
+	})
+}
+
+func BenchmarkMsgPack(b *testing.B) {
+	b.Run("dataReq", func(b *testing.B) {
+		benchmarkCodec(b, MsgPack, dataReq{Name: "kwok"}, func() any { return new(dataReq) })
+	})
+	b.Run("user", func(b *testing.B) {
+		benchmarkCodec(b, MsgPack, benchUser, func() any { return new(user) })
+	})
+}
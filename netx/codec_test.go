@@ -0,0 +1,133 @@
+package netx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	msgs := [][]byte{[]byte(`{"name":"name-0"}`), {}, []byte("你好")}
+
+	var stream bytes.Buffer
+	for _, m := range msgs {
+		b, err := Encode(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream.Write(b)
+	}
+
+	r := bufio.NewReader(&stream)
+	for i, want := range msgs {
+		got, err := Decode(r)
+		if err != nil {
+			t.Fatalf("Decode #%d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Decode #%d = %q, want %q", i, got, want)
+		}
+	}
+	if _, err := Decode(r); err != io.EOF {
+		t.Errorf("Decode at end = %v, want io.EOF", err)
+	}
+}
+
+// TCP 编码的字节流可以直接作为 UDP 数据报解码，反之亦然
+func TestCodecCrossTransport(t *testing.T) {
+	msgs := [][]byte{[]byte("hello"), []byte("server"), {}}
+
+	var tcp []byte
+	for _, m := range msgs {
+		b, _ := Encode(m)
+		tcp = append(tcp, b...)
+	}
+
+	var datagram []byte
+	for _, m := range msgs {
+		datagram = AppendFrame(datagram, m)
+	}
+	if !bytes.Equal(tcp, datagram) {
+		t.Fatalf("TCP bytes %x != UDP datagram %x", tcp, datagram)
+	}
+
+	got, err := DecodePacket(tcp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, msgs) {
+		t.Errorf("DecodePacket = %q, want %q", got, msgs)
+	}
+
+	r := bufio.NewReader(bytes.NewReader(datagram))
+	for i, want := range msgs {
+		got, err := Decode(r)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("Decode #%d = %q, %v, want %q", i, got, err, want)
+		}
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	b, _ := Encode([]byte("hello"))
+
+	if _, err := DecodePacket(b[:len(b)-1]); err != ErrTruncated {
+		t.Errorf("DecodePacket(truncated) = %v, want %v", err, ErrTruncated)
+	}
+	if _, err := DecodePacket(b[:2]); err != ErrTruncated {
+		t.Errorf("DecodePacket(short header) = %v, want %v", err, ErrTruncated)
+	}
+	if _, err := Decode(bufio.NewReader(bytes.NewReader(b[:len(b)-1]))); err != io.ErrUnexpectedEOF {
+		t.Errorf("Decode(truncated) = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if _, err := DecodePacket([]byte{0xff, 0xff, 0xff, 0xff}); err != ErrInvalidLength {
+		t.Errorf("DecodePacket(negative length) = %v, want %v", err, ErrInvalidLength)
+	}
+}
+
+func TestDecodeContextCancel(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := DecodeContext(ctx, server, bufio.NewReader(server))
+		errc <- err
+	}()
+
+	// 发送半个消息后不再发送，Decode 会一直阻塞在读取上
+	b, _ := Encode([]byte("hello"))
+	client.Write(b[:3])
+	cancel()
+
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			t.Errorf("DecodeContext() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("DecodeContext did not return after cancel")
+	}
+}
+
+func TestDecodeContext(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		b, _ := Encode([]byte("hello"))
+		client.Write(b)
+	}()
+	msg, err := DecodeContext(context.Background(), server, bufio.NewReader(server))
+	if err != nil || string(msg) != "hello" {
+		t.Errorf("DecodeContext() = %q, %v", msg, err)
+	}
+}
@@ -0,0 +1,89 @@
+package netx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+// ErrUnknownCompression 消息头中的压缩算法没有注册对应的 Compressor
+var ErrUnknownCompression = errors.New("netx: unknown compression flag")
+
+// 消息头中标识压缩算法的标志位
+const (
+	// FlagNone 没有压缩
+	FlagNone byte = 0
+	// FlagGzip gzip 压缩，见 Gzip
+	FlagGzip byte = 1
+	// FlagSnappy 预留给 snappy，netx 不内置实现，需要时自行实现 Compressor 并返回这个标志位
+	FlagSnappy byte = 2
+)
+
+// Compressor 消息的压缩算法
+// Flag 会写入消息头，接收方根据它选择解压的 Compressor，收发双方需要使用相同的标志位。
+type Compressor interface {
+	// Flag 算法的标志位，不能是 FlagNone
+	Flag() byte
+	// Compress 返回压缩后的数据
+	Compress(src []byte) ([]byte, error)
+	// Decompress 返回解压后的数据
+	Decompress(src []byte) ([]byte, error)
+}
+
+// DefaultMaxDecompressedSize Framer 默认允许的解压后的最大消息长度，用 WithMaxDecompressedSize 修改
+const DefaultMaxDecompressedSize = 64 << 20
+
+// LimitedDecompressor 可以限制解压后大小的 Compressor，Framer 优先使用 DecompressLimit，
+// 避免一个很小的压缩炸弹解压出几个 GB 的数据。没有实现时 Framer 在 Decompress 之后再检查大小。
+type LimitedDecompressor interface {
+	// DecompressLimit 返回解压后的数据，超过 max 字节时返回 ErrFrameTooLarge
+	DecompressLimit(src []byte, max int) ([]byte, error)
+}
+
+// Gzip 使用默认压缩级别的 gzip
+var Gzip Compressor = gzipCompressor{level: gzip.DefaultCompression}
+
+type gzipCompressor struct {
+	level int
+}
+
+func (gzipCompressor) Flag() byte {
+	return FlagGzip
+}
+
+func (g gzipCompressor) Compress(src []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, g.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress 解压后的数据最多为一个消息的最大长度
+func (g gzipCompressor) Decompress(src []byte) ([]byte, error) {
+	return g.DecompressLimit(src, maxFrameLen)
+}
+
+func (gzipCompressor) DecompressLimit(src []byte, max int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > max {
+		return nil, ErrFrameTooLarge
+	}
+	return b, nil
+}
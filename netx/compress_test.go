@@ -0,0 +1,107 @@
+package netx
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// halfCompressor 用来测试可插拔压缩算法的假 Compressor，只适用于前后两半相同的数据
+type halfCompressor struct{}
+
+func (halfCompressor) Flag() byte { return FlagSnappy }
+
+func (halfCompressor) Compress(src []byte) ([]byte, error) {
+	return src[:len(src)/2], nil
+}
+
+func (halfCompressor) Decompress(src []byte) ([]byte, error) {
+	return append(append([]byte(nil), src...), src...), nil
+}
+
+func TestFramerCompression(t *testing.T) {
+	large := strings.Repeat(`{"name":"gopractice"}`, 100)
+	msgs := []string{"small", large, ""}
+
+	for _, opts := range [][]FramerOption{
+		{WithCompression(Gzip, 64)},
+		{WithCompression(Gzip, 64), WithChecksum()},
+	} {
+		f := NewFramer(opts...)
+		var buf bytes.Buffer
+		for _, m := range msgs {
+			if err := f.WriteFrame(&buf, []byte(m)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if buf.Len() >= len(large) {
+			t.Errorf("encoded %d bytes, large payload is not compressed", buf.Len())
+		}
+		data := append([]byte(nil), buf.Bytes()...)
+
+		r := bufio.NewReader(oneByteReader{&buf})
+		for _, want := range msgs {
+			got, err := f.ReadFrame(r)
+			if err != nil || string(got) != want {
+				t.Errorf("ReadFrame() = %.16q, %v, want %.16q", got, err, want)
+			}
+		}
+
+		got, err := f.DecodePacket(data)
+		if err != nil || len(got) != len(msgs) || string(got[1]) != large {
+			t.Errorf("DecodePacket() = %d msgs, %v", len(got), err)
+		}
+
+		sc := NewFrameScanner(0, opts...).NewScanner(bytes.NewReader(data))
+		for i := 0; sc.Scan(); i++ {
+			if sc.Text() != msgs[i] {
+				t.Errorf("Scan() = %.16q, want %.16q", sc.Text(), msgs[i])
+			}
+		}
+		if err := sc.Err(); err != nil {
+			t.Errorf("Scanner.Err() = %v", err)
+		}
+	}
+}
+
+func TestFramerPluggableCompressor(t *testing.T) {
+	sender := NewFramer(WithCompression(halfCompressor{}, 0))
+	frame := sender.AppendFrame(nil, []byte("abcabc"))
+	if frame[HeaderSize] != FlagSnappy {
+		t.Fatalf("flag = %d, want %d", frame[HeaderSize], FlagSnappy)
+	}
+
+	// 接收方只注册解压算法，同时也能接收没有压缩的消息
+	receiver := NewFramer(WithDecompressor(halfCompressor{}), WithDecompressor(Gzip))
+	frame = NewFramer(WithCompression(Gzip, 1<<20)).AppendFrame(frame, []byte("raw"))
+	msgs, err := receiver.DecodePacket(frame)
+	if err != nil || len(msgs) != 2 || string(msgs[0]) != "abcabc" || string(msgs[1]) != "raw" {
+		t.Fatalf("DecodePacket() = %q, %v", msgs, err)
+	}
+
+	if _, err := NewFramer(WithDecompressor(Gzip)).DecodePacket(sender.AppendFrame(nil, []byte("abcabc"))); err != ErrUnknownCompression {
+		t.Errorf("DecodePacket(unknown flag) = %v, want %v", err, ErrUnknownCompression)
+	}
+}
+
+// 压缩炸弹：很小的压缩数据解压后超过限制
+func TestFramerDecompressLimit(t *testing.T) {
+	bomb := make([]byte, 8<<20)
+	frame := NewFramer(WithCompression(Gzip, 0)).AppendFrame(nil, bomb)
+	if len(frame) > 64<<10 {
+		t.Fatalf("compressed frame is %d bytes", len(frame))
+	}
+	if _, err := NewFramer(WithDecompressor(Gzip), WithMaxDecompressedSize(1<<20)).DecodePacket(frame); err != ErrFrameTooLarge {
+		t.Errorf("DecodePacket(bomb) = %v, want ErrFrameTooLarge", err)
+	}
+	if msgs, err := NewFramer(WithDecompressor(Gzip)).DecodePacket(frame); err != nil || len(msgs[0]) != len(bomb) {
+		t.Errorf("DecodePacket() within the limit = %v", err)
+	}
+
+	// 没有实现 LimitedDecompressor 的 Compressor 在解压之后检查
+	frame = NewFramer(WithCompression(halfCompressor{}, 0)).AppendFrame(nil, []byte("abcabc"))
+	if _, err := NewFramer(WithDecompressor(halfCompressor{}), WithMaxDecompressedSize(4)).DecodePacket(frame); err != ErrFrameTooLarge {
+		t.Errorf("DecodePacket() = %v, want ErrFrameTooLarge", err)
+	}
+}
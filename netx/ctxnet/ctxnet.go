@@ -0,0 +1,36 @@
+// Package ctxnet 让 netx.Server 使用 contextx/source 实现的 Context
+// source.Context 与标准库 context.Context 的方法完全一致，两者的值可以直接互相赋值，
+// 所以只需要替换 netx.Server 的 BaseContext 和 NewContext 即可。
+package ctxnet
+
+import (
+	"context"
+	"gopractice/contextx/source"
+	"gopractice/netx"
+	"net"
+)
+
+// Handler 处理一个连接，ctx 是 contextx 的 Context，在连接处理结束或 Server 关闭时被取消
+type Handler func(ctx source.Context, conn net.Conn)
+
+// NewServer 返回一个连接 Context 全部由 contextx 派生的 netx.Server
+// 根 Context 是 source.Background()，所以每一层都能通过 children 级联取消，不需要额外的 goroutine。
+// 对方断开时也要取消 ctx、ctx 取消时关闭连接的话，设置返回的 Server 的 BindConnContext。
+func NewServer(addr string, handler Handler) *netx.Server {
+	return &netx.Server{
+		Addr: addr,
+		Handler: func(ctx context.Context, conn net.Conn) {
+			handler(ctx, conn)
+		},
+		BaseContext: func() context.Context {
+			return source.Background()
+		},
+		NewContext: WithCancel,
+	}
+}
+
+// WithCancel 用 source.WithCancel 派生子 Context，签名与 context.WithCancel 一致
+func WithCancel(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := source.WithCancel(parent)
+	return ctx, context.CancelFunc(cancel)
+}
@@ -0,0 +1,55 @@
+package ctxnet
+
+import (
+	"context"
+	"gopractice/contextx/source"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownCancelsContextxHandlers(t *testing.T) {
+	const clients = 3
+
+	var started, exited sync.WaitGroup
+	started.Add(clients)
+	exited.Add(clients)
+	errs := make(chan error, clients)
+	s := NewServer("", func(ctx source.Context, conn net.Conn) {
+		defer exited.Done()
+		started.Done()
+		<-ctx.Done()
+		errs <- ctx.Err()
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+
+	for i := 0; i < clients; i++ {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+	}
+	started.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	exited.Wait()
+
+	close(errs)
+	for err := range errs {
+		// 取消原因是 contextx 中定义的 Canceled，而不是标准库的 context.Canceled
+		if err != source.Canceled {
+			t.Errorf("handler ctx.Err() = %v, want source.Canceled", err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package netx
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// ErrDelimiterInPayload 关闭转义时消息内容中包含分隔符
+var ErrDelimiterInPayload = errors.New("netx: payload contains the delimiter")
+
+// DelimiterCodec 以分隔符结尾的封包方式，例如按行分割的文本协议
+// 默认开启转义：消息中的转义字符和分隔符的第一个字节前面都会加上转义字符，
+// 这样消息内容中不会出现未转义的分隔符，即使分隔符有多个字节并且首尾重叠也能正确拆包。
+type DelimiterCodec struct {
+	delim   []byte
+	escape  byte
+	escOn   bool
+	maxSize int
+}
+
+// DelimiterOption 创建 DelimiterCodec 时的可选配置
+type DelimiterOption func(c *DelimiterCodec)
+
+// WithEscape 设置转义字符，默认为 '\\'
+func WithEscape(esc byte) DelimiterOption {
+	return func(c *DelimiterCodec) {
+		c.escape = esc
+		c.escOn = true
+	}
+}
+
+// WithoutEscape 关闭转义，消息原样发送，适用于消息中不会出现分隔符的文本协议
+func WithoutEscape() DelimiterOption {
+	return func(c *DelimiterCodec) {
+		c.escOn = false
+	}
+}
+
+// WithDelimiterMaxSize 设置 ReadFrame 允许的最大消息长度（去掉转义之后），n<=0 时使用 DefaultMaxMessageSize
+func WithDelimiterMaxSize(n int) DelimiterOption {
+	return func(c *DelimiterCodec) {
+		c.maxSize = maxSizeOr(n)
+	}
+}
+
+// NewDelimiterCodec 使用 delim 作为分隔符，delim 为空或者与转义字符冲突时 panic
+func NewDelimiterCodec(delim []byte, opts ...DelimiterOption) *DelimiterCodec {
+	if len(delim) == 0 {
+		panic("netx: empty delimiter")
+	}
+	c := &DelimiterCodec{
+		delim:   append([]byte(nil), delim...),
+		escape:  '\\',
+		escOn:   true,
+		maxSize: DefaultMaxMessageSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.escOn && c.escape == c.delim[0] {
+		panic("netx: escape byte conflicts with the delimiter")
+	}
+	return c
+}
+
+// NewLineCodec 以 '\n' 作为分隔符
+func NewLineCodec(opts ...DelimiterOption) *DelimiterCodec {
+	return NewDelimiterCodec([]byte{'\n'}, opts...)
+}
+
+// WriteFrame 把 payload 转义后加上分隔符，一次性写入 w
+func (c *DelimiterCodec) WriteFrame(w io.Writer, payload []byte) error {
+	b, err := c.appendFrame(make([]byte, 0, len(payload)+len(c.delim)), payload)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// ReadFrame 读取到下一个未转义的分隔符为止，返回去掉转义和分隔符后的消息
+// 消息超过 WithDelimiterMaxSize 时返回 ErrFrameTooLarge，不会一直等待分隔符而缓存无限多的数据。
+func (c *DelimiterCodec) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	var payload []byte
+	for n := 0; ; n++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && n > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		switch {
+		case c.escOn && b == c.escape:
+			b, err = r.ReadByte()
+			if err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return nil, err
+			}
+		case b == c.delim[0]:
+			ok, err := c.matchRest(r)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				if payload == nil {
+					payload = []byte{}
+				}
+				return payload, nil
+			}
+		}
+		if len(payload) >= c.maxSize {
+			return nil, ErrFrameTooLarge
+		}
+		payload = append(payload, b)
+	}
+}
+
+// matchRest 判断接下来的数据是否是分隔符剩余的部分，是的话消费掉
+func (c *DelimiterCodec) matchRest(r *bufio.Reader) (bool, error) {
+	rest := c.delim[1:]
+	if len(rest) == 0 {
+		return true, nil
+	}
+	b, err := r.Peek(len(rest))
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		// 剩余的数据不够，且已经读到结尾，不可能是分隔符
+		if len(b) < len(rest) && !bytes.HasPrefix(rest, b) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !bytes.Equal(b, rest) {
+		return false, nil
+	}
+	r.Discard(len(rest))
+	return true, nil
+}
+
+// AppendFrame 把 payload 封包后追加到 dst，关闭转义且 payload 中包含分隔符时 panic
+func (c *DelimiterCodec) AppendFrame(dst, payload []byte) []byte {
+	b, err := c.appendFrame(dst, payload)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// DecodePacket 解出一个 UDP 数据报中的所有消息
+func (c *DelimiterCodec) DecodePacket(b []byte) ([][]byte, error) {
+	r := bufio.NewReaderSize(bytes.NewReader(b), len(c.delim)+16)
+	var msgs [][]byte
+	for {
+		msg, err := c.ReadFrame(r)
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return msgs, ErrTruncated
+		}
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func (c *DelimiterCodec) appendFrame(dst, payload []byte) ([]byte, error) {
+	if !c.escOn {
+		start := len(dst)
+		dst = append(dst, payload...)
+		dst = append(dst, c.delim...)
+		// 分隔符首尾重叠时，消息的结尾和分隔符拼起来也可能提前出现分隔符
+		if bytes.Index(dst[start:], c.delim) != len(payload) {
+			return nil, ErrDelimiterInPayload
+		}
+		return dst, nil
+	}
+
+	for _, b := range payload {
+		if b == c.escape || b == c.delim[0] {
+			dst = append(dst, c.escape)
+		}
+		dst = append(dst, b)
+	}
+	return append(dst, c.delim...), nil
+}
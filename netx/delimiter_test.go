@@ -0,0 +1,113 @@
+package netx
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestDelimiterCodec(t *testing.T) {
+	tests := []struct {
+		name  string
+		codec *DelimiterCodec
+		msgs  []string
+	}{
+		{"line", NewLineCodec(), []string{"hello", "", "a\nb", `c:\path\`, "\n\n"}},
+		{"crlf", NewDelimiterCodec([]byte("\r\n")), []string{"GET /", "a\r\nb", "a\r", "\rb\n"}},
+		// 分隔符首尾重叠
+		{"overlap", NewDelimiterCodec([]byte("aba")), []string{"ab", "a", "abab", "xaba"}},
+		{"custom escape", NewLineCodec(WithEscape('%')), []string{"100%", "a\nb"}},
+		{"no escape", NewLineCodec(WithoutEscape()), []string{"PING", `c:\path\`, ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			for _, m := range tt.msgs {
+				if err := tt.codec.WriteFrame(&buf, []byte(m)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			data := buf.Bytes()
+
+			r := bufio.NewReader(oneByteReader{bytes.NewReader(data)})
+			for _, want := range tt.msgs {
+				got, err := tt.codec.ReadFrame(r)
+				if err != nil || string(got) != want {
+					t.Fatalf("ReadFrame() = %q, %v, want %q", got, err, want)
+				}
+			}
+			if _, err := tt.codec.ReadFrame(r); err != io.EOF {
+				t.Errorf("ReadFrame at end = %v, want io.EOF", err)
+			}
+
+			got, err := tt.codec.DecodePacket(data)
+			if err != nil || len(got) != len(tt.msgs) {
+				t.Fatalf("DecodePacket() = %q, %v", got, err)
+			}
+			for i := range got {
+				if string(got[i]) != tt.msgs[i] {
+					t.Errorf("DecodePacket()[%d] = %q, want %q", i, got[i], tt.msgs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDelimiterCodecWire(t *testing.T) {
+	var buf bytes.Buffer
+	NewLineCodec().WriteFrame(&buf, []byte("a\nb\\c"))
+	if got, want := buf.String(), "a\\\nb\\\\c\n"; got != want {
+		t.Errorf("wire format = %q, want %q", got, want)
+	}
+
+	// 不包含分隔符和转义字符的消息原样发送
+	buf.Reset()
+	NewDelimiterCodec([]byte("\r\n")).WriteFrame(&buf, []byte("PING"))
+	if got := buf.String(); got != "PING\r\n" {
+		t.Errorf("wire format = %q, want %q", got, "PING\r\n")
+	}
+}
+
+func TestDelimiterCodecErrors(t *testing.T) {
+	c := NewLineCodec(WithoutEscape())
+	if err := c.WriteFrame(io.Discard, []byte("a\nb")); err != ErrDelimiterInPayload {
+		t.Errorf("WriteFrame() = %v, want %v", err, ErrDelimiterInPayload)
+	}
+	overlap := NewDelimiterCodec([]byte("aba"), WithoutEscape())
+	if err := overlap.WriteFrame(io.Discard, []byte("ab")); err != ErrDelimiterInPayload {
+		t.Errorf("WriteFrame(overlap) = %v, want %v", err, ErrDelimiterInPayload)
+	}
+
+	for _, data := range []string{"abc", "abc\\", "abc\r"} {
+		c := NewDelimiterCodec([]byte("\r\n"))
+		if _, err := c.ReadFrame(bufio.NewReader(bytes.NewReader([]byte(data)))); err != io.ErrUnexpectedEOF {
+			t.Errorf("ReadFrame(%q) = %v, want %v", data, err, io.ErrUnexpectedEOF)
+		}
+		if _, err := c.DecodePacket([]byte(data)); err != ErrTruncated {
+			t.Errorf("DecodePacket(%q) = %v, want %v", data, err, ErrTruncated)
+		}
+	}
+}
+
+// 切换到按行分割时，处理逻辑不需要修改
+func TestDelimiterCodecClient(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	codec := NewLineCodec()
+	go func() {
+		s := NewClient(server, WithFrameCodec(codec))
+		msg, _ := s.Recv()
+		s.Send(msg)
+	}()
+
+	c := NewClient(client, WithFrameCodec(codec))
+	c.Send([]byte("multi\nline"))
+	if msg, err := c.Recv(); err != nil || string(msg) != "multi\nline" {
+		t.Errorf("Recv() = %q, %v", msg, err)
+	}
+}
@@ -0,0 +1,252 @@
+// Package discovery 局域网内基于 UDP 广播的服务发现
+// 服务端用 Announcer 定期广播自己的名称、地址和端口，客户端用 Discover 或 Lookup 收听广播，
+// 不需要事先知道服务端的地址。广播不会跨越路由器，只适用于同一网段。
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"gopractice/logx"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ErrNotFound ctx 结束前没有收到指定名称的服务的广播
+var ErrNotFound = errors.New("discovery: service not found")
+
+const (
+	// DefaultPort 广播使用的 UDP 端口
+	DefaultPort = 8999
+	// DefaultInterval 默认的广播间隔
+	DefaultInterval = time.Second
+)
+
+// magic 广播数据报的前缀，用来过滤同一端口上的其他数据
+var magic = []byte("gopractice-discovery\n")
+
+// ServiceInfo 广播中的服务信息
+type ServiceInfo struct {
+	Name string `json:"name"`
+	// Addr 服务的 IP 地址或主机名，广播时为空表示使用广播数据报的来源地址
+	Addr string `json:"addr,omitempty"`
+	Port int    `json:"port"`
+}
+
+// NewServiceInfo 根据服务监听的地址创建 ServiceInfo，监听的是 0.0.0.0 等通配地址时 Addr 留空，
+// 由接收方填入广播的来源地址
+func NewServiceInfo(name string, addr net.Addr) ServiceInfo {
+	info := ServiceInfo{Name: name}
+	if ta, ok := addr.(*net.TCPAddr); ok {
+		info.Port = ta.Port
+		if !ta.IP.IsUnspecified() {
+			info.Addr = ta.IP.String()
+		}
+		return info
+	}
+	host, port, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return info
+	}
+	info.Port, _ = strconv.Atoi(port)
+	if ip := net.ParseIP(host); ip == nil || !ip.IsUnspecified() {
+		info.Addr = host
+	}
+	return info
+}
+
+// HostPort 返回可以直接用于 Dial 的 "host:port"
+func (s ServiceInfo) HostPort() string {
+	return net.JoinHostPort(s.Addr, strconv.Itoa(s.Port))
+}
+
+// Announcer 定期广播服务信息
+type Announcer struct {
+	// Info 广播的服务信息
+	Info ServiceInfo
+
+	// Target 广播的目的地址，为空时使用 255.255.255.255:DefaultPort；
+	// 有多个网卡时可以改成某个网段的定向广播地址，例如 192.168.1.255:8999
+	Target string
+
+	// Interval 广播间隔，为 0 时使用 DefaultInterval
+	Interval time.Duration
+
+	// Logger 为 nil 时不输出日志
+	Logger logx.Logger
+}
+
+// Run 立即广播一次，之后每隔 Interval 广播一次，直到 ctx 结束后返回 nil
+func (a *Announcer) Run(ctx context.Context) error {
+	target := a.Target
+	if target == "" {
+		target = net.JoinHostPort(net.IPv4bcast.String(), strconv.Itoa(DefaultPort))
+	}
+	taddr, err := net.ResolveUDPAddr("udp4", target)
+	if err != nil {
+		return err
+	}
+	interval := a.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	pkt, err := encode(a.Info)
+	if err != nil {
+		return err
+	}
+	// Go 创建的 UDP 套接字默认开启了 SO_BROADCAST，可以直接发送到广播地址
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	logger := a.logger()
+	logger.Infof("开始广播服务 %s 到 %s", a.Info.Name, taddr)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := conn.WriteTo(pkt, taddr); err != nil {
+			logger.Errorf("广播服务信息失败 %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Announcer) logger() logx.Logger {
+	if a.Logger == nil {
+		return logx.Nop
+	}
+	return a.Logger
+}
+
+// Option Discover 和 Lookup 的可选配置
+type Option func(o *options)
+
+type options struct {
+	addr string
+}
+
+// WithListenAddr 设置收听广播的地址，默认为 ":DefaultPort"，需要与 Announcer.Target 的端口一致
+func WithListenAddr(addr string) Option {
+	return func(o *options) {
+		o.addr = addr
+	}
+}
+
+// Discover 收听广播直到 ctx 结束，返回期间发现的所有服务，按名称和地址排序。
+// ctx 需要设置超时，一般不短于 Announcer 的广播间隔。
+func Discover(ctx context.Context, opts ...Option) ([]ServiceInfo, error) {
+	seen := make(map[ServiceInfo]bool)
+	err := listen(ctx, opts, func(info ServiceInfo) bool {
+		seen[info] = true
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	services := make([]ServiceInfo, 0, len(seen))
+	for info := range seen {
+		services = append(services, info)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Name != services[j].Name {
+			return services[i].Name < services[j].Name
+		}
+		return services[i].HostPort() < services[j].HostPort()
+	})
+	return services, nil
+}
+
+// Lookup 收听广播，返回第一个名称为 name 的服务；ctx 结束前没有发现时返回 ErrNotFound
+func Lookup(ctx context.Context, name string, opts ...Option) (ServiceInfo, error) {
+	var found *ServiceInfo
+	err := listen(ctx, opts, func(info ServiceInfo) bool {
+		if info.Name != name {
+			return true
+		}
+		found = &info
+		return false
+	})
+	if err != nil {
+		return ServiceInfo{}, err
+	}
+	if found == nil {
+		return ServiceInfo{}, ErrNotFound
+	}
+	return *found, nil
+}
+
+// listen 把收到的服务信息交给 f，直到 f 返回 false 或者 ctx 结束
+func listen(ctx context.Context, opts []Option, f func(info ServiceInfo) bool) error {
+	o := options{addr: ":" + strconv.Itoa(DefaultPort)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	// 同一台机器上的多个进程可以同时收听
+	lc := net.ListenConfig{Control: reuseAddr}
+	pc, err := lc.ListenPacket(ctx, "udp4", o.addr)
+	if err != nil {
+		return err
+	}
+	defer pc.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			pc.SetReadDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		info, ok := decode(buf[:n])
+		if !ok {
+			continue
+		}
+		if info.Addr == "" {
+			if ua, ok := addr.(*net.UDPAddr); ok {
+				info.Addr = ua.IP.String()
+			}
+		}
+		if !f(info) {
+			return nil
+		}
+	}
+}
+
+func encode(info ServiceInfo) ([]byte, error) {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte(nil), magic...), b...), nil
+}
+
+func decode(b []byte) (ServiceInfo, bool) {
+	var info ServiceInfo
+	if !bytes.HasPrefix(b, magic) {
+		return info, false
+	}
+	if err := json.Unmarshal(b[len(magic):], &info); err != nil || info.Name == "" {
+		return info, false
+	}
+	return info, true
+}
@@ -0,0 +1,111 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// freePort 返回一个空闲的 UDP 端口
+func freePort(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	return strconv.Itoa(pc.LocalAddr().(*net.UDPAddr).Port)
+}
+
+// announce 在后台运行 a，测试结束时停止
+func announce(t *testing.T, a *Announcer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- a.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run() = %v, want nil", err)
+		}
+	})
+}
+
+func TestNewServiceInfo(t *testing.T) {
+	tests := []struct {
+		addr net.Addr
+		want ServiceInfo
+	}{
+		{&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8001}, ServiceInfo{Name: "s", Addr: "127.0.0.1", Port: 8001}},
+		{&net.TCPAddr{IP: net.IPv4zero, Port: 8001}, ServiceInfo{Name: "s", Port: 8001}},
+		{&net.UDPAddr{IP: net.IPv6unspecified, Port: 3000}, ServiceInfo{Name: "s", Port: 3000}},
+		{&net.UDPAddr{IP: net.ParseIP("::1"), Port: 3000}, ServiceInfo{Name: "s", Addr: "::1", Port: 3000}},
+	}
+	for _, tt := range tests {
+		if got := NewServiceInfo("s", tt.addr); got != tt.want {
+			t.Errorf("NewServiceInfo(%v) = %+v, want %+v", tt.addr, got, tt.want)
+		}
+	}
+	if got := (ServiceInfo{Addr: "::1", Port: 80}).HostPort(); got != "[::1]:80" {
+		t.Errorf("HostPort() = %q, want %q", got, "[::1]:80")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	port := freePort(t)
+	target := "127.0.0.1:" + port
+	announce(t, &Announcer{Info: ServiceInfo{Name: "b", Addr: "10.0.0.1", Port: 2}, Target: target, Interval: 10 * time.Millisecond})
+	// Addr 为空时使用来源地址
+	announce(t, &Announcer{Info: ServiceInfo{Name: "a", Port: 1}, Target: target, Interval: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	got, err := Discover(ctx, WithListenAddr(target))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ServiceInfo{{Name: "a", Addr: "127.0.0.1", Port: 1}, {Name: "b", Addr: "10.0.0.1", Port: 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Discover() = %+v, want %+v", got, want)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if info, err := Lookup(ctx, "b", WithListenAddr(target)); err != nil || info.HostPort() != "10.0.0.1:2" {
+		t.Errorf("Lookup(b) = %+v, %v", info, err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := Lookup(ctx, "c", WithListenAddr(target)); err != ErrNotFound {
+		t.Errorf("Lookup(c) = %v, want %v", err, ErrNotFound)
+	}
+}
+
+// TestBroadcast 同一台机器上的多个进程同时收听广播
+func TestBroadcast(t *testing.T) {
+	port := freePort(t)
+	announce(t, &Announcer{
+		Info:     ServiceInfo{Name: "svc", Addr: "127.0.0.1", Port: 8001},
+		Target:   "255.255.255.255:" + port,
+		Interval: 10 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := Lookup(ctx, "svc", WithListenAddr(":"+port))
+			results <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-results; err == ErrNotFound {
+			t.Skip("no broadcast received, broadcast may be unavailable on this host")
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
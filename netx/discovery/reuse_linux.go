@@ -0,0 +1,15 @@
+package discovery
+
+import "syscall"
+
+// reuseAddr 允许多个套接字绑定同一个 UDP 端口，Linux 上每个套接字都能收到广播
+func reuseAddr(network, address string, c syscall.RawConn) error {
+	var serr error
+	err := c.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package discovery
+
+import "syscall"
+
+// reuseAddr 当前平台不支持端口复用，同一台机器上只能有一个进程收听
+func reuseAddr(network, address string, c syscall.RawConn) error {
+	return nil
+}
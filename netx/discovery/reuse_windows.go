@@ -0,0 +1,15 @@
+package discovery
+
+import "syscall"
+
+// reuseAddr 允许多个套接字绑定同一个 UDP 端口
+func reuseAddr(network, address string, c syscall.RawConn) error {
+	var serr error
+	err := c.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
@@ -0,0 +1,259 @@
+package netx
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType 连接生命周期事件的类型
+type EventType uint8
+
+const (
+	EventAccepted      EventType = iota + 1 // 接受了一个新连接
+	EventHandshakeDone                      // TLS 握手或者认证握手（Server.Auth）完成
+	EventFrameReceived                      // Handler 中通过 NewClient 收到一个消息
+	EventClosed                             // 连接处理结束
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventAccepted:
+		return "accepted"
+	case EventHandshakeDone:
+		return "handshake_done"
+	case EventFrameReceived:
+		return "frame_received"
+	case EventClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// Event 连接生命周期事件，各个字段只在注释中说明的事件中有值
+type Event struct {
+	Type   EventType
+	Time   time.Time
+	ConnID uint64 // 同一个 Server 上从 1 开始递增，关联同一个连接的事件
+	Local  net.Addr
+	Remote net.Addr
+
+	TLS     *tls.ConnectionState // EventHandshakeDone：TLS 握手完成时为连接的状态
+	Session *Session             // EventHandshakeDone：认证通过时为新的会话
+
+	Size int // EventFrameReceived：消息的字节数
+
+	Frames       uint64        // EventClosed：收到的消息数
+	BytesRead    uint64        // EventClosed：读取的字节数
+	BytesWritten uint64        // EventClosed：写入的字节数
+	Duration     time.Duration // EventClosed：从 Accept 到处理结束的时间
+	Err          error         // EventClosed：关闭的原因，Handler 正常返回时为 nil
+}
+
+// Reason 关闭原因的文字描述
+func (e Event) Reason() string {
+	switch e.Err {
+	case nil:
+		return "handler returned"
+	case io.EOF:
+		return "peer closed"
+	}
+	return e.Err.Error()
+}
+
+// EventLogger 接收连接生命周期事件，可能在多个 goroutine 中同时调用，
+// 在连接的 goroutine 中同步调用，不能阻塞太久
+type EventLogger interface {
+	LogEvent(e Event)
+}
+
+// EventLoggerFunc 把函数转换为 EventLogger
+type EventLoggerFunc func(e Event)
+
+func (f EventLoggerFunc) LogEvent(e Event) {
+	f(e)
+}
+
+// JSONEventLogger 把事件以 JSON Lines 的格式写入 W，每个事件一行，作为类似 HTTP 服务的访问日志
+// 默认不记录数量很多的 EventFrameReceived，Frames 为 true 时才记录。
+type JSONEventLogger struct {
+	W      io.Writer
+	Frames bool
+
+	mu sync.Mutex
+}
+
+// NewJSONEventLogger 返回写入 w 的 JSONEventLogger
+func NewJSONEventLogger(w io.Writer) *JSONEventLogger {
+	return &JSONEventLogger{W: w}
+}
+
+// jsonEvent JSONEventLogger 输出的一行
+type jsonEvent struct {
+	Time         string   `json:"time"`
+	Event        string   `json:"event"`
+	Conn         uint64   `json:"conn"`
+	Remote       string   `json:"remote,omitempty"`
+	Local        string   `json:"local,omitempty"`
+	Handshake    string   `json:"handshake,omitempty"`
+	TLSVersion   string   `json:"tls_version,omitempty"`
+	ServerName   string   `json:"sni,omitempty"`
+	Size         *int     `json:"size,omitempty"`
+	Frames       *uint64  `json:"frames,omitempty"`
+	BytesRead    *uint64  `json:"bytes_in,omitempty"`
+	BytesWritten *uint64  `json:"bytes_out,omitempty"`
+	DurationMS   *float64 `json:"duration_ms,omitempty"`
+	Reason       string   `json:"reason,omitempty"`
+}
+
+func (l *JSONEventLogger) LogEvent(e Event) {
+	if e.Type == EventFrameReceived && !l.Frames {
+		return
+	}
+	je := jsonEvent{
+		Time:   e.Time.Format(time.RFC3339Nano),
+		Event:  e.Type.String(),
+		Conn:   e.ConnID,
+		Remote: addrString(e.Remote),
+		Local:  addrString(e.Local),
+	}
+	switch e.Type {
+	case EventHandshakeDone:
+		if e.Session != nil {
+			je.Handshake = "auth"
+		}
+		if e.TLS != nil {
+			je.Handshake = "tls"
+			je.TLSVersion = tlsVersionName(e.TLS.Version)
+			je.ServerName = e.TLS.ServerName
+		}
+	case EventFrameReceived:
+		je.Size = &e.Size
+	case EventClosed:
+		ms := float64(e.Duration) / float64(time.Millisecond)
+		je.Frames, je.BytesRead, je.BytesWritten, je.DurationMS = &e.Frames, &e.BytesRead, &e.BytesWritten, &ms
+		je.Reason = e.Reason()
+	}
+	b, err := json.Marshal(je)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.W.Write(append(b, '\n'))
+}
+
+func addrString(a net.Addr) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return "0x" + strconv.FormatUint(uint64(v), 16)
+}
+
+// connEvents 一个连接的事件和统计，方法在 nil 上调用时什么都不做
+type connEvents struct {
+	log    EventLogger
+	id     uint64
+	local  net.Addr
+	remote net.Addr
+	start  time.Time
+
+	frames       uint64
+	bytesRead    uint64
+	bytesWritten uint64
+
+	mu  sync.Mutex
+	err error // 第一个导致连接结束的错误
+}
+
+func newConnEvents(log EventLogger, id uint64, conn net.Conn) *connEvents {
+	return &connEvents{
+		log:    log,
+		id:     id,
+		local:  conn.LocalAddr(),
+		remote: conn.RemoteAddr(),
+		start:  time.Now(),
+	}
+}
+
+func (ev *connEvents) event(t EventType) Event {
+	return Event{Type: t, Time: time.Now(), ConnID: ev.id, Local: ev.local, Remote: ev.remote}
+}
+
+func (ev *connEvents) accepted() {
+	ev.log.LogEvent(ev.event(EventAccepted))
+}
+
+func (ev *connEvents) handshakeDone(state *tls.ConnectionState, sess *Session) {
+	if ev == nil {
+		return
+	}
+	e := ev.event(EventHandshakeDone)
+	e.TLS, e.Session = state, sess
+	ev.log.LogEvent(e)
+}
+
+func (ev *connEvents) frameReceived(size int) {
+	if ev == nil {
+		return
+	}
+	atomic.AddUint64(&ev.frames, 1)
+	e := ev.event(EventFrameReceived)
+	e.Size = size
+	ev.log.LogEvent(e)
+}
+
+func (ev *connEvents) addRead(n int) {
+	if ev != nil {
+		atomic.AddUint64(&ev.bytesRead, uint64(n))
+	}
+}
+
+func (ev *connEvents) addWritten(n int) {
+	if ev != nil {
+		atomic.AddUint64(&ev.bytesWritten, uint64(n))
+	}
+}
+
+// fail 记录连接结束的原因，只保留第一个
+func (ev *connEvents) fail(err error) {
+	if ev == nil {
+		return
+	}
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	if ev.err == nil {
+		ev.err = err
+	}
+}
+
+func (ev *connEvents) closed() {
+	e := ev.event(EventClosed)
+	e.Frames = atomic.LoadUint64(&ev.frames)
+	e.BytesRead = atomic.LoadUint64(&ev.bytesRead)
+	e.BytesWritten = atomic.LoadUint64(&ev.bytesWritten)
+	e.Duration = e.Time.Sub(ev.start)
+	ev.mu.Lock()
+	e.Err = ev.err
+	ev.mu.Unlock()
+	ev.log.LogEvent(e)
+}
@@ -0,0 +1,160 @@
+package netx
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer 可以在多个 goroutine 中同时使用的 bytes.Buffer
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+// waitEvents 等待 ch 中收到 n 个事件
+func waitEvents(t *testing.T, ch <-chan Event, n int) []Event {
+	t.Helper()
+	var events []Event
+	for len(events) < n {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		case <-time.After(time.Second):
+			t.Fatalf("got %d events, want %d", len(events), n)
+		}
+	}
+	return events
+}
+
+func TestServerEventLog(t *testing.T) {
+	var buf syncBuffer
+	jl := NewJSONEventLogger(&buf)
+	jl.Frames = true
+	ch := make(chan Event, 10)
+	s := NewTCPServer("", HandleFrames(FrameHandlerFunc(func(ctx context.Context, req []byte) ([]byte, error) {
+		return req, nil
+	})))
+	s.EventLog = EventLoggerFunc(func(e Event) {
+		jl.LogEvent(e)
+		ch <- e
+	})
+	addr := startTCPServer(t, s)
+
+	c, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo(c, "hello")
+	echo(c, "world")
+	c.Close()
+
+	events := waitEvents(t, ch, 4)
+	want := []EventType{EventAccepted, EventFrameReceived, EventFrameReceived, EventClosed}
+	for i, e := range events {
+		if e.Type != want[i] || e.ConnID != 1 {
+			t.Errorf("event %d = %v on conn %d, want %v on conn 1", i, e.Type, e.ConnID, want[i])
+		}
+	}
+	closed := events[3]
+	if closed.Err != io.EOF || closed.Reason() != "peer closed" || closed.Frames != 2 ||
+		closed.BytesRead != 2*(4+5) || closed.BytesWritten != 2*(4+5) || closed.Duration <= 0 {
+		t.Errorf("closed event = %+v", closed)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace([]byte(buf.String())), []byte("\n"))
+	if len(lines) != 4 {
+		t.Fatalf("access log has %d lines, want 4:\n%s", len(lines), buf.String())
+	}
+	var last map[string]any
+	if err := json.Unmarshal(lines[3], &last); err != nil {
+		t.Fatal(err)
+	}
+	if last["event"] != "closed" || last["reason"] != "peer closed" || last["frames"] != 2.0 ||
+		last["remote"] != c.Conn().LocalAddr().String() {
+		t.Errorf("closed line = %s", lines[3])
+	}
+}
+
+func TestServerEventLogShutdown(t *testing.T) {
+	ch := make(chan Event, 10)
+	started := make(chan struct{})
+	s := &Server{
+		EventLog: EventLoggerFunc(func(e Event) { ch <- e }),
+		Handler: func(ctx context.Context, conn net.Conn) {
+			close(started)
+			<-ctx.Done()
+		},
+	}
+	addr := startTCPServer(t, s)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	<-started
+	s.Shutdown(context.Background())
+	if e := waitEvents(t, ch, 2)[1]; e.Type != EventClosed || e.Err != ErrServerClosed {
+		t.Errorf("event = %v, %v, want closed by ErrServerClosed", e.Type, e.Err)
+	}
+}
+
+func TestServerEventLogTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	server := newTestCert(t, "server", ca)
+	config, err := ServerTLSConfig(server.certFile, server.keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan Event, 10)
+	s := &Server{
+		TLSConfig: config,
+		EventLog:  EventLoggerFunc(func(e Event) { ch <- e }),
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServeTLS(l, "", "")
+	defer s.Shutdown(context.Background())
+
+	c, err := DialTLS(l.Addr().String(), &tls.Config{InsecureSkipVerify: true, ServerName: "netx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	events := waitEvents(t, ch, 3)
+	if e := events[1]; e.Type != EventHandshakeDone || e.TLS == nil || e.TLS.ServerName != "netx" {
+		t.Errorf("event = %+v, want handshake done with SNI", e)
+	}
+
+	// 握手失败时没有 EventHandshakeDone，关闭原因是握手的错误
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("not a tls client hello"))
+	conn.Close()
+	events = waitEvents(t, ch, 2)
+	if e := events[1]; e.Type != EventClosed || e.Err == nil {
+		t.Errorf("event = %v, %v, want closed by the handshake error", e.Type, e.Err)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"gopractice/netx"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// benchConfig bench 的参数，通过 -c、-m 和 -size 设置
+type benchConfig struct {
+	Conns int // 同时使用的连接数
+	Msgs  int // 每个连接发送的消息数
+	Size  int // 每个消息的字节数
+}
+
+var benchCfg = benchConfig{Conns: 10, Msgs: 1000, Size: 64}
+
+// benchResult bench 的结果
+type benchResult struct {
+	Msgs      int // 成功收到回复的消息数
+	Errors    int // 连接失败、收发出错或者回复不一致的消息数
+	Bytes     int64
+	Elapsed   time.Duration
+	Latencies []time.Duration // 每个成功的消息从发送到收到回复的时间，已排序
+}
+
+// Bench 压测 tcp 服务端：benchCfg.Conns 个连接同时按顺序发送 benchCfg.Msgs 个 msgEcho 消息并等待回复，
+// 结束后输出吞吐量、延迟分位数和错误数，用来比较封包方式和服务端的修改
+func Bench(ctx context.Context) error {
+	cfg := benchCfg
+	if cfg.Conns <= 0 || cfg.Msgs <= 0 || cfg.Size < 0 {
+		return errInvalidArgs
+	}
+	r := runBench(ctx, serverAddr(), cfg)
+	r.report(os.Stdout, cfg)
+	return nil
+}
+
+func runBench(ctx context.Context, addr string, cfg benchConfig) benchResult {
+	payload := bytes.Repeat([]byte{'x'}, cfg.Size)
+	msg := netx.AppendMsg(nil, msgEcho, payload)
+
+	var mu sync.Mutex
+	var result benchResult
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < cfg.Conns; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lat, errs := benchConn(ctx, addr, msg, cfg.Msgs)
+			mu.Lock()
+			defer mu.Unlock()
+			result.Latencies = append(result.Latencies, lat...)
+			result.Errors += errs
+		}()
+	}
+	wg.Wait()
+	result.Elapsed = time.Since(start)
+	result.Msgs = len(result.Latencies)
+	result.Bytes = int64(result.Msgs) * int64(len(msg)) * 2
+	sort.Slice(result.Latencies, func(i, j int) bool { return result.Latencies[i] < result.Latencies[j] })
+	return result
+}
+
+// benchConn 在一个连接上发送 n 个 msg，返回成功的消息的延迟和失败的消息数
+// 连接出错之后剩下的消息都算作失败。
+func benchConn(ctx context.Context, addr string, msg []byte, n int) ([]time.Duration, int) {
+	c, err := netx.DialContext(ctx, addr)
+	if err != nil {
+		logger.Errorf("bench: 连接服务端失败 %v", err)
+		return nil, n
+	}
+	defer c.Close()
+
+	lat := make([]time.Duration, 0, n)
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		if err := c.Send(msg); err != nil {
+			logger.Errorf("bench: 发送失败 %v", err)
+			return lat, n - i
+		}
+		reply, err := c.RecvContext(ctx)
+		if err != nil {
+			logger.Errorf("bench: 接收失败 %v", err)
+			return lat, n - i
+		}
+		if !bytes.Equal(reply, msg) {
+			logger.Errorf("bench: 回复与请求不一致")
+			return lat, n - i
+		}
+		lat = append(lat, time.Since(start))
+	}
+	return lat, 0
+}
+
+// percentile 返回第 p 百分位的延迟，没有成功的消息时返回 0
+func (r benchResult) percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(r.Latencies))*p/100+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(r.Latencies) {
+		i = len(r.Latencies) - 1
+	}
+	return r.Latencies[i]
+}
+
+func (r benchResult) report(w io.Writer, cfg benchConfig) {
+	secs := r.Elapsed.Seconds()
+	fmt.Fprintf(w, "连接数 %d，每个连接 %d 个消息，消息大小 %d 字节，耗时 %v\n", cfg.Conns, cfg.Msgs, cfg.Size, r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "成功 %d，失败 %d\n", r.Msgs, r.Errors)
+	if secs > 0 {
+		fmt.Fprintf(w, "吞吐量 %.0f msg/s，%.2f MB/s（收发合计）\n", float64(r.Msgs)/secs, float64(r.Bytes)/secs/(1<<20))
+	}
+	fmt.Fprintf(w, "延迟 p50 %v，p95 %v，p99 %v\n", r.percentile(50), r.percentile(95), r.percentile(99))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunBench(t *testing.T) {
+	useRecorder(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serveTCP(ctx, l) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	cfg := benchConfig{Conns: 4, Msgs: 50, Size: 32}
+	r := runBench(context.Background(), l.Addr().String(), cfg)
+	if r.Msgs != 200 || r.Errors != 0 || r.Bytes != 200*2*(2+32) {
+		t.Fatalf("runBench() = %d msgs, %d errors, %d bytes", r.Msgs, r.Errors, r.Bytes)
+	}
+	if p50, p99 := r.percentile(50), r.percentile(99); p50 <= 0 || p50 > p99 {
+		t.Errorf("p50 = %v, p99 = %v", p50, p99)
+	}
+	var sb strings.Builder
+	r.report(&sb, cfg)
+	if !strings.Contains(sb.String(), "成功 200，失败 0") {
+		t.Errorf("report = %q", sb.String())
+	}
+
+	// 连接失败的消息都算作错误
+	l.Close()
+	if r := runBench(context.Background(), l.Addr().String(), benchConfig{Conns: 2, Msgs: 3}); r.Msgs != 0 || r.Errors != 6 {
+		t.Errorf("runBench() against a closed port = %d msgs, %d errors, want 0, 6", r.Msgs, r.Errors)
+	}
+}
+
+func TestBenchPercentile(t *testing.T) {
+	var r benchResult
+	if got := r.percentile(99); got != 0 {
+		t.Errorf("percentile of no latencies = %v", got)
+	}
+	for i := 1; i <= 100; i++ {
+		r.Latencies = append(r.Latencies, time.Duration(i)*time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{50: 50 * time.Millisecond, 95: 95 * time.Millisecond, 99: 99 * time.Millisecond, 100: 100 * time.Millisecond} {
+		if got := r.percentile(p); got != want {
+			t.Errorf("percentile(%v) = %v, want %v", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"gopractice/netx"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// chatAddr 聊天室服务端监听的地址
+var chatAddr = "127.0.0.1:8004"
+
+// chatNick 聊天室客户端的昵称，通过 -nick 设置
+var chatNick string
+
+// 聊天室的消息类型，请求和回复使用相同的类型，msgChatEvent 是服务端主动推送的消息
+const (
+	msgChatJoin uint16 = iota + 100
+	msgChatLeave
+	msgChatSay
+	msgChatPrivate
+	msgChatNick
+	msgChatEvent
+)
+
+// 推送消息的种类
+const (
+	chatWelcome = "welcome" // 连接成功，Nick 为服务端分配的昵称
+	chatJoined  = "join"    // 有人进入房间
+	chatLeft    = "leave"   // 有人离开房间
+	chatSaid    = "say"     // 房间内的消息
+	chatWhisper = "private" // 私聊消息
+	chatRenamed = "nick"    // 有人修改了昵称，Text 为新的昵称
+)
+
+type chatRoomReq struct {
+	Room string `json:"room"`
+}
+
+type chatSayReq struct {
+	Room string `json:"room"`
+	Text string `json:"text"`
+}
+
+type chatPrivateReq struct {
+	To   string `json:"to"`
+	Text string `json:"text"`
+}
+
+type chatNickReq struct {
+	Nick string `json:"nick"`
+}
+
+// chatReply 所有请求的回复，Err 不为空表示请求失败，连接不会因此断开
+type chatReply struct {
+	Err     string   `json:"err,omitempty"`
+	Members []string `json:"members,omitempty"` // 进入房间时房间内的成员
+}
+
+type chatEvent struct {
+	Kind string `json:"kind"`
+	Room string `json:"room,omitempty"`
+	Nick string `json:"nick,omitempty"`
+	Text string `json:"text,omitempty"`
+}
+
+var (
+	errChatBadNick   = errors.New("昵称不能为空，也不能包含空白字符")
+	errChatNickTaken = errors.New("昵称已经被使用")
+	errChatNoRoom    = errors.New("还没有进入这个房间")
+	errChatNoUser    = errors.New("用户不在线")
+)
+
+// chatUser 一个在线用户，key 为 Session.ID
+type chatUser struct {
+	id    uint64
+	nick  string
+	rooms map[string]struct{}
+}
+
+// chatServer 多房间聊天室：连接时以昵称认证，房间是 Hub 的主题，私聊用 Hub.SendTo
+type chatServer struct {
+	hub    *netx.Hub
+	router *netx.Router
+
+	mu    sync.Mutex
+	users map[uint64]*chatUser
+	nicks map[string]*chatUser
+}
+
+func newChatServer() *chatServer {
+	cs := &chatServer{
+		hub:    netx.NewHub(),
+		router: netx.NewRouter(),
+		users:  make(map[uint64]*chatUser),
+		nicks:  make(map[string]*chatUser),
+	}
+	cs.hub.Logger = logger
+	netx.HandleValue(cs.router, msgChatJoin, cs.join)
+	netx.HandleValue(cs.router, msgChatLeave, cs.leave)
+	netx.HandleValue(cs.router, msgChatSay, cs.say)
+	netx.HandleValue(cs.router, msgChatPrivate, cs.private)
+	netx.HandleValue(cs.router, msgChatNick, cs.rename)
+	return cs
+}
+
+// ServerChat 聊天室服务端，ctx 结束时优雅关闭
+func ServerChat(ctx context.Context) error {
+	l, err := net.Listen("tcp", chatAddr)
+	if err != nil {
+		return err
+	}
+	return serveChat(ctx, l)
+}
+
+func serveChat(ctx context.Context, l net.Listener) error {
+	cs := newChatServer()
+	s := netx.NewTCPServer("", netx.HandlerFunc(cs.serve))
+	s.Logger = logger
+	s.Codec = payloadCodec
+	s.IdleTimeout = idleTimeout
+	s.EventLog = accessLog
+	s.Auth = func(ctx context.Context, conn net.Conn, msg []byte) (any, error) {
+		if !validNick(string(msg)) {
+			return nil, errChatBadNick
+		}
+		return string(msg), nil
+	}
+	return serveUntil(ctx, s, func() error {
+		return s.Serve(l)
+	})
+}
+
+func validNick(nick string) bool {
+	return nick != "" && len(nick) <= 32 && !strings.ContainsAny(nick, " \t\r\n")
+}
+
+// serve 处理一个已经认证的连接：登记昵称、加入 Hub，然后由 router 处理请求
+func (cs *chatServer) serve(ctx context.Context, conn *netx.Conn) {
+	sess, _ := netx.SessionFrom(ctx)
+	u := cs.register(sess.ID, sess.User.(string))
+	if err := cs.hub.Join(u.id, conn); err != nil {
+		logger.Errorf("加入 Hub 失败 %v", err)
+		return
+	}
+	defer cs.unregister(u)
+	defer cs.hub.Leave(u.id)
+	logger.Infof("%s 上线了（%v）", u.nick, conn.RemoteAddr())
+
+	cs.push(u.id, chatEvent{Kind: chatWelcome, Nick: u.nick})
+	cs.router.ServeConn(ctx, conn)
+}
+
+// register 登记在线用户，昵称已经被使用时加上会话 ID 作为后缀
+func (cs *chatServer) register(id uint64, nick string) *chatUser {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if _, ok := cs.nicks[nick]; ok {
+		nick = fmt.Sprintf("%s#%d", nick, id)
+	}
+	u := &chatUser{id: id, nick: nick, rooms: make(map[string]struct{})}
+	cs.users[id] = u
+	cs.nicks[nick] = u
+	return u
+}
+
+// unregister 用户下线，离开所有房间
+func (cs *chatServer) unregister(u *chatUser) {
+	cs.mu.Lock()
+	delete(cs.users, u.id)
+	delete(cs.nicks, u.nick)
+	rooms := make([]string, 0, len(u.rooms))
+	for room := range u.rooms {
+		rooms = append(rooms, room)
+	}
+	nick := u.nick
+	cs.mu.Unlock()
+
+	for _, room := range rooms {
+		cs.publish(room, chatEvent{Kind: chatLeft, Room: room, Nick: nick})
+	}
+	logger.Infof("%s 下线了", nick)
+}
+
+// user 返回 ctx 所属连接的用户
+func (cs *chatServer) user(ctx context.Context) *chatUser {
+	sess, _ := netx.SessionFrom(ctx)
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.users[sess.ID]
+}
+
+func (cs *chatServer) join(ctx context.Context, req *chatRoomReq) (*chatReply, error) {
+	u := cs.user(ctx)
+	if req.Room == "" {
+		return &chatReply{Err: "房间名不能为空"}, nil
+	}
+	cs.mu.Lock()
+	u.rooms[req.Room] = struct{}{}
+	nick := u.nick
+	cs.mu.Unlock()
+	cs.publish(req.Room, chatEvent{Kind: chatJoined, Room: req.Room, Nick: nick})
+	cs.hub.Subscribe(u.id, chatTopic(req.Room))
+	return &chatReply{Members: cs.members(req.Room)}, nil
+}
+
+func (cs *chatServer) leave(ctx context.Context, req *chatRoomReq) (*chatReply, error) {
+	u := cs.user(ctx)
+	cs.mu.Lock()
+	_, ok := u.rooms[req.Room]
+	delete(u.rooms, req.Room)
+	nick := u.nick
+	cs.mu.Unlock()
+	if !ok {
+		return &chatReply{Err: errChatNoRoom.Error()}, nil
+	}
+	cs.hub.Unsubscribe(u.id, chatTopic(req.Room))
+	cs.publish(req.Room, chatEvent{Kind: chatLeft, Room: req.Room, Nick: nick})
+	return &chatReply{}, nil
+}
+
+func (cs *chatServer) say(ctx context.Context, req *chatSayReq) (*chatReply, error) {
+	u := cs.user(ctx)
+	cs.mu.Lock()
+	_, ok := u.rooms[req.Room]
+	nick := u.nick
+	cs.mu.Unlock()
+	if !ok {
+		return &chatReply{Err: errChatNoRoom.Error()}, nil
+	}
+	cs.publish(req.Room, chatEvent{Kind: chatSaid, Room: req.Room, Nick: nick, Text: req.Text})
+	return &chatReply{}, nil
+}
+
+func (cs *chatServer) private(ctx context.Context, req *chatPrivateReq) (*chatReply, error) {
+	u := cs.user(ctx)
+	cs.mu.Lock()
+	to := cs.nicks[req.To]
+	nick := u.nick
+	cs.mu.Unlock()
+	if to == nil {
+		return &chatReply{Err: errChatNoUser.Error()}, nil
+	}
+	cs.push(to.id, chatEvent{Kind: chatWhisper, Nick: nick, Text: req.Text})
+	return &chatReply{}, nil
+}
+
+func (cs *chatServer) rename(ctx context.Context, req *chatNickReq) (*chatReply, error) {
+	if !validNick(req.Nick) {
+		return &chatReply{Err: errChatBadNick.Error()}, nil
+	}
+	u := cs.user(ctx)
+	cs.mu.Lock()
+	if _, ok := cs.nicks[req.Nick]; ok {
+		cs.mu.Unlock()
+		return &chatReply{Err: errChatNickTaken.Error()}, nil
+	}
+	old := u.nick
+	delete(cs.nicks, old)
+	u.nick = req.Nick
+	cs.nicks[req.Nick] = u
+	rooms := make([]string, 0, len(u.rooms))
+	for room := range u.rooms {
+		rooms = append(rooms, room)
+	}
+	cs.mu.Unlock()
+
+	for _, room := range rooms {
+		cs.publish(room, chatEvent{Kind: chatRenamed, Room: room, Nick: old, Text: req.Nick})
+	}
+	return &chatReply{}, nil
+}
+
+// members 返回房间内所有成员的昵称，按字母顺序
+func (cs *chatServer) members(room string) []string {
+	ids := cs.hub.Subscribers(chatTopic(room))
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	nicks := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if u := cs.users[id]; u != nil {
+			nicks = append(nicks, u.nick)
+		}
+	}
+	sort.Strings(nicks)
+	return nicks
+}
+
+func (cs *chatServer) publish(room string, ev chatEvent) {
+	if b, err := encodeChatEvent(ev); err == nil {
+		cs.hub.Publish(chatTopic(room), b)
+	}
+}
+
+func (cs *chatServer) push(id uint64, ev chatEvent) {
+	if b, err := encodeChatEvent(ev); err == nil {
+		cs.hub.SendTo(id, b)
+	}
+}
+
+func chatTopic(room string) string {
+	return "room:" + room
+}
+
+func encodeChatEvent(ev chatEvent) ([]byte, error) {
+	b, err := payloadCodec.Encode(ev)
+	if err != nil {
+		return nil, err
+	}
+	return netx.AppendMsg(nil, msgChatEvent, b), nil
+}
+
+// ClientChat 聊天室客户端，从标准输入读取命令：
+//
+//	/join 房间    进入房间，之后直接输入的文字发到最近进入的房间
+//	/leave 房间   离开房间
+//	/msg 昵称 内容 私聊
+//	/nick 昵称    修改昵称
+//	/quit        退出
+func ClientChat(ctx context.Context) error {
+	nick := chatNick
+	if nick == "" {
+		nick = os.Getenv("USER")
+	}
+	c, err := netx.DialContext(ctx, chatAddr, netx.WithCodec(payloadCodec))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if _, err := c.Authenticate(ctx, []byte(nick)); err != nil {
+		return err
+	}
+
+	go func() {
+		if err := printChat(c, os.Stdout); err != nil && err != io.EOF {
+			logger.Errorf("连接断开 %v", err)
+		}
+		c.Close()
+	}()
+	return runChatCommands(c, os.Stdin)
+}
+
+// runChatCommands 把 r 中的每一行转换为请求发送出去，回复由 printChat 输出
+func runChatCommands(c *netx.Client, r io.Reader) error {
+	var room string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var err error
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch cmd {
+		case "/join":
+			room = arg
+			err = c.SendMsg(msgChatJoin, chatRoomReq{Room: arg})
+		case "/leave":
+			err = c.SendMsg(msgChatLeave, chatRoomReq{Room: arg})
+		case "/msg":
+			to, text, _ := strings.Cut(arg, " ")
+			err = c.SendMsg(msgChatPrivate, chatPrivateReq{To: to, Text: text})
+		case "/nick":
+			err = c.SendMsg(msgChatNick, chatNickReq{Nick: arg})
+		case "/quit":
+			return nil
+		default:
+			if room == "" {
+				fmt.Println("先用 /join 房间名 进入一个房间")
+				continue
+			}
+			err = c.SendMsg(msgChatSay, chatSayReq{Room: room, Text: line})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// printChat 把服务端的回复和推送逐行写到 w，直到连接断开
+func printChat(c *netx.Client, w io.Writer) error {
+	for {
+		b, err := c.Recv()
+		if err != nil {
+			return err
+		}
+		typ, payload, err := netx.SplitMsg(b)
+		if err != nil {
+			return err
+		}
+		if typ != msgChatEvent {
+			var reply chatReply
+			if err := payloadCodec.Decode(payload, &reply); err != nil {
+				return err
+			}
+			if reply.Err != "" {
+				fmt.Fprintf(w, "! %s\n", reply.Err)
+			} else if reply.Members != nil {
+				fmt.Fprintf(w, "* 房间成员：%s\n", strings.Join(reply.Members, ", "))
+			}
+			continue
+		}
+		var ev chatEvent
+		if err := payloadCodec.Decode(payload, &ev); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, formatChatEvent(ev))
+	}
+}
+
+func formatChatEvent(ev chatEvent) string {
+	switch ev.Kind {
+	case chatWelcome:
+		return fmt.Sprintf("* 欢迎，你的昵称是 %s", ev.Nick)
+	case chatJoined:
+		return fmt.Sprintf("[%s] * %s 进入了房间", ev.Room, ev.Nick)
+	case chatLeft:
+		return fmt.Sprintf("[%s] * %s 离开了房间", ev.Room, ev.Nick)
+	case chatSaid:
+		return fmt.Sprintf("[%s] %s: %s", ev.Room, ev.Nick, ev.Text)
+	case chatWhisper:
+		return fmt.Sprintf("(私聊) %s: %s", ev.Nick, ev.Text)
+	case chatRenamed:
+		return fmt.Sprintf("[%s] * %s 改名为 %s", ev.Room, ev.Nick, ev.Text)
+	}
+	return fmt.Sprintf("* %+v", ev)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"gopractice/netx"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lineWriter 把每次 Write 的内容作为一行发送到 ch，printChat 每行只调用一次 Write
+type lineWriter chan string
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	w <- strings.TrimSuffix(string(p), "\n")
+	return len(p), nil
+}
+
+// chatClient 连接 addr，以 nick 认证并在后台输出收到的消息
+func chatClient(t *testing.T, addr, nick string) (*netx.Client, lineWriter) {
+	t.Helper()
+	c, err := netx.Dial(addr, netx.WithCodec(payloadCodec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if _, err := c.Authenticate(context.Background(), []byte(nick)); err != nil {
+		t.Fatal(err)
+	}
+	lines := make(lineWriter, 16)
+	go printChat(c, lines)
+	return c, lines
+}
+
+// command 执行一行客户端命令
+func command(t *testing.T, c *netx.Client, line string) {
+	t.Helper()
+	if err := runChatCommands(c, strings.NewReader(line)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expect 等待下一行输出并与 want 比较
+func expect(t *testing.T, lines lineWriter, want string) {
+	t.Helper()
+	select {
+	case got := <-lines:
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %q", want)
+	}
+}
+
+func TestChat(t *testing.T) {
+	useRecorder(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serveChat(ctx, l) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	addr := l.Addr().String()
+
+	alice, aliceOut := chatClient(t, addr, "alice")
+	expect(t, aliceOut, "* 欢迎，你的昵称是 alice")
+	command(t, alice, "/join go")
+	expect(t, aliceOut, "* 房间成员：alice")
+
+	bob, bobOut := chatClient(t, addr, "bob")
+	expect(t, bobOut, "* 欢迎，你的昵称是 bob")
+	command(t, bob, "/join go\nhello gophers")
+	expect(t, aliceOut, "[go] * bob 进入了房间")
+	expect(t, bobOut, "* 房间成员：alice, bob")
+	expect(t, aliceOut, "[go] bob: hello gophers")
+	expect(t, bobOut, "[go] bob: hello gophers")
+
+	command(t, alice, "/msg bob psst")
+	expect(t, bobOut, "(私聊) alice: psst")
+	command(t, alice, "/msg carol hi")
+	expect(t, aliceOut, "! "+errChatNoUser.Error())
+
+	// 昵称已经被使用时服务端加上后缀，改名时则直接拒绝
+	_, dupOut := chatClient(t, addr, "alice")
+	select {
+	case got := <-dupOut:
+		if !strings.HasPrefix(got, "* 欢迎，你的昵称是 alice#") {
+			t.Errorf("got %q, want a suffixed nickname", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no welcome message")
+	}
+	command(t, bob, "/nick alice")
+	expect(t, bobOut, "! "+errChatNickTaken.Error())
+	command(t, bob, "/nick robert")
+	expect(t, aliceOut, "[go] * bob 改名为 robert")
+	expect(t, bobOut, "[go] * bob 改名为 robert")
+
+	// 断开连接时离开所有房间
+	bob.Close()
+	expect(t, aliceOut, "[go] * robert 离开了房间")
+}
+
+func TestChatBadNick(t *testing.T) {
+	useRecorder(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serveChat(ctx, l) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	c, err := netx.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Authenticate(context.Background(), []byte("two words")); err == nil {
+		t.Error("Authenticate() with a bad nickname succeeded")
+	}
+}
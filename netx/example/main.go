@@ -1,36 +1,156 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"gopractice/logx"
+	"gopractice/netx"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// logger 服务端和客户端共用的日志，默认不输出，main 中替换为输出到标准输出
+var logger = logx.Nop
+
+// accessLog tcp 服务端的访问日志，通过 -access-log 开启
+var accessLog netx.EventLogger
+
+// shutdownTimeout 收到退出信号后等待连接处理结束的最长时间
+var shutdownTimeout = 5 * time.Second
+
+var errInvalidArgs = errors.New("参数不正确")
+
 func main() {
 	var network string
 	var app string
-	flag.StringVar(&network, "n", "tcp", "tcp/udp，默认为tcp")
-	flag.StringVar(&app, "a", "server", "server/client/client_sp，默认为server")
+	flag.StringVar(&network, "n", "tcp", "tcp/udp/ws/multicast/chat，默认为tcp")
+	flag.StringVar(&app, "a", "server", "server/client/client_sp/bench，默认为server")
+	debug := flag.Bool("debug", false, "以十六进制输出 tcp 服务端收发的每个消息")
+	flag.IntVar(&benchCfg.Conns, "c", benchCfg.Conns, "bench 同时使用的连接数")
+	flag.IntVar(&benchCfg.Msgs, "m", benchCfg.Msgs, "bench 每个连接发送的消息数")
+	flag.IntVar(&benchCfg.Size, "size", benchCfg.Size, "bench 每个消息的字节数")
+	flag.StringVar(&chatNick, "nick", "", "聊天室客户端的昵称，默认为当前用户名")
+	accessLogFile := flag.String("access-log", "", "tcp 服务端以 JSON Lines 格式记录连接事件的文件，- 表示标准输出")
 	flag.Parse()
 
+	level := logx.LevelInfo
+	if *debug {
+		netx.Debug = true
+		level = logx.LevelDebug
+	}
+	logger = logx.New(os.Stdout, level)
+	switch *accessLogFile {
+	case "":
+	case "-":
+		accessLog = netx.NewJSONEventLogger(os.Stdout)
+	default:
+		f, err := os.OpenFile(*accessLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer f.Close()
+		accessLog = netx.NewJSONEventLogger(f)
+	}
+
+	// Ctrl-C 或者 kill 时取消 ctx，服务端在 shutdownTimeout 内优雅关闭后再退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, network, app); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// run 运行选择的程序，服务端在 ctx 结束时关闭
+func run(ctx context.Context, network, app string) error {
 	if network == "tcp" {
-		if app == "server" {
-			Server()
-		} else if app == "client" {
+		switch app {
+		case "server":
+			return Server(ctx)
+		case "client":
 			Client()
-		} else if app == "client_sp" {
+			return nil
+		case "client_sp":
 			ClientTestStickyPacket()
-		} else {
-			fmt.Sprintln("参数不正确")
+			return nil
+		case "bench":
+			return Bench(ctx)
+		}
+	}
+
+	if network == "ws" {
+		switch app {
+		case "server":
+			return ServerWS(ctx)
+		case "client":
+			ClientWS()
+			return nil
 		}
 	}
 
 	if network == "udp" {
-		if app == "server" {
-			ServerUDP()
-		} else if app == "client" {
+		switch app {
+		case "server":
+			return ServerUDP(ctx)
+		case "client":
 			ClientUDP()
-		} else {
-			fmt.Sprintln("参数不正确")
+			return nil
 		}
 	}
+
+	if network == "chat" {
+		switch app {
+		case "server":
+			return ServerChat(ctx)
+		case "client":
+			return ClientChat(ctx)
+		}
+	}
+
+	if network == "multicast" {
+		switch app {
+		case "server":
+			return ServerAnnounce(ctx)
+		case "client":
+			return ClientAnnounce(ctx)
+		}
+	}
+
+	return errInvalidArgs
+}
+
+// shutdowner netx.Server 和 netx.UDPServer 都实现了这个接口
+type shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// serveUntil 在后台运行 serve，ctx 结束时调用 srv.Shutdown，最多等待 shutdownTimeout
+func serveUntil(ctx context.Context, srv shutdowner, serve func() error) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- serve()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Infof("收到退出信号，正在关闭服务端。。。")
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		return err
+	}
+	if err := <-errc; err != netx.ErrServerClosed {
+		return err
+	}
+	logger.Infof("服务端已关闭")
+	return nil
 }
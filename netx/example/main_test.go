@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"gopractice/logx"
+	"gopractice/netx"
+	"gopractice/netx/discovery"
+	"gopractice/netx/wsx"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useRecorder 把 logger 替换为 Recorder，测试结束时恢复
+func useRecorder(t *testing.T) *logx.Recorder {
+	rec := new(logx.Recorder)
+	old := logger
+	logger = rec
+	t.Cleanup(func() { logger = old })
+	return rec
+}
+
+// waitLog 等待 rec 中出现包含 substr 的日志
+func waitLog(t *testing.T, rec *logx.Recorder, substr string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		for _, e := range rec.Entries() {
+			if strings.Contains(e.Msg, substr) {
+				return
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no log entry containing %q in %v", substr, rec.Entries())
+}
+
+func TestServerShutdownOnSignal(t *testing.T) {
+	rec := useRecorder(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 取消 ctx 相当于收到了 SIGINT/SIGTERM
+	ctx, signal := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serveTCP(ctx, l) }()
+
+	c, err := netx.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Send(netx.AppendMsg(nil, msgData, []byte(`{"name":"kwok"}`)))
+	waitLog(t, rec, "kwok")
+	c.Close()
+
+	signal()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("serveTCP() = %v, want nil", err)
+		}
+	case <-time.After(shutdownTimeout):
+		t.Fatal("server did not shut down")
+	}
+	waitLog(t, rec, "服务端已关闭")
+	if conn, err := net.Dial("tcp", l.Addr().String()); err == nil {
+		conn.Close()
+		t.Error("server still accepting connections after shutdown")
+	}
+}
+
+func TestServerUDPShutdownOnSignal(t *testing.T) {
+	rec := useRecorder(t)
+	old := udpAddr
+	udpAddr = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+	defer func() { udpAddr = old }()
+
+	ctx, signal := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, "udp", "server") }()
+
+	signal()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("run() = %v, want nil", err)
+		}
+	case <-time.After(shutdownTimeout):
+		t.Fatal("server did not shut down")
+	}
+	waitLog(t, rec, "服务端已关闭")
+}
+
+func TestRunInvalidArgs(t *testing.T) {
+	if err := run(context.Background(), "tcp", "unknown"); err != errInvalidArgs {
+		t.Errorf("run() = %v, want %v", err, errInvalidArgs)
+	}
+}
+
+// 客户端连上之后一直不发送数据，processCode 阻塞在读取上，Shutdown 也要在期限内完成
+func TestServerShutdownIdleConn(t *testing.T) {
+	useRecorder(t)
+	old := shutdownTimeout
+	shutdownTimeout = time.Second
+	defer func() { shutdownTimeout = old }()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, signal := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serveTCP(ctx, l) }()
+
+	c, err := netx.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	// 先完整地发送一个消息，确保连接已经进入 processCode
+	c.Send(netx.AppendMsg(nil, msgData, []byte(`{"name":"idle"}`)))
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	signal()
+	if err := <-done; err != nil {
+		t.Fatalf("serveTCP() = %v, want nil", err)
+	}
+	if d := time.Since(start); d >= shutdownTimeout {
+		t.Errorf("shutdown took %v, want less than %v", d, shutdownTimeout)
+	}
+	// 服务端关闭了连接
+	c.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c.Recv(); err != io.EOF {
+		t.Errorf("client Recv() = %v, want io.EOF", err)
+	}
+}
+
+// 客户端连上之后超过 idleTimeout 没有发送消息，服务端断开连接
+func TestServerIdleTimeout(t *testing.T) {
+	rec := useRecorder(t)
+	old := idleTimeout
+	idleTimeout = 50 * time.Millisecond
+	defer func() { idleTimeout = old }()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, signal := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serveTCP(ctx, l) }()
+	defer func() {
+		signal()
+		<-done
+	}()
+
+	c, err := netx.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	waitLog(t, rec, netx.ErrIdleTimeout.Error())
+	c.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c.Recv(); err != io.EOF {
+		t.Errorf("client Recv() = %v, want io.EOF", err)
+	}
+}
+
+func TestServerWS(t *testing.T) {
+	rec := useRecorder(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, signal := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- serveWS(ctx, l) }()
+
+	c, err := wsx.Dial(l.Addr().String(), "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.WriteMessage(wsx.TextMessage, []byte("kwok"))
+	if _, msg, err := c.ReadMessage(); err != nil || string(msg) != "kwok" {
+		t.Errorf("ReadMessage() = %q, %v, want echo", msg, err)
+	}
+	if err := c.Close(wsx.CloseNormal, "bye"); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+	waitLog(t, rec, "1000 bye")
+
+	signal()
+	if err := <-done; err != nil {
+		t.Fatalf("serveWS() = %v, want nil", err)
+	}
+}
+
+func TestMulticastAnnounce(t *testing.T) {
+	rec := useRecorder(t)
+	oldGroup, oldInterval := multicastGroup, announceInterval
+	multicastGroup, announceInterval = "239.255.77.78:29998", 10*time.Millisecond
+	defer func() { multicastGroup, announceInterval = oldGroup, oldInterval }()
+
+	ctx, signal := context.WithCancel(context.Background())
+	client := make(chan error, 1)
+	go func() { client <- run(ctx, "multicast", "client") }()
+	server := make(chan error, 1)
+	go func() { server <- run(ctx, "multicast", "server") }()
+
+	waitLog(t, rec, "收到服务信息：gopractice tcp "+tcpAddr)
+	signal()
+	for _, done := range []chan error{client, server} {
+		if err := <-done; err != nil {
+			t.Errorf("run() = %v, want nil", err)
+		}
+	}
+}
+
+func TestServerAddrDiscovery(t *testing.T) {
+	rec := useRecorder(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	a := &discovery.Announcer{
+		Info:     discovery.ServiceInfo{Name: serviceName, Addr: "127.0.0.1", Port: 18001},
+		Interval: 10 * time.Millisecond,
+	}
+	go a.Run(ctx)
+
+	old := discoverTimeout
+	discoverTimeout = time.Second
+	defer func() { discoverTimeout = old }()
+	if got := serverAddr(); got != "127.0.0.1:18001" {
+		if got == tcpAddr {
+			t.Skipf("no broadcast received: %v", rec.Entries())
+		}
+		t.Errorf("serverAddr() = %q, want %q", got, "127.0.0.1:18001")
+	}
+	waitLog(t, rec, "发现服务端 127.0.0.1:18001")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gopractice/netx"
+	"net"
+	"time"
+)
+
+// multicastGroup 发布服务信息使用的组播地址，239.0.0.0/8 是组织内部使用的范围
+var multicastGroup = "239.0.0.1:9999"
+
+// announceInterval 发布服务信息的间隔
+var announceInterval = time.Second
+
+// ServerAnnounce 定期向组播组发布 tcp 服务端的名称和地址，ctx 结束时停止
+// 同一网段内加入了组播组的所有客户端都能收到，不需要知道发布者的地址。
+func ServerAnnounce(ctx context.Context) error {
+	conn, err := netx.DialMulticast(multicastGroup, netx.WithMulticastTTL(1))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	msg := fmt.Sprintf("gopractice tcp %s", tcpAddr)
+	ticker := time.NewTicker(announceInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			logger.Errorf("发布服务信息失败 %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			logger.Infof("停止发布服务信息")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// ClientAnnounce 加入组播组，打印收到的服务信息，ctx 结束时退出
+func ClientAnnounce(ctx context.Context) error {
+	pc, err := netx.ListenMulticast(multicastGroup)
+	if err != nil {
+		return err
+	}
+	s := &netx.UDPServer{
+		Logger: logger,
+		Handler: func(ctx context.Context, pc net.PacketConn, addr net.Addr, data []byte) {
+			logger.Infof("收到服务信息：%s，来自 %s", data, addr)
+		},
+	}
+	return serveUntil(ctx, s, func() error {
+		return s.Serve(pc)
+	})
+}
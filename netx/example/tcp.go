@@ -2,10 +2,12 @@ package main
 
 import (
 	"bufio"
-	"bytes"
-	"encoding/binary"
+	"context"
 	"encoding/json"
 	"fmt"
+	"gopractice/netx"
+	"gopractice/netx/codec"
+	"gopractice/netx/discovery"
 	"io"
 	"net"
 	"os"
@@ -13,30 +15,66 @@ import (
 	"time"
 )
 
-// Server tcp 服务端
-func Server() {
-	// 监听
-	listen, err := net.Listen("tcp", "127.0.0.1:8001")
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
+// tcpAddr tcp 服务端监听的地址，客户端没有通过广播发现服务端时也连接这个地址
+var tcpAddr = "127.0.0.1:8001"
 
-	fmt.Println("服务端已启动。。。")
+// payloadCodec 消息内容的编码方式，改成 codec.Protobuf、codec.Gob 或 codec.MsgPack 即可切换，
+// 服务端和客户端使用同一个变量，不需要修改处理逻辑
+var payloadCodec = codec.JSON
 
-	defer listen.Close()
+// idleTimeout 客户端超过这个时间没有发送消息时断开连接，避免失效的客户端一直占用连接
+var idleTimeout = 5 * time.Minute
 
-	for {
-		conn, err := listen.Accept()
-		if err != nil {
-			fmt.Println("accept failed, err:", err)
-			continue
-		}
+// serviceName tcp 服务端在局域网内广播的服务名称，客户端按名称查找服务端的地址
+const serviceName = "gopractice-tcp"
+
+// discoverTimeout 客户端查找服务端地址的最长时间，超时后使用 tcpAddr
+var discoverTimeout = 3 * time.Second
 
-		// 启动一个goroutine处理连接
-		//go process(conn)
-		go processCode(conn)
+// Server tcp 服务端，ctx 结束时优雅关闭
+// 监听、Accept 以及为每个连接启动 goroutine 都由 netx.Server 完成，同时在局域网内广播服务端的地址
+func Server(ctx context.Context) error {
+	l, err := net.Listen("tcp", tcpAddr)
+	if err != nil {
+		return err
 	}
+	a := &discovery.Announcer{Info: discovery.NewServiceInfo(serviceName, l.Addr()), Logger: logger}
+	go a.Run(ctx)
+	return serveTCP(ctx, l)
+}
+
+// serverAddr 通过局域网广播查找 tcp 服务端的地址，没有找到时使用 tcpAddr
+func serverAddr() string {
+	ctx, cancel := context.WithTimeout(context.Background(), discoverTimeout)
+	defer cancel()
+	info, err := discovery.Lookup(ctx, serviceName)
+	if err != nil {
+		logger.Infof("没有发现服务端（%v），使用默认地址 %s", err, tcpAddr)
+		return tcpAddr
+	}
+	logger.Infof("发现服务端 %s", info.HostPort())
+	return info.HostPort()
+}
+
+func serveTCP(ctx context.Context, l net.Listener) error {
+	s := &netx.Server{
+		Logger:      logger,
+		Codec:       payloadCodec,
+		Tracer:      netx.DebugTracer(logger),
+		EventLog:    accessLog,
+		IdleTimeout: idleTimeout,
+		ReadTimeout: 10 * time.Second,
+		OnTimeout: func(conn net.Conn, err error) {
+			logger.Infof("断开连接 %s：%v", conn.RemoteAddr(), err)
+		},
+		Handler: func(ctx context.Context, conn net.Conn) {
+			//process(conn)
+			processCode(ctx, conn)
+		},
+	}
+	return serveUntil(ctx, s, func() error {
+		return s.Serve(l)
+	})
 }
 
 // 服务端处理逻辑
@@ -50,17 +88,17 @@ func process(conn net.Conn) {
 			break
 		}
 		if err != nil {
-			fmt.Println("read from client failed, err:", err)
+			logger.Errorf("read from client failed, err: %v", err)
 			break
 		}
 
 		recvData := new(dataReq)
 		err = json.Unmarshal(buf[:n], recvData)
 		if err != nil {
-			fmt.Println("json error", err)
+			logger.Errorf("json error %v", err)
 			continue
 		}
-		fmt.Println("收到client端发来的数据：", recvData.Name)
+		logger.Infof("收到client端发来的数据：%s", recvData.Name)
 
 		// 处理逻辑
 		//time.Sleep(3 * time.Second)
@@ -74,9 +112,9 @@ func process(conn net.Conn) {
 
 // Client 客户端
 func Client() {
-	conn, err := net.Dial("tcp", "127.0.0.1:8001")
+	conn, err := net.Dial("tcp", serverAddr())
 	if err != nil {
-		fmt.Println(err)
+		logger.Errorf("dial failed, err: %v", err)
 		return
 	}
 	defer conn.Close()
@@ -90,13 +128,13 @@ func Client() {
 		}
 		_, err = conn.Write([]byte(inputInfo)) // 发送数据
 		if err != nil {
-			fmt.Println("发送数据失败, err:", err)
+			logger.Errorf("发送数据失败, err: %v", err)
 			return
 		}
 		buf := [512]byte{}
 		n, err := conn.Read(buf[:])
 		if err != nil {
-			fmt.Println("读取服务器数据失败, err:", err)
+			logger.Errorf("读取服务器数据失败, err: %v", err)
 			return
 		}
 
@@ -112,82 +150,93 @@ type dataReq struct {
 // ClientTestStickyPacket 复现粘包场景
 // 跟粘包关系最大的就是基于字节流这个特点，数据可能被切割和组装成各种数据包，接收端收到这些数据包后没有正确还原原来的消息，因此出现粘包现象。
 // ref: https://segmentfault.com/a/1190000039691657
+// 连续发送的小消息由 BatchedWriter 合并成一次写入，减少系统调用，服务端照样能正确拆包。
+// 按指定的方式合并和拆分消息、自动验证封包方式的工具见 netxtest.VerifyCodec。
+// 发完后用 CloseWrite 告诉服务端没有更多消息，服务端处理完所有消息后关闭连接，RecvAll 随之返回。
 func ClientTestStickyPacket() {
-	conn, _ := net.Dial("tcp", "127.0.0.1:8001")
-	defer conn.Close()
+	conn, err := net.Dial("tcp", serverAddr())
+	if err != nil {
+		logger.Errorf("连接服务端失败 %v", err)
+		return
+	}
+	c := netx.NewClient(conn)
+	defer c.Close()
 
+	bw := netx.NewBatchedWriter(conn)
 	for i := 0; i < 20; i++ {
-		str, _ := json.Marshal(dataReq{
+		str, _ := payloadCodec.Encode(dataReq{
 			Name: fmt.Sprintf("%s-%d", "name", i),
 		})
-		b, _ := Encode(string(str))
-		conn.Write(b)
+		bw.WriteFrame(netx.AppendMsg(nil, msgData, str))
+	}
+	bw.Close()
+	if err := c.CloseWrite(); err != nil {
+		logger.Errorf("关闭写方向失败 %v", err)
+		return
+	}
+	replies, err := c.RecvAll(context.Background())
+	if err != nil {
+		logger.Errorf("等待服务端处理失败 %v", err)
+		return
 	}
-	time.Sleep(3 * time.Second)
+	logger.Infof("服务端已处理完所有消息，收到 %d 个回复", len(replies))
 }
 
 // 解决粘包问题
 // 出现”粘包”的关键在于接收方不确定将要传输的数据包的大小，因此我们可以对数据包进行封包和拆包的操作。
-// 超过一个字节的数据类型在内存中存储的顺序有大小端模式，所以int8不能用
+// 封包和拆包的实现在 netx 包中，UDP 的例子也使用同一套编码。
 
 // Encode 编码
 func Encode(msg string) ([]byte, error) {
-	length := int32(len(msg))
-	pkg := new(bytes.Buffer)
-	err := binary.Write(pkg, binary.LittleEndian, length)
-	if err != nil {
-		return nil, err
-	}
-
-	err = binary.Write(pkg, binary.LittleEndian, []byte(msg))
-	if err != nil {
-		return nil, err
-	}
-
-	return pkg.Bytes(), nil
+	return netx.Encode([]byte(msg))
 }
 
 // Decode 解码
 func Decode(reader *bufio.Reader) ([]byte, error) {
-	// 读取消息长度
-	lenBytes, _ := reader.Peek(4)
-	lenBuff := bytes.NewBuffer(lenBytes)
-	var length int32
-	err := binary.Read(lenBuff, binary.LittleEndian, &length)
-	if err != nil {
-		return nil, err
-	}
+	return netx.Decode(reader)
+}
 
-	// Buffered返回缓冲中现有的可读取的字节数, 是不是一个完整的消息，不是则直接返回
-	if int32(reader.Buffered()) < length+4 {
-		return nil, err
-	}
+// 消息类型，每个消息前面带上类型，由 router 分发给对应的处理函数
+const (
+	msgData uint16 = iota + 1
+	msgEcho        // 原样返回，bench 用来测量延迟
+)
 
-	// 读取真正的消息数据
-	pack := make([]byte, length+4)
-	_, err = reader.Read(pack)
-	if err != nil {
-		return nil, err
-	}
+var router = newRouter()
 
-	return pack[4:], nil
+func newRouter() *netx.Router {
+	r := netx.NewRouter()
+	netx.HandleValue(r, msgData, handleData)
+	r.HandleFunc(msgEcho, func(ctx context.Context, req []byte) ([]byte, error) {
+		return req, nil
+	})
+	return r
 }
 
-func processCode(conn net.Conn) {
+// processCode 服务端处理逻辑，ctx 结束时（例如服务端 Shutdown）即使正在等待消息也会立即返回，
+// 客户端超时没有发送消息时也会返回
+func processCode(ctx context.Context, conn net.Conn) {
 	defer conn.Close()
 
-	reader := bufio.NewReader(conn)
+	c := netx.NewClient(conn)
 	for {
-		b, err := Decode(reader)
+		b, err := c.RecvContext(ctx)
 		if err != nil {
 			return
 		}
-		recvData := new(dataReq)
-		err = json.Unmarshal(b, recvData)
+		resp, err := router.HandleFrame(ctx, b)
 		if err != nil {
-			fmt.Println("json error", err)
+			logger.Errorf("处理消息失败 %v", err)
 			continue
 		}
-		fmt.Println("收到client端发来的数据：", recvData.Name)
+		if resp != nil {
+			c.Send(resp)
+		}
 	}
 }
+
+// handleData 处理 msgData 类型的消息，不需要回复
+func handleData(ctx context.Context, req *dataReq) (*dataReq, error) {
+	logger.Infof("收到client端发来的数据：%s", req.Name)
+	return nil, nil
+}
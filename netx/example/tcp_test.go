@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"gopractice/logx"
+	"gopractice/netx"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestProcessCodeLogger(t *testing.T) {
+	rec := new(logx.Recorder)
+	old := logger
+	logger = rec
+	defer func() { logger = old }()
+
+	server, client := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		processCode(context.Background(), server)
+		close(done)
+	}()
+
+	for _, msg := range []string{`{"name":"kwok"}`, `not json`} {
+		b, _ := Encode(string(netx.AppendMsg(nil, msgData, []byte(msg))))
+		if _, err := client.Write(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client.Close()
+	<-done
+
+	entries := rec.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("got %d log entries, want 2: %v", len(entries), entries)
+	}
+	if entries[0].Level != logx.LevelInfo || !strings.Contains(entries[0].Msg, "kwok") {
+		t.Errorf("entries[0] = %v, want info log containing the name", entries[0])
+	}
+	if entries[1].Level != logx.LevelError || !strings.Contains(entries[1].Msg, "处理消息失败") {
+		t.Errorf("entries[1] = %v, want decode error", entries[1])
+	}
+}
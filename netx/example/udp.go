@@ -1,68 +1,101 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"gopractice/netx"
+	"gopractice/netx/workerpool"
 	"net"
+	"sync/atomic"
 )
 
-// 服务端
-func ServerUDP() {
-	listen, err := net.ListenUDP("udp", &net.UDPAddr{
-		IP:   net.IPv4(0, 0, 0, 0),
-		Port: 3000,
-		Zone: "",
-	})
-	if err != nil {
-		fmt.Println("监听失败 ", err)
-		return
-	}
-	defer listen.Close()
+// udpAddr udp 服务端监听的地址
+var udpAddr = &net.UDPAddr{
+	IP:   net.IPv4(0, 0, 0, 0),
+	Port: 3000,
+	Zone: "",
+}
 
-	i := 0
-	for {
-		var data [1024]byte
-		n, addr, err := listen.ReadFromUDP(data[:])
-		if err != nil {
-			fmt.Println("读取数据失败 ", err)
-			continue
-		}
-		i++
-		fmt.Printf("data:%v addr:%v count:%v seq:%d \n", string(data[:n]), addr, n, i)
+// ServerUDP udp 服务端，ctx 结束时优雅关闭
+// 数据报交给 worker 池并发处理，池满时停止读取
+func ServerUDP(ctx context.Context) error {
+	pool := workerpool.New(4, 64, workerpool.WithPanicHandler(func(v any) {
+		logger.Errorf("处理数据报 panic %v", v)
+	}))
+	defer pool.Stop()
 
-		_, err = listen.WriteToUDP([]byte("我收到了"), addr)
-		if err != nil {
-			fmt.Println("写入数据失败 ", err)
-			continue
-		}
+	var seq int32
+	reassembler := netx.NewReassembler()
+	s := &netx.UDPServer{
+		Pool:   pool,
+		Logger: logger,
+		Handler: func(ctx context.Context, pc net.PacketConn, addr net.Addr, data []byte) {
+			// 超过 MTU 的数据被拆成多个分片发送，收齐之后才能解码
+			packet, err := reassembler.Add(addr, data)
+			if err != nil {
+				logger.Errorf("重组分片失败 %v", err)
+				return
+			}
+			if packet == nil {
+				return
+			}
+
+			// 与 TCP 使用同一套编码，一个数据报中可能打包了多个消息
+			msgs, err := netx.DecodePacket(packet)
+			if err != nil {
+				logger.Errorf("解码失败 %v", err)
+			}
+			for _, msg := range msgs {
+				i := atomic.AddInt32(&seq, 1)
+				logger.Infof("data:%v addr:%v count:%v seq:%d", abbreviate(msg), addr, len(packet), i)
+			}
+
+			_, err = pc.WriteTo([]byte("我收到了"), addr)
+			if err != nil {
+				logger.Errorf("写入数据失败 %v", err)
+			}
+		},
 	}
+
+	pc, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return err
+	}
+	return serveUntil(ctx, s, func() error {
+		return s.Serve(pc)
+	})
 }
 
 func ClientUDP() {
-	conn, err := net.DialUDP("udp", nil, &net.UDPAddr{
-		IP:   net.IPv4(0, 0, 0, 0),
-		Port: 3000,
-		Zone: "",
-	})
+	conn, err := net.DialUDP("udp", nil, udpAddr)
 	if err != nil {
-		fmt.Println("连接服务端失败，err:", err)
+		logger.Errorf("连接服务端失败，err: %v", err)
 		return
 	}
 
 	defer conn.Close()
 
+	// 最后一个消息超过 MTU，由 Fragmenter 拆成多个分片发送
+	fragmenter := netx.NewFragmenter(0)
+	msgs := make([][]byte, 0, 21)
 	for i := 0; i < 20; i++ {
-		_, err = conn.Write([]byte("hello server"))
-		if err != nil {
-			fmt.Println("发送数据失败，err:", err)
+		msgs = append(msgs, []byte("hello server"))
+	}
+	msgs = append(msgs, bytes.Repeat([]byte("hello server "), 1000))
+	for _, msg := range msgs {
+		if err := fragmenter.Write(conn, netx.AppendFrame(nil, msg)); err != nil {
+			logger.Errorf("发送数据失败，err: %v", err)
 			return
 		}
 	}
+}
 
-	//data := make([]byte, 4096)
-	//n, remoteAddr, err := conn.ReadFromUDP(data) // 接收数据
-	//if err != nil {
-	//	fmt.Println("接收数据失败，err:", err)
-	//	return
-	//}
-	//fmt.Printf("recv:%v addr:%v count:%v\n", string(data[:n]), remoteAddr, n)
+// abbreviate 日志中只打印长消息的开头
+func abbreviate(msg []byte) string {
+	const max = 64
+	if len(msg) <= max {
+		return string(msg)
+	}
+	return fmt.Sprintf("%s...(%d bytes)", msg[:max], len(msg))
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"gopractice/netx/wsx"
+	"net"
+	"os"
+	"strings"
+)
+
+// wsAddr WebSocket 服务端监听的地址，可以用浏览器的 new WebSocket("ws://127.0.0.1:8002") 连接
+var wsAddr = "127.0.0.1:8002"
+
+// ServerWS WebSocket 服务端，把收到的消息原样发回，ctx 结束时优雅关闭
+func ServerWS(ctx context.Context) error {
+	l, err := net.Listen("tcp", wsAddr)
+	if err != nil {
+		return err
+	}
+	return serveWS(ctx, l)
+}
+
+func serveWS(ctx context.Context, l net.Listener) error {
+	s := wsx.NewServer(wsAddr, wsx.HandlerFunc(func(ctx context.Context, c *wsx.Conn) {
+		addr := c.NetConn().RemoteAddr()
+		logger.Infof("WebSocket 连接建立 %s", addr)
+		for {
+			typ, msg, err := c.ReadMessage()
+			var ce *wsx.CloseError
+			if errors.As(err, &ce) {
+				logger.Infof("WebSocket 连接关闭 %s：%d %s", addr, ce.Code, ce.Reason)
+				return
+			}
+			if err != nil {
+				logger.Errorf("读取 WebSocket 消息失败 %v", err)
+				return
+			}
+			logger.Infof("收到 WebSocket 消息：%s", msg)
+			if err := c.WriteMessage(typ, msg); err != nil {
+				return
+			}
+		}
+	}))
+	s.Logger = logger
+	return serveUntil(ctx, s, func() error {
+		return s.Serve(l)
+	})
+}
+
+// ClientWS WebSocket 客户端，发送输入的每一行并打印服务端的回复，输入 q 退出
+func ClientWS() {
+	c, err := wsx.Dial(wsAddr, "/")
+	if err != nil {
+		logger.Errorf("dial failed, err: %v", err)
+		return
+	}
+	defer c.Close(wsx.CloseNormal, "")
+
+	inputReader := bufio.NewReader(os.Stdin)
+	for {
+		input, _ := inputReader.ReadString('\n')
+		inputInfo := strings.Trim(input, "\r\n")
+		if strings.ToUpper(inputInfo) == "Q" {
+			return
+		}
+		if err := c.WriteMessage(wsx.TextMessage, []byte(inputInfo)); err != nil {
+			logger.Errorf("发送数据失败, err: %v", err)
+			return
+		}
+		_, msg, err := c.ReadMessage()
+		if err != nil {
+			logger.Errorf("读取服务器数据失败, err: %v", err)
+			return
+		}
+		fmt.Println(string(msg))
+	}
+}
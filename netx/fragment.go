@@ -0,0 +1,288 @@
+package netx
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+var (
+	// ErrBadFragment 分片格式错误，或者与同一个消息的其他分片不一致
+	ErrBadFragment = errors.New("netx: malformed fragment")
+	// ErrTooManyFragments 消息太大，拆出的分片超过 MaxFragments 个
+	ErrTooManyFragments = errors.New("netx: too many fragments")
+	// ErrReassemblyLimit 对方未重组完成的分片超过了缓冲区上限，当前消息被丢弃
+	ErrReassemblyLimit = errors.New("netx: reassembly buffer limit exceeded")
+)
+
+const (
+	// FragmentHeaderSize 每个分片前面的头：消息编号（4 字节）、分片序号和分片总数（各 2 字节），小端序
+	FragmentHeaderSize = 8
+	// MaxFragments 一个消息最多拆成的分片数
+	MaxFragments = 1<<16 - 1
+	// DefaultFragmentSize 默认的分片大小（包括分片头），以太网 MTU 1500 减去 IP 和 UDP 头后留出余量
+	DefaultFragmentSize = 1400
+
+	// DefaultReassemblyTimeout 默认的重组超时，超过这个时间还没有收齐分片的消息被丢弃
+	DefaultReassemblyTimeout = 5 * time.Second
+	// DefaultPeerBufferLimit 默认每个对方地址未重组完成的分片最多占用的字节数
+	DefaultPeerBufferLimit = 4 << 20
+)
+
+// Fragmenter 把超过 MTU 的消息拆成多个带编号的分片，每个分片单独作为一个 UDP 数据报发送，
+// 接收方用 Reassembler 重组。可以在多个 goroutine 中同时使用。
+type Fragmenter struct {
+	size int
+	next uint32
+}
+
+// NewFragmenter 创建一个 Fragmenter，size 为每个分片（包括分片头）的最大字节数，为 0 时使用 DefaultFragmentSize
+func NewFragmenter(size int) *Fragmenter {
+	if size <= 0 {
+		size = DefaultFragmentSize
+	}
+	if size <= FragmentHeaderSize {
+		panic("netx: fragment size too small")
+	}
+	return &Fragmenter{size: size}
+}
+
+// Split 给 msg 分配一个新的消息编号并拆成分片，空消息也会产生一个分片
+func (f *Fragmenter) Split(msg []byte) ([][]byte, error) {
+	chunk := f.size - FragmentHeaderSize
+	count := (len(msg) + chunk - 1) / chunk
+	if count == 0 {
+		count = 1
+	}
+	if count > MaxFragments {
+		return nil, ErrTooManyFragments
+	}
+
+	id := atomic.AddUint32(&f.next, 1)
+	frags := make([][]byte, count)
+	for i := range frags {
+		part := msg[i*chunk:]
+		if len(part) > chunk {
+			part = part[:chunk]
+		}
+		b := make([]byte, FragmentHeaderSize, FragmentHeaderSize+len(part))
+		binary.LittleEndian.PutUint32(b[0:], id)
+		binary.LittleEndian.PutUint16(b[4:], uint16(i))
+		binary.LittleEndian.PutUint16(b[6:], uint16(count))
+		frags[i] = append(b, part...)
+	}
+	return frags, nil
+}
+
+// WriteTo 把 msg 拆成分片后依次发送给 addr
+func (f *Fragmenter) WriteTo(pc net.PacketConn, addr net.Addr, msg []byte) error {
+	frags, err := f.Split(msg)
+	if err != nil {
+		return err
+	}
+	for _, b := range frags {
+		if _, err := pc.WriteTo(b, addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write 与 WriteTo 相同，发送到已经连接的 conn，例如 net.DialUDP 返回的连接
+func (f *Fragmenter) Write(conn net.Conn, msg []byte) error {
+	frags, err := f.Split(msg)
+	if err != nil {
+		return err
+	}
+	for _, b := range frags {
+		if _, err := conn.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reassembler 按对方地址和消息编号收集分片，收齐后还原出完整的消息
+// 分片可以乱序、重复到达；丢失分片的消息在超时后被丢弃，UDP 本身不会重传。
+// 可以在多个 goroutine 中同时使用，例如 UDPServer 使用 Pool 并发处理数据报时。
+type Reassembler struct {
+	timeout time.Duration
+	limit   int
+
+	mu        sync.Mutex
+	peers     map[string]*peerFragments
+	lastSweep time.Time
+}
+
+// ReassemblerOption 创建 Reassembler 时的可选配置
+type ReassemblerOption func(r *Reassembler)
+
+// WithReassemblyTimeout 设置重组超时，默认为 DefaultReassemblyTimeout，从收到消息的第一个分片开始计算
+func WithReassemblyTimeout(d time.Duration) ReassemblerOption {
+	return func(r *Reassembler) {
+		r.timeout = d
+	}
+}
+
+// WithPeerBufferLimit 设置每个对方地址未重组完成的分片最多占用的字节数，默认为 DefaultPeerBufferLimit。
+// 除了分片的数据，每个消息按分片总数保存分片的切片也计算在内（每个分片 24 字节），
+// 限制同时也决定了能重组的最大消息，避免对方只发一部分分片，或者只发分片头耗尽内存。
+func WithPeerBufferLimit(n int) ReassemblerOption {
+	return func(r *Reassembler) {
+		r.limit = n
+	}
+}
+
+// NewReassembler 创建一个 Reassembler
+func NewReassembler(opts ...ReassemblerOption) *Reassembler {
+	r := &Reassembler{
+		timeout: DefaultReassemblyTimeout,
+		limit:   DefaultPeerBufferLimit,
+		peers:   make(map[string]*peerFragments),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// peerFragments 一个对方地址未重组完成的消息
+type peerFragments struct {
+	msgs  map[uint32]*partialMessage
+	bytes int
+}
+
+type partialMessage struct {
+	frags   [][]byte
+	got     int
+	bytes   int // 收到的数据的字节数
+	cost    int // 计入 peerFragments.bytes 的字节数，包括 frags 本身
+	expires time.Time
+}
+
+// fragsCost 保存 count 个分片的切片占用的字节数
+func fragsCost(count int) int {
+	return count * int(unsafe.Sizeof([]byte(nil)))
+}
+
+// Add 处理从 addr 收到的一个分片，消息的分片收齐时返回完整的消息，否则返回 nil。
+// 只有一个分片的消息直接返回，引用 pkt 的内存；其他情况会复制分片的数据。
+func (r *Reassembler) Add(addr net.Addr, pkt []byte) ([]byte, error) {
+	if len(pkt) < FragmentHeaderSize {
+		return nil, ErrBadFragment
+	}
+	id := binary.LittleEndian.Uint32(pkt[0:])
+	index := int(binary.LittleEndian.Uint16(pkt[4:]))
+	count := int(binary.LittleEndian.Uint16(pkt[6:]))
+	data := pkt[FragmentHeaderSize:]
+	if count == 0 || index >= count {
+		return nil, ErrBadFragment
+	}
+	if count == 1 {
+		return data, nil
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweep(now)
+
+	key := addr.String()
+	p := r.peers[key]
+	if p == nil {
+		p = &peerFragments{msgs: make(map[uint32]*partialMessage)}
+		r.peers[key] = p
+	}
+	m := p.msgs[id]
+	if m != nil && now.After(m.expires) {
+		p.drop(id)
+		m = nil
+	}
+	if m == nil {
+		// 分配之前检查，只有分片头的数据包也会占用 frags 的内存
+		cost := fragsCost(count)
+		if p.bytes+cost > r.limit {
+			r.cleanup(key, p)
+			return nil, ErrReassemblyLimit
+		}
+		m = &partialMessage{frags: make([][]byte, count), cost: cost, expires: now.Add(r.timeout)}
+		p.msgs[id] = m
+		p.bytes += cost
+	}
+	if len(m.frags) != count {
+		p.drop(id)
+		r.cleanup(key, p)
+		return nil, ErrBadFragment
+	}
+	if m.frags[index] != nil {
+		// 重复的分片
+		return nil, nil
+	}
+	if p.bytes+len(data) > r.limit {
+		p.drop(id)
+		r.cleanup(key, p)
+		return nil, ErrReassemblyLimit
+	}
+
+	m.frags[index] = append(make([]byte, 0, len(data)), data...)
+	m.got++
+	m.bytes += len(data)
+	m.cost += len(data)
+	p.bytes += len(data)
+	if m.got < count {
+		return nil, nil
+	}
+
+	msg := make([]byte, 0, m.bytes)
+	for _, b := range m.frags {
+		msg = append(msg, b...)
+	}
+	p.drop(id)
+	r.cleanup(key, p)
+	return msg, nil
+}
+
+// Pending 返回还没有重组完成的消息数
+func (r *Reassembler) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sweep(time.Now())
+	n := 0
+	for _, p := range r.peers {
+		n += len(p.msgs)
+	}
+	return n
+}
+
+// sweep 丢弃超时的消息，每半个超时周期最多完整检查一次
+func (r *Reassembler) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < r.timeout/2 {
+		return
+	}
+	r.lastSweep = now
+	for key, p := range r.peers {
+		for id, m := range p.msgs {
+			if now.After(m.expires) {
+				p.drop(id)
+			}
+		}
+		r.cleanup(key, p)
+	}
+}
+
+func (r *Reassembler) cleanup(key string, p *peerFragments) {
+	if len(p.msgs) == 0 {
+		delete(r.peers, key)
+	}
+}
+
+func (p *peerFragments) drop(id uint32) {
+	if m, ok := p.msgs[id]; ok {
+		p.bytes -= m.cost
+		delete(p.msgs, id)
+	}
+}
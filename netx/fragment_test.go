@@ -0,0 +1,160 @@
+package netx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFragmentReassemble(t *testing.T) {
+	f := NewFragmenter(100)
+	r := NewReassembler()
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+
+	for _, n := range []int{0, 10, 92, 93, 10000} {
+		msg := bytes.Repeat([]byte{'x'}, n)
+		frags, err := f.Split(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range frags {
+			if len(b) > 100 {
+				t.Fatalf("fragment of %d bytes exceeds size 100", len(b))
+			}
+		}
+
+		// 乱序并且重复到达
+		rnd := rand.New(rand.NewSource(int64(n)))
+		rnd.Shuffle(len(frags), func(i, j int) { frags[i], frags[j] = frags[j], frags[i] })
+		frags = append(append([][]byte(nil), frags[:len(frags)/2]...), frags...)
+		var got []byte
+		for i, b := range frags {
+			out, err := r.Add(addr, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != nil {
+				if i != len(frags)-1 {
+					t.Fatalf("%d bytes: message reassembled before all fragments arrived", n)
+				}
+				got = out
+			}
+		}
+		if !bytes.Equal(got, msg) {
+			t.Errorf("%d bytes: reassembled %d bytes", n, len(got))
+		}
+	}
+	if n := r.Pending(); n != 0 {
+		t.Errorf("Pending() = %d, want 0", n)
+	}
+}
+
+func TestReassemblerTimeout(t *testing.T) {
+	f := NewFragmenter(100)
+	r := NewReassembler(WithReassemblyTimeout(20 * time.Millisecond))
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+
+	frags, _ := f.Split(make([]byte, 1000))
+	for _, b := range frags[:len(frags)-1] {
+		r.Add(addr, b)
+	}
+	if n := r.Pending(); n != 1 {
+		t.Fatalf("Pending() = %d, want 1", n)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := r.Pending(); n != 0 {
+		t.Errorf("Pending() after timeout = %d, want 0", n)
+	}
+	// 过期之后才到达的分片不能还原出消息
+	if msg, err := r.Add(addr, frags[len(frags)-1]); msg != nil || err != nil {
+		t.Errorf("Add() after timeout = %d bytes, %v, want nil", len(msg), err)
+	}
+}
+
+func TestReassemblerLimit(t *testing.T) {
+	f := NewFragmenter(100)
+	r := NewReassembler(WithPeerBufferLimit(1000))
+	a1 := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	a2 := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2}
+
+	big, _ := f.Split(make([]byte, 5000))
+	var err error
+	for _, b := range big {
+		if _, err = r.Add(a1, b); err != nil {
+			break
+		}
+	}
+	if err != ErrReassemblyLimit {
+		t.Errorf("Add() = %v, want %v", err, ErrReassemblyLimit)
+	}
+
+	// 其他地址不受影响，8 个分片的数据加上 frags 共 892 字节
+	small, _ := f.Split(make([]byte, 700))
+	var msg []byte
+	for _, b := range small {
+		if msg, err = r.Add(a2, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(msg) != 700 {
+		t.Errorf("reassembled %d bytes, want 700", len(msg))
+	}
+}
+
+// 只有分片头、分片总数很大的数据包同样受限制
+func TestReassemblerHeaderOnlyLimit(t *testing.T) {
+	r := NewReassembler(WithPeerBufferLimit(1 << 20))
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	accepted := 0
+	for id := 0; id < 500; id++ {
+		pkt := make([]byte, FragmentHeaderSize)
+		binary.LittleEndian.PutUint32(pkt[0:], uint32(id))
+		binary.LittleEndian.PutUint16(pkt[6:], MaxFragments)
+		if _, err := r.Add(addr, pkt); err == nil {
+			accepted++
+		} else if err != ErrReassemblyLimit {
+			t.Fatalf("Add() = %v", err)
+		}
+	}
+	// 每个消息的 frags 约 1.5 MB，超过了 1 MB 的限制
+	if accepted != 0 || r.Pending() != 0 {
+		t.Errorf("accepted %d header-only messages, %d pending", accepted, r.Pending())
+	}
+
+	r = NewReassembler(WithPeerBufferLimit(4 << 20))
+	for id := 0; id < 500; id++ {
+		pkt := make([]byte, FragmentHeaderSize)
+		binary.LittleEndian.PutUint32(pkt[0:], uint32(id))
+		binary.LittleEndian.PutUint16(pkt[6:], MaxFragments)
+		r.Add(addr, pkt)
+	}
+	if n := r.Pending(); n != 2 {
+		t.Errorf("Pending() = %d, want 2 within a 4 MB limit", n)
+	}
+}
+
+func TestFragmentErrors(t *testing.T) {
+	if _, err := NewFragmenter(FragmentHeaderSize + 1).Split(make([]byte, MaxFragments+1)); err != ErrTooManyFragments {
+		t.Errorf("Split() = %v, want %v", err, ErrTooManyFragments)
+	}
+
+	r := NewReassembler()
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+	for _, b := range [][]byte{
+		{1, 2, 3},                     // 不完整的分片头
+		{1, 0, 0, 0, 0, 0, 0, 0},      // 分片总数为 0
+		{1, 0, 0, 0, 2, 0, 2, 0, 'x'}, // 分片序号超出总数
+	} {
+		if _, err := r.Add(addr, b); err != ErrBadFragment {
+			t.Errorf("Add(%v) = %v, want %v", b, err, ErrBadFragment)
+		}
+	}
+	// 同一个消息的分片总数不一致
+	r.Add(addr, []byte{7, 0, 0, 0, 0, 0, 2, 0, 'x'})
+	if _, err := r.Add(addr, []byte{7, 0, 0, 0, 1, 0, 3, 0, 'x'}); err != ErrBadFragment {
+		t.Errorf("Add() with inconsistent count = %v, want %v", err, ErrBadFragment)
+	}
+}
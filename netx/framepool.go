@@ -0,0 +1,86 @@
+package netx
+
+import (
+	"math/bits"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// 池中缓冲区的大小按 2 的幂分级，从 64B 到 1MB，更大的消息直接分配，不放回池中
+const (
+	minPooledShift = 6
+	maxPooledShift = 20
+)
+
+var framePools [maxPooledShift - minPooledShift + 1]sync.Pool
+
+// lease 池中的一块缓冲区，每次租借和归还 gen 都会加一，用来发现重复归还
+type lease struct {
+	buf   []byte
+	gen   uint32
+	class int
+	stack []byte // 开启泄漏检测时记录租借的位置
+}
+
+// Frame 从池中租借内存的消息，处理完后调用 Release 归还，之后不能再访问 B。
+// 不是从池中租借的 Frame（例如 FrameCodec 不是 *Framer 时）调用 Release 也是安全的。
+type Frame struct {
+	B   []byte
+	l   *lease
+	gen uint32
+}
+
+// Release 把消息的内存归还到池中，同一个 Frame 归还两次时 panic
+func (f Frame) Release() {
+	if f.l == nil {
+		return
+	}
+	if !atomic.CompareAndSwapUint32(&f.l.gen, f.gen, f.gen+1) {
+		panic("netx: Frame released twice")
+	}
+	if f.l.stack != nil {
+		runtime.SetFinalizer(f.l, nil)
+		f.l.stack = nil
+	}
+	framePools[f.l.class].Put(f.l)
+}
+
+// leakReporter 开启泄漏检测时的回调，类型为 func(stack string)
+var leakReporter atomic.Value
+
+// SetFrameLeakReporter 开启消息泄漏检测：租借的 Frame 没有 Release 就被 GC 回收时调用 report，
+// stack 为租借时的调用栈。检测需要记录调用栈并设置 finalizer，开销较大，只用于调试；report 为 nil 时关闭。
+func SetFrameLeakReporter(report func(stack string)) {
+	leakReporter.Store(report)
+}
+
+// leaseFrame 从池中租借长度为 n 的 Frame
+func leaseFrame(n int) Frame {
+	shift := minPooledShift
+	if n > 1<<minPooledShift {
+		shift = bits.Len(uint(n - 1))
+	}
+	if shift > maxPooledShift {
+		return Frame{B: make([]byte, n)}
+	}
+
+	class := shift - minPooledShift
+	l, _ := framePools[class].Get().(*lease)
+	if l == nil {
+		l = &lease{buf: make([]byte, 1<<shift), class: class}
+	}
+	gen := atomic.AddUint32(&l.gen, 1)
+	if report, _ := leakReporter.Load().(func(string)); report != nil {
+		l.stack = stack()
+		runtime.SetFinalizer(l, func(l *lease) {
+			report(string(l.stack))
+		})
+	}
+	return Frame{B: l.buf[:n], l: l, gen: gen}
+}
+
+func stack() []byte {
+	buf := make([]byte, 4096)
+	return buf[:runtime.Stack(buf, false)]
+}
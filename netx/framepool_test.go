@@ -0,0 +1,144 @@
+package netx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadFrameLease(t *testing.T) {
+	f := NewFramer(WithChecksum(), WithCompression(Gzip, 1024))
+	sizes := []int{0, 100, 5000, 2 << 20}
+	var buf bytes.Buffer
+	for _, n := range sizes {
+		f.WriteFrame(&buf, bytes.Repeat([]byte{'x'}, n))
+	}
+	buf.Write([]byte{1, 0}) // 不完整的长度头
+
+	r := bufio.NewReader(&buf)
+	for _, n := range sizes {
+		fr, err := f.ReadFrameLease(r)
+		if err != nil || len(fr.B) != n || bytes.Count(fr.B, []byte{'x'}) != n {
+			t.Fatalf("ReadFrameLease() = %d bytes, %v, want %d bytes", len(fr.B), err, n)
+		}
+		fr.Release()
+	}
+	if _, err := f.ReadFrameLease(r); err == nil {
+		t.Error("ReadFrameLease() on truncated header succeeded")
+	}
+}
+
+func TestFrameReleaseTwice(t *testing.T) {
+	fr := leaseFrame(10)
+	fr.Release()
+	defer func() {
+		if recover() == nil {
+			t.Error("second Release did not panic")
+		}
+	}()
+	fr.Release()
+}
+
+func TestClientRecvFrame(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	c := NewClient(client)
+	defer c.Close()
+	go func() {
+		sc := NewClient(server)
+		sc.Send([]byte("hello"))
+		sc.Send([]byte("world"))
+	}()
+
+	for _, want := range []string{"hello", "world"} {
+		fr, err := c.RecvFrame(context.Background())
+		if err != nil || string(fr.B) != want {
+			t.Errorf("RecvFrame() = %q, %v, want %q", fr.B, err, want)
+		}
+		fr.Release()
+	}
+
+	// ctx 结束时立即返回
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.RecvFrame(ctx); err != context.DeadlineExceeded {
+		t.Errorf("RecvFrame() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestFrameLeakReporter(t *testing.T) {
+	leaks := make(chan string, 1)
+	SetFrameLeakReporter(func(stack string) {
+		select {
+		case leaks <- stack:
+		default:
+		}
+	})
+	defer SetFrameLeakReporter(nil)
+
+	leaseFrame(10).Release()
+	func() {
+		fr := leaseFrame(10)
+		_ = fr
+	}()
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		select {
+		case stack := <-leaks:
+			if !strings.Contains(stack, "TestFrameLeakReporter") {
+				t.Errorf("leak stack does not point to the test:\n%s", stack)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Error("leaked frame was not reported")
+}
+
+// repeatReader 不断重复同一段数据，读取时不分配内存
+type repeatReader struct {
+	data []byte
+	off  int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := copy(p, r.data[r.off:])
+	r.off = (r.off + n) % len(r.data)
+	return n, nil
+}
+
+func BenchmarkReadFrame(b *testing.B) {
+	f := NewFramer()
+	for _, size := range []int{128, 4096, 64 << 10} {
+		var buf bytes.Buffer
+		f.WriteFrame(&buf, make([]byte, size))
+		r := bufio.NewReader(&repeatReader{data: buf.Bytes()})
+
+		b.Run("alloc/"+strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				if _, err := f.ReadFrame(r); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run("lease/"+strconv.Itoa(size), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				fr, err := f.ReadFrameLease(r)
+				if err != nil {
+					b.Fatal(err)
+				}
+				fr.Release()
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package netx
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// ErrChecksumMismatch 消息的 CRC32 校验和不一致，数据在传输过程中被损坏。
+// 出错的消息已经被完整读出，连接上的后续消息不受影响，可以选择记录日志后继续读取。
+var ErrChecksumMismatch = errors.New("netx: frame checksum mismatch")
+
+// ChecksumSize 开启校验和时每个消息末尾追加的字节数
+const ChecksumSize = 4
+
+// Framer 长度前缀的消息封包和拆包
+// 每个消息前面加上 4 字节的长度（int32，默认小端序），TCP 和 UDP 共用同一套格式。
+// Framer 创建之后不会再修改，可以在多个 goroutine 中同时使用。
+type Framer struct {
+	order    binary.ByteOrder
+	checksum bool
+
+	// 开启压缩时长度头后面多一个字节的标志位
+	compressors map[byte]Compressor
+	compressor  Compressor
+	threshold   int
+	// 解压后的最大长度
+	maxDecompressed int
+	// 读取时长度头中允许的最大长度
+	maxSize int
+}
+
+// DefaultMaxMessageSize Framer、VarintFramer 和 DelimiterCodec 读取时默认允许的最大消息长度
+// 长度头来自对方，不加限制时一个 4 字节的头就能让本端分配 2GB 的内存。
+const DefaultMaxMessageSize = 16 << 20
+
+// maxSizeOr n<=0 时返回 DefaultMaxMessageSize
+func maxSizeOr(n int) int {
+	if n <= 0 {
+		return DefaultMaxMessageSize
+	}
+	return n
+}
+
+// FramerOption 创建 Framer 时的可选配置
+type FramerOption func(f *Framer)
+
+// WithByteOrder 设置长度头的字节序，默认为 binary.LittleEndian
+// 大多数标准网络协议使用大端序（网络字节序），与它们互通时使用 binary.BigEndian。
+func WithByteOrder(order binary.ByteOrder) FramerOption {
+	return func(f *Framer) {
+		f.order = order
+	}
+}
+
+// WithChecksum 在每个消息末尾追加 4 字节的 CRC32 校验和（IEEE，字节序与长度头相同），
+// 长度头中的长度不包括校验和。默认关闭，需要收发双方同时开启。
+func WithChecksum() FramerOption {
+	return func(f *Framer) {
+		f.checksum = true
+	}
+}
+
+// WithCompression 长度不小于 threshold 的消息使用 c 压缩后发送，压缩后没有变小时原样发送。
+// 开启后长度头后面会多一个字节标识压缩算法，接收方需要注册相同的 Compressor（WithCompression 或者 WithDecompressor）。
+func WithCompression(c Compressor, threshold int) FramerOption {
+	return func(f *Framer) {
+		f.compressor = c
+		f.threshold = threshold
+		f.register(c)
+	}
+}
+
+// WithDecompressor 只用 c 解压收到的消息，发送时不压缩
+func WithDecompressor(c Compressor) FramerOption {
+	return func(f *Framer) {
+		f.register(c)
+	}
+}
+
+// WithMaxDecompressedSize 设置解压后允许的最大消息长度，默认为 DefaultMaxDecompressedSize，
+// 超过时 ReadFrame 等返回 ErrFrameTooLarge。
+func WithMaxDecompressedSize(n int) FramerOption {
+	return func(f *Framer) {
+		f.maxDecompressed = n
+	}
+}
+
+// WithMaxFrameSize 设置 ReadFrame 允许的最大消息长度（压缩后的长度，不包括长度头），n<=0 时使用 DefaultMaxMessageSize。
+// 长度头中的长度超过 n 时 ReadFrame 在分配内存之前返回 ErrFrameTooLarge。
+func WithMaxFrameSize(n int) FramerOption {
+	return func(f *Framer) {
+		f.maxSize = maxSizeOr(n)
+	}
+}
+
+// NewFramer 创建一个 Framer
+func NewFramer(opts ...FramerOption) *Framer {
+	f := &Framer{order: binary.LittleEndian, maxDecompressed: DefaultMaxDecompressedSize, maxSize: DefaultMaxMessageSize}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// defaultFramer Encode、Decode 等包级别函数使用的 Framer
+var defaultFramer = NewFramer()
+
+// WriteFrame 把 payload 封包后一次性写入 w，不会把一个消息拆成多次 Write
+func (f *Framer) WriteFrame(w io.Writer, payload []byte) error {
+	if int64(len(payload)) > maxFrameLen {
+		return ErrInvalidLength
+	}
+	_, err := w.Write(f.AppendFrame(make([]byte, 0, HeaderSize+len(payload)+f.extraSize()), payload))
+	return err
+}
+
+// ReadFrame 从 r 中读取一个完整的消息，消息不完整时会一直等待后续的数据。
+// 在消息边界上遇到 EOF 时返回 io.EOF，读到一半遇到 EOF 时返回 io.ErrUnexpectedEOF。
+// 开启校验和时，校验失败返回 ErrChecksumMismatch；压缩过的消息会自动解压。
+// 长度超过 WithMaxFrameSize 时返回 ErrFrameTooLarge，之后连接上的数据无法再拆包，应当关闭连接。
+func (f *Framer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	n, err := f.readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// 读取真正的消息数据，以及压缩标志位和末尾的校验和
+	b := make([]byte, n)
+	if err := readBody(r, b); err != nil {
+		return nil, err
+	}
+	return f.unpack(b)
+}
+
+// ReadFrameLease 与 ReadFrame 相同，但消息的内存从池中租借，不再使用时调用 Frame.Release 归还。
+// 解压之后的消息不在池中，Release 只归还读取时使用的缓冲区。
+func (f *Framer) ReadFrameLease(r *bufio.Reader) (Frame, error) {
+	n, err := f.readHeader(r)
+	if err != nil {
+		return Frame{}, err
+	}
+	fr := leaseFrame(n)
+	if err := readBody(r, fr.B); err != nil {
+		fr.Release()
+		return Frame{}, err
+	}
+	if fr.B, err = f.unpack(fr.B); err != nil {
+		fr.Release()
+		return Frame{}, err
+	}
+	return fr, nil
+}
+
+// readHeader 读取长度头，返回长度头之后还需要读取的字节数
+func (f *Framer) readHeader(r *bufio.Reader) (int, error) {
+	// 用 Peek 代替 io.ReadFull，避免长度头逃逸到堆上
+	h, err := r.Peek(HeaderSize)
+	if err != nil {
+		if err == io.EOF && len(h) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	length, err := f.parseHeader(h)
+	if err != nil {
+		return 0, err
+	}
+	if length > f.maxSize {
+		return 0, ErrFrameTooLarge
+	}
+	r.Discard(HeaderSize)
+	return length + f.extraSize(), nil
+}
+
+func readBody(r *bufio.Reader, b []byte) error {
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+// AppendFrame 把 payload 封包后追加到 dst，可以用来把多个消息打包进同一个 UDP 数据报
+func (f *Framer) AppendFrame(dst, payload []byte) []byte {
+	flag, body := f.compress(payload)
+	var h [HeaderSize]byte
+	f.putHeader(h[:], len(body))
+	dst = append(dst, h[:]...)
+	if f.compressors != nil {
+		dst = append(dst, flag)
+	}
+	dst = append(dst, body...)
+	if f.checksum {
+		var sum [ChecksumSize]byte
+		f.order.PutUint32(sum[:], crc32.ChecksumIEEE(body))
+		dst = append(dst, sum[:]...)
+	}
+	return dst
+}
+
+// DecodePacket 解出一个 UDP 数据报中的所有消息
+// 返回的消息引用 b 的内存，不会复制，压缩过的消息除外。
+func (f *Framer) DecodePacket(b []byte) ([][]byte, error) {
+	var msgs [][]byte
+	for len(b) > 0 {
+		if len(b) < HeaderSize {
+			return msgs, ErrTruncated
+		}
+		length, err := f.parseHeader(b)
+		if err != nil {
+			return msgs, err
+		}
+		b = b[HeaderSize:]
+		n := length + f.extraSize()
+		if len(b) < n {
+			return msgs, ErrTruncated
+		}
+		msg, err := f.unpack(b[:n])
+		if err != nil {
+			return msgs, err
+		}
+		msgs = append(msgs, msg)
+		b = b[n:]
+	}
+	return msgs, nil
+}
+
+// 超过一个字节的数据类型在内存中存储的顺序有大小端模式，所以int8不能用
+// 长度使用 int32 编码，字节序由 WithByteOrder 指定
+const maxFrameLen = 1<<31 - 1
+
+func (f *Framer) putHeader(b []byte, length int) {
+	f.order.PutUint32(b, uint32(length))
+}
+
+func (f *Framer) parseHeader(b []byte) (int, error) {
+	length := int32(f.order.Uint32(b))
+	if length < 0 {
+		return 0, ErrInvalidLength
+	}
+	return int(length), nil
+}
+
+func (f *Framer) register(c Compressor) {
+	if c.Flag() == FlagNone {
+		panic("netx: compressor flag must not be FlagNone")
+	}
+	if f.compressors == nil {
+		f.compressors = make(map[byte]Compressor)
+	}
+	f.compressors[c.Flag()] = c
+}
+
+// extraSize 除了长度头和消息之外的字节数：压缩标志位和校验和
+func (f *Framer) extraSize() int {
+	n := 0
+	if f.compressors != nil {
+		n++
+	}
+	if f.checksum {
+		n += ChecksumSize
+	}
+	return n
+}
+
+func (f *Framer) compress(payload []byte) (byte, []byte) {
+	if f.compressor == nil || len(payload) < f.threshold {
+		return FlagNone, payload
+	}
+	// 压缩失败时不影响发送，原样发送即可
+	b, err := f.compressor.Compress(payload)
+	if err != nil || len(b) >= len(payload) || int64(len(b)) > maxFrameLen {
+		return FlagNone, payload
+	}
+	return f.compressor.Flag(), b
+}
+
+// unpack 从长度头之后的数据中取出消息：检查校验和，按标志位解压
+func (f *Framer) unpack(b []byte) ([]byte, error) {
+	flag := FlagNone
+	if f.compressors != nil {
+		flag, b = b[0], b[1:]
+	}
+	n := len(b)
+	if f.checksum {
+		n -= ChecksumSize
+		if f.order.Uint32(b[n:]) != crc32.ChecksumIEEE(b[:n]) {
+			return nil, ErrChecksumMismatch
+		}
+	}
+
+	body := b[:n:n]
+	if flag == FlagNone {
+		return body, nil
+	}
+	c, ok := f.compressors[flag]
+	if !ok {
+		return nil, ErrUnknownCompression
+	}
+	if lc, ok := c.(LimitedDecompressor); ok {
+		return lc.DecompressLimit(body, f.maxDecompressed)
+	}
+	msg, err := c.Decompress(body)
+	if err == nil && len(msg) > f.maxDecompressed {
+		return nil, ErrFrameTooLarge
+	}
+	return msg, err
+}
@@ -0,0 +1,176 @@
+package netx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// oneByteReader 每次 Read 只返回一个字节，模拟消息被拆成很多段到达
+type oneByteReader struct {
+	r io.Reader
+}
+
+func (o oneByteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	return o.r.Read(p[:1])
+}
+
+func TestFramerShortReads(t *testing.T) {
+	f := NewFramer()
+	var buf bytes.Buffer
+	for _, m := range []string{"hello", "", "你好，世界"} {
+		if err := f.WriteFrame(&buf, []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := bufio.NewReader(oneByteReader{&buf})
+	for _, want := range []string{"hello", "", "你好，世界"} {
+		got, err := f.ReadFrame(r)
+		if err != nil || string(got) != want {
+			t.Errorf("ReadFrame() = %q, %v, want %q", got, err, want)
+		}
+	}
+	if _, err := f.ReadFrame(r); err != io.EOF {
+		t.Errorf("ReadFrame at end = %v, want io.EOF", err)
+	}
+}
+
+func TestFramerTruncated(t *testing.T) {
+	f := NewFramer()
+	var buf bytes.Buffer
+	f.WriteFrame(&buf, []byte("hello"))
+	b := buf.Bytes()
+
+	// 消息头不完整
+	if _, err := f.ReadFrame(bufio.NewReader(bytes.NewReader(b[:2]))); err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadFrame(short header) = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	// 消息体不完整
+	if _, err := f.ReadFrame(bufio.NewReader(bytes.NewReader(b[:len(b)-1]))); err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadFrame(short body) = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+// errWriter 写入固定数量的字节后返回错误
+type errWriter struct{ n int }
+
+func (w *errWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return w.n, io.ErrShortWrite
+	}
+	return len(p), nil
+}
+
+func TestFramerWriteError(t *testing.T) {
+	if err := NewFramer().WriteFrame(&errWriter{n: 3}, []byte("hello")); err != io.ErrShortWrite {
+		t.Errorf("WriteFrame() = %v, want %v", err, io.ErrShortWrite)
+	}
+}
+
+func TestFramerByteOrder(t *testing.T) {
+	// 大端序的对端发来的数据：长度 5 + "hello"
+	peer := []byte{0, 0, 0, 5, 'h', 'e', 'l', 'l', 'o'}
+
+	be := NewFramer(WithByteOrder(binary.BigEndian))
+	got, err := be.ReadFrame(bufio.NewReader(bytes.NewReader(peer)))
+	if err != nil || string(got) != "hello" {
+		t.Errorf("big endian ReadFrame() = %q, %v, want %q", got, err, "hello")
+	}
+
+	var buf bytes.Buffer
+	if err := be.WriteFrame(&buf, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), peer) {
+		t.Errorf("big endian WriteFrame() = %x, want %x", buf.Bytes(), peer)
+	}
+	if msgs, err := be.DecodePacket(peer); err != nil || len(msgs) != 1 || string(msgs[0]) != "hello" {
+		t.Errorf("big endian DecodePacket() = %q, %v", msgs, err)
+	}
+
+	// 默认仍然是小端序，与之前的 Encode/Decode 兼容
+	if h := NewFramer().AppendFrame(nil, []byte("hello"))[:4]; !bytes.Equal(h, []byte{5, 0, 0, 0}) {
+		t.Errorf("default header = %x, want little endian", h)
+	}
+}
+
+func TestFramerChecksum(t *testing.T) {
+	f := NewFramer(WithChecksum())
+	var buf bytes.Buffer
+	for _, m := range []string{"first", "second", "third"} {
+		f.WriteFrame(&buf, []byte(m))
+	}
+	data := buf.Bytes()
+	if n := 3*(HeaderSize+ChecksumSize) + len("firstsecondthird"); len(data) != n {
+		t.Fatalf("encoded %d bytes, want %d", len(data), n)
+	}
+
+	msgs, err := f.DecodePacket(data)
+	if err != nil || len(msgs) != 3 || string(msgs[1]) != "second" {
+		t.Fatalf("DecodePacket() = %q, %v", msgs, err)
+	}
+
+	// 损坏第二个消息中的一个字节
+	corrupted := append([]byte(nil), data...)
+	corrupted[2*HeaderSize+ChecksumSize+len("first")+1] ^= 0xff
+	if _, err := f.DecodePacket(corrupted); err != ErrChecksumMismatch {
+		t.Errorf("DecodePacket(corrupted) = %v, want %v", err, ErrChecksumMismatch)
+	}
+
+	// 校验失败之后连接上的后续消息仍然可以正常读取
+	r := bufio.NewReader(bytes.NewReader(corrupted))
+	want := []struct {
+		msg string
+		err error
+	}{{"first", nil}, {"", ErrChecksumMismatch}, {"third", nil}}
+	for _, w := range want {
+		got, err := f.ReadFrame(r)
+		if err != w.err || string(got) != w.msg {
+			t.Errorf("ReadFrame() = %q, %v, want %q, %v", got, err, w.msg, w.err)
+		}
+	}
+
+	sc := NewFrameScanner(0, WithChecksum()).NewScanner(bytes.NewReader(corrupted))
+	for sc.Scan() {
+	}
+	if err := sc.Err(); err != ErrChecksumMismatch {
+		t.Errorf("Scanner.Err() = %v, want %v", err, ErrChecksumMismatch)
+	}
+}
+
+// TestMaxFrameSize 长度头超过限制时在分配内存之前返回 ErrFrameTooLarge，不需要等到消息体到达
+func TestMaxFrameSize(t *testing.T) {
+	huge := []byte{0xff, 0xff, 0xff, 0x7f}
+	if _, err := NewFramer().ReadFrame(bufio.NewReader(bytes.NewReader(huge))); err != ErrFrameTooLarge {
+		t.Errorf("Framer.ReadFrame(2GB header) = %v, want ErrFrameTooLarge", err)
+	}
+	if _, err := NewFramer().ReadFrameLease(bufio.NewReader(bytes.NewReader(huge))); err != ErrFrameTooLarge {
+		t.Errorf("Framer.ReadFrameLease(2GB header) = %v, want ErrFrameTooLarge", err)
+	}
+	if _, err := NewVarintFramer().ReadFrame(bufio.NewReader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 0x07}))); err != ErrFrameTooLarge {
+		t.Errorf("VarintFramer.ReadFrame(2GB header) = %v, want ErrFrameTooLarge", err)
+	}
+
+	for _, fc := range []FrameCodec{
+		NewFramer(WithMaxFrameSize(10)),
+		NewVarintFramer(WithVarintMaxSize(10)),
+		NewLineCodec(WithDelimiterMaxSize(10)),
+	} {
+		var buf bytes.Buffer
+		fc.WriteFrame(&buf, []byte("0123456789"))
+		fc.WriteFrame(&buf, []byte("0123456789a"))
+		r := bufio.NewReader(&buf)
+		if msg, err := fc.ReadFrame(r); err != nil || string(msg) != "0123456789" {
+			t.Errorf("%T.ReadFrame() = %q, %v", fc, msg, err)
+		}
+		if _, err := fc.ReadFrame(r); err != ErrFrameTooLarge {
+			t.Errorf("%T.ReadFrame(11 bytes) = %v, want ErrFrameTooLarge", fc, err)
+		}
+	}
+}
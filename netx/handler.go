@@ -0,0 +1,148 @@
+package netx
+
+import (
+	"context"
+	"gopractice/netx/workerpool"
+	"net"
+	"sync"
+)
+
+// Handler 处理一个连接，ServeConn 返回后连接会被关闭
+type Handler interface {
+	ServeConn(ctx context.Context, conn *Conn)
+}
+
+// HandlerFunc 把普通函数转换为 Handler
+type HandlerFunc func(ctx context.Context, conn *Conn)
+
+// ServeConn 调用 f(ctx, conn)
+func (f HandlerFunc) ServeConn(ctx context.Context, conn *Conn) {
+	f(ctx, conn)
+}
+
+// FrameHandler 按消息处理请求，返回的 resp 不为 nil 时发送给客户端
+type FrameHandler interface {
+	HandleFrame(ctx context.Context, req []byte) (resp []byte, err error)
+}
+
+// FrameHandlerFunc 把普通函数转换为 FrameHandler
+type FrameHandlerFunc func(ctx context.Context, req []byte) ([]byte, error)
+
+// HandleFrame 调用 f(ctx, req)
+func (f FrameHandlerFunc) HandleFrame(ctx context.Context, req []byte) ([]byte, error) {
+	return f(ctx, req)
+}
+
+// HandleFrames 返回一个逐个读取消息并交给 h 处理的 Handler，
+// 读取失败、发送失败或者 h 返回错误时关闭连接
+func HandleFrames(h FrameHandler) Handler {
+	return HandlerFunc(func(ctx context.Context, conn *Conn) {
+		ctx = withCodec(ctx, conn.client.values)
+		for {
+			req, err := conn.Recv(ctx)
+			if err != nil {
+				return
+			}
+			resp, err := h.HandleFrame(ctx, req)
+			if err != nil {
+				return
+			}
+			if resp == nil {
+				continue
+			}
+			if err := conn.Send(resp); err != nil {
+				return
+			}
+		}
+	})
+}
+
+// HandleFramesPool 与 HandleFrames 相同，但消息交给 pool 中的 worker 处理，
+// 多个连接共用一个 pool 时，正在处理和排队的消息总数不会超过 pool 的 worker 数加队列长度。
+// 连接的 goroutine 只负责读取消息并提交，队列满时提交会阻塞，连接停止读取，
+// 数据留在内核缓冲区中，由 TCP 的流量控制让客户端放慢发送。
+// 同一个连接上的消息可能被并发处理，回复的顺序不一定与请求相同，需要对应请求和回复时使用 HandleCallsPool。
+// pool 由调用者通过 workerpool.New 创建并负责 Stop，Stop 之后连接会被关闭。
+func HandleFramesPool(h FrameHandler, pool *workerpool.Pool) Handler {
+	return HandlerFunc(func(ctx context.Context, conn *Conn) {
+		ctx = withCodec(ctx, conn.client.values)
+		var wg sync.WaitGroup
+		defer wg.Wait()
+		for {
+			req, err := conn.Recv(ctx)
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			err = pool.Submit(func() {
+				defer wg.Done()
+				resp, err := h.HandleFrame(ctx, req)
+				if err == nil && resp != nil {
+					err = conn.Send(resp)
+				}
+				if err != nil {
+					// 让正在等待 Recv 的读取循环退出
+					conn.client.Close()
+				}
+			})
+			if err != nil {
+				wg.Done()
+				return
+			}
+		}
+	})
+}
+
+// Conn Handler 处理的连接，在 net.Conn 之上按 FrameCodec 收发消息
+// Server 的指标、跟踪、超时和心跳配置都会生效。使用 Recv 之后不要再直接调用 Read，
+// 否则会和 Recv 的缓冲区抢数据。
+type Conn struct {
+	net.Conn
+	client *Client
+}
+
+// Recv 读取一个完整的消息，ctx 结束时立即返回 ctx.Err()
+func (c *Conn) Recv(ctx context.Context) ([]byte, error) {
+	return c.client.RecvContext(ctx)
+}
+
+// RecvFrame 与 Recv 相同，但消息的内存从池中租借，处理完后必须调用 Frame.Release，见 Client.RecvFrame
+func (c *Conn) RecvFrame(ctx context.Context) (Frame, error) {
+	return c.client.RecvFrame(ctx)
+}
+
+// Send 发送一个消息，可以在多个 goroutine 中同时调用
+func (c *Conn) Send(msg []byte) error {
+	return c.client.Send(msg)
+}
+
+// RecvValue 读取一个消息，并用 WithCodec 设置的 Codec 解码到 v 中
+func (c *Conn) RecvValue(ctx context.Context, v any) error {
+	b, err := c.Recv(ctx)
+	if err != nil {
+		return err
+	}
+	return c.client.values.Decode(b, v)
+}
+
+// SendValue 用 WithCodec 设置的 Codec 编码 v 后发送
+func (c *Conn) SendValue(v any) error {
+	return c.client.SendValue(v)
+}
+
+// CloseWrite 关闭连接的写方向，见 Client.CloseWrite
+func (c *Conn) CloseWrite() error {
+	return c.client.CloseWrite()
+}
+
+// NewTCPServer 返回一个使用 h 处理连接的 Server，opts 设置连接收发消息的方式，例如 WithFrameCodec、WithCodec。
+// Accept、为每个连接启动 goroutine、从 Handler 的 panic 中恢复以及关闭连接都由 Server 完成，
+// 返回的 Server 在调用 ListenAndServe 之前还可以修改其他配置。
+func NewTCPServer(addr string, h Handler, opts ...ClientOption) *Server {
+	return &Server{
+		Addr: addr,
+		Handler: func(ctx context.Context, conn net.Conn) {
+			h.ServeConn(ctx, &Conn{Conn: conn, client: NewClient(conn, opts...)})
+		},
+	}
+}
@@ -0,0 +1,283 @@
+package netx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"gopractice/logx"
+	"gopractice/netx/codec"
+	"gopractice/netx/metrics"
+	"gopractice/netx/workerpool"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// startTCPServer 在随机端口上启动 s，测试结束时关闭
+func startTCPServer(t *testing.T, s *Server) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return l.Addr().String()
+}
+
+func TestHandleFrames(t *testing.T) {
+	errQuit := errors.New("quit")
+	h := FrameHandlerFunc(func(ctx context.Context, req []byte) ([]byte, error) {
+		switch string(req) {
+		case "quit":
+			return nil, errQuit
+		case "noreply":
+			return nil, nil
+		}
+		return bytes.ToUpper(req), nil
+	})
+	addr := startTCPServer(t, NewTCPServer("", HandleFrames(h), WithFrameCodec(NewVarintFramer())))
+
+	c, err := Dial(addr, WithFrameCodec(NewVarintFramer()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Send([]byte("noreply"))
+	if got, err := echo(c, "hello"); err != nil || got != "HELLO" {
+		t.Errorf("echo = %q, %v, want %q", got, err, "HELLO")
+	}
+
+	// FrameHandler 返回错误时关闭连接
+	c.Send([]byte("quit"))
+	c.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	if msg, err := c.Recv(); err == nil {
+		t.Errorf("Recv() after quit = %q, want error", msg)
+	}
+}
+
+func TestServerRecoversPanic(t *testing.T) {
+	rec := new(logx.Recorder)
+	h := HandlerFunc(func(ctx context.Context, conn *Conn) {
+		msg, err := conn.Recv(ctx)
+		if err != nil {
+			return
+		}
+		if string(msg) == "panic" {
+			panic("boom")
+		}
+		conn.Send(msg)
+	})
+	s := NewTCPServer("", h)
+	s.Logger = rec
+	addr := startTCPServer(t, s)
+
+	c, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Send([]byte("panic"))
+	c.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c.Recv(); err == nil {
+		t.Error("Recv() after panic succeeded, want connection closed")
+	}
+
+	// panic 只影响当前连接
+	c2, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	if got, err := echo(c2, "still alive"); err != nil || got != "still alive" {
+		t.Errorf("echo = %q, %v", got, err)
+	}
+
+	var logged bool
+	for _, e := range rec.Entries() {
+		if e.Level == logx.LevelError && strings.Contains(e.Msg, "boom") {
+			logged = true
+		}
+	}
+	if !logged {
+		t.Errorf("panic was not logged: %v", rec.Entries())
+	}
+}
+
+func TestConnValues(t *testing.T) {
+	h := HandlerFunc(func(ctx context.Context, conn *Conn) {
+		for {
+			var req wrapperspb.StringValue
+			if err := conn.RecvValue(ctx, &req); err != nil {
+				return
+			}
+			conn.SendValue(wrapperspb.String(strings.ToUpper(req.Value)))
+		}
+	})
+	addr := startTCPServer(t, NewTCPServer("", h, WithCodec(codec.Protobuf)))
+
+	c, err := Dial(addr, WithCodec(codec.Protobuf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.SendValue(wrapperspb.String("hello")); err != nil {
+		t.Fatal(err)
+	}
+	var resp wrapperspb.StringValue
+	if err := c.RecvValue(&resp); err != nil || resp.Value != "HELLO" {
+		t.Errorf("RecvValue() = %q, %v, want %q", resp.Value, err, "HELLO")
+	}
+	// Protobuf 只能编码 proto.Message
+	if err := c.SendValue("not a proto message"); err != codec.ErrNotProtoMessage {
+		t.Errorf("SendValue(string) = %v, want %v", err, codec.ErrNotProtoMessage)
+	}
+}
+
+// pool 满了之后服务端停止读取，释放后积压的消息全部被处理
+func TestHandleFramesPoolBackpressure(t *testing.T) {
+	pool := workerpool.New(1, 1)
+	defer pool.Stop()
+	release := make(chan struct{})
+	h := FrameHandlerFunc(func(ctx context.Context, req []byte) ([]byte, error) {
+		<-release
+		return req, nil
+	})
+	col := new(captureCollector)
+	s := NewTCPServer("", HandleFramesPool(h, pool))
+	s.Metrics = col
+	addr := startTCPServer(t, s)
+
+	c, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	const n = 10
+	for i := 0; i < n; i++ {
+		c.Send([]byte{byte(i)})
+	}
+
+	framesRead := func() int {
+		count := 0
+		for _, e := range col.Events() {
+			if e == "inc "+metrics.FramesRead {
+				count++
+			}
+		}
+		return count
+	}
+	// 一个正在处理，一个在队列中，一个阻塞在提交上
+	time.Sleep(50 * time.Millisecond)
+	if got := framesRead(); got != 3 {
+		t.Errorf("frames read while pool is full = %d, want 3", got)
+	}
+
+	close(release)
+	c.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < n; i++ {
+		if msg, err := c.Recv(); err != nil || len(msg) != 1 {
+			t.Fatalf("Recv() = %v, %v", msg, err)
+		}
+	}
+}
+
+func TestHandleCallsPool(t *testing.T) {
+	pool := workerpool.New(2, 0)
+	defer pool.Stop()
+	h := FrameHandlerFunc(func(ctx context.Context, req []byte) ([]byte, error) {
+		if string(req) == "fail" {
+			return nil, errors.New("failed")
+		}
+		return bytes.ToUpper(req), nil
+	})
+	addr := startTCPServer(t, NewTCPServer("", HandleCallsPool(h, pool)))
+
+	c, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := NewCallClient(c)
+	defer cc.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp, err := cc.Call(context.Background(), []byte("hi")); err != nil || string(resp) != "HI" {
+				t.Errorf("Call() = %q, %v", resp, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := cc.Call(context.Background(), []byte("fail")); err == nil {
+		t.Error("Call(fail) succeeded, want RemoteError")
+	}
+}
+
+// 客户端发完请求后 CloseWrite，服务端读到 io.EOF 后回复处理结果并关闭写方向，客户端 RecvAll 正常结束
+func TestClientCloseWrite(t *testing.T) {
+	h := HandlerFunc(func(ctx context.Context, conn *Conn) {
+		var n int
+		for {
+			_, err := conn.Recv(ctx)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("server Recv() = %v, want io.EOF after the requests", err)
+				return
+			}
+			n++
+		}
+		conn.Send([]byte(strconv.Itoa(n)))
+		if err := conn.CloseWrite(); err != nil {
+			t.Errorf("server CloseWrite() = %v", err)
+		}
+	})
+	addr := startTCPServer(t, NewTCPServer("", h))
+
+	c, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for i := 0; i < 5; i++ {
+		c.Send([]byte("req"))
+	}
+	if err := c.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Send([]byte("late")); err != ErrWriteClosed {
+		t.Errorf("Send() after CloseWrite = %v, want ErrWriteClosed", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	replies, err := c.RecvAll(ctx)
+	if err != nil || len(replies) != 1 || string(replies[0]) != "5" {
+		t.Errorf("RecvAll() = %q, %v, want [\"5\"], nil", replies, err)
+	}
+}
+
+// 消息中途断开时返回 io.ErrUnexpectedEOF，和正常结束区分开
+func TestClientRecvUnexpectedEOF(t *testing.T) {
+	a, b := net.Pipe()
+	c := NewClient(b)
+	if err := c.CloseWrite(); err != ErrCloseWriteUnsupported {
+		t.Errorf("CloseWrite() on net.Pipe = %v, want ErrCloseWriteUnsupported", err)
+	}
+	go func() {
+		a.Write([]byte{10, 0, 0, 0, 'h', 'i'})
+		a.Close()
+	}()
+	if _, err := c.RecvAll(context.Background()); err != io.ErrUnexpectedEOF {
+		t.Errorf("RecvAll() = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
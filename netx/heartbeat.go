@@ -0,0 +1,101 @@
+package netx
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+var (
+	// ErrHeartbeatTimeout 连续 N 个心跳周期没有收到对方的任何消息
+	ErrHeartbeatTimeout = errors.New("netx: heartbeat timeout")
+	// ErrUnknownFrameType 开启心跳后收到了不认识的消息类型
+	ErrUnknownFrameType = errors.New("netx: unknown frame type")
+)
+
+// DefaultHeartbeatMisses 允许连续错过的心跳次数的默认值
+const DefaultHeartbeatMisses = 3
+
+// 开启心跳后每个消息的第一个字节表示消息类型，PING 和 PONG 是控制消息，不会交给调用者
+const (
+	frameData byte = iota
+	framePing
+	framePong
+)
+
+// heartbeat 心跳配置，见 WithHeartbeat 和 Server.HeartbeatInterval
+type heartbeat struct {
+	interval time.Duration
+	misses   int
+	// ping 是否主动发送 PING，服务端只回复 PONG
+	ping bool
+}
+
+func (h heartbeat) enabled() bool {
+	return h.interval > 0
+}
+
+// timeout 超过这个时间没有收到任何消息就认为对方已经失效
+func (h heartbeat) timeout() time.Duration {
+	if !h.enabled() {
+		return 0
+	}
+	misses := h.misses
+	if misses <= 0 {
+		misses = DefaultHeartbeatMisses
+	}
+	return h.interval * time.Duration(misses)
+}
+
+// WithHeartbeat 每隔 interval 发送一个 PING，连续 misses 个周期没有收到服务端的任何消息（包括 PONG）时
+// Recv 返回 ErrHeartbeatTimeout 并关闭连接，misses<=0 时使用 DefaultHeartbeatMisses。
+// 服务端需要设置相同的 Server.HeartbeatInterval，心跳消息在 Recv 中处理，所以需要一直有 goroutine 在调用 Recv。
+func WithHeartbeat(interval time.Duration, misses int) ClientOption {
+	return func(c *Client) {
+		c.heartbeat = heartbeat{interval: interval, misses: misses, ping: true}
+	}
+}
+
+// heartbeatOf 返回 conn 的心跳配置，不是 Server 交给 Handler 的连接时不开启心跳
+func heartbeatOf(conn net.Conn) heartbeat {
+	if sc, ok := conn.(*serverConn); ok {
+		return sc.heartbeat
+	}
+	return heartbeat{}
+}
+
+// pingLoop 定时发送 PING，直到 Client 被关闭或者发送失败
+func (c *Client) pingLoop() {
+	ticker := time.NewTicker(c.heartbeat.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.writeFrame([]byte{framePing}); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// unwrapFrame 处理控制消息，返回去掉类型的数据消息，控制消息返回 nil
+func (c *Client) unwrapFrame(msg []byte) ([]byte, error) {
+	if len(msg) == 0 {
+		return nil, ErrUnknownFrameType
+	}
+	switch msg[0] {
+	case frameData:
+		return msg[1:], nil
+	case framePing:
+		if err := c.writeFrame([]byte{framePong}); err != nil && err != ErrWriteClosed {
+			return nil, err
+		}
+		return nil, nil
+	case framePong:
+		return nil, nil
+	default:
+		return nil, ErrUnknownFrameType
+	}
+}
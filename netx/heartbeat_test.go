@@ -0,0 +1,121 @@
+package netx
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// startHeartbeatEcho 启动一个开启心跳的回显服务端，handler 返回的错误发送到 errc
+func startHeartbeatEcho(t *testing.T, interval time.Duration, errc chan<- error) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		HeartbeatInterval: interval,
+		Handler: func(ctx context.Context, conn net.Conn) {
+			c := NewClient(conn)
+			for {
+				msg, err := c.RecvContext(ctx)
+				if err != nil {
+					errc <- err
+					return
+				}
+				c.Send(msg)
+			}
+		},
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return l.Addr().String()
+}
+
+func TestHeartbeatKeepsConnAlive(t *testing.T) {
+	errc := make(chan error, 1)
+	addr := startHeartbeatEcho(t, 20*time.Millisecond, errc)
+	c, err := Dial(addr, WithHeartbeat(20*time.Millisecond, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// 空闲时间超过了 3 个心跳周期，PING 让连接保持活跃，PONG 不会被 Recv 返回
+	time.Sleep(150 * time.Millisecond)
+	for _, msg := range []string{"hello", ""} {
+		if got, err := echo(c, msg); err != nil || got != msg {
+			t.Fatalf("echo = %q, %v, want %q", got, err, msg)
+		}
+	}
+	select {
+	case err := <-errc:
+		t.Fatalf("server handler returned %v", err)
+	default:
+	}
+}
+
+// 客户端不发送心跳时，服务端在 HeartbeatMisses 个周期后关闭连接
+func TestHeartbeatServerDetectsDeadClient(t *testing.T) {
+	errc := make(chan error, 1)
+	addr := startHeartbeatEcho(t, 20*time.Millisecond, errc)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	select {
+	case err := <-errc:
+		if err != ErrHeartbeatTimeout {
+			t.Errorf("server Recv() = %v, want %v", err, ErrHeartbeatTimeout)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("server did not close the dead connection")
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("client Read() = %v, want io.EOF", err)
+	}
+}
+
+// 服务端不回复 PONG 时，客户端的 Recv 返回 ErrHeartbeatTimeout
+func TestHeartbeatClientDetectsDeadServer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	c, err := Dial(l.Addr().String(), WithHeartbeat(10*time.Millisecond, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Recv(); err != ErrHeartbeatTimeout {
+		t.Errorf("Recv() = %v, want %v", err, ErrHeartbeatTimeout)
+	}
+}
+
+func TestHeartbeatUnknownFrameType(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	go NewFramer().WriteFrame(server, []byte{9, 'x'})
+	c := NewClient(client, WithHeartbeat(time.Hour, 0))
+	defer c.Close()
+	if _, err := c.Recv(); err != ErrUnknownFrameType {
+		t.Errorf("Recv() = %v, want %v", err, ErrUnknownFrameType)
+	}
+}
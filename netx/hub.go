@@ -0,0 +1,296 @@
+package netx
+
+import (
+	"errors"
+	"gopractice/logx"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrHubMemberExists Join 的 id 已经在 Hub 中
+	ErrHubMemberExists = errors.New("netx: hub member already exists")
+	// ErrHubMemberNotFound id 不在 Hub 中
+	ErrHubMemberNotFound = errors.New("netx: hub member not found")
+	// ErrSlowConsumer 发送队列已满，SlowConsumerPolicy 为 DisconnectSlow 时连接因此被关闭
+	ErrSlowConsumer = errors.New("netx: slow consumer")
+)
+
+// DefaultHubQueueSize Hub.QueueSize 为 0 时每个连接的发送队列长度
+const DefaultHubQueueSize = 256
+
+// SlowConsumerPolicy 连接的发送队列满时的处理方式
+type SlowConsumerPolicy uint8
+
+const (
+	DropOldest     SlowConsumerPolicy = iota // 丢弃队列中最早的消息，适合只关心最新状态的推送
+	DisconnectSlow                           // 关闭连接，适合不能丢消息的场景，客户端重连后自己补齐
+)
+
+// HubConn Hub 中的连接，*Conn 和 *Client 都实现了它
+type HubConn interface {
+	Send(msg []byte) error
+	Close() error
+}
+
+// HubStats Hub 的统计信息
+type HubStats struct {
+	Members      int    // 当前的连接数
+	Topics       int    // 至少有一个订阅者的主题数
+	Dropped      uint64 // DropOldest 丢弃的消息数
+	Disconnected uint64 // DisconnectSlow 关闭的连接数
+}
+
+// Hub 向多个连接推送消息：广播、发给指定的连接以及按主题发布订阅
+// 每个连接有自己的发送队列和发送 goroutine，一个连接发送慢不会阻塞其他连接和发布者，
+// 队列满时按 Policy 处理。同一个消息的 payload 被所有连接共享，发布之后不能再修改。
+// 通常在 Handler 中用 Session.ID 调用 Join，Handler 返回前调用 Leave。
+type Hub struct {
+	// QueueSize 每个连接的发送队列长度，为 0 时使用 DefaultHubQueueSize
+	QueueSize int
+
+	// Policy 发送队列满时的处理方式，默认为 DropOldest
+	Policy SlowConsumerPolicy
+
+	// Logger 为 nil 时不输出日志
+	Logger logx.Logger
+
+	mu      sync.RWMutex
+	members map[uint64]*hubMember
+	topics  map[string]map[uint64]*hubMember
+
+	dropped      uint64
+	disconnected uint64
+}
+
+type hubMember struct {
+	id     uint64
+	conn   HubConn
+	queue  chan []byte
+	done   chan struct{}
+	topics map[string]struct{} // 由 Hub.mu 保护
+}
+
+// NewHub 返回一个空的 Hub
+func NewHub() *Hub {
+	return &Hub{}
+}
+
+// Join 把 conn 以 id 加入 Hub，并启动它的发送 goroutine
+func (h *Hub) Join(id uint64, conn HubConn) error {
+	size := h.QueueSize
+	if size == 0 {
+		size = DefaultHubQueueSize
+	}
+	m := &hubMember{
+		id:     id,
+		conn:   conn,
+		queue:  make(chan []byte, size),
+		done:   make(chan struct{}),
+		topics: make(map[string]struct{}),
+	}
+	h.mu.Lock()
+	if _, ok := h.members[id]; ok {
+		h.mu.Unlock()
+		return ErrHubMemberExists
+	}
+	if h.members == nil {
+		h.members = make(map[uint64]*hubMember)
+		h.topics = make(map[string]map[uint64]*hubMember)
+	}
+	h.members[id] = m
+	h.mu.Unlock()
+
+	go h.writeLoop(m)
+	return nil
+}
+
+// Leave 把 id 移出 Hub 并取消它的所有订阅，队列中还没有发送的消息被丢弃，不会关闭连接
+func (h *Hub) Leave(id uint64) {
+	h.mu.Lock()
+	m := h.members[id]
+	if m != nil {
+		h.remove(m)
+	}
+	h.mu.Unlock()
+}
+
+// remove 调用时需要持有 h.mu
+func (h *Hub) remove(m *hubMember) {
+	if h.members[m.id] != m {
+		return
+	}
+	delete(h.members, m.id)
+	for topic := range m.topics {
+		h.unsubscribe(m, topic)
+	}
+	close(m.done)
+}
+
+// Broadcast 把 payload 发给所有连接，返回放入队列的连接数
+func (h *Hub) Broadcast(payload []byte) int {
+	h.mu.RLock()
+	members := make([]*hubMember, 0, len(h.members))
+	for _, m := range h.members {
+		members = append(members, m)
+	}
+	h.mu.RUnlock()
+	return h.deliver(members, payload)
+}
+
+// SendTo 把 payload 发给 id，id 不在 Hub 中时返回 ErrHubMemberNotFound，
+// 因为队列已满被断开时返回 ErrSlowConsumer
+func (h *Hub) SendTo(id uint64, payload []byte) error {
+	h.mu.RLock()
+	m := h.members[id]
+	h.mu.RUnlock()
+	if m == nil {
+		return ErrHubMemberNotFound
+	}
+	if !h.enqueue(m, payload) {
+		return ErrSlowConsumer
+	}
+	return nil
+}
+
+// Subscribe 让 id 订阅 topic，重复订阅没有影响
+func (h *Hub) Subscribe(id uint64, topic string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	m := h.members[id]
+	if m == nil {
+		return ErrHubMemberNotFound
+	}
+	subs := h.topics[topic]
+	if subs == nil {
+		subs = make(map[uint64]*hubMember)
+		h.topics[topic] = subs
+	}
+	subs[id] = m
+	m.topics[topic] = struct{}{}
+	return nil
+}
+
+// Unsubscribe 取消 id 对 topic 的订阅
+func (h *Hub) Unsubscribe(id uint64, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if m := h.members[id]; m != nil {
+		h.unsubscribe(m, topic)
+	}
+}
+
+func (h *Hub) unsubscribe(m *hubMember, topic string) {
+	delete(m.topics, topic)
+	if subs := h.topics[topic]; subs != nil {
+		delete(subs, m.id)
+		if len(subs) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+}
+
+// Publish 把 payload 发给 topic 的所有订阅者，返回放入队列的订阅者数
+func (h *Hub) Publish(topic string, payload []byte) int {
+	h.mu.RLock()
+	subs := h.topics[topic]
+	members := make([]*hubMember, 0, len(subs))
+	for _, m := range subs {
+		members = append(members, m)
+	}
+	h.mu.RUnlock()
+	return h.deliver(members, payload)
+}
+
+// Subscribers 返回 topic 的订阅者 ID
+func (h *Hub) Subscribers(topic string) []uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	ids := make([]uint64, 0, len(h.topics[topic]))
+	for id := range h.topics[topic] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Stats 返回统计信息
+func (h *Hub) Stats() HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return HubStats{
+		Members:      len(h.members),
+		Topics:       len(h.topics),
+		Dropped:      atomic.LoadUint64(&h.dropped),
+		Disconnected: atomic.LoadUint64(&h.disconnected),
+	}
+}
+
+func (h *Hub) deliver(members []*hubMember, payload []byte) int {
+	n := 0
+	for _, m := range members {
+		if h.enqueue(m, payload) {
+			n++
+		}
+	}
+	return n
+}
+
+// enqueue 把 payload 放入 m 的发送队列，队列满时按 Policy 处理，m 被断开时返回 false
+func (h *Hub) enqueue(m *hubMember, payload []byte) bool {
+	for {
+		select {
+		case <-m.done:
+			return false
+		case m.queue <- payload:
+			return true
+		default:
+		}
+		if h.Policy == DisconnectSlow {
+			h.disconnect(m)
+			return false
+		}
+		// 其他发布者可能同时在丢弃，所以重新尝试放入，而不是假设一定有空位
+		select {
+		case <-m.queue:
+			atomic.AddUint64(&h.dropped, 1)
+		default:
+		}
+	}
+}
+
+func (h *Hub) disconnect(m *hubMember) {
+	h.mu.Lock()
+	removed := h.members[m.id] == m
+	h.remove(m)
+	h.mu.Unlock()
+	if removed {
+		atomic.AddUint64(&h.disconnected, 1)
+		h.logger().Errorf("netx: hub member %d is too slow, disconnected", m.id)
+		m.conn.Close()
+	}
+}
+
+// writeLoop 把 m 队列中的消息依次发送出去，发送失败时把 m 移出 Hub
+func (h *Hub) writeLoop(m *hubMember) {
+	for {
+		select {
+		case payload := <-m.queue:
+			if err := m.conn.Send(payload); err != nil {
+				h.logger().Debugf("netx: hub member %d send failed, err: %v", m.id, err)
+				h.mu.Lock()
+				h.remove(m)
+				h.mu.Unlock()
+				return
+			}
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func (h *Hub) logger() logx.Logger {
+	if h.Logger == nil {
+		return logx.Nop
+	}
+	return h.Logger
+}
@@ -0,0 +1,195 @@
+package netx
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordConn 记录收到的消息，gate 不为 nil 时每次 Send 都等待 gate 放行
+type recordConn struct {
+	gate chan struct{}
+
+	mu     sync.Mutex
+	msgs   []string
+	closed bool
+}
+
+func (c *recordConn) Send(msg []byte) error {
+	if c.gate != nil {
+		<-c.gate
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.msgs = append(c.msgs, string(msg))
+	return nil
+}
+
+func (c *recordConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// wait 等待收到 n 个消息并返回它们
+func (c *recordConn) wait(t *testing.T, n int) []string {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		c.mu.Lock()
+		msgs := append([]string(nil), c.msgs...)
+		c.mu.Unlock()
+		if len(msgs) >= n {
+			return msgs
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %q, want %d messages", msgs, n)
+		}
+	}
+}
+
+func TestHub(t *testing.T) {
+	h := NewHub()
+	conns := make([]*recordConn, 3)
+	for i := range conns {
+		conns[i] = new(recordConn)
+		if err := h.Join(uint64(i+1), conns[i]); err != nil {
+			t.Fatal(err)
+		}
+		defer h.Leave(uint64(i + 1))
+	}
+	if err := h.Join(1, new(recordConn)); err != ErrHubMemberExists {
+		t.Errorf("Join() with a duplicate id = %v, want ErrHubMemberExists", err)
+	}
+
+	h.Subscribe(1, "news")
+	h.Subscribe(2, "news")
+	h.Subscribe(2, "sports")
+	if n := h.Publish("news", []byte("n1")); n != 2 {
+		t.Errorf("Publish(news) = %d, want 2", n)
+	}
+	h.Unsubscribe(2, "news")
+	h.Publish("news", []byte("n2"))
+	h.Publish("sports", []byte("s1"))
+	if err := h.SendTo(3, []byte("p1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SendTo(9, []byte("p2")); err != ErrHubMemberNotFound {
+		t.Errorf("SendTo(9) = %v, want ErrHubMemberNotFound", err)
+	}
+	if n := h.Broadcast([]byte("all")); n != 3 {
+		t.Errorf("Broadcast() = %d, want 3", n)
+	}
+
+	want := [][]string{{"n1", "n2", "all"}, {"n1", "s1", "all"}, {"p1", "all"}}
+	for i, c := range conns {
+		if got := c.wait(t, len(want[i])); fmt.Sprint(got) != fmt.Sprint(want[i]) {
+			t.Errorf("member %d got %q, want %q", i+1, got, want[i])
+		}
+	}
+
+	h.Leave(2)
+	if st := h.Stats(); st.Members != 2 || st.Topics != 1 {
+		t.Errorf("Stats() = %+v, want 2 members and 1 topic", st)
+	}
+}
+
+func TestHubDropOldest(t *testing.T) {
+	h := &Hub{QueueSize: 2}
+	c := &recordConn{gate: make(chan struct{})}
+	h.Join(1, c)
+	defer h.Leave(1)
+
+	h.SendTo(1, []byte("0"))
+	// 等待 "0" 被取出，正在发送
+	for deadline := time.Now().Add(time.Second); len(h.members[1].queue) != 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the first message was not dequeued")
+		}
+	}
+	for i := 1; i <= 5; i++ {
+		if err := h.SendTo(1, []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(c.gate)
+	if got := c.wait(t, 3); fmt.Sprint(got) != "[0 4 5]" {
+		t.Errorf("got %q, want the in-flight message and the newest 2", got)
+	}
+	if st := h.Stats(); st.Dropped != 3 {
+		t.Errorf("Stats().Dropped = %d, want 3", st.Dropped)
+	}
+}
+
+func TestHubDisconnectSlow(t *testing.T) {
+	h := &Hub{QueueSize: 2, Policy: DisconnectSlow}
+	slow := &recordConn{gate: make(chan struct{})}
+	defer close(slow.gate)
+	fast := new(recordConn)
+	h.Join(1, slow)
+	h.Join(2, fast)
+	defer h.Leave(2)
+
+	// 慢的连接最多一个消息正在发送、两个在队列中，第四个消息一定放不下
+	for i := 0; i < 4; i++ {
+		h.Broadcast([]byte(fmt.Sprint(i)))
+		fast.wait(t, i+1)
+	}
+	slow.mu.Lock()
+	closed := slow.closed
+	slow.mu.Unlock()
+	if !closed {
+		t.Error("slow member was not closed")
+	}
+	if err := h.SendTo(1, []byte("x")); err != ErrHubMemberNotFound {
+		t.Errorf("SendTo(slow) = %v, want ErrHubMemberNotFound", err)
+	}
+	if st := h.Stats(); st.Members != 1 || st.Disconnected != 1 {
+		t.Errorf("Stats() = %+v, want 1 member and 1 disconnected", st)
+	}
+}
+
+// 在 Handler 中用 Session.ID 加入 Hub，向所有连接推送消息
+func TestHubServer(t *testing.T) {
+	h := NewHub()
+	joined := make(chan struct{}, 2)
+	s := NewTCPServer("", HandlerFunc(func(ctx context.Context, conn *Conn) {
+		sess, _ := SessionFrom(ctx)
+		if err := h.Join(sess.ID, conn); err != nil {
+			return
+		}
+		defer h.Leave(sess.ID)
+		joined <- struct{}{}
+		conn.Recv(ctx)
+	}))
+	s.Auth = func(ctx context.Context, _ net.Conn, msg []byte) (any, error) {
+		return string(msg), nil
+	}
+	addr := startTCPServer(t, s)
+
+	var clients []*Client
+	for _, name := range []string{"alice", "bob"} {
+		c, err := Dial(addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if _, err := c.Authenticate(context.Background(), []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+		clients = append(clients, c)
+	}
+	<-joined
+	<-joined
+
+	h.Broadcast([]byte("hello"))
+	for _, c := range clients {
+		c.Conn().SetReadDeadline(time.Now().Add(time.Second))
+		if msg, err := c.Recv(); err != nil || string(msg) != "hello" {
+			t.Errorf("Recv() = %q, %v, want hello", msg, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package netx
+
+import (
+	"errors"
+	"gopractice/netx/ratelimit"
+	"net"
+	"os"
+	"time"
+)
+
+// ErrFrameRateExceeded 连接接收消息的速度超过了 Server.ConnFramesPerSecond，超出的消息被丢弃
+// 连接仍然可以继续读取，Handler 可以选择断开连接或者忽略。
+var ErrFrameRateExceeded = errors.New("netx: frame rate exceeded")
+
+// connLimits 一个连接的限流配置，为 nil 的 Limiter 表示不限制
+type connLimits struct {
+	read   *ratelimit.Limiter // 每个连接读取的字节数
+	write  *ratelimit.Limiter // 每个连接写入的字节数
+	global *ratelimit.Limiter // 所有连接共享的字节数
+	frames *ratelimit.Limiter // 每个连接接收的消息数
+}
+
+func (s *Server) hasRateLimits() bool {
+	return s.ConnBytesPerSecond > 0 || s.ConnFramesPerSecond > 0 || s.BandwidthLimiter != nil
+}
+
+// connLimits 为一个新连接创建限流器，每个连接有自己的令牌桶，最多积攒 1 秒的令牌
+func (s *Server) connLimits() connLimits {
+	l := connLimits{global: s.BandwidthLimiter}
+	if s.ConnBytesPerSecond > 0 {
+		l.read = ratelimit.New(float64(s.ConnBytesPerSecond), 0)
+		l.write = ratelimit.New(float64(s.ConnBytesPerSecond), 0)
+	}
+	if s.ConnFramesPerSecond > 0 {
+		l.frames = ratelimit.New(float64(s.ConnFramesPerSecond), 0)
+	}
+	return l
+}
+
+// wait 等待 n 个字节的令牌，per 是当前方向的连接级 Limiter
+// stop 关闭时返回 net.ErrClosed，done 关闭（Server 关闭）时返回 ErrServerClosed；
+// deadline 不为零值并且在等到令牌之前到达时，等到 deadline 返回 os.ErrDeadlineExceeded。
+func (l connLimits) wait(per *ratelimit.Limiter, n int, stop, done <-chan struct{}, deadline time.Time) error {
+	for _, lim := range [...]*ratelimit.Limiter{per, l.global} {
+		if lim == nil {
+			continue
+		}
+		if err := waitTokens(lim, n, stop, done, deadline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func waitTokens(lim *ratelimit.Limiter, n int, stop, done <-chan struct{}, deadline time.Time) error {
+	d := lim.Reserve(n)
+	if d <= 0 {
+		return nil
+	}
+	var err error
+	if !deadline.IsZero() {
+		if left := time.Until(deadline); left < d {
+			d, err = left, os.ErrDeadlineExceeded
+		}
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		if err == nil {
+			return nil
+		}
+	case <-stop:
+		err = net.ErrClosed
+	case <-done:
+		err = ErrServerClosed
+	}
+	lim.Cancel(n)
+	return err
+}
+
+// frameLimiterOf 返回 conn 接收消息的限流器，不限制时返回 nil
+func frameLimiterOf(conn net.Conn) *ratelimit.Limiter {
+	if sc, ok := conn.(*serverConn); ok {
+		return sc.limits.frames
+	}
+	return nil
+}
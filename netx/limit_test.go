@@ -0,0 +1,130 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"gopractice/netx/ratelimit"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestServerFrameRateLimit(t *testing.T) {
+	results := make(chan error, 5)
+	s := &Server{
+		ConnFramesPerSecond: 2,
+		Handler: func(ctx context.Context, conn net.Conn) {
+			c := NewClient(conn)
+			for i := 0; i < 5; i++ {
+				_, err := c.Recv()
+				results <- err
+			}
+		},
+	}
+	c, err := Dial(startTCPServer(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for i := 0; i < 5; i++ {
+		c.Send([]byte("flood"))
+	}
+
+	for i := 0; i < 5; i++ {
+		want := error(nil)
+		if i >= 2 {
+			want = ErrFrameRateExceeded
+		}
+		if err := <-results; err != want {
+			t.Errorf("frame %d: Recv() = %v, want %v", i, err, want)
+		}
+	}
+}
+
+func TestServerBandwidthLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		srv  func(s *Server)
+	}{
+		{"per conn", func(s *Server) { s.ConnBytesPerSecond = 10000 }},
+		{"global", func(s *Server) { s.BandwidthLimiter = ratelimit.New(10000, 0) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan time.Duration, 1)
+			s := &Server{
+				Handler: func(ctx context.Context, conn net.Conn) {
+					start := time.Now()
+					if _, err := NewClient(conn).Recv(); err != nil {
+						t.Error(err)
+					}
+					done <- time.Since(start)
+				},
+			}
+			tt.srv(s)
+			c, err := Dial(startTCPServer(t, s))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			// 初始的 10000 个令牌用完之后，剩下的 5000 字节需要等待 0.5 秒
+			c.Send(make([]byte, 15000))
+			if d := <-done; d < 400*time.Millisecond {
+				t.Errorf("received 15000 bytes in %v, want at least 400ms", d)
+			}
+		})
+	}
+}
+
+func TestServerRateLimitInterrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  func(conn net.Conn)
+		after   func(s *Server, conn net.Conn)
+		wantErr error
+	}{
+		{"deadline", func(conn net.Conn) { conn.SetWriteDeadline(time.Now().Add(100 * time.Millisecond)) }, nil, os.ErrDeadlineExceeded},
+		{"close", nil, func(s *Server, conn net.Conn) { conn.Close() }, net.ErrClosed},
+		{"server close", nil, func(s *Server, conn net.Conn) { s.Close() }, ErrServerClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conns := make(chan net.Conn, 1)
+			errc := make(chan error, 1)
+			s := &Server{
+				ConnBytesPerSecond: 1000,
+				Handler: func(ctx context.Context, conn net.Conn) {
+					if tt.before != nil {
+						tt.before(conn)
+					}
+					conns <- conn
+					conn.Write(make([]byte, 1000))
+					// 令牌用完，这次写入需要等待 100 秒
+					_, err := conn.Write(make([]byte, 100000))
+					errc <- err
+				},
+			}
+			c, err := Dial(startTCPServer(t, s))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+
+			conn := <-conns
+			if tt.after != nil {
+				time.Sleep(50 * time.Millisecond)
+				tt.after(s, conn)
+			}
+			select {
+			case err := <-errc:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Write() = %v, want %v", err, tt.wantErr)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("rate-limited Write was not interrupted")
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+)
+
+// NewDebugServer 返回一个用于查看指标的 http.Server，与业务端口分开监听：
+// /metrics 输出 p 中的指标（p 为 nil 时不注册），/debug/vars 输出 expvar。
+// 调用者负责 ListenAndServe 和 Shutdown。
+func NewDebugServer(addr string, p *Prometheus) *http.Server {
+	mux := http.NewServeMux()
+	if p != nil {
+		mux.Handle("/metrics", p)
+	}
+	mux.Handle("/debug/vars", expvar.Handler())
+	return &http.Server{Addr: addr, Handler: mux}
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"expvar"
+	"time"
+)
+
+// Expvar 把指标发布到 expvar 的 Collector，通过 expvar.Handler()（默认是 /debug/vars）查看
+// 耗时记录为 name_count 和 name_sum_seconds 两个值。
+type Expvar struct {
+	m *expvar.Map
+}
+
+// NewExpvar 把指标发布到名为 name 的 expvar.Map 中，name 已经被使用时复用已有的 Map
+func NewExpvar(name string) *Expvar {
+	if m, ok := expvar.Get(name).(*expvar.Map); ok {
+		return &Expvar{m: m}
+	}
+	return &Expvar{m: expvar.NewMap(name)}
+}
+
+func (e *Expvar) IncCounter(name string) {
+	e.m.Add(name, 1)
+}
+
+func (e *Expvar) AddCounter(name string, delta int64) {
+	e.m.Add(name, delta)
+}
+
+func (e *Expvar) SetGauge(name string, v float64) {
+	f := new(expvar.Float)
+	f.Set(v)
+	e.m.Set(name, f)
+}
+
+func (e *Expvar) ObserveLatency(name string, d time.Duration) {
+	e.m.Add(name+"_count", 1)
+	e.m.AddFloat(name+"_sum_seconds", d.Seconds())
+}
+
+// Map 返回保存指标的 expvar.Map
+func (e *Expvar) Map() *expvar.Map {
+	return e.m
+}
@@ -0,0 +1,129 @@
+// Package metrics 服务端指标的收集接口
+// netx 的 TCP 和 UDP 服务端通过 Collector 上报连接数、字节数、消息数和处理耗时，
+// 使用者可以实现 Collector 把指标接入其他监控系统，内置了 expvar 和 Prometheus 文本格式的实现。
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// netx 上报的指标名称
+const (
+	ConnsAccepted  = "netx_conns_accepted_total"
+	ConnsRejected  = "netx_conns_rejected_total"
+	ConnsActive    = "netx_conns_active"
+	PacketsRead    = "netx_packets_read_total"
+	BytesRead      = "netx_bytes_read_total"
+	BytesWritten   = "netx_bytes_written_total"
+	FramesRead     = "netx_frames_read_total"
+	FramesWritten  = "netx_frames_written_total"
+	DecodeErrors   = "netx_decode_errors_total"
+	HandlerLatency = "netx_handler_latency"
+)
+
+// Collector 指标收集接口，实现需要是并发安全的
+type Collector interface {
+	// IncCounter 计数器加一
+	IncCounter(name string)
+	// AddCounter 计数器加 delta
+	AddCounter(name string, delta int64)
+	// SetGauge 设置瞬时值
+	SetGauge(name string, v float64)
+	// ObserveLatency 记录一次耗时
+	ObserveLatency(name string, d time.Duration)
+}
+
+// Nop 丢弃所有指标，是 netx 的默认 Collector
+var Nop Collector = nop{}
+
+type nop struct{}
+
+func (nop) IncCounter(name string)                      {}
+func (nop) AddCounter(name string, delta int64)         {}
+func (nop) SetGauge(name string, v float64)             {}
+func (nop) ObserveLatency(name string, d time.Duration) {}
+
+// Memory 把指标保存在内存中的 Collector
+type Memory struct {
+	mu        sync.Mutex
+	counters  map[string]int64
+	gauges    map[string]float64
+	latencies map[string][]time.Duration
+}
+
+// NewMemory 创建一个空的 Memory
+func NewMemory() *Memory {
+	return &Memory{
+		counters:  make(map[string]int64),
+		gauges:    make(map[string]float64),
+		latencies: make(map[string][]time.Duration),
+	}
+}
+
+func (m *Memory) IncCounter(name string) {
+	m.AddCounter(name, 1)
+}
+
+func (m *Memory) AddCounter(name string, delta int64) {
+	m.mu.Lock()
+	m.counters[name] += delta
+	m.mu.Unlock()
+}
+
+func (m *Memory) SetGauge(name string, v float64) {
+	m.mu.Lock()
+	m.gauges[name] = v
+	m.mu.Unlock()
+}
+
+func (m *Memory) ObserveLatency(name string, d time.Duration) {
+	m.mu.Lock()
+	m.latencies[name] = append(m.latencies[name], d)
+	m.mu.Unlock()
+}
+
+// Counter 返回计数器的当前值
+func (m *Memory) Counter(name string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name]
+}
+
+// Gauge 返回最近一次设置的瞬时值
+func (m *Memory) Gauge(name string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.gauges[name]
+}
+
+// Latencies 返回记录的所有耗时
+func (m *Memory) Latencies(name string) []time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]time.Duration(nil), m.latencies[name]...)
+}
+
+// Names 返回所有出现过的指标名称，按字典序排列
+func (m *Memory) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]struct{})
+	for name := range m.counters {
+		seen[name] = struct{}{}
+	}
+	for name := range m.gauges {
+		seen[name] = struct{}{}
+	}
+	for name := range m.latencies {
+		seen[name] = struct{}{}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	m.IncCounter("c")
+	m.AddCounter("c", 2)
+	m.SetGauge("g", 1)
+	m.SetGauge("g", 3)
+	m.ObserveLatency("l", time.Second)
+
+	if got := m.Counter("c"); got != 3 {
+		t.Errorf("Counter(c) = %d, want 3", got)
+	}
+	if got := m.Gauge("g"); got != 3 {
+		t.Errorf("Gauge(g) = %v, want 3", got)
+	}
+	if got := m.Latencies("l"); !reflect.DeepEqual(got, []time.Duration{time.Second}) {
+		t.Errorf("Latencies(l) = %v", got)
+	}
+	if got := m.Names(); !reflect.DeepEqual(got, []string{"c", "g", "l"}) {
+		t.Errorf("Names() = %v", got)
+	}
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Prometheus 以 Prometheus 文本格式输出指标的 Collector，本身是一个 http.Handler，
+// 不需要依赖 Prometheus 的客户端库。耗时以 summary 类型输出 name_count 和 name_sum（秒）。
+type Prometheus struct {
+	mu        sync.Mutex
+	counters  map[string]int64
+	gauges    map[string]float64
+	summaries map[string]*summary
+}
+
+type summary struct {
+	count int64
+	sum   float64
+}
+
+// NewPrometheus 创建一个空的 Prometheus
+func NewPrometheus() *Prometheus {
+	return &Prometheus{
+		counters:  make(map[string]int64),
+		gauges:    make(map[string]float64),
+		summaries: make(map[string]*summary),
+	}
+}
+
+func (p *Prometheus) IncCounter(name string) {
+	p.AddCounter(name, 1)
+}
+
+func (p *Prometheus) AddCounter(name string, delta int64) {
+	p.mu.Lock()
+	p.counters[name] += delta
+	p.mu.Unlock()
+}
+
+func (p *Prometheus) SetGauge(name string, v float64) {
+	p.mu.Lock()
+	p.gauges[name] = v
+	p.mu.Unlock()
+}
+
+func (p *Prometheus) ObserveLatency(name string, d time.Duration) {
+	p.mu.Lock()
+	s, ok := p.summaries[name]
+	if !ok {
+		s = new(summary)
+		p.summaries[name] = s
+	}
+	s.count++
+	s.sum += d.Seconds()
+	p.mu.Unlock()
+}
+
+// WriteTo 按指标名称排序，以 Prometheus 文本格式写入 w
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, name := range sortedKeys(p.counters) {
+		fmt.Fprintf(cw, "# TYPE %s counter\n%s %d\n", name, name, p.counters[name])
+	}
+	for _, name := range sortedKeys(p.gauges) {
+		fmt.Fprintf(cw, "# TYPE %s gauge\n%s %s\n", name, name, formatFloat(p.gauges[name]))
+	}
+	for _, name := range sortedKeys(p.summaries) {
+		s := p.summaries[name]
+		fmt.Fprintf(cw, "# TYPE %s summary\n%s_sum %s\n%s_count %d\n",
+			name, name, formatFloat(s.sum), name, s.count)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP 输出所有指标，可以直接作为 /metrics 的 Handler
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	p.WriteTo(w)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// countingWriter 统计写入的字节数，并记住第一个错误
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusWriteTo(t *testing.T) {
+	p := NewPrometheus()
+	p.IncCounter(ConnsAccepted)
+	p.AddCounter(BytesRead, 10)
+	p.SetGauge(ConnsActive, 2)
+	p.ObserveLatency(HandlerLatency, 500*time.Millisecond)
+	p.ObserveLatency(HandlerLatency, time.Second)
+
+	var b strings.Builder
+	n, err := p.WriteTo(&b)
+	want := `# TYPE netx_bytes_read_total counter
+netx_bytes_read_total 10
+# TYPE netx_conns_accepted_total counter
+netx_conns_accepted_total 1
+# TYPE netx_conns_active gauge
+netx_conns_active 2
+# TYPE netx_handler_latency summary
+netx_handler_latency_sum 1.5
+netx_handler_latency_count 2
+`
+	if err != nil || b.String() != want || n != int64(len(want)) {
+		t.Errorf("WriteTo() = %d, %v:\n%s\nwant:\n%s", n, err, b.String(), want)
+	}
+}
+
+// debugRuns expvar 是进程级的，go test -count=N 时每次运行使用不同的名字
+var debugRuns int
+
+func TestDebugServer(t *testing.T) {
+	debugRuns++
+	name := fmt.Sprintf("netx_test_%d", debugRuns)
+	p := NewPrometheus()
+	p.IncCounter(FramesRead)
+	e := NewExpvar(name)
+	e.AddCounter(FramesWritten, 3)
+	e.SetGauge(ConnsActive, 1.5)
+	e.ObserveLatency(HandlerLatency, 2*time.Second)
+
+	srv := httptest.NewServer(NewDebugServer("", p).Handler)
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), FramesRead+" 1\n") {
+		t.Errorf("/metrics = %q", body)
+	}
+
+	resp, err = srv.Client().Get(srv.URL + "/debug/vars")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var vars map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		t.Fatal(err)
+	}
+	var netx map[string]float64
+	if err := json.Unmarshal(vars[name], &netx); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{
+		FramesWritten:                   3,
+		ConnsActive:                     1.5,
+		HandlerLatency + "_count":       1,
+		HandlerLatency + "_sum_seconds": 2,
+	}
+	for k, v := range want {
+		if netx[k] != v {
+			t.Errorf("/debug/vars %s = %v, want %v", k, netx[k], v)
+		}
+	}
+
+	// 同名的 Expvar 复用已有的 Map，不会 panic
+	if NewExpvar(name).Map() != e.Map() {
+		t.Error("NewExpvar with the same name returned a different Map")
+	}
+}
@@ -0,0 +1,89 @@
+package netx
+
+import (
+	"context"
+	"gopractice/logx"
+	"gopractice/netx/ratelimit"
+	"runtime/debug"
+	"time"
+)
+
+// Middleware 在 Handler 外面包一层，用来实现日志、鉴权、限流等通用逻辑
+type Middleware func(Handler) Handler
+
+// Chain 按顺序把 mws 应用到 h 上，mws[0] 在最外层，最先执行
+//
+//	NewTCPServer(addr, Chain(h, Recover(l), Logging(l), Auth(check)))
+func Chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// FrameMiddleware 在 FrameHandler 外面包一层，对每个消息生效
+type FrameMiddleware func(FrameHandler) FrameHandler
+
+// ChainFrames 与 Chain 相同，作用于 FrameHandler
+func ChainFrames(h FrameHandler, mws ...FrameMiddleware) FrameHandler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Recover 从 Handler 的 panic 中恢复并记录到 l
+// Server 本身也会恢复 panic，放在 Chain 中可以让外层的中间件（例如 Logging）正常结束。
+func Recover(l logx.Logger) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, conn *Conn) {
+			defer func() {
+				if v := recover(); v != nil {
+					l.Errorf("netx: panic serving %v: %v\n%s", conn.RemoteAddr(), v, debug.Stack())
+				}
+			}()
+			next.ServeConn(ctx, conn)
+		})
+	}
+}
+
+// Logging 记录连接的建立和断开，以及连接持续的时间
+func Logging(l logx.Logger) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, conn *Conn) {
+			start := time.Now()
+			l.Infof("连接建立 %v", conn.RemoteAddr())
+			defer func() {
+				l.Infof("连接断开 %v，持续 %v", conn.RemoteAddr(), time.Since(start))
+			}()
+			next.ServeConn(ctx, conn)
+		})
+	}
+}
+
+// RateLimit 限制新连接的速度，令牌不够时直接关闭新连接
+// 限制每个连接读写速度见 Server.ConnBytesPerSecond。
+func RateLimit(limiter *ratelimit.Limiter) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, conn *Conn) {
+			if !limiter.Allow(1) {
+				return
+			}
+			next.ServeConn(ctx, conn)
+		})
+	}
+}
+
+// Auth 在处理连接之前调用 authenticate 鉴权，例如读取第一个消息中的 token。
+// authenticate 返回错误时关闭连接；否则使用它返回的 ctx 继续处理，可以在其中保存用户信息。
+func Auth(authenticate func(ctx context.Context, conn *Conn) (context.Context, error)) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, conn *Conn) {
+			ctx, err := authenticate(ctx, conn)
+			if err != nil {
+				return
+			}
+			next.ServeConn(ctx, conn)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"gopractice/logx"
+	"gopractice/netx/ratelimit"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, conn *Conn) {
+				calls = append(calls, name+" before")
+				next.ServeConn(ctx, conn)
+				calls = append(calls, name+" after")
+			})
+		}
+	}
+	h := Chain(HandlerFunc(func(ctx context.Context, conn *Conn) {
+		calls = append(calls, "handler")
+	}), mw("a"), mw("b"))
+	h.ServeConn(context.Background(), nil)
+
+	want := []string{"a before", "b before", "handler", "b after", "a after"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+type userKey struct{}
+
+func TestMiddlewares(t *testing.T) {
+	rec := new(logx.Recorder)
+	auth := Auth(func(ctx context.Context, conn *Conn) (context.Context, error) {
+		token, err := conn.Recv(ctx)
+		if err != nil || !strings.HasPrefix(string(token), "token:") {
+			return nil, errors.New("unauthorized")
+		}
+		return context.WithValue(ctx, userKey{}, strings.TrimPrefix(string(token), "token:")), nil
+	})
+	h := HandlerFunc(func(ctx context.Context, conn *Conn) {
+		for {
+			msg, err := conn.Recv(ctx)
+			if err != nil {
+				return
+			}
+			if string(msg) == "panic" {
+				panic("boom")
+			}
+			conn.Send([]byte(ctx.Value(userKey{}).(string) + ":" + string(msg)))
+		}
+	})
+	s := NewTCPServer("", Chain(h,
+		Logging(rec), Recover(rec), RateLimit(ratelimit.New(0.001, 2)), auth))
+	addr := startTCPServer(t, s)
+
+	// 鉴权通过后可以从 ctx 中取出用户
+	c, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Send([]byte("token:kwok"))
+	if got, err := echo(c, "hi"); err != nil || got != "kwok:hi" {
+		t.Errorf("echo = %q, %v", got, err)
+	}
+
+	// 鉴权失败时关闭连接
+	c2, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	c2.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := echo(c2, "no token"); err == nil {
+		t.Error("unauthorized connection was served")
+	}
+
+	// 前两个连接用完了令牌，第三个连接被直接关闭
+	c3, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c3.Close()
+	c3.Send([]byte("token:kwok"))
+	c3.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := echo(c3, "hi"); err == nil {
+		t.Error("rate limited connection was served")
+	}
+
+	// Recover 在 Logging 里面，panic 之后 Logging 仍然记录连接断开
+	c.Send([]byte("panic"))
+	c.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	c.Recv()
+	s.Shutdown(context.Background())
+	var panics, closed int
+	for _, e := range rec.Entries() {
+		if strings.Contains(e.Msg, "boom") {
+			panics++
+		}
+		if strings.Contains(e.Msg, "连接断开") {
+			closed++
+		}
+	}
+	if panics != 1 || closed != 3 {
+		t.Errorf("logged %d panics and %d closed connections, want 1 and 3: %v", panics, closed, rec.Entries())
+	}
+}
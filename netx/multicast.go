@@ -0,0 +1,110 @@
+package netx
+
+import (
+	"errors"
+	"net"
+)
+
+var (
+	// ErrNotMulticast 地址不是组播地址，IPv4 组播地址的范围是 224.0.0.0 到 239.255.255.255
+	ErrNotMulticast = errors.New("netx: not a multicast address")
+
+	errMulticastUnsupported = errors.New("netx: multicast options not supported on this platform")
+)
+
+// MulticastOption 组播的可选配置
+type MulticastOption func(o *multicastOptions)
+
+type multicastOptions struct {
+	ifi      *net.Interface
+	ttl      int
+	loopback *bool
+}
+
+// WithMulticastInterface 接收时在 ifi 上加入组播组，发送时从 ifi 发出，默认由系统选择
+func WithMulticastInterface(ifi *net.Interface) MulticastOption {
+	return func(o *multicastOptions) {
+		o.ifi = ifi
+	}
+}
+
+// WithMulticastTTL 设置发送的组播数据报的 TTL（IPv6 为跳数限制），只对 DialMulticast 有效。
+// 系统默认为 1，只在本地网段内传播，跨路由器需要调大。
+func WithMulticastTTL(ttl int) MulticastOption {
+	return func(o *multicastOptions) {
+		o.ttl = ttl
+	}
+}
+
+// WithMulticastLoopback 设置发送的组播数据报是否回送给本机的接收者，只对 DialMulticast 有效，系统默认开启
+func WithMulticastLoopback(on bool) MulticastOption {
+	return func(o *multicastOptions) {
+		o.loopback = &on
+	}
+}
+
+// ListenMulticast 监听组播地址 group（例如 "239.0.0.1:9999"）并加入组播组。
+// 同一台机器上的多个进程可以监听同一个组播地址，每个进程都能收到组播数据报；
+// 返回的连接可以交给 UDPServer.Serve，用 Handler 处理收到的数据报。
+func ListenMulticast(group string, opts ...MulticastOption) (*net.UDPConn, error) {
+	gaddr, network, err := resolveMulticast(group)
+	if err != nil {
+		return nil, err
+	}
+	var o multicastOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return net.ListenMulticastUDP(network, o.ifi, gaddr)
+}
+
+// DialMulticast 创建向组播地址 group 发送数据报的连接，用 Write 发送
+func DialMulticast(group string, opts ...MulticastOption) (*net.UDPConn, error) {
+	gaddr, network, err := resolveMulticast(group)
+	if err != nil {
+		return nil, err
+	}
+	var o multicastOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	conn, err := net.DialUDP(network, nil, gaddr)
+	if err != nil {
+		return nil, err
+	}
+	if err := setMulticastOptions(conn, network == "udp6", &o); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func resolveMulticast(group string) (*net.UDPAddr, string, error) {
+	gaddr, err := net.ResolveUDPAddr("udp", group)
+	if err != nil {
+		return nil, "", err
+	}
+	if !gaddr.IP.IsMulticast() {
+		return nil, "", ErrNotMulticast
+	}
+	if gaddr.IP.To4() != nil {
+		return gaddr, "udp4", nil
+	}
+	return gaddr, "udp6", nil
+}
+
+// interfaceIPv4 返回网卡的第一个 IPv4 地址，IPv4 的 IP_MULTICAST_IF 选项需要用地址指定网卡
+func interfaceIPv4(ifi *net.Interface) ([4]byte, error) {
+	var ip4 [4]byte
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return ip4, err
+	}
+	for _, a := range addrs {
+		if ipn, ok := a.(*net.IPNet); ok && ipn.IP.To4() != nil {
+			copy(ip4[:], ipn.IP.To4())
+			return ip4, nil
+		}
+	}
+	return ip4, errors.New("netx: no IPv4 address on interface " + ifi.Name)
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package netx
+
+import "net"
+
+// setMulticastOptions 当前平台不支持设置组播的套接字选项
+func setMulticastOptions(conn *net.UDPConn, v6 bool, o *multicastOptions) error {
+	if o.ifi == nil && o.ttl == 0 && o.loopback == nil {
+		return nil
+	}
+	return errMulticastUnsupported
+}
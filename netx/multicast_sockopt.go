@@ -0,0 +1,75 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || windows
+
+package netx
+
+import (
+	"net"
+	"syscall"
+)
+
+// setMulticastOptions 设置发送组播数据报的网卡、TTL 和回送，没有设置的选项保持系统默认值
+func setMulticastOptions(conn *net.UDPConn, v6 bool, o *multicastOptions) error {
+	if o.ifi == nil && o.ttl == 0 && o.loopback == nil {
+		return nil
+	}
+	var ip4 [4]byte
+	if o.ifi != nil && !v6 {
+		var err error
+		if ip4, err = interfaceIPv4(o.ifi); err != nil {
+			return err
+		}
+	}
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	err = rc.Control(func(fd uintptr) {
+		serr = setMulticastSockopts(fd, v6, o, ip4)
+	})
+	if err != nil {
+		return err
+	}
+	return serr
+}
+
+func setMulticastSockopts(fd uintptr, v6 bool, o *multicastOptions, ip4 [4]byte) error {
+	if v6 {
+		if o.ifi != nil {
+			if err := setsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_IF, o.ifi.Index); err != nil {
+				return err
+			}
+		}
+		if o.ttl > 0 {
+			if err := setsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_HOPS, o.ttl); err != nil {
+				return err
+			}
+		}
+		if o.loopback != nil {
+			return setsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_MULTICAST_LOOP, boolint(*o.loopback))
+		}
+		return nil
+	}
+
+	if o.ifi != nil {
+		if err := setsockoptInet4Addr(fd, syscall.IPPROTO_IP, syscall.IP_MULTICAST_IF, ip4); err != nil {
+			return err
+		}
+	}
+	if o.ttl > 0 {
+		if err := setsockoptIPv4Byte(fd, syscall.IP_MULTICAST_TTL, o.ttl); err != nil {
+			return err
+		}
+	}
+	if o.loopback != nil {
+		return setsockoptIPv4Byte(fd, syscall.IP_MULTICAST_LOOP, boolint(*o.loopback))
+	}
+	return nil
+}
+
+func boolint(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,80 @@
+package netx
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// multicastInterface 返回一个支持组播并且有 IPv4 地址的网卡，没有时跳过测试
+func multicastInterface(t *testing.T) *net.Interface {
+	t.Helper()
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+	for i := range ifis {
+		ifi := &ifis[i]
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		if _, err := interfaceIPv4(ifi); err == nil {
+			return ifi
+		}
+	}
+	t.Skip("no multicast interface")
+	return nil
+}
+
+func TestMulticast(t *testing.T) {
+	ifi := multicastInterface(t)
+	const group = "239.255.77.77:29999"
+	pc, err := ListenMulticast(group, WithMulticastInterface(ifi))
+	if err != nil {
+		t.Skipf("join multicast group: %v", err)
+	}
+
+	got := make(chan string, 1)
+	s := &UDPServer{
+		Handler: func(ctx context.Context, pc net.PacketConn, addr net.Addr, data []byte) {
+			select {
+			case got <- string(data):
+			default:
+			}
+		},
+	}
+	go s.Serve(pc)
+	defer s.Shutdown(context.Background())
+
+	conn, err := DialMulticast(group, WithMulticastInterface(ifi), WithMulticastTTL(1), WithMulticastLoopback(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 组播是不可靠的，重复发送直到收到
+	timeout := time.After(2 * time.Second)
+	for {
+		conn.Write([]byte("announce"))
+		select {
+		case msg := <-got:
+			if msg != "announce" {
+				t.Errorf("received %q, want %q", msg, "announce")
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-timeout:
+			t.Skipf("no multicast datagram received on %s, multicast routing may be unavailable", ifi.Name)
+		}
+	}
+}
+
+func TestMulticastNotMulticast(t *testing.T) {
+	if _, err := ListenMulticast("127.0.0.1:29999"); err != ErrNotMulticast {
+		t.Errorf("ListenMulticast() = %v, want %v", err, ErrNotMulticast)
+	}
+	if _, err := DialMulticast("10.0.0.1:29999"); err != ErrNotMulticast {
+		t.Errorf("DialMulticast() = %v, want %v", err, ErrNotMulticast)
+	}
+}
@@ -0,0 +1,246 @@
+package netxtest
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrInjectedReset FaultyConn 按照 FaultPolicy 主动重置了连接，之后的 Read 和 Write 都返回这个错误
+var ErrInjectedReset = errors.New("netxtest: injected connection reset")
+
+// FaultPolicy FaultyConn 注入故障的规则，零值不注入任何故障
+// 随机数由 Seed 决定，相同的 Seed 和相同的读写顺序产生相同的故障，失败的测试可以重现。
+type FaultPolicy struct {
+	// Seed 随机数种子
+	Seed int64
+
+	// Latency 每次 Read 和 Write 之前的延迟，再加上 [0, Jitter) 之间的随机延迟
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// MaxReadSize 大于 0 时每次 Read 最多返回 [1, MaxReadSize] 之间随机个字节，模拟数据被拆成多个 TCP 段到达
+	MaxReadSize int
+
+	// MaxWriteSize 大于 0 时一次 Write 被拆成多次写入底层连接，每次 [1, MaxWriteSize] 之间随机个字节，
+	// 每次之间有 Latency 的延迟，使对方更容易一次只读到一部分
+	MaxWriteSize int
+
+	// PartialWriteRate 一次 Write 只写入一部分并返回 io.ErrShortWrite 的概率
+	PartialWriteRate float64
+
+	// DropRate 写入的每个字节被丢弃的概率，被丢弃的字节仍然计入 Write 的返回值，对方收到的数据因此错乱
+	DropRate float64
+
+	// ResetRate 每次 Read 和 Write 时重置连接的概率
+	ResetRate float64
+
+	// ResetAfter 大于 0 时，读写的字节数合计达到 ResetAfter 后重置连接
+	ResetAfter int
+}
+
+// FaultStats FaultyConn 注入的故障次数
+type FaultStats struct {
+	ReadSplits    int // Read 返回的数据少于底层可以返回的次数
+	WriteSplits   int // Write 被拆成多次写入的次数
+	PartialWrites int // 返回 io.ErrShortWrite 的次数
+	DroppedBytes  int // 丢弃的字节数
+	Reset         bool
+}
+
+// FaultyConn 按照 FaultPolicy 注入延迟、拆分读写、部分写入、丢弃字节和重置连接的 net.Conn，
+// 用来测试 Handler 和 FrameCodec 在糟糕的网络条件下的行为。可以在多个 goroutine 中同时读写。
+type FaultyConn struct {
+	net.Conn
+	policy FaultPolicy
+
+	mu    sync.Mutex
+	rng   *rand.Rand
+	bytes int
+	stats FaultStats
+
+	rmu     sync.Mutex
+	pending []byte // 拆分 Read 时底层已经读出、还没有返回的数据，由 rmu 保护
+	wmu     sync.Mutex
+}
+
+// NewFaultyConn 用 policy 包装 conn
+func NewFaultyConn(conn net.Conn, policy FaultPolicy) *FaultyConn {
+	return &FaultyConn{Conn: conn, policy: policy, rng: rand.New(rand.NewSource(policy.Seed))}
+}
+
+// Stats 返回到目前为止注入的故障
+func (c *FaultyConn) Stats() FaultStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+func (c *FaultyConn) Read(b []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	if err := c.before(); err != nil {
+		return 0, err
+	}
+	max := c.policy.MaxReadSize
+	if max <= 0 || len(b) == 0 {
+		n, err := c.Conn.Read(b)
+		c.count(n)
+		return n, err
+	}
+
+	if len(c.pending) == 0 {
+		// 先按 b 的大小从底层读出来，再拆成多次返回；底层的错误在下一次 Read 时会再次出现
+		buf := make([]byte, len(b))
+		n, err := c.Conn.Read(buf)
+		if n == 0 {
+			return 0, err
+		}
+		c.pending = buf[:n]
+	}
+	n := len(c.pending)
+	c.mu.Lock()
+	if k := 1 + c.rng.Intn(max); k < n {
+		n = k
+		c.stats.ReadSplits++
+	}
+	c.bytes += n
+	c.mu.Unlock()
+	copy(b, c.pending[:n])
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *FaultyConn) Write(b []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if err := c.before(); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	want := len(b)
+	var err error
+	if c.policy.PartialWriteRate > 0 && len(b) > 1 && c.rng.Float64() < c.policy.PartialWriteRate {
+		want = 1 + c.rng.Intn(len(b)-1)
+		err = io.ErrShortWrite
+		c.stats.PartialWrites++
+	}
+	var chunks []int
+	for rest := want; rest > 0; {
+		n := rest
+		if max := c.policy.MaxWriteSize; max > 0 {
+			if k := 1 + c.rng.Intn(max); k < n {
+				n = k
+			}
+		}
+		chunks = append(chunks, n)
+		rest -= n
+	}
+	if len(chunks) > 1 {
+		c.stats.WriteSplits++
+	}
+	c.mu.Unlock()
+
+	written := 0
+	for i, n := range chunks {
+		if i > 0 {
+			c.sleep()
+		}
+		chunk := c.drop(b[written : written+n])
+		if _, werr := c.Conn.Write(chunk); werr != nil {
+			return written, werr
+		}
+		written += n
+		c.count(n)
+	}
+	return written, err
+}
+
+// drop 按照 DropRate 丢弃 b 中的字节，返回剩下的字节
+func (c *FaultyConn) drop(b []byte) []byte {
+	if c.policy.DropRate <= 0 {
+		return b
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	kept := make([]byte, 0, len(b))
+	for _, x := range b {
+		if c.rng.Float64() < c.policy.DropRate {
+			c.stats.DroppedBytes++
+			continue
+		}
+		kept = append(kept, x)
+	}
+	return kept
+}
+
+// before 每次读写之前延迟，并判断是否重置连接
+func (c *FaultyConn) before() error {
+	c.sleep()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stats.Reset {
+		return ErrInjectedReset
+	}
+	p := c.policy
+	if (p.ResetAfter > 0 && c.bytes >= p.ResetAfter) || (p.ResetRate > 0 && c.rng.Float64() < p.ResetRate) {
+		c.stats.Reset = true
+		// SO_LINGER 为 0 时关闭连接会发送 RST，对方读写时得到 connection reset by peer
+		if tc, ok := c.Conn.(*net.TCPConn); ok {
+			tc.SetLinger(0)
+		}
+		c.Conn.Close()
+		return ErrInjectedReset
+	}
+	return nil
+}
+
+func (c *FaultyConn) count(n int) {
+	c.mu.Lock()
+	c.bytes += n
+	c.mu.Unlock()
+}
+
+func (c *FaultyConn) sleep() {
+	d := c.policy.Latency
+	if c.policy.Jitter > 0 {
+		c.mu.Lock()
+		d += time.Duration(c.rng.Int63n(int64(c.policy.Jitter)))
+		c.mu.Unlock()
+	}
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// FaultyListener Accept 到的每个连接都用 FaultyConn 包装，第 i 个连接的种子为 Seed+i
+type FaultyListener struct {
+	net.Listener
+	policy FaultPolicy
+
+	mu sync.Mutex
+	n  int64
+}
+
+// NewFaultyListener 用 policy 包装 l，可以直接交给 netx.Server.Serve
+func NewFaultyListener(l net.Listener, policy FaultPolicy) *FaultyListener {
+	return &FaultyListener{Listener: l, policy: policy}
+}
+
+// Accept 等待下一个连接并用 FaultyConn 包装
+func (l *FaultyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	p := l.policy
+	p.Seed += l.n
+	l.n++
+	l.mu.Unlock()
+	return NewFaultyConn(conn, p), nil
+}
@@ -0,0 +1,165 @@
+package netxtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopractice/netx"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// tcpPair 返回一对已经连接的 tcp 连接
+func tcpPair(t *testing.T) (client, server net.Conn) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	client, err = net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err = l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+// 读写都被拆成很小的片段时，Framer 仍然能还原出每个消息
+func TestFaultyConnSplits(t *testing.T) {
+	policy := FaultPolicy{Seed: 1, MaxReadSize: 3, MaxWriteSize: 5}
+	s := Start(&netx.Server{Handler: echo})
+	defer s.Close()
+	conn, err := net.Dial("tcp", s.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := NewFaultyConn(conn, policy)
+	c := netx.NewClient(fc)
+	defer c.Close()
+
+	for i := 0; i < 20; i++ {
+		want := bytes.Repeat([]byte(fmt.Sprint(i)), 10*i)
+		if err := c.Send(want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.Recv()
+		if err != nil || !bytes.Equal(got, want) {
+			t.Fatalf("message #%d = %q, %v, want %q", i, got, err, want)
+		}
+	}
+	if st := fc.Stats(); st.ReadSplits == 0 || st.WriteSplits == 0 {
+		t.Errorf("Stats() = %+v, want reads and writes to be split", st)
+	}
+}
+
+// 相同的种子产生相同的故障
+func TestFaultyConnSeed(t *testing.T) {
+	chunks := func(seed int64) []int {
+		client, server := tcpPair(t)
+		fc := NewFaultyConn(server, FaultPolicy{Seed: seed, MaxReadSize: 8})
+		client.Write(make([]byte, 100))
+		client.Close()
+		var sizes []int
+		buf := make([]byte, 100)
+		for {
+			n, err := fc.Read(buf)
+			if err != nil {
+				return sizes
+			}
+			sizes = append(sizes, n)
+		}
+	}
+	a, b := chunks(7), chunks(7)
+	if fmt.Sprint(a) != fmt.Sprint(b) {
+		t.Errorf("read sizes with the same seed differ: %v and %v", a, b)
+	}
+}
+
+func TestFaultyConnPartialWriteAndDrop(t *testing.T) {
+	client, server := tcpPair(t)
+	fc := NewFaultyConn(client, FaultPolicy{Seed: 1, PartialWriteRate: 1})
+	short, err := fc.Write([]byte("hello"))
+	if err != io.ErrShortWrite || short == 0 || short >= 5 {
+		t.Errorf("Write() = %d, %v, want a short write", short, err)
+	}
+
+	fc = NewFaultyConn(client, FaultPolicy{Seed: 1, DropRate: 0.5})
+	msg := bytes.Repeat([]byte("x"), 1000)
+	if n, err := fc.Write(msg); n != len(msg) || err != nil {
+		t.Fatalf("Write() = %d, %v, want %d, nil", n, err, len(msg))
+	}
+	client.Close()
+	got, _ := io.ReadAll(server)
+	dropped := fc.Stats().DroppedBytes
+	if dropped == 0 || len(got) != short+len(msg)-dropped {
+		t.Errorf("received %d bytes, want %d written minus %d dropped", len(got), short+len(msg), dropped)
+	}
+}
+
+func TestFaultyConnReset(t *testing.T) {
+	client, server := tcpPair(t)
+	fc := NewFaultyConn(client, FaultPolicy{ResetAfter: 10})
+	if _, err := fc.Write(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fc.Write([]byte("x")); err != ErrInjectedReset {
+		t.Errorf("Write() after ResetAfter = %v, want %v", err, ErrInjectedReset)
+	}
+	if _, err := fc.Read(make([]byte, 1)); err != ErrInjectedReset {
+		t.Errorf("Read() after reset = %v, want %v", err, ErrInjectedReset)
+	}
+	if !fc.Stats().Reset {
+		t.Error("Stats().Reset = false")
+	}
+
+	// 对方先读到已经发送的数据，然后得到连接被重置的错误
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err := io.ReadAll(server)
+	var ne net.Error
+	if err == nil || errors.As(err, &ne) && ne.Timeout() {
+		t.Errorf("peer ReadAll() = %v, want connection reset", err)
+	}
+}
+
+// 服务端的连接被注入延迟，客户端仍然能正常收发
+func TestFaultyListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &netx.Server{Handler: echo}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(NewFaultyListener(l, FaultPolicy{Latency: time.Millisecond, Jitter: time.Millisecond, MaxReadSize: 2}))
+	}()
+	defer func() {
+		srv.Close()
+		<-done
+	}()
+
+	c, err := netx.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	start := time.Now()
+	if err := c.Send([]byte("slow")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.Recv(); err != nil || string(got) != "slow" {
+		t.Fatalf("Recv() = %q, %v", got, err)
+	}
+	if d := time.Since(start); d < 2*time.Millisecond {
+		t.Errorf("round trip took %v, want latency to be injected", d)
+	}
+}
@@ -0,0 +1,130 @@
+package netxtest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"gopractice/netx"
+	"io"
+	"net"
+	"time"
+)
+
+// Segmentation 控制封包后的消息如何被合并和拆分后写入连接，用来复现 TCP 的粘包和半包
+// 接收方一次 Read 读到的数据与发送方的 Write 没有对应关系，这里通过控制每次 Write 的内容
+// 和 Write 之间的间隔，让接收方尽量按指定的边界收到数据。
+type Segmentation struct {
+	// Name 在错误信息中标识这种拆分方式
+	Name string
+
+	// Coalesce 合并成一组写入的消息数，0 表示所有消息合并成一组，1 表示每个消息单独写入
+	Coalesce int
+
+	// Split 每组数据按这些大小依次切成多次 Write，用完之后循环使用，为空时每组一次写入
+	Split []int
+
+	// Delay 每次 Write 之间的间隔，大于 0 时接收方更可能在两次 Write 之间读一次
+	Delay time.Duration
+}
+
+// StickySegmentations 常见的粘包和半包场景：每个消息单独写入、所有消息合并写入、
+// 每次只写一个字节、长度头和消息体分开写入，以及不规则的拆分
+func StickySegmentations() []Segmentation {
+	return []Segmentation{
+		{Name: "one per write", Coalesce: 1, Delay: time.Millisecond},
+		{Name: "all in one write"},
+		{Name: "three per write", Coalesce: 3},
+		{Name: "byte by byte", Split: []int{1}},
+		{Name: "split header", Coalesce: 1, Split: []int{2, 1 << 20}, Delay: time.Millisecond},
+		{Name: "irregular", Coalesce: 4, Split: []int{3, 7, 1, 13, 5}, Delay: 100 * time.Microsecond},
+	}
+}
+
+// WriteSegmented 用 fc 把 msgs 逐个封包，按 seg 合并和拆分后写入 w
+func WriteSegmented(w io.Writer, fc netx.FrameCodec, msgs [][]byte, seg Segmentation) error {
+	group := seg.Coalesce
+	if group <= 0 {
+		group = len(msgs)
+	}
+	split := 0 // 下一次使用 seg.Split 中的第几个大小
+	first := true
+	for len(msgs) > 0 {
+		n := group
+		if n > len(msgs) {
+			n = len(msgs)
+		}
+		var buf bytes.Buffer
+		for _, msg := range msgs[:n] {
+			if err := fc.WriteFrame(&buf, msg); err != nil {
+				return err
+			}
+		}
+		msgs = msgs[n:]
+
+		for data := buf.Bytes(); len(data) > 0; {
+			size := len(data)
+			if len(seg.Split) > 0 {
+				if s := seg.Split[split%len(seg.Split)]; s > 0 && s < size {
+					size = s
+				}
+				split++
+			}
+			if !first && seg.Delay > 0 {
+				time.Sleep(seg.Delay)
+			}
+			first = false
+			if _, err := w.Write(data[:size]); err != nil {
+				return err
+			}
+			data = data[size:]
+		}
+	}
+	return nil
+}
+
+// VerifyCodec 在本地回环的 tcp 连接上按 seg 发送 msgs，接收方用同一个 fc 拆包，
+// 收到的消息与 msgs 不一致时返回描述第一个差异的错误
+func VerifyCodec(fc netx.FrameCodec, msgs [][]byte, seg Segmentation) error {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	werr := make(chan error, 1)
+	go func() {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			werr <- err
+			return
+		}
+		defer conn.Close()
+		// 关闭 Nagle 算法，小的 Write 不会在内核中被合并
+		conn.(*net.TCPConn).SetNoDelay(true)
+		werr <- WriteSegmented(conn, fc, msgs, seg)
+	}()
+
+	conn, err := l.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	r := bufio.NewReader(conn)
+	for i, want := range msgs {
+		got, err := fc.ReadFrame(r)
+		if err != nil {
+			return fmt.Errorf("%s: message #%d: %w", seg.Name, i, err)
+		}
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("%s: message #%d = %q, want %q", seg.Name, i, got, want)
+		}
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		return fmt.Errorf("%s: unexpected data after the last message", seg.Name)
+	}
+	if err := <-werr; err != nil {
+		return fmt.Errorf("%s: write: %w", seg.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package netxtest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"gopractice/netx"
+	"io"
+	"testing"
+)
+
+func TestWriteSegmented(t *testing.T) {
+	msgs := [][]byte{[]byte("a"), []byte("bb"), []byte("ccc")}
+	fc := netx.NewVarintFramer()
+	tests := []struct {
+		seg  Segmentation
+		want []string
+	}{
+		{Segmentation{Coalesce: 1}, []string{"\x01a", "\x02bb", "\x03ccc"}},
+		{Segmentation{}, []string{"\x01a\x02bb\x03ccc"}},
+		{Segmentation{Coalesce: 2, Split: []int{2, 1}}, []string{"\x01a", "\x02", "bb", "\x03", "cc", "c"}},
+	}
+	for _, tt := range tests {
+		var w writes
+		if err := WriteSegmented(&w, fc, msgs, tt.seg); err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprintf("%q", w) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("WriteSegmented(%+v) writes = %q, want %q", tt.seg, w, tt.want)
+		}
+	}
+}
+
+// writes 记录每次 Write 的内容
+type writes []string
+
+func (w *writes) Write(p []byte) (int, error) {
+	*w = append(*w, string(p))
+	return len(p), nil
+}
+
+// 各种封包方式在粘包和半包的情况下都能还原出每个消息
+func TestVerifyCodec(t *testing.T) {
+	var msgs [][]byte
+	for i := 0; i < 20; i++ {
+		msgs = append(msgs, bytes.Repeat([]byte(fmt.Sprintf("name-%d;", i)), i))
+	}
+	codecs := map[string]netx.FrameCodec{
+		"Framer":         netx.NewFramer(),
+		"Framer+crc":     netx.NewFramer(netx.WithChecksum()),
+		"VarintFramer":   netx.NewVarintFramer(),
+		"DelimiterCodec": netx.NewLineCodec(),
+	}
+	for name, fc := range codecs {
+		for _, seg := range StickySegmentations() {
+			if err := VerifyCodec(fc, msgs, seg); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+}
+
+// readAllCodec 错误的实现：把一次 Read 读到的数据当作一个消息，粘包时就会出错
+type readAllCodec struct{}
+
+func (readAllCodec) WriteFrame(w io.Writer, payload []byte) error {
+	_, err := w.Write(payload)
+	return err
+}
+
+func (readAllCodec) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	if _, err := r.Peek(1); err != nil {
+		return nil, err
+	}
+	b, _ := r.Peek(r.Buffered())
+	r.Discard(len(b))
+	return append([]byte(nil), b...), nil
+}
+
+func TestVerifyCodecDetectsStickyPacket(t *testing.T) {
+	msgs := [][]byte{[]byte("hello"), []byte("world")}
+	if err := VerifyCodec(readAllCodec{}, msgs, Segmentation{Name: "all in one write"}); err == nil {
+		t.Error("VerifyCodec() = nil, want the coalesced messages to be detected")
+	}
+}
@@ -0,0 +1,94 @@
+// Package netxtest 在进程内启动 netx.Server 并连接客户端，用于端到端测试
+// 与 net/http/httptest 类似，服务端监听在 127.0.0.1 的随机端口上，不依赖固定端口，可以并行运行。
+package netxtest
+
+import (
+	"context"
+	"fmt"
+	"gopractice/netx"
+	"net"
+	"sync"
+	"time"
+)
+
+// ShutdownTimeout Close 等待连接处理结束的最长时间
+var ShutdownTimeout = 5 * time.Second
+
+// Server 运行在本地回环地址上的 netx.Server
+type Server struct {
+	// Addr 服务端监听的地址，格式为 "127.0.0.1:port"
+	Addr string
+	// Config 实际运行的 netx.Server
+	Config *netx.Server
+
+	mu      sync.Mutex
+	clients []*netx.Client
+	serve   chan error
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewServer 使用 handler 启动一个服务端，调用者需要在结束时调用 Close
+func NewServer(handler func(ctx context.Context, conn net.Conn)) *Server {
+	return Start(&netx.Server{Handler: handler})
+}
+
+// Start 在 127.0.0.1 的随机端口上启动 srv，srv.Addr 会被忽略
+// 监听失败时 panic，与 httptest.NewServer 一致。
+func Start(srv *netx.Server) *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("netxtest: failed to listen on a port: %v", err))
+	}
+
+	s := &Server{
+		Addr:   l.Addr().String(),
+		Config: srv,
+		serve:  make(chan error, 1),
+	}
+	go func() {
+		s.serve <- srv.Serve(l)
+	}()
+	return s
+}
+
+// Dial 创建一个连接到服务端的客户端，Close 时会一并关闭
+func (s *Server) Dial() (*netx.Client, error) {
+	c, err := netx.Dial(s.Addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.clients = append(s.clients, c)
+	s.mu.Unlock()
+	return c, nil
+}
+
+// Close 关闭所有客户端并关闭服务端，等待所有连接处理结束，可以多次调用
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		s.closeErr = s.close()
+	})
+	return s.closeErr
+}
+
+func (s *Server) close() error {
+	s.mu.Lock()
+	for _, c := range s.clients {
+		c.Close()
+	}
+	s.clients = nil
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	if err := s.Config.Shutdown(ctx); err != nil {
+		return err
+	}
+	if err := <-s.serve; err != netx.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package netxtest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"gopractice/netx"
+	"net"
+	"sync"
+	"testing"
+)
+
+// echo 把收到的每个消息原样返回
+func echo(ctx context.Context, conn net.Conn) {
+	c := netx.NewClient(conn)
+	for {
+		msg, err := c.Recv()
+		if err != nil {
+			return
+		}
+		if err := c.Send(msg); err != nil {
+			return
+		}
+	}
+}
+
+func TestRequestResponse(t *testing.T) {
+	s := NewServer(echo)
+	defer s.Close()
+
+	c, err := s.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		want := []byte(fmt.Sprintf(`{"name":"name-%d"}`, i))
+		if err := c.Send(want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Recv() = %q, want %q", got, want)
+		}
+	}
+}
+
+// 客户端一次 Write 写入多个消息，服务端仍然要逐个解出来
+func TestStickyPacket(t *testing.T) {
+	const n = 20
+	got := make(chan []byte, n)
+	s := NewServer(func(ctx context.Context, conn net.Conn) {
+		r := bufio.NewReader(conn)
+		for {
+			msg, err := netx.Decode(r)
+			if err != nil {
+				return
+			}
+			got <- msg
+		}
+	})
+	defer s.Close()
+
+	c, err := s.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var batch []byte
+	for i := 0; i < n; i++ {
+		batch = netx.AppendFrame(batch, []byte(fmt.Sprintf("name-%d", i)))
+	}
+	if _, err := c.Conn().Write(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < n; i++ {
+		if msg, want := <-got, fmt.Sprintf("name-%d", i); string(msg) != want {
+			t.Errorf("message #%d = %q, want %q", i, msg, want)
+		}
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	const clients = 3
+
+	var (
+		mu        sync.Mutex
+		conns     = make(map[*netx.Client]struct{})
+		connected sync.WaitGroup
+	)
+	connected.Add(clients)
+	s := NewServer(func(ctx context.Context, conn net.Conn) {
+		c := netx.NewClient(conn)
+		mu.Lock()
+		conns[c] = struct{}{}
+		mu.Unlock()
+		connected.Done()
+
+		for {
+			msg, err := c.Recv()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			for peer := range conns {
+				peer.Send(msg)
+			}
+			mu.Unlock()
+		}
+	})
+	defer s.Close()
+
+	var cs []*netx.Client
+	for i := 0; i < clients; i++ {
+		c, err := s.Dial()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs = append(cs, c)
+	}
+	connected.Wait()
+
+	if err := cs[0].Send([]byte("hello all")); err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range cs {
+		msg, err := c.Recv()
+		if err != nil || string(msg) != "hello all" {
+			t.Errorf("client #%d Recv() = %q, %v", i, msg, err)
+		}
+	}
+}
+
+func TestCloseWaitsForHandlers(t *testing.T) {
+	started, exited := make(chan struct{}), make(chan struct{})
+	s := NewServer(func(ctx context.Context, conn net.Conn) {
+		close(started)
+		<-ctx.Done()
+		close(exited)
+	})
+	if _, err := s.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	select {
+	case <-exited:
+	default:
+		t.Error("Close returned before the handler exited")
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"gopractice/netx/workerpool"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrBadPortRange 端口范围格式错误，或者超出 1-65535
+var ErrBadPortRange = errors.New("netx: bad port range")
+
+const (
+	// DefaultScanDialTimeout Scan 连接每个端口的默认超时
+	DefaultScanDialTimeout = time.Second
+	// DefaultBannerTimeout Scan 连接成功后等待服务端发送欢迎信息的默认时间
+	DefaultBannerTimeout = 500 * time.Millisecond
+	// maxBannerSize 最多读取的欢迎信息字节数
+	maxBannerSize = 256
+)
+
+// PortRange 端口范围，包括 First 和 Last
+type PortRange struct {
+	First, Last int
+}
+
+// ParsePortRange 解析 "80" 或者 "1-1024" 格式的端口范围
+func ParsePortRange(s string) (PortRange, error) {
+	first, last := s, s
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		first, last = s[:i], s[i+1:]
+	}
+	a, err1 := strconv.Atoi(strings.TrimSpace(first))
+	b, err2 := strconv.Atoi(strings.TrimSpace(last))
+	r := PortRange{First: a, Last: b}
+	if err1 != nil || err2 != nil || !r.valid() {
+		return PortRange{}, ErrBadPortRange
+	}
+	return r, nil
+}
+
+func (r PortRange) valid() bool {
+	return r.First >= 1 && r.First <= r.Last && r.Last <= 65535
+}
+
+// String 返回 "First-Last" 格式
+func (r PortRange) String() string {
+	return strconv.Itoa(r.First) + "-" + strconv.Itoa(r.Last)
+}
+
+// OpenPort 扫描到的开放端口
+type OpenPort struct {
+	Port int
+	// Banner 连接成功后服务端主动发送的欢迎信息（例如 SSH、SMTP），去掉了首尾的空白，没有时为空
+	Banner string
+}
+
+// ScanOption Scan 的可选配置
+type ScanOption func(s *scanner)
+
+type scanner struct {
+	dialTimeout   time.Duration
+	bannerTimeout time.Duration
+}
+
+// WithScanDialTimeout 设置连接每个端口的超时，默认为 DefaultScanDialTimeout
+func WithScanDialTimeout(d time.Duration) ScanOption {
+	return func(s *scanner) {
+		s.dialTimeout = d
+	}
+}
+
+// WithBannerTimeout 设置等待欢迎信息的时间，默认为 DefaultBannerTimeout，为负数时不读取欢迎信息
+func WithBannerTimeout(d time.Duration) ScanOption {
+	return func(s *scanner) {
+		s.bannerTimeout = d
+	}
+}
+
+// Scan 扫描 host 在 ports 范围内开放的 tcp 端口，按端口号排序返回
+// 最多同时连接 concurrency 个端口（小于 1 时按 1 处理），连接成功的端口再读取欢迎信息。
+// ctx 结束时停止扫描，返回已经找到的端口和 ctx.Err()。
+func Scan(ctx context.Context, host string, ports PortRange, concurrency int, opts ...ScanOption) ([]OpenPort, error) {
+	if !ports.valid() {
+		return nil, ErrBadPortRange
+	}
+	s := &scanner{dialTimeout: DefaultScanDialTimeout, bannerTimeout: DefaultBannerTimeout}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	var mu sync.Mutex
+	var open []OpenPort
+	pool := workerpool.New(concurrency, 0)
+	for port := ports.First; port <= ports.Last && ctx.Err() == nil; port++ {
+		port := port
+		pool.Submit(func() {
+			if p, ok := s.probe(ctx, host, port); ok {
+				mu.Lock()
+				open = append(open, p)
+				mu.Unlock()
+			}
+		})
+	}
+	pool.Stop()
+
+	sort.Slice(open, func(i, j int) bool { return open[i].Port < open[j].Port })
+	return open, ctx.Err()
+}
+
+// probe 连接一个端口，连接成功时读取欢迎信息
+func (s *scanner) probe(ctx context.Context, host string, port int) (OpenPort, bool) {
+	if ctx.Err() != nil {
+		return OpenPort{}, false
+	}
+	d := net.Dialer{Timeout: s.dialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return OpenPort{}, false
+	}
+	defer conn.Close()
+
+	p := OpenPort{Port: port}
+	if s.bannerTimeout >= 0 {
+		conn.SetReadDeadline(time.Now().Add(s.bannerTimeout))
+		buf := make([]byte, maxBannerSize)
+		n, _ := conn.Read(buf)
+		p.Banner = strings.TrimSpace(string(buf[:n]))
+	}
+	return p, true
+}
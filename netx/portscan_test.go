@@ -0,0 +1,119 @@
+package netx
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		s    string
+		want PortRange
+		err  error
+	}{
+		{"80", PortRange{80, 80}, nil},
+		{"1-1024", PortRange{1, 1024}, nil},
+		{"0-10", PortRange{}, ErrBadPortRange},
+		{"10-1", PortRange{}, ErrBadPortRange},
+		{"1-65536", PortRange{}, ErrBadPortRange},
+		{"http", PortRange{}, ErrBadPortRange},
+	}
+	for _, tt := range tests {
+		got, err := ParsePortRange(tt.s)
+		if got != tt.want || err != tt.err {
+			t.Errorf("ParsePortRange(%q) = %v, %v, want %v, %v", tt.s, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+// listenPorts 在一段连续的端口上监听，banners[i] 不为空时第 i 个端口连接后先发送欢迎信息，
+// 为 "-" 时这个端口不监听。返回这段端口的范围。
+func listenPorts(t *testing.T, banners []string) PortRange {
+	t.Helper()
+	// 随机找一个端口作为起点，后面的端口被占用时换一个起点
+	for attempt := 0; attempt < 10; attempt++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		first := l.Addr().(*net.TCPAddr).Port
+		l.Close()
+		ls, ok := listenRange(first, banners)
+		if !ok {
+			continue
+		}
+		t.Cleanup(func() {
+			for _, l := range ls {
+				l.Close()
+			}
+		})
+		return PortRange{First: first, Last: first + len(banners) - 1}
+	}
+	t.Skip("no free consecutive ports")
+	return PortRange{}
+}
+
+func listenRange(first int, banners []string) ([]net.Listener, bool) {
+	var ls []net.Listener
+	for i, banner := range banners {
+		if banner == "-" {
+			continue
+		}
+		l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(first+i)))
+		if err != nil {
+			for _, l := range ls {
+				l.Close()
+			}
+			return nil, false
+		}
+		ls = append(ls, l)
+		go func(banner string) {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				if banner != "" {
+					conn.Write([]byte(banner + "\r\n"))
+				}
+				// 不发送欢迎信息的端口保持连接，直到扫描方超时关闭
+				go func() {
+					conn.Read(make([]byte, 1))
+					conn.Close()
+				}()
+			}
+		}(banner)
+	}
+	return ls, true
+}
+
+func TestScan(t *testing.T) {
+	r := listenPorts(t, []string{"SSH-2.0-test", "-", "", "-", "220 ready"})
+	got, err := Scan(context.Background(), "127.0.0.1", r, 2, WithBannerTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []OpenPort{{r.First, "SSH-2.0-test"}, {r.First + 2, ""}, {r.First + 4, "220 ready"}}
+	if len(got) != len(want) {
+		t.Fatalf("Scan(%v) = %v, want %v", r, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Scan(%v)[%d] = %v, want %v", r, i, got[i], want[i])
+		}
+	}
+}
+
+func TestScanCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Scan(ctx, "127.0.0.1", PortRange{1, 65535}, 4); err != context.Canceled {
+		t.Errorf("Scan() = %v, want %v", err, context.Canceled)
+	}
+	if _, err := Scan(context.Background(), "127.0.0.1", PortRange{0, 1}, 4); err != ErrBadPortRange {
+		t.Errorf("Scan() = %v, want %v", err, ErrBadPortRange)
+	}
+}
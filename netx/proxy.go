@@ -0,0 +1,245 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoUpstream Proxy 没有配置上游地址
+var ErrNoUpstream = errors.New("netx: proxy has no upstream")
+
+const (
+	// DefaultProxyDialTimeout Proxy.DialTimeout 为 0 时连接上游的超时
+	DefaultProxyDialTimeout = 5 * time.Second
+	// DefaultUpstreamFailTimeout Proxy.FailTimeout 为 0 时，连接失败的上游暂停使用的时间
+	DefaultUpstreamFailTimeout = 10 * time.Second
+)
+
+// proxyBufSize 每个方向转发时使用的缓冲区大小
+const proxyBufSize = 32 << 10
+
+var proxyBufPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, proxyBufSize)
+		return &b
+	},
+}
+
+// Proxy tcp 代理（端口转发），把在 Addr 上接受的连接双向转发到上游地址
+// 有多个上游时按轮询选择，连接失败的上游在 FailTimeout 内被跳过，所有上游都失败时才放弃这个连接。
+// Accept、MaxConns、Shutdown 等都由内嵌的 Server 完成，使用 NewProxy 创建。
+type Proxy struct {
+	Server
+
+	// Upstreams 上游地址，至少一个
+	Upstreams []string
+
+	// DialTimeout 连接上游的超时，为 0 时使用 DefaultProxyDialTimeout
+	DialTimeout time.Duration
+
+	// IdleTimeout 两个方向都没有数据的最长时间，超过后关闭连接，0 表示不限制
+	IdleTimeout time.Duration
+
+	// FailTimeout 上游连接失败后暂停使用的时间，为 0 时使用 DefaultUpstreamFailTimeout
+	FailTimeout time.Duration
+
+	next      uint32
+	mu        sync.Mutex
+	downUntil map[string]time.Time
+
+	conns      uint64
+	active     int64
+	dialErrors uint64
+	bytesUp    uint64
+	bytesDown  uint64
+}
+
+// ProxyStats Proxy 的统计信息
+type ProxyStats struct {
+	Conns      uint64 // 成功连接上游的连接数
+	Active     int64  // 正在转发的连接数
+	DialErrors uint64 // 连接上游失败的次数
+	BytesUp    uint64 // 从客户端转发到上游的字节数
+	BytesDown  uint64 // 从上游转发到客户端的字节数
+}
+
+// NewProxy 返回一个把 addr 上的连接转发到 upstreams 的 Proxy
+func NewProxy(addr string, upstreams ...string) *Proxy {
+	p := &Proxy{Upstreams: upstreams}
+	p.Addr = addr
+	p.Handler = p.serve
+	return p
+}
+
+// Stats 返回统计信息，字节数在转发过程中实时更新
+func (p *Proxy) Stats() ProxyStats {
+	return ProxyStats{
+		Conns:      atomic.LoadUint64(&p.conns),
+		Active:     atomic.LoadInt64(&p.active),
+		DialErrors: atomic.LoadUint64(&p.dialErrors),
+		BytesUp:    atomic.LoadUint64(&p.bytesUp),
+		BytesDown:  atomic.LoadUint64(&p.bytesDown),
+	}
+}
+
+// serve 连接上游并双向转发，一个方向读到 EOF 时关闭另一端的写，两个方向都结束后返回
+func (p *Proxy) serve(ctx context.Context, conn net.Conn) {
+	up, addr, err := p.dial(ctx)
+	if err != nil {
+		p.logger().Errorf("proxy %v: dial upstream failed, err: %v", conn.RemoteAddr(), err)
+		return
+	}
+	defer up.Close()
+	atomic.AddUint64(&p.conns, 1)
+	atomic.AddInt64(&p.active, 1)
+	defer atomic.AddInt64(&p.active, -1)
+	p.logger().Debugf("代理连接 %v -> %s", conn.RemoteAddr(), addr)
+
+	// Shutdown 或者 Close 时关闭两端，让转发结束
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			up.Close()
+		case <-done:
+		}
+	}()
+
+	var last int64 // 最后一次收到数据的时间，两个方向共用
+	atomic.StoreInt64(&last, time.Now().UnixNano())
+	var nup, ndown uint64
+	errc := make(chan error, 2)
+	go func() {
+		err := p.pipe(up, conn, &nup, &p.bytesUp, &last)
+		closeWrite(up)
+		errc <- err
+	}()
+	go func() {
+		err := p.pipe(conn, up, &ndown, &p.bytesDown, &last)
+		closeWrite(conn)
+		errc <- err
+	}()
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != nil {
+			// 一个方向出错（包括空闲超时）时另一个方向也没有继续的必要
+			conn.Close()
+			up.Close()
+		}
+	}
+	p.logger().Debugf("代理连接 %v -> %s 结束，上行 %d 字节，下行 %d 字节",
+		conn.RemoteAddr(), addr, atomic.LoadUint64(&nup), atomic.LoadUint64(&ndown))
+}
+
+// pipe 把 src 的数据写到 dst，直到 src 读到 EOF
+// 开启 IdleTimeout 时每次读之前设置读超时，超时的时候如果另一个方向最近收到过数据，继续等待。
+func (p *Proxy) pipe(dst, src net.Conn, n, total *uint64, last *int64) error {
+	bp := proxyBufPool.Get().(*[]byte)
+	defer proxyBufPool.Put(bp)
+	buf := *bp
+	for {
+		if p.IdleTimeout > 0 {
+			src.SetReadDeadline(time.Now().Add(p.IdleTimeout))
+		}
+		nr, err := src.Read(buf)
+		if nr > 0 {
+			atomic.StoreInt64(last, time.Now().UnixNano())
+			if p.IdleTimeout > 0 {
+				dst.SetWriteDeadline(time.Now().Add(p.IdleTimeout))
+			}
+			nw, werr := dst.Write(buf[:nr])
+			atomic.AddUint64(n, uint64(nw))
+			atomic.AddUint64(total, uint64(nw))
+			if werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if isTimeout(err) && p.IdleTimeout > 0 {
+				idle := time.Since(time.Unix(0, atomic.LoadInt64(last)))
+				if idle < p.IdleTimeout {
+					continue
+				}
+				return ErrIdleTimeout
+			}
+			return err
+		}
+	}
+}
+
+// dial 从下一个上游开始依次尝试，跳过最近连接失败的上游；都在暂停期内时仍然全部尝试一遍
+func (p *Proxy) dial(ctx context.Context) (net.Conn, string, error) {
+	if len(p.Upstreams) == 0 {
+		return nil, "", ErrNoUpstream
+	}
+	start := int(atomic.AddUint32(&p.next, 1) - 1)
+	candidates := make([]string, 0, len(p.Upstreams))
+	var down []string
+	now := time.Now()
+	p.mu.Lock()
+	for i := range p.Upstreams {
+		addr := p.Upstreams[(start+i)%len(p.Upstreams)]
+		if now.Before(p.downUntil[addr]) {
+			down = append(down, addr)
+		} else {
+			candidates = append(candidates, addr)
+		}
+	}
+	p.mu.Unlock()
+	candidates = append(candidates, down...)
+
+	timeout := p.DialTimeout
+	if timeout == 0 {
+		timeout = DefaultProxyDialTimeout
+	}
+	d := net.Dialer{Timeout: timeout}
+	var err error
+	for _, addr := range candidates {
+		var up net.Conn
+		up, err = d.DialContext(ctx, "tcp", addr)
+		if err == nil {
+			p.markUp(addr)
+			return up, addr, nil
+		}
+		atomic.AddUint64(&p.dialErrors, 1)
+		if ctx.Err() != nil {
+			return nil, "", err
+		}
+		p.logger().Errorf("proxy: dial upstream %s failed, err: %v", addr, err)
+		p.markDown(addr)
+	}
+	return nil, "", err
+}
+
+func (p *Proxy) markDown(addr string) {
+	d := p.FailTimeout
+	if d == 0 {
+		d = DefaultUpstreamFailTimeout
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.downUntil == nil {
+		p.downUntil = make(map[string]time.Time)
+	}
+	p.downUntil[addr] = time.Now().Add(d)
+}
+
+func (p *Proxy) markUp(addr string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.downUntil, addr)
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,186 @@
+package netx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// startBackend 启动一个回显服务，回显之前加上 name 前缀，用来区分连接被转发到了哪个上游
+func startBackend(t *testing.T, name string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte(name))
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func startProxy(t *testing.T, p *Proxy) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- p.Serve(l) }()
+	t.Cleanup(func() {
+		p.Close()
+		if err := <-done; err != ErrServerClosed {
+			t.Errorf("Serve() = %v, want %v", err, ErrServerClosed)
+		}
+	})
+	return l.Addr().String()
+}
+
+// proxyEcho 通过代理发送 msg，半关闭后读出全部回复
+func proxyEcho(t *testing.T, addr string, msg []byte) []byte {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	conn.(*net.TCPConn).CloseWrite()
+	got, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestProxy(t *testing.T) {
+	a, b := startBackend(t, "a:"), startBackend(t, "b:")
+	p := NewProxy("", a, b)
+	addr := startProxy(t, p)
+
+	msg := bytes.Repeat([]byte("0123456789"), 10_000)
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		got := proxyEcho(t, addr, msg)
+		if len(got) != 2+len(msg) || !bytes.Equal(got[2:], msg) {
+			t.Fatalf("echo %d bytes = %d bytes", len(msg), len(got))
+		}
+		seen[string(got[:2])]++
+	}
+	if seen["a:"] != 2 || seen["b:"] != 2 {
+		t.Errorf("upstreams used = %v, want round robin", seen)
+	}
+
+	// 计数在 Handler 返回之前更新，客户端读到 EOF 时可能还没有完全结束
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().Active != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	want := ProxyStats{Conns: 4, BytesUp: 4 * uint64(len(msg)), BytesDown: 4 * uint64(2+len(msg))}
+	if st := p.Stats(); st != want {
+		t.Errorf("Stats() = %+v, want %+v", st, want)
+	}
+}
+
+func TestProxyFailover(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := l.Addr().String()
+	l.Close()
+
+	p := NewProxy("", dead, startBackend(t, "ok"))
+	addr := startProxy(t, p)
+	for i := 0; i < 3; i++ {
+		if got := proxyEcho(t, addr, []byte("!")); string(got) != "ok!" {
+			t.Errorf("echo = %q, want %q", got, "ok!")
+		}
+	}
+	// 第一次失败之后 dead 被暂停使用，不会再尝试
+	if st := p.Stats(); st.DialErrors != 1 || st.Conns != 3 {
+		t.Errorf("Stats() = %+v, want 1 dial error and 3 conns", st)
+	}
+
+	// 所有上游都失败时直接关闭客户端的连接
+	p = NewProxy("", dead)
+	conn, err := net.Dial("tcp", startProxy(t, p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if n, err := conn.Read(make([]byte, 1)); err == nil || isTimeout(err) {
+		t.Errorf("Read() = %d, %v, want connection closed", n, err)
+	}
+}
+
+func TestProxyIdleTimeout(t *testing.T) {
+	p := NewProxy("", startBackend(t, ""))
+	p.IdleTimeout = 50 * time.Millisecond
+	addr := startProxy(t, p)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	// 持续有数据时不会超时
+	for i := 0; i < 5; i++ {
+		time.Sleep(20 * time.Millisecond)
+		conn.Write([]byte("x"))
+		if _, err := io.ReadFull(conn, make([]byte, 1)); err != nil {
+			t.Fatalf("read %d: %v", i, err)
+		}
+	}
+	start := time.Now()
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read() = %v, want EOF after idle timeout", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("closed after %v, want about %v", d, p.IdleTimeout)
+	}
+}
+
+func TestProxyShutdown(t *testing.T) {
+	p := NewProxy("", startBackend(t, ""))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go p.Serve(l)
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("x"))
+	io.ReadFull(conn, make([]byte, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if st := p.Stats(); st.Active != 0 {
+		t.Errorf("Active = %d after Shutdown, want 0", st.Active)
+	}
+}
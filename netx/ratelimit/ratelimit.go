@@ -0,0 +1,85 @@
+// Package ratelimit 令牌桶限流，netx 用它限制每个连接的读写字节数和消息数
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter 令牌桶，每秒补充 rate 个令牌，最多积攒 burst 个
+// 可以在多个 goroutine 中同时使用。
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// New 创建一个令牌桶，初始时是满的，burst<=0 时使用 rate
+func New(rate float64, burst int) *Limiter {
+	if burst <= 0 {
+		burst = int(rate)
+	}
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+}
+
+// Allow 令牌足够时取走 n 个并返回 true，否则不取令牌并返回 false
+func (l *Limiter) Allow(n int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	if l.tokens < float64(n) {
+		return false
+	}
+	l.tokens -= float64(n)
+	return true
+}
+
+// Reserve 取走 n 个令牌，令牌不够时先欠着，返回需要等待多久才能还清
+// n 可以大于 burst，这样一次大的读写也只是等待更长的时间。
+func (l *Limiter) Reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// Wait 取走 n 个令牌，令牌不够时等待
+// 等待不能被打断，需要在连接关闭或者超时时放弃等待的话用 Reserve 和 Cancel 自己等待。
+func (l *Limiter) Wait(n int) {
+	if d := l.Reserve(n); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// Cancel 还回 Reserve 取走但最终没有使用的 n 个令牌，例如等待的过程中连接被关闭
+func (l *Limiter) Cancel(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens += float64(n)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+func (l *Limiter) refill() {
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+}
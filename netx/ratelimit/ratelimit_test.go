@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func newTestLimiter(rate float64, burst int) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	l := New(rate, burst)
+	l.now = clock.now
+	return l, clock
+}
+
+func TestLimiterAllow(t *testing.T) {
+	l, clock := newTestLimiter(10, 5)
+	for i := 0; i < 5; i++ {
+		if !l.Allow(1) {
+			t.Fatalf("Allow #%d = false within burst", i)
+		}
+	}
+	if l.Allow(1) {
+		t.Error("Allow over burst = true")
+	}
+
+	clock.t = clock.t.Add(200 * time.Millisecond)
+	if !l.Allow(2) || l.Allow(1) {
+		t.Error("expected exactly 2 tokens after 200ms at 10/s")
+	}
+
+	// 令牌最多积攒 burst 个
+	clock.t = clock.t.Add(time.Hour)
+	if l.Allow(6) || !l.Allow(5) {
+		t.Error("tokens are not capped at burst")
+	}
+}
+
+func TestLimiterReserve(t *testing.T) {
+	l, clock := newTestLimiter(100, 0)
+	if d := l.Reserve(100); d != 0 {
+		t.Errorf("Reserve(burst) = %v, want 0", d)
+	}
+	if d := l.Reserve(50); d != 500*time.Millisecond {
+		t.Errorf("Reserve(50) = %v, want 500ms", d)
+	}
+	// 欠下的令牌要先还清
+	clock.t = clock.t.Add(500 * time.Millisecond)
+	if d := l.Reserve(300); d != 3*time.Second {
+		t.Errorf("Reserve(300) = %v, want 3s", d)
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	l, _ := newTestLimiter(100, 0)
+	l.Reserve(100)
+	if d := l.Reserve(50); d != 500*time.Millisecond {
+		t.Fatalf("Reserve(50) = %v, want 500ms", d)
+	}
+	l.Cancel(50)
+	if d := l.Reserve(50); d != 500*time.Millisecond {
+		t.Errorf("Reserve(50) after Cancel = %v, want 500ms", d)
+	}
+	// 还回的令牌不超过 burst
+	l.Cancel(1000)
+	if l.Allow(101) || !l.Allow(100) {
+		t.Error("Cancel does not cap tokens at burst")
+	}
+}
@@ -0,0 +1,110 @@
+package reactor
+
+import (
+	"context"
+	"gopractice/netx"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// idleConns 基准测试中空闲连接的数量，默认 1000。
+// 测试 10 万个连接时需要先调大文件描述符的限制（客户端和服务端各占一个），例如：
+//
+//	ulimit -n 250000 && NETX_IDLE_CONNS=100000 go test -run x -bench IdleConns ./netx/reactor
+func idleConns(b *testing.B) int {
+	if s := os.Getenv("NETX_IDLE_CONNS"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return n
+	}
+	return 1000
+}
+
+// BenchmarkIdleConns 比较有大量空闲连接时两种服务端的内存占用，以及一个活跃连接上 echo 的延迟。
+// B/conn 是服务端和客户端连接一起增加的内存（堆和栈），ns/op 是一次 echo 的耗时。
+func BenchmarkIdleConns(b *testing.B) {
+	b.Run("reactor", func(b *testing.B) {
+		var opened int64
+		s := echoServer(nil)
+		s.Loops = 0
+		s.OnOpen = func(c *Conn) { atomic.AddInt64(&opened, 1) }
+		addr := startServer(b, s)
+		benchmarkIdleConns(b, addr, &opened)
+	})
+	b.Run("goroutine", func(b *testing.B) {
+		var opened int64
+		h := netx.HandlerFunc(func(ctx context.Context, conn *netx.Conn) {
+			atomic.AddInt64(&opened, 1)
+			for {
+				msg, err := conn.Recv(ctx)
+				if err != nil {
+					return
+				}
+				conn.Send(msg)
+			}
+		})
+		s := netx.NewTCPServer("", h)
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			b.Fatal(err)
+		}
+		go s.Serve(l)
+		b.Cleanup(func() { s.Close() })
+		benchmarkIdleConns(b, l.Addr().String(), &opened)
+	})
+}
+
+func benchmarkIdleConns(b *testing.B, addr string, opened *int64) {
+	n := idleConns(b)
+	before := memInUse()
+	conns := make([]net.Conn, 0, n)
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+	}()
+	for i := 0; i < n; i++ {
+		c, err := net.Dial("tcp", addr)
+		if err != nil {
+			b.Fatalf("dial %d: %v", i, err)
+		}
+		conns = append(conns, c)
+	}
+	for deadline := time.Now().Add(10 * time.Second); atomic.LoadInt64(opened) < int64(n); {
+		if time.Now().After(deadline) {
+			b.Fatalf("only %d of %d connections opened", atomic.LoadInt64(opened), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	perConn := float64(memInUse()-before) / float64(n)
+
+	c, err := netx.Dial(addr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer c.Close()
+	msg := []byte("ping")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Send(msg)
+		if _, err := c.Recv(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(perConn, "B/conn")
+}
+
+// memInUse GC 之后堆和栈占用的内存
+func memInUse() int64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return int64(m.HeapInuse + m.StackInuse)
+}
@@ -0,0 +1,63 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package reactor
+
+import "syscall"
+
+// errAgain 非阻塞的读暂时没有数据
+var errAgain error = syscall.EAGAIN
+
+func readFD(fd int, b []byte) (int, error) {
+	for {
+		n, err := syscall.Read(fd, b)
+		if err == syscall.EINTR {
+			continue
+		}
+		if n < 0 {
+			n = 0
+		}
+		return n, err
+	}
+}
+
+// writeFD 非阻塞地写入，缓冲区满时返回已经写入的字节数，不返回错误
+func writeFD(fd int, b []byte) (int, error) {
+	for {
+		n, err := syscall.Write(fd, b)
+		if err == syscall.EINTR {
+			continue
+		}
+		if n < 0 {
+			n = 0
+		}
+		if err == syscall.EAGAIN {
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func closeFD(fd int) error {
+	return syscall.Close(fd)
+}
+
+// dupFD 复制 rc 的文件描述符，设置为非阻塞
+func dupFD(rc syscall.RawConn) (int, error) {
+	fd := -1
+	var dupErr error
+	err := rc.Control(func(s uintptr) {
+		fd, dupErr = syscall.Dup(int(s))
+	})
+	if err != nil {
+		return -1, err
+	}
+	if dupErr != nil {
+		return -1, dupErr
+	}
+	syscall.CloseOnExec(fd)
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
@@ -0,0 +1,108 @@
+//go:build linux
+
+package reactor
+
+import (
+	"sync/atomic"
+	"syscall"
+)
+
+// poller Linux 上使用 epoll，水平触发
+type poller struct {
+	epfd   int
+	wakeR  int // 唤醒用的管道，读端注册到 epoll 中
+	wakeW  int
+	woken  int32
+	events []syscall.EpollEvent
+}
+
+func newPoller() (*poller, error) {
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	var fds [2]int
+	if err := syscall.Pipe2(fds[:], syscall.O_NONBLOCK|syscall.O_CLOEXEC); err != nil {
+		syscall.Close(epfd)
+		return nil, err
+	}
+	p := &poller{epfd: epfd, wakeR: fds[0], wakeW: fds[1], events: make([]syscall.EpollEvent, 128)}
+	if err := p.ctl(syscall.EPOLL_CTL_ADD, p.wakeR, syscall.EPOLLIN); err != nil {
+		p.close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *poller) ctl(op, fd int, events uint32) error {
+	return syscall.EpollCtl(p.epfd, op, fd, &syscall.EpollEvent{Events: events, Fd: int32(fd)})
+}
+
+// add 监听 fd 的可读事件
+func (p *poller) add(fd int) error {
+	return p.ctl(syscall.EPOLL_CTL_ADD, fd, syscall.EPOLLIN|syscall.EPOLLRDHUP)
+}
+
+// watchWrite 开始或者停止监听 fd 的可写事件
+func (p *poller) watchWrite(fd int, on bool) error {
+	var events uint32 = syscall.EPOLLIN | syscall.EPOLLRDHUP
+	if on {
+		events |= syscall.EPOLLOUT
+	}
+	return p.ctl(syscall.EPOLL_CTL_MOD, fd, events)
+}
+
+// stopRead 停止监听 fd 的可读事件，只监听可写事件
+func (p *poller) stopRead(fd int) error {
+	return p.ctl(syscall.EPOLL_CTL_MOD, fd, syscall.EPOLLOUT)
+}
+
+func (p *poller) del(fd int) error {
+	return p.ctl(syscall.EPOLL_CTL_DEL, fd, 0)
+}
+
+// wake 让阻塞在 wait 中的事件循环返回，多次调用只写一次管道
+func (p *poller) wake() error {
+	if !atomic.CompareAndSwapInt32(&p.woken, 0, 1) {
+		return nil
+	}
+	_, err := syscall.Write(p.wakeW, []byte{1})
+	if err == syscall.EAGAIN {
+		return nil
+	}
+	return err
+}
+
+// wait 等待事件并逐个交给 handle，被 wake 唤醒或者被信号中断时也会返回
+func (p *poller) wait(handle func(fd int, readable, writable bool)) error {
+	n, err := syscall.EpollWait(p.epfd, p.events, -1)
+	if err == syscall.EINTR {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, ev := range p.events[:n] {
+		fd := int(ev.Fd)
+		if fd == p.wakeR {
+			atomic.StoreInt32(&p.woken, 0)
+			var buf [64]byte
+			for {
+				if n, _ := syscall.Read(p.wakeR, buf[:]); n <= 0 {
+					break
+				}
+			}
+			continue
+		}
+		readable := ev.Events&(syscall.EPOLLIN|syscall.EPOLLRDHUP|syscall.EPOLLHUP|syscall.EPOLLERR) != 0
+		writable := ev.Events&(syscall.EPOLLOUT|syscall.EPOLLHUP|syscall.EPOLLERR) != 0
+		handle(fd, readable, writable)
+	}
+	return nil
+}
+
+func (p *poller) close() error {
+	syscall.Close(p.wakeR)
+	syscall.Close(p.wakeW)
+	return syscall.Close(p.epfd)
+}
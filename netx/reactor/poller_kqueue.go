@@ -0,0 +1,120 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package reactor
+
+import (
+	"sync/atomic"
+	"syscall"
+)
+
+// poller BSD 和 macOS 上使用 kqueue
+type poller struct {
+	kq     int
+	wakeR  int // 唤醒用的管道，读端注册到 kqueue 中
+	wakeW  int
+	woken  int32
+	events []syscall.Kevent_t
+}
+
+func newPoller() (*poller, error) {
+	kq, err := syscall.Kqueue()
+	if err != nil {
+		return nil, err
+	}
+	syscall.CloseOnExec(kq)
+	var fds [2]int
+	if err := syscall.Pipe(fds[:]); err != nil {
+		syscall.Close(kq)
+		return nil, err
+	}
+	for _, fd := range fds {
+		syscall.CloseOnExec(fd)
+		syscall.SetNonblock(fd, true)
+	}
+	p := &poller{kq: kq, wakeR: fds[0], wakeW: fds[1], events: make([]syscall.Kevent_t, 128)}
+	if err := p.ctl(p.wakeR, syscall.EVFILT_READ, syscall.EV_ADD); err != nil {
+		p.close()
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *poller) ctl(fd, filter, flags int) error {
+	var ev [1]syscall.Kevent_t
+	syscall.SetKevent(&ev[0], fd, filter, flags)
+	_, err := syscall.Kevent(p.kq, ev[:], nil, nil)
+	return err
+}
+
+// add 监听 fd 的可读事件
+func (p *poller) add(fd int) error {
+	return p.ctl(fd, syscall.EVFILT_READ, syscall.EV_ADD)
+}
+
+// watchWrite 开始或者停止监听 fd 的可写事件
+func (p *poller) watchWrite(fd int, on bool) error {
+	if on {
+		return p.ctl(fd, syscall.EVFILT_WRITE, syscall.EV_ADD)
+	}
+	err := p.ctl(fd, syscall.EVFILT_WRITE, syscall.EV_DELETE)
+	if err == syscall.ENOENT {
+		return nil
+	}
+	return err
+}
+
+// stopRead 停止监听 fd 的可读事件
+func (p *poller) stopRead(fd int) error {
+	return p.ctl(fd, syscall.EVFILT_READ, syscall.EV_DELETE)
+}
+
+// del 关闭 fd 时 kqueue 会自动删除它的事件，这里只是提前删除
+func (p *poller) del(fd int) error {
+	p.ctl(fd, syscall.EVFILT_WRITE, syscall.EV_DELETE)
+	return p.ctl(fd, syscall.EVFILT_READ, syscall.EV_DELETE)
+}
+
+// wake 让阻塞在 wait 中的事件循环返回，多次调用只写一次管道
+func (p *poller) wake() error {
+	if !atomic.CompareAndSwapInt32(&p.woken, 0, 1) {
+		return nil
+	}
+	_, err := syscall.Write(p.wakeW, []byte{1})
+	if err == syscall.EAGAIN {
+		return nil
+	}
+	return err
+}
+
+// wait 等待事件并逐个交给 handle，被 wake 唤醒或者被信号中断时也会返回
+func (p *poller) wait(handle func(fd int, readable, writable bool)) error {
+	n, err := syscall.Kevent(p.kq, nil, p.events, nil)
+	if err == syscall.EINTR {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, ev := range p.events[:n] {
+		fd := int(ev.Ident)
+		if fd == p.wakeR {
+			atomic.StoreInt32(&p.woken, 0)
+			var buf [64]byte
+			for {
+				if n, _ := syscall.Read(p.wakeR, buf[:]); n <= 0 {
+					break
+				}
+			}
+			continue
+		}
+		eof := ev.Flags&syscall.EV_EOF != 0
+		handle(fd, ev.Filter == syscall.EVFILT_READ || eof, ev.Filter == syscall.EVFILT_WRITE)
+	}
+	return nil
+}
+
+func (p *poller) close() error {
+	syscall.Close(p.wakeR)
+	syscall.Close(p.wakeW)
+	return syscall.Close(p.kq)
+}
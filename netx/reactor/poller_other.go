@@ -0,0 +1,33 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package reactor
+
+import "syscall"
+
+// poller 当前平台不支持，Serve 返回 ErrUnsupported
+type poller struct{}
+
+func newPoller() (*poller, error) {
+	return nil, ErrUnsupported
+}
+
+func (p *poller) add(fd int) error                 { return ErrUnsupported }
+func (p *poller) watchWrite(fd int, on bool) error { return ErrUnsupported }
+func (p *poller) stopRead(fd int) error            { return ErrUnsupported }
+func (p *poller) del(fd int) error                 { return ErrUnsupported }
+func (p *poller) wake() error                      { return ErrUnsupported }
+func (p *poller) close() error                     { return ErrUnsupported }
+
+func (p *poller) wait(handle func(fd int, readable, writable bool)) error {
+	return ErrUnsupported
+}
+
+var errAgain = ErrUnsupported
+
+func readFD(fd int, b []byte) (int, error)  { return 0, ErrUnsupported }
+func writeFD(fd int, b []byte) (int, error) { return 0, ErrUnsupported }
+func closeFD(fd int) error                  { return ErrUnsupported }
+
+func dupFD(rc syscall.RawConn) (int, error) {
+	return -1, ErrUnsupported
+}
@@ -0,0 +1,439 @@
+// Package reactor 基于事件循环（reactor 模式）的 tcp 服务端
+// netx.Server 为每个连接启动一个 goroutine，阻塞地读取消息，代码简单，
+// 但是每个 goroutine 至少占用几 KB 的栈，大量空闲连接时内存占用很高。
+// reactor 只启动少量的事件循环 goroutine，用 epoll（Linux）或 kqueue（BSD、macOS）
+// 监听所有连接，连接可读时才读取数据，空闲连接只占用一个 Conn 结构体。
+// 代价是 Handler 在事件循环中执行，不能阻塞，否则会影响同一个循环上的其他连接。
+package reactor
+
+import (
+	"bufio"
+	"errors"
+	"gopractice/logx"
+	"gopractice/netx"
+	"io"
+	"net"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrUnsupported 当前平台不支持 epoll 或者 kqueue
+	ErrUnsupported = errors.New("reactor: unsupported platform")
+
+	errNotSyscallConn = errors.New("reactor: connection does not implement syscall.Conn")
+)
+
+// readBufferSize 每个事件循环共用的读缓冲区大小
+const readBufferSize = 64 << 10
+
+// Server 基于事件循环的 tcp 服务端，消息格式默认与 netx.Encode 相同
+type Server struct {
+	// Loops 事件循环的数量，为 0 时使用 runtime.NumCPU()
+	Loops int
+
+	// Split 从收到的字节流中拆出消息，为 nil 时使用 netx.FrameScanner 的 Split，
+	// 与 netx.Encode 的格式相同
+	Split bufio.SplitFunc
+
+	// Handler 处理一个消息，在事件循环的 goroutine 中调用，不能阻塞；msg 只在调用期间有效
+	Handler func(c *Conn, msg []byte)
+
+	// OnOpen 不为 nil 时在连接加入事件循环后调用
+	OnOpen func(c *Conn)
+
+	// OnClose 不为 nil 时在连接关闭后调用，err 为 nil 表示由 Conn.Close 关闭，
+	// io.EOF 表示对方关闭了连接
+	OnClose func(c *Conn, err error)
+
+	// Logger 为 nil 时不输出日志
+	Logger logx.Logger
+
+	mu       sync.Mutex
+	listener net.Listener
+	loops    []*loop
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// ListenAndServe 监听 addr 并开始处理连接
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve 在 l 上 Accept 连接，把连接从 Go 的网络轮询中取出，按顺序分配给各个事件循环。
+// 返回时所有事件循环都已经退出，Close 之后返回 netx.ErrServerClosed。
+func (s *Server) Serve(l net.Listener) error {
+	defer l.Close()
+	n := s.Loops
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	split := s.Split
+	if split == nil {
+		split = netx.NewFrameScanner(netx.DefaultMaxFrameSize).Split
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return netx.ErrServerClosed
+	}
+	s.listener = l
+	for i := 0; i < n; i++ {
+		p, err := newPoller()
+		if err != nil {
+			s.mu.Unlock()
+			s.Close()
+			return err
+		}
+		lp := &loop{s: s, split: split, p: p, conns: make(map[int]*Conn), buf: make([]byte, readBufferSize)}
+		s.loops = append(s.loops, lp)
+		s.wg.Add(1)
+		go lp.run()
+	}
+	s.mu.Unlock()
+	defer s.wg.Wait()
+
+	logger := s.logger()
+	logger.Infof("服务端已启动，监听 %s，%d 个事件循环", l.Addr(), n)
+	for next := 0; ; next++ {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.shuttingDown() {
+				return netx.ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			s.Close()
+			return err
+		}
+		fd, err := detach(conn)
+		if err != nil {
+			logger.Errorf("reactor: 取出连接的文件描述符失败 %v", err)
+			continue
+		}
+		s.loops[next%n].add(&Conn{fd: fd, remote: conn.RemoteAddr()})
+	}
+}
+
+// Close 停止 Accept，关闭所有连接和事件循环
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for _, lp := range s.loops {
+		lp.exec(lp.stop)
+	}
+	return nil
+}
+
+func (s *Server) logger() logx.Logger {
+	if s.Logger == nil {
+		return logx.Nop
+	}
+	return s.Logger
+}
+
+func (s *Server) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// detach 复制连接的文件描述符并关闭原来的连接，让连接脱离 Go 的网络轮询，由事件循环接管
+func detach(conn net.Conn) (int, error) {
+	defer conn.Close()
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return -1, errNotSyscallConn
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+	return dupFD(rc)
+}
+
+// Conn 事件循环中的一个连接
+// Write 和 Close 可以在任何 goroutine 中调用，不会阻塞。
+type Conn struct {
+	fd     int
+	remote net.Addr
+	loop   *loop
+	inbuf  []byte // 不完整的消息，只在事件循环中访问
+	eof    bool   // 对方已经关闭了写的一端，等缓存的数据写完再关闭，只在事件循环中访问
+
+	mu     sync.Mutex
+	outbuf []byte // 没有写完的数据，等待连接可写时继续写入
+	closed bool
+}
+
+// RemoteAddr 返回对方的地址
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// Write 立即尝试写入，写不完的部分缓存起来，等连接可写时由事件循环继续写入。
+// 返回 nil 不代表数据已经发送给对方。
+func (c *Conn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	if len(c.outbuf) > 0 {
+		c.outbuf = append(c.outbuf, b...)
+		return len(b), nil
+	}
+	n, err := writeFD(c.fd, b)
+	if err != nil {
+		return n, err
+	}
+	if n < len(b) {
+		c.outbuf = append(c.outbuf, b[n:]...)
+		if err := c.loop.p.watchWrite(c.fd, true); err != nil {
+			return n, err
+		}
+	}
+	return len(b), nil
+}
+
+// Close 在事件循环中关闭连接，关闭前尽量把缓存的数据写完
+func (c *Conn) Close() error {
+	c.loop.exec(func() { c.loop.closeConn(c, nil) })
+	return nil
+}
+
+// pending 返回还没有写完的字节数
+func (c *Conn) pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.outbuf)
+}
+
+// flush 写入缓存的数据，写完后取消对可写事件的监听
+func (c *Conn) flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || len(c.outbuf) == 0 {
+		return nil
+	}
+	n, err := writeFD(c.fd, c.outbuf)
+	if err != nil {
+		return err
+	}
+	c.outbuf = c.outbuf[n:]
+	if len(c.outbuf) > 0 {
+		return nil
+	}
+	c.outbuf = nil
+	return c.loop.p.watchWrite(c.fd, false)
+}
+
+// loop 一个事件循环，连接只在这个 goroutine 中读取和关闭
+type loop struct {
+	s     *Server
+	split bufio.SplitFunc
+	p     *poller
+	conns map[int]*Conn
+	buf   []byte
+
+	mu      sync.Mutex
+	tasks   []func()
+	stopped bool // 只在事件循环中访问
+	// closed 事件循环已经退出，poller 的文件描述符已经关闭，编号可能已经分配给了别的文件，不能再唤醒
+	closed bool
+}
+
+// exec 在事件循环中执行 f，可以在任何 goroutine 中调用，事件循环已经退出时返回 false，f 不会执行
+func (lp *loop) exec(f func()) bool {
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	if lp.closed {
+		return false
+	}
+	lp.tasks = append(lp.tasks, f)
+	// 持有锁唤醒，保证唤醒时管道还没有被 run 关闭
+	lp.p.wake()
+	return true
+}
+
+// add 把新连接交给事件循环
+func (lp *loop) add(c *Conn) {
+	c.loop = lp
+	ok := lp.exec(func() {
+		if lp.stopped {
+			c.mu.Lock()
+			c.closed = true
+			c.mu.Unlock()
+			closeFD(c.fd)
+			return
+		}
+		if err := lp.p.add(c.fd); err != nil {
+			closeFD(c.fd)
+			lp.s.logger().Errorf("reactor: 注册连接失败 %v", err)
+			return
+		}
+		lp.conns[c.fd] = c
+		if lp.s.OnOpen != nil {
+			lp.s.OnOpen(c)
+		}
+	})
+	if !ok {
+		closeFD(c.fd)
+	}
+}
+
+func (lp *loop) run() {
+	defer lp.s.wg.Done()
+	defer lp.shutdown()
+	for !lp.stopped {
+		if err := lp.p.wait(lp.handle); err != nil {
+			lp.s.logger().Errorf("reactor: 等待事件失败 %v", err)
+			lp.stop()
+			return
+		}
+		lp.mu.Lock()
+		tasks := lp.tasks
+		lp.tasks = nil
+		lp.mu.Unlock()
+		for _, f := range tasks {
+			f()
+		}
+	}
+}
+
+// shutdown 事件循环退出时调用，之后 exec 不再接受任务，已经提交的任务执行完之后关闭 poller
+func (lp *loop) shutdown() {
+	lp.mu.Lock()
+	lp.closed = true
+	tasks := lp.tasks
+	lp.tasks = nil
+	lp.mu.Unlock()
+	// 事件循环已经停止，这些任务只会关闭连接
+	for _, f := range tasks {
+		f()
+	}
+	lp.p.close()
+}
+
+// stop 关闭所有连接，事件循环在这一轮事件处理完后退出
+func (lp *loop) stop() {
+	for _, c := range lp.conns {
+		lp.closeConn(c, netx.ErrServerClosed)
+	}
+	lp.stopped = true
+}
+
+func (lp *loop) handle(fd int, readable, writable bool) {
+	c, ok := lp.conns[fd]
+	if !ok {
+		return
+	}
+	if writable {
+		if err := c.flush(); err != nil {
+			lp.closeConn(c, err)
+			return
+		}
+		if c.eof && c.pending() == 0 {
+			lp.closeConn(c, io.EOF)
+			return
+		}
+	}
+	if readable {
+		lp.read(c)
+	}
+}
+
+// read 读取可用的数据，拆出完整的消息交给 Handler，剩余不完整的部分保存到 c.inbuf
+func (lp *loop) read(c *Conn) {
+	n, err := readFD(c.fd, lp.buf)
+	if err == errAgain {
+		return
+	}
+	if n == 0 || err != nil {
+		if err == nil {
+			if c.eof {
+				return
+			}
+			if lp.drain(c) {
+				return
+			}
+			err = io.EOF
+		}
+		lp.closeConn(c, err)
+		return
+	}
+
+	data := lp.buf[:n]
+	if len(c.inbuf) > 0 {
+		c.inbuf = append(c.inbuf, data...)
+		data = c.inbuf
+	}
+	for len(data) > 0 {
+		advance, msg, err := lp.split(data, false)
+		if err != nil {
+			lp.closeConn(c, err)
+			return
+		}
+		if advance == 0 {
+			break
+		}
+		data = data[advance:]
+		if msg != nil && lp.s.Handler != nil {
+			lp.s.Handler(c, msg)
+		}
+	}
+	if len(data) == 0 {
+		// 空闲连接不保留缓冲区
+		c.inbuf = nil
+		return
+	}
+	c.inbuf = append(c.inbuf[:0], data...)
+}
+
+// drain 对方关闭了写的一端而还有缓存的数据没有写完时，停止监听可读事件，等 handle 写完之后再关闭，
+// 返回 false 表示可以直接关闭
+func (lp *loop) drain(c *Conn) bool {
+	if c.pending() == 0 || lp.p.stopRead(c.fd) != nil {
+		return false
+	}
+	c.eof = true
+	return true
+}
+
+func (lp *loop) closeConn(c *Conn, err error) {
+	// fd 可能已经被关闭并分配给了新的连接
+	if lp.conns[c.fd] != c {
+		return
+	}
+	// 对方只是关闭了写的一端时还能收到数据，尽量把缓存的数据写完
+	c.flush()
+	c.mu.Lock()
+	c.closed = true
+	c.outbuf = nil
+	c.mu.Unlock()
+
+	delete(lp.conns, c.fd)
+	lp.p.del(c.fd)
+	closeFD(c.fd)
+	if lp.s.OnClose != nil {
+		lp.s.OnClose(c, err)
+	}
+}
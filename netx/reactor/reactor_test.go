@@ -0,0 +1,189 @@
+package reactor
+
+import (
+	"bytes"
+	"errors"
+	"gopractice/netx"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// startServer 在随机端口上启动 s，测试结束时关闭并检查 Serve 的返回值
+func startServer(t testing.TB, s *Server) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+	t.Cleanup(func() {
+		s.Close()
+		if err := <-done; err != netx.ErrServerClosed {
+			t.Errorf("Serve() = %v, want %v", err, netx.ErrServerClosed)
+		}
+	})
+	return l.Addr().String()
+}
+
+// echoServer 把收到的消息原样发回，收到 "quit" 时回复后关闭连接
+func echoServer(closed chan<- error) *Server {
+	return &Server{
+		Loops: 2,
+		Split: netx.NewFrameScanner(16 << 20).Split,
+		Handler: func(c *Conn, msg []byte) {
+			b, _ := netx.Encode(msg)
+			c.Write(b)
+			if string(msg) == "quit" {
+				c.Close()
+			}
+		},
+		OnClose: func(c *Conn, err error) {
+			if closed != nil {
+				closed <- err
+			}
+		},
+	}
+}
+
+func TestEcho(t *testing.T) {
+	closed := make(chan error, 4)
+	addr := startServer(t, echoServer(closed))
+
+	c, err := netx.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	// 超过读缓冲区的消息需要多次读取才能拼出来，回复也写不进一次 write
+	big := bytes.Repeat([]byte("0123456789"), 800_000)
+	for _, msg := range [][]byte{[]byte("hello"), big, []byte("world")} {
+		if err := c.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+		c.Conn().SetReadDeadline(time.Now().Add(5 * time.Second))
+		got, err := c.Recv()
+		if err != nil || !bytes.Equal(got, msg) {
+			t.Fatalf("echo %d bytes = %d bytes, %v", len(msg), len(got), err)
+		}
+	}
+
+	// 多个消息在一次写入中到达
+	var batch []byte
+	for _, s := range []string{"a", "b", "c"} {
+		b, _ := netx.Encode([]byte(s))
+		batch = append(batch, b...)
+	}
+	c.Conn().Write(batch)
+	for _, want := range []string{"a", "b", "c"} {
+		if got, err := c.Recv(); err != nil || string(got) != want {
+			t.Errorf("Recv() = %q, %v, want %q", got, err, want)
+		}
+	}
+
+	// 服务端 Close 之前写入的回复也能收到
+	c.Send([]byte("quit"))
+	if got, err := c.Recv(); err != nil || string(got) != "quit" {
+		t.Errorf("Recv() = %q, %v, want quit", got, err)
+	}
+	if _, err := c.Recv(); err != io.EOF {
+		t.Errorf("Recv() after quit = %v, want io.EOF", err)
+	}
+	if err := <-closed; err != nil {
+		t.Errorf("OnClose err = %v, want nil", err)
+	}
+
+	// 客户端关闭连接
+	c2, err := netx.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2.Close()
+	if err := <-closed; err != io.EOF {
+		t.Errorf("OnClose err = %v, want io.EOF", err)
+	}
+}
+
+func TestFrameTooLarge(t *testing.T) {
+	closed := make(chan error, 1)
+	s := echoServer(closed)
+	s.Split = nil
+	addr := startServer(t, s)
+
+	c, err := netx.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Send(make([]byte, netx.DefaultMaxFrameSize+1))
+	if err := <-closed; !errors.Is(err, netx.ErrFrameTooLarge) {
+		t.Errorf("OnClose err = %v, want %v", err, netx.ErrFrameTooLarge)
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	s := echoServer(nil)
+	opened := make(chan *Conn, 1)
+	s.OnOpen = func(c *Conn) { opened <- c }
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+
+	c, err := netx.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	conn := <-opened
+
+	s.Close()
+	if err := <-done; err != netx.ErrServerClosed {
+		t.Errorf("Serve() = %v, want %v", err, netx.ErrServerClosed)
+	}
+	c.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c.Recv(); err != io.EOF {
+		t.Errorf("Recv() after Close = %v, want io.EOF", err)
+	}
+
+	conn.Close()
+	if conn.loop.exec(func() {}) {
+		t.Error("exec() accepted a task after the loop stopped")
+	}
+}
+
+// 对方关闭写的一端之后，缓存中还没有写完的回复仍然会发送完
+func TestHalfCloseFlush(t *testing.T) {
+	big := bytes.Repeat([]byte("x"), 8<<20)
+	s := &Server{
+		Split: netx.NewFrameScanner(0).Split,
+		Handler: func(c *Conn, msg []byte) {
+			b, _ := netx.Encode(big)
+			c.Write(b)
+		},
+	}
+	addr := startServer(t, s)
+	c, err := netx.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Send([]byte("big"))
+	if err := c.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	// 等服务端读到 EOF 之后再开始读
+	time.Sleep(50 * time.Millisecond)
+	c.Conn().SetReadDeadline(time.Now().Add(5 * time.Second))
+	got, err := c.Recv()
+	if err != nil || len(got) != len(big) {
+		t.Fatalf("Recv() = %d bytes, %v, want %d bytes", len(got), err, len(big))
+	}
+	if _, err := c.Recv(); err != io.EOF {
+		t.Errorf("Recv() after the reply = %v, want io.EOF", err)
+	}
+}
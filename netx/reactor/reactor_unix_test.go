@@ -0,0 +1,54 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package reactor
+
+import (
+	"gopractice/netx"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// 事件循环退出之后唤醒管道的编号可能分配给了别的文件，Conn.Close 不能再写入
+func TestCloseAfterLoopStopped(t *testing.T) {
+	s := echoServer(nil)
+	opened := make(chan *Conn, 1)
+	s.OnOpen = func(c *Conn) { opened <- c }
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+
+	c, err := netx.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	conn := <-opened
+	s.Close()
+	<-done
+
+	wakeW := conn.loop.p.wakeW
+	var reuse *os.File
+	for i := 0; i < 16 && reuse == nil; i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		defer w.Close()
+		if int(w.Fd()) == wakeW {
+			reuse = r
+		}
+	}
+	conn.Close()
+	if reuse != nil {
+		reuse.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		if n, _ := reuse.Read(make([]byte, 1)); n != 0 {
+			t.Error("Conn.Close() wrote to a reused file descriptor")
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// ErrReusePortUnsupported 当前平台不支持 SO_REUSEPORT，例如 Windows
+var ErrReusePortUnsupported = errors.New("netx: SO_REUSEPORT not supported on this platform")
+
+// ListenReusePort 开启 SO_REUSEPORT 后在 addr 上打开 n 个监听，由内核把新连接分配给它们，
+// 每个监听可以在单独的 goroutine 中 Accept，在多核机器上提高 Accept 的吞吐量。
+// addr 的端口为 0 时，所有监听都使用第一个监听分配到的端口。
+func ListenReusePort(network, addr string, n int) ([]net.Listener, error) {
+	if !reusePortSupported {
+		return nil, ErrReusePortUnsupported
+	}
+	lc := net.ListenConfig{Control: reusePort}
+	ls := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		l, err := lc.Listen(context.Background(), network, addr)
+		if err != nil {
+			for _, l := range ls {
+				l.Close()
+			}
+			return nil, err
+		}
+		if i == 0 {
+			addr = l.Addr().String()
+		}
+		ls = append(ls, l)
+	}
+	return ls, nil
+}
@@ -0,0 +1,6 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+
+package netx
+
+// soReusePort Linux 上 SO_REUSEPORT 的值，syscall 包没有导出
+const soReusePort = 0xf
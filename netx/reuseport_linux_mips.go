@@ -0,0 +1,6 @@
+//go:build linux && (mips || mipsle || mips64 || mips64le)
+
+package netx
+
+// soReusePort MIPS 上的 SO_REUSEPORT 与其他架构不同
+const soReusePort = 0x200
@@ -0,0 +1,115 @@
+package netx
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestListenReusePort(t *testing.T) {
+	ls, err := ListenReusePort("tcp", "127.0.0.1:0", 4)
+	if err == ErrReusePortUnsupported {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted := make([]int64, len(ls))
+	var wg sync.WaitGroup
+	for i, l := range ls {
+		defer l.Close()
+		if l.Addr().String() != ls[0].Addr().String() {
+			t.Fatalf("listener %d on %v, want %v", i, l.Addr(), ls[0].Addr())
+		}
+		wg.Add(1)
+		go func(i int, l net.Listener) {
+			defer wg.Done()
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+				atomic.AddInt64(&accepted[i], 1)
+				c.Close()
+			}
+		}(i, l)
+	}
+
+	const n = 64
+	for i := 0; i < n; i++ {
+		c, err := net.Dial("tcp", ls[0].Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		total, used := int64(0), 0
+		for i := range accepted {
+			if v := atomic.LoadInt64(&accepted[i]); v > 0 {
+				total += v
+				used++
+			}
+		}
+		if total == n {
+			// 内核按连接的四元组做哈希，64 个连接全部落在同一个监听上的概率可以忽略
+			if used < 2 {
+				t.Errorf("all %d connections accepted by one listener", n)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("accepted %d of %d connections", total, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, l := range ls {
+		l.Close()
+	}
+	wg.Wait()
+}
+
+func TestServerReusePort(t *testing.T) {
+	s := NewTCPServer("127.0.0.1:0", HandlerFunc(func(ctx context.Context, conn *Conn) {
+		for {
+			msg, err := conn.Recv(ctx)
+			if err != nil {
+				return
+			}
+			conn.Send(msg)
+		}
+	}))
+	s.ReusePort = 4
+	done := make(chan error, 1)
+	go func() { done <- s.ListenAndServe() }()
+	var addr net.Addr
+	for deadline := time.Now().Add(time.Second); addr == nil; addr = s.ListenAddr() {
+		if time.Now().After(deadline) {
+			t.Fatal("server did not start listening")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	for i := 0; i < 16; i++ {
+		c, err := Dial(addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Send([]byte("ping"))
+		if msg, err := c.Recv(); err != nil || string(msg) != "ping" {
+			t.Errorf("Recv() = %q, %v, want ping", msg, err)
+		}
+		c.Close()
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != ErrServerClosed {
+		t.Errorf("ListenAndServe() = %v, want %v", err, ErrServerClosed)
+	}
+}
@@ -0,0 +1,117 @@
+package netx
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"gopractice/netx/codec"
+	"sync"
+)
+
+// MsgTypeSize 消息类型占用的字节数
+const MsgTypeSize = 2
+
+var (
+	// ErrUnknownMsgType Router 中没有注册这个消息类型
+	ErrUnknownMsgType = errors.New("netx: unknown message type")
+	// ErrShortMsg 消息的长度不足以放下消息类型
+	ErrShortMsg = errors.New("netx: message too short for type")
+)
+
+// AppendMsg 把消息类型（uint16，小端序）和 payload 追加到 dst，得到 Router 处理的消息
+func AppendMsg(dst []byte, typ uint16, payload []byte) []byte {
+	var h [MsgTypeSize]byte
+	binary.LittleEndian.PutUint16(h[:], typ)
+	dst = append(dst, h[:]...)
+	return append(dst, payload...)
+}
+
+// SplitMsg 从消息中解出消息类型和 payload，payload 引用 msg 的内存
+func SplitMsg(msg []byte) (typ uint16, payload []byte, err error) {
+	if len(msg) < MsgTypeSize {
+		return 0, nil, ErrShortMsg
+	}
+	return binary.LittleEndian.Uint16(msg), msg[MsgTypeSize:], nil
+}
+
+// Router 按消息类型把消息分发给注册的 FrameHandler，回复带上相同的消息类型
+// Router 实现了 FrameHandler 和 Handler：直接作为 Handler 时逐个处理消息；
+// 交给 HandleCalls 时每个请求并发处理，回复还会带上请求 ID。
+type Router struct {
+	// Codec HandleValue 注册的处理函数解码请求、编码回复使用的编码方式，
+	// 为 nil 时使用连接的编码（WithCodec 或者 Server.Codec），都没有设置时使用 codec.JSON
+	Codec codec.Codec
+
+	mu       sync.RWMutex
+	handlers map[uint16]FrameHandler
+}
+
+// NewRouter 创建一个空的 Router
+func NewRouter() *Router {
+	return &Router{handlers: make(map[uint16]FrameHandler)}
+}
+
+// Handle 注册 typ 类型消息的处理函数，重复注册时覆盖之前的
+func (r *Router) Handle(typ uint16, h FrameHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[typ] = h
+}
+
+// HandleFunc 与 Handle 相同，接收普通函数
+func (r *Router) HandleFunc(typ uint16, f func(ctx context.Context, req []byte) ([]byte, error)) {
+	r.Handle(typ, FrameHandlerFunc(f))
+}
+
+// HandleValue 注册 typ 类型消息的处理函数，请求和回复由 r.Codec 或者连接的编码编解码，h 返回的回复为 nil 时不回复。
+// 消息内容无法解码时返回错误，不会调用 h。
+func HandleValue[Req, Resp any](r *Router, typ uint16, h func(ctx context.Context, req *Req) (*Resp, error)) {
+	r.HandleFunc(typ, func(ctx context.Context, b []byte) ([]byte, error) {
+		req := new(Req)
+		c := r.codec(ctx)
+		if err := c.Decode(b, req); err != nil {
+			return nil, fmt.Errorf("netx: decode message type %d: %w", typ, err)
+		}
+		resp, err := h(ctx, req)
+		if err != nil || resp == nil {
+			return nil, err
+		}
+		return c.Encode(resp)
+	})
+}
+
+func (r *Router) codec(ctx context.Context) codec.Codec {
+	if r.Codec != nil {
+		return r.Codec
+	}
+	if c, ok := ctx.Value(codecKey{}).(codec.Codec); ok {
+		return c
+	}
+	return codec.JSON
+}
+
+// HandleFrame 解出消息类型并分发，处理函数返回的回复不为 nil 时加上相同的消息类型
+func (r *Router) HandleFrame(ctx context.Context, msg []byte) ([]byte, error) {
+	typ, req, err := SplitMsg(msg)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	h, ok := r.handlers[typ]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownMsgType
+	}
+
+	resp, err := h.HandleFrame(ctx, req)
+	if err != nil || resp == nil {
+		return nil, err
+	}
+	return AppendMsg(make([]byte, 0, MsgTypeSize+len(resp)), typ, resp), nil
+}
+
+// ServeConn 逐个读取消息并分发，见 HandleFrames
+func (r *Router) ServeConn(ctx context.Context, conn *Conn) {
+	HandleFrames(r).ServeConn(ctx, conn)
+}
@@ -0,0 +1,107 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"gopractice/netx/codec"
+	"testing"
+	"time"
+)
+
+const (
+	msgEcho uint16 = iota + 1
+	msgLen
+	msgUnknown
+)
+
+func newTestRouter() *Router {
+	r := NewRouter()
+	r.HandleFunc(msgEcho, func(ctx context.Context, req []byte) ([]byte, error) {
+		return req, nil
+	})
+	r.HandleFunc(msgLen, func(ctx context.Context, req []byte) ([]byte, error) {
+		return []byte{byte(len(req))}, nil
+	})
+	return r
+}
+
+func TestRouterHandleFrame(t *testing.T) {
+	r := newTestRouter()
+	ctx := context.Background()
+
+	resp, err := r.HandleFrame(ctx, AppendMsg(nil, msgLen, []byte("hello")))
+	typ, body, _ := SplitMsg(resp)
+	if err != nil || typ != msgLen || string(body) != "\x05" {
+		t.Errorf("HandleFrame(msgLen) = %d %q, %v", typ, body, err)
+	}
+	if _, err := r.HandleFrame(ctx, AppendMsg(nil, msgUnknown, nil)); err != ErrUnknownMsgType {
+		t.Errorf("HandleFrame(unknown) = %v, want %v", err, ErrUnknownMsgType)
+	}
+	if _, err := r.HandleFrame(ctx, []byte{1}); err != ErrShortMsg {
+		t.Errorf("HandleFrame(short) = %v, want %v", err, ErrShortMsg)
+	}
+}
+
+// Router 交给 HandleCalls 时，回复同时带上消息类型和请求 ID
+func TestRouterWithCalls(t *testing.T) {
+	addr := startTCPServer(t, NewTCPServer("", HandleCalls(newTestRouter())))
+	c, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := NewCallClient(c)
+	defer cc.Close()
+
+	resp, err := cc.Call(context.Background(), AppendMsg(nil, msgEcho, []byte("ping")))
+	typ, body, _ := SplitMsg(resp)
+	if err != nil || typ != msgEcho || string(body) != "ping" {
+		t.Errorf("Call(msgEcho) = %d %q, %v", typ, body, err)
+	}
+
+	_, err = cc.Call(context.Background(), AppendMsg(nil, msgUnknown, nil))
+	var re *RemoteError
+	if !errors.As(err, &re) || re.Msg != ErrUnknownMsgType.Error() {
+		t.Errorf("Call(unknown) = %v, want RemoteError", err)
+	}
+}
+
+type greetReq struct {
+	Name string
+}
+
+type greetResp struct {
+	Greeting string
+}
+
+// 切换编码只需要修改 Server.Codec，Router 和处理函数不变
+func TestRouterHandleValue(t *testing.T) {
+	for _, c := range []codec.Codec{codec.JSON, codec.Gob, codec.MsgPack} {
+		r := NewRouter()
+		HandleValue(r, msgEcho, func(ctx context.Context, req *greetReq) (*greetResp, error) {
+			return &greetResp{Greeting: "hello " + req.Name}, nil
+		})
+		s := NewTCPServer("", r)
+		s.Codec = c
+		addr := startTCPServer(t, s)
+
+		cl, err := Dial(addr, WithCodec(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cl.SendMsg(msgEcho, greetReq{Name: "kwok"}); err != nil {
+			t.Fatal(err)
+		}
+		var resp greetResp
+		if typ, err := cl.RecvMsg(&resp); err != nil || typ != msgEcho || resp.Greeting != "hello kwok" {
+			t.Errorf("%T: RecvMsg() = %d, %+v, %v", c, typ, resp, err)
+		}
+
+		// 无法解码的消息返回错误，连接被关闭
+		cl.Send(AppendMsg(nil, msgEcho, []byte{0xc1}))
+		cl.Conn().SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := cl.Recv(); err == nil {
+			t.Errorf("%T: Recv() after invalid message succeeded", c)
+		}
+		cl.Close()
+	}
+}
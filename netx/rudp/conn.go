@@ -0,0 +1,496 @@
+package rudp
+
+import (
+	"encoding/binary"
+	"gopractice/netx"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// segment 已发送还没有确认的数据包
+type segment struct {
+	seq      uint32
+	pkt      []byte
+	sentAt   time.Time
+	deadline time.Time // 超过这个时间没有确认就重传
+	retrans  int
+}
+
+// inbound 收到的乱序数据包
+type inbound struct {
+	data []byte
+	fin  bool
+}
+
+// Conn 一个可靠的 UDP 连接，实现了 net.Conn
+// Write 把数据拆成不超过 MaxPayload 的数据包发送，窗口满时阻塞；Read 按发送的顺序返回数据，
+// 对方关闭后返回 io.EOF。可以同时在一个 goroutine 中 Read、另一个 goroutine 中 Write。
+type Conn struct {
+	pc     net.PacketConn
+	raddr  net.Addr
+	conv   uint32
+	cfg    config
+	onDone func() // 连接彻底结束后调用，释放 PacketConn 或者从 Listener 中移除
+
+	mu          sync.Mutex
+	sndNext     uint32
+	inflight    []*segment // 按序号排列
+	rttvar      time.Duration
+	rcvNext     uint32
+	ooo         map[uint32]inbound
+	rbuf        []byte
+	eof         bool // 收到了对方的 FIN
+	closed      bool // 本端调用了 Close
+	lingerUntil time.Time
+	finished    bool
+	err         error
+	rdeadline   time.Time
+	wdeadline   time.Time
+	stats       Stats
+
+	readable chan struct{}
+	writable chan struct{}
+	kick     chan struct{} // 唤醒重传的 goroutine 重新计算超时
+	done     chan struct{}
+}
+
+func newConn(pc net.PacketConn, raddr net.Addr, conv uint32, cfg config, onDone func()) *Conn {
+	c := &Conn{
+		pc:       pc,
+		raddr:    raddr,
+		conv:     conv,
+		cfg:      cfg,
+		onDone:   onDone,
+		ooo:      make(map[uint32]inbound),
+		readable: make(chan struct{}, 1),
+		writable: make(chan struct{}, 1),
+		kick:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	c.stats.RTO = initialRTO
+	go c.retransmitLoop()
+	return c
+}
+
+// Dial 连接 addr 上的 Listener，本地使用一个新的 UDP 端口
+func Dial(addr string, opts ...Option) (*Conn, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(pc, raddr, opts...), nil
+}
+
+// NewConn 在 pc 上建立到 raddr 的连接，pc 只能给这一个连接使用，连接结束时关闭
+// 不需要握手，第一个数据包到达时对方的 Listener 才会 Accept 到这个连接。
+func NewConn(pc net.PacketConn, raddr net.Addr, opts ...Option) *Conn {
+	c := newConn(pc, raddr, randomConv(), newConfig(opts), func() { pc.Close() })
+	go c.readLoop()
+	return c
+}
+
+// readLoop 客户端连接读取自己的 PacketConn
+func (c *Conn) readLoop() {
+	buf := make([]byte, netx.DefaultPacketSize)
+	for {
+		n, addr, err := c.pc.ReadFrom(buf)
+		if err != nil {
+			c.mu.Lock()
+			c.fail(err)
+			c.mu.Unlock()
+			return
+		}
+		conv, flags, seq, payload, ok := parsePacket(buf[:n])
+		if !ok || conv != c.conv || !sameAddr(addr, c.raddr) {
+			continue
+		}
+		c.input(flags, seq, payload)
+	}
+}
+
+// Read 读取按序到达的数据，对方关闭并且数据读完后返回 io.EOF
+func (c *Conn) Read(b []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		switch {
+		case c.closed:
+			c.mu.Unlock()
+			return 0, net.ErrClosed
+		case len(c.rbuf) > 0:
+			n := copy(b, c.rbuf)
+			c.rbuf = c.rbuf[n:]
+			if len(c.rbuf) == 0 {
+				c.rbuf = nil
+			}
+			c.mu.Unlock()
+			return n, nil
+		case c.eof:
+			c.mu.Unlock()
+			return 0, io.EOF
+		case c.err != nil:
+			err := c.err
+			c.mu.Unlock()
+			return 0, err
+		}
+		deadline := c.rdeadline
+		c.mu.Unlock()
+		if err := wait(c.readable, deadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Write 把 b 拆成数据包发送，返回时数据包已经发出，但不代表对方已经收到
+func (c *Conn) Write(b []byte) (int, error) {
+	n := 0
+	for len(b) > 0 {
+		c.mu.Lock()
+		switch {
+		case c.closed:
+			c.mu.Unlock()
+			return n, net.ErrClosed
+		case c.err != nil:
+			err := c.err
+			c.mu.Unlock()
+			return n, err
+		case len(c.inflight) >= c.cfg.window:
+			deadline := c.wdeadline
+			c.mu.Unlock()
+			if err := wait(c.writable, deadline); err != nil {
+				return n, err
+			}
+			continue
+		}
+		chunk := b
+		if len(chunk) > MaxPayload {
+			chunk = chunk[:MaxPayload]
+		}
+		c.send(flagData, chunk)
+		c.mu.Unlock()
+		n += len(chunk)
+		b = b[len(chunk):]
+	}
+	return n, nil
+}
+
+// Close 发送 FIN 后立即返回，之后的 Read 和 Write 返回 net.ErrClosed。
+// 没有确认的数据和 FIN 在后台继续重传，直到全部确认或者对方超时。
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	c.closed = true
+	c.rbuf = nil
+	signal(c.readable)
+	signal(c.writable)
+	if c.err != nil {
+		c.finish()
+		return nil
+	}
+	c.lingerUntil = time.Now().Add(closeLinger)
+	c.send(flagFin, nil)
+	return nil
+}
+
+// LocalAddr 返回本地地址
+func (c *Conn) LocalAddr() net.Addr {
+	return c.pc.LocalAddr()
+}
+
+// RemoteAddr 返回对方的地址
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.raddr
+}
+
+// SetDeadline 同时设置读和写的截止时间
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+// SetReadDeadline 设置 Read 的截止时间，超时返回 os.ErrDeadlineExceeded
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.rdeadline = t
+	c.mu.Unlock()
+	signal(c.readable)
+	return nil
+}
+
+// SetWriteDeadline 设置 Write 等待窗口的截止时间，超时返回 os.ErrDeadlineExceeded
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.wdeadline = t
+	c.mu.Unlock()
+	signal(c.writable)
+	return nil
+}
+
+// Stats 返回连接的统计信息
+func (c *Conn) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// send 发送一个新的数据包并记录下来等待确认，调用时持有 mu
+func (c *Conn) send(flags byte, payload []byte) {
+	now := time.Now()
+	s := &segment{
+		seq:      c.sndNext,
+		pkt:      appendPacket(nil, c.conv, flags, c.sndNext, payload),
+		sentAt:   now,
+		deadline: now.Add(c.stats.RTO),
+	}
+	c.sndNext++
+	c.inflight = append(c.inflight, s)
+	c.stats.Sent++
+	c.pc.WriteTo(s.pkt, c.raddr)
+	signal(c.kick)
+}
+
+// input 处理收到的一个数据包
+func (c *Conn) input(flags byte, seq uint32, payload []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.finished {
+		return
+	}
+	if flags&flagAck != 0 {
+		if len(payload) >= 4 {
+			c.handleAck(seq, binary.BigEndian.Uint32(payload))
+		}
+		return
+	}
+	c.handleData(seq, flags&flagFin != 0, payload)
+}
+
+// handleAck 移除被确认的数据包，没有重传过的数据包用来估算 RTT（Karn 算法）
+func (c *Conn) handleAck(seq, cum uint32) {
+	now := time.Now()
+	kept := c.inflight[:0]
+	for _, s := range c.inflight {
+		if s.seq == seq || seqLess(s.seq, cum) {
+			if s.seq == seq && s.retrans == 0 {
+				c.updateRTT(now.Sub(s.sentAt))
+			}
+			continue
+		}
+		kept = append(kept, s)
+	}
+	for i := len(kept); i < len(c.inflight); i++ {
+		c.inflight[i] = nil
+	}
+	c.inflight = kept
+	signal(c.writable)
+	if c.closed && len(c.inflight) == 0 {
+		signal(c.kick)
+	}
+}
+
+// updateRTT 按 RFC 6298 更新 SRTT、RTTVAR 和 RTO
+func (c *Conn) updateRTT(r time.Duration) {
+	if c.stats.SRTT == 0 {
+		c.stats.SRTT = r
+		c.rttvar = r / 2
+	} else {
+		d := c.stats.SRTT - r
+		if d < 0 {
+			d = -d
+		}
+		c.rttvar = (3*c.rttvar + d) / 4
+		c.stats.SRTT = (7*c.stats.SRTT + r) / 8
+	}
+	c.stats.RTO = clampRTO(c.stats.SRTT + 4*c.rttvar)
+}
+
+// backoff 重传 n 次之后的超时时间
+func backoff(rto time.Duration, n int) time.Duration {
+	for ; n > 0 && rto < maxRTO; n-- {
+		rto *= 2
+	}
+	return clampRTO(rto)
+}
+
+func clampRTO(d time.Duration) time.Duration {
+	if d < minRTO {
+		return minRTO
+	}
+	if d > maxRTO {
+		return maxRTO
+	}
+	return d
+}
+
+// handleData 确认并缓存数据包，按序号把连续的数据交付给 Read，重复的数据包只回复 ACK
+func (c *Conn) handleData(seq uint32, fin bool, payload []byte) {
+	switch {
+	case seqLess(seq, c.rcvNext):
+		c.stats.Duplicates++
+	case !seqLess(seq, c.rcvNext+uint32(c.cfg.window)):
+		// 超出接收窗口，不确认，等对方重传
+		return
+	default:
+		if _, ok := c.ooo[seq]; ok {
+			c.stats.Duplicates++
+			break
+		}
+		if len(c.rbuf) >= c.cfg.window*MaxPayload {
+			// 接收缓冲区满了，不确认，对方重传时再接收
+			return
+		}
+		c.ooo[seq] = inbound{data: append([]byte(nil), payload...), fin: fin}
+		for {
+			p, ok := c.ooo[c.rcvNext]
+			if !ok {
+				break
+			}
+			delete(c.ooo, c.rcvNext)
+			c.rcvNext++
+			if p.fin {
+				c.eof = true
+				if c.closed {
+					signal(c.kick)
+				}
+			} else if !c.closed && !c.eof {
+				c.rbuf = append(c.rbuf, p.data...)
+			}
+		}
+		signal(c.readable)
+	}
+
+	var cum [4]byte
+	binary.BigEndian.PutUint32(cum[:], c.rcvNext)
+	c.pc.WriteTo(appendPacket(nil, c.conv, flagAck, seq, cum[:]), c.raddr)
+}
+
+// retransmitLoop 在最早的超时时间到达时重传，直到连接结束
+func (c *Conn) retransmitLoop() {
+	t := time.NewTimer(time.Hour)
+	defer t.Stop()
+	for {
+		c.mu.Lock()
+		next := c.tick(time.Now())
+		c.mu.Unlock()
+
+		if !t.Stop() {
+			select {
+			case <-t.C:
+			default:
+			}
+		}
+		if !next.IsZero() {
+			t.Reset(time.Until(next))
+		}
+		select {
+		case <-t.C:
+		case <-c.kick:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// tick 重传超时的数据包，返回下一次需要检查的时间，为零值时等待唤醒。调用时持有 mu。
+func (c *Conn) tick(now time.Time) time.Time {
+	if c.finished {
+		return time.Time{}
+	}
+	var next time.Time
+	for _, s := range c.inflight {
+		if s.deadline.After(now) {
+			if next.IsZero() || s.deadline.Before(next) {
+				next = s.deadline
+			}
+			continue
+		}
+		if s.retrans >= c.cfg.maxRetransmits {
+			c.fail(ErrPeerTimeout)
+			return time.Time{}
+		}
+		// 每个数据包各自退避，每重传一次超时加倍，不影响其他数据包的 RTO
+		s.retrans++
+		s.deadline = now.Add(backoff(c.stats.RTO, s.retrans))
+		c.stats.Retransmits++
+		c.pc.WriteTo(s.pkt, c.raddr)
+		if next.IsZero() || s.deadline.Before(next) {
+			next = s.deadline
+		}
+	}
+
+	if c.closed && len(c.inflight) == 0 {
+		if c.eof || !now.Before(c.lingerUntil) {
+			c.finish()
+			return time.Time{}
+		}
+		next = c.lingerUntil
+	}
+	return next
+}
+
+// fail 连接出错，之后的 Read 和 Write 返回 err。调用时持有 mu。
+func (c *Conn) fail(err error) {
+	if c.finished {
+		return
+	}
+	if c.err == nil {
+		c.err = err
+	}
+	c.finish()
+}
+
+// finish 停止重传并释放连接占用的资源。调用时持有 mu。
+func (c *Conn) finish() {
+	if c.finished {
+		return
+	}
+	c.finished = true
+	if c.err == nil {
+		c.err = net.ErrClosed
+	}
+	c.inflight = nil
+	c.ooo = nil
+	close(c.done)
+	signal(c.readable)
+	signal(c.writable)
+	if c.onDone != nil {
+		c.onDone()
+	}
+}
+
+// signal 非阻塞地通知等待的一方重新检查状态
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// wait 等待 ch 的通知，deadline 不为零值时最多等到 deadline
+func wait(ch chan struct{}, deadline time.Time) error {
+	if deadline.IsZero() {
+		<-ch
+		return nil
+	}
+	d := time.Until(deadline)
+	if d <= 0 {
+		return os.ErrDeadlineExceeded
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ch:
+		return nil
+	case <-t.C:
+		return os.ErrDeadlineExceeded
+	}
+}
@@ -0,0 +1,157 @@
+package rudp
+
+import (
+	"gopractice/netx"
+	"net"
+	"sync"
+	"time"
+)
+
+// acceptBacklog 等待 Accept 的连接数上限，超过后新连接的第一个数据包被丢弃，由对方重传
+const acceptBacklog = 128
+
+// tombstoneTTL 连接结束后记住它的时间，期间收到的数据包直接丢弃，
+// 不会因为迟到的序号为 0 的重传包再创建一个新的连接。对方按最大的 RTO 重传完所有次数也不会超过这个时间。
+const tombstoneTTL = DefaultMaxRetransmits * maxRTO
+
+// connKey 同一个地址上的不同连接用 conv 区分
+type connKey struct {
+	addr string
+	conv uint32
+}
+
+// Listener 在一个 UDP 端口上接受可靠连接，实现了 net.Listener，可以直接交给 netx.Server.Serve
+// 所有连接共用同一个 PacketConn，Close 时这些连接也会一起失效。
+type Listener struct {
+	pc   net.PacketConn
+	opts []Option
+
+	mu      sync.Mutex
+	conns   map[connKey]*Conn
+	dead    map[connKey]time.Time // 最近结束的连接，值是过期时间
+	backlog chan *Conn
+	done    chan struct{}
+	closed  bool
+}
+
+// Listen 监听 udp 地址 addr
+func Listen(addr string, opts ...Option) (*Listener, error) {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewListener(pc, opts...), nil
+}
+
+// NewListener 在 pc 上接受连接，opts 应用到每个 Accept 到的连接
+func NewListener(pc net.PacketConn, opts ...Option) *Listener {
+	l := &Listener{
+		pc:      pc,
+		opts:    opts,
+		conns:   make(map[connKey]*Conn),
+		dead:    make(map[connKey]time.Time),
+		backlog: make(chan *Conn, acceptBacklog),
+		done:    make(chan struct{}),
+	}
+	go l.readLoop()
+	return l
+}
+
+// Accept 等待下一个连接，Listener 关闭后返回 net.ErrClosed
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.backlog:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close 关闭 PacketConn，所有连接的 Read 和 Write 返回 net.ErrClosed
+func (l *Listener) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return net.ErrClosed
+	}
+	l.closed = true
+	close(l.done)
+	conns := make([]*Conn, 0, len(l.conns))
+	for _, c := range l.conns {
+		conns = append(conns, c)
+	}
+	l.mu.Unlock()
+
+	err := l.pc.Close()
+	for _, c := range conns {
+		c.mu.Lock()
+		c.fail(net.ErrClosed)
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// Addr 返回监听的地址
+func (l *Listener) Addr() net.Addr {
+	return l.pc.LocalAddr()
+}
+
+// readLoop 读取数据包并按地址和 conv 分发给对应的连接，序号为 0 的数据包创建新的连接
+func (l *Listener) readLoop() {
+	buf := make([]byte, netx.DefaultPacketSize)
+	for {
+		n, addr, err := l.pc.ReadFrom(buf)
+		if err != nil {
+			l.Close()
+			return
+		}
+		conv, flags, seq, payload, ok := parsePacket(buf[:n])
+		if !ok {
+			continue
+		}
+		if c := l.conn(addr, conv, flags, seq); c != nil {
+			c.input(flags, seq, payload)
+		}
+	}
+}
+
+// conn 找到数据包所属的连接，需要时创建新的连接
+func (l *Listener) conn(addr net.Addr, conv uint32, flags byte, seq uint32) *Conn {
+	key := connKey{addr: addr.String(), conv: conv}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.conns[key]; ok {
+		return c
+	}
+	if until, ok := l.dead[key]; ok {
+		if time.Now().Before(until) {
+			return nil
+		}
+		delete(l.dead, key)
+	}
+	if l.closed || flags != flagData || seq != 0 || len(l.backlog) == cap(l.backlog) {
+		return nil
+	}
+	var c *Conn
+	c = newConn(l.pc, addr, conv, newConfig(l.opts), func() { l.remove(key, c) })
+	l.conns[key] = c
+	l.backlog <- c
+	return c
+}
+
+// remove 连接结束后从 Listener 中移除并记下 tombstoneTTL，同时清理已经过期的记录，在连接的 mu 中调用
+func (l *Listener) remove(key connKey, c *Conn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conns[key] != c {
+		return
+	}
+	delete(l.conns, key)
+	now := time.Now()
+	for k, until := range l.dead {
+		if !now.Before(until) {
+			delete(l.dead, k)
+		}
+	}
+	l.dead[key] = now.Add(tombstoneTTL)
+}
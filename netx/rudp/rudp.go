@@ -0,0 +1,126 @@
+// Package rudp 在 UDP 上实现可靠、有序的字节流
+// 每个数据包带有序号，接收方对每个数据包单独回复 ACK（同时带上累计确认的序号），
+// 发送方根据 RTT 估算重传超时（RFC 6298），超时未确认的数据包重传；接收方丢弃重复的数据包，
+// 乱序到达的数据包缓存起来按序交付。Conn 实现了 net.Conn，Listener 实现了 net.Listener，
+// 因此 netx.Client、netx.Server 以及各种 FrameCodec 可以不加修改地运行在 UDP 上。
+// 需要加密时把 netx.SecurePacketConn 交给 NewListener 和 NewConn。
+package rudp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+// ErrPeerTimeout 数据包重传了 MaxRetransmits 次仍然没有收到确认，对方可能已经断开
+var ErrPeerTimeout = errors.New("rudp: peer not responding")
+
+// MaxPayload 每个数据包最多携带的数据，加上包头不超过常见的 MTU，避免 IP 分片
+const MaxPayload = 1200
+
+const (
+	// DefaultWindow 默认同时在途（已发送未确认）的数据包个数上限
+	DefaultWindow = 256
+	// DefaultMaxRetransmits 默认一个数据包最多重传的次数
+	DefaultMaxRetransmits = 10
+)
+
+// 重传超时的初始值和上下限，本地网络的 RTT 很小，下限比 RFC 6298 建议的 1 秒小得多
+const (
+	initialRTO = 200 * time.Millisecond
+	minRTO     = 20 * time.Millisecond
+	maxRTO     = 3 * time.Second
+)
+
+// closeLinger 本端关闭后等待对方关闭的最长时间，期间继续确认对方的数据包
+const closeLinger = 2 * time.Second
+
+// 数据包格式（大端序）：conv(4) flags(1) seq(4) payload
+// conv 由发起连接的一方随机生成，同一个地址上的多个连接用它区分；
+// ACK 包的 seq 是被确认的数据包序号，payload 是 4 字节的累计确认序号，表示之前的数据包都已收到。
+const (
+	flagData byte = 1 << iota
+	flagAck
+	flagFin
+)
+
+const headerSize = 9
+
+func appendPacket(dst []byte, conv uint32, flags byte, seq uint32, payload []byte) []byte {
+	var h [headerSize]byte
+	binary.BigEndian.PutUint32(h[0:], conv)
+	h[4] = flags
+	binary.BigEndian.PutUint32(h[5:], seq)
+	return append(append(dst, h[:]...), payload...)
+}
+
+func parsePacket(b []byte) (conv uint32, flags byte, seq uint32, payload []byte, ok bool) {
+	if len(b) < headerSize {
+		return 0, 0, 0, nil, false
+	}
+	return binary.BigEndian.Uint32(b), b[4], binary.BigEndian.Uint32(b[5:]), b[headerSize:], true
+}
+
+// seqLess 考虑回绕的序号比较
+func seqLess(a, b uint32) bool {
+	return int32(a-b) < 0
+}
+
+func randomConv() uint32 {
+	var b [4]byte
+	rand.Read(b[:])
+	return binary.BigEndian.Uint32(b[:])
+}
+
+// sameAddr 比较两个地址，监听 IPv6 通配地址时收到的 IPv4 地址是映射后的 16 字节形式
+func sameAddr(a, b net.Addr) bool {
+	ua, ok1 := a.(*net.UDPAddr)
+	ub, ok2 := b.(*net.UDPAddr)
+	if ok1 && ok2 {
+		return ua.Port == ub.Port && ua.IP.Equal(ub.IP)
+	}
+	return a.String() == b.String()
+}
+
+// Option 创建连接时的可选配置，两端可以不同
+type Option func(c *config)
+
+type config struct {
+	window         int
+	maxRetransmits int
+}
+
+// WithWindow 设置同时在途的数据包个数上限，默认为 DefaultWindow，也是接收方缓存乱序数据包的上限。
+// 窗口满时 Write 阻塞，直到有数据包被确认。
+func WithWindow(n int) Option {
+	return func(c *config) {
+		c.window = n
+	}
+}
+
+// WithMaxRetransmits 设置一个数据包最多重传的次数，默认为 DefaultMaxRetransmits，
+// 超过后连接失败，Read 和 Write 返回 ErrPeerTimeout
+func WithMaxRetransmits(n int) Option {
+	return func(c *config) {
+		c.maxRetransmits = n
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{window: DefaultWindow, maxRetransmits: DefaultMaxRetransmits}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// Stats 连接的统计信息
+type Stats struct {
+	Sent        uint64        // 发送的数据包数，不包括重传和 ACK
+	Retransmits uint64        // 重传的次数
+	Duplicates  uint64        // 收到并丢弃的重复数据包数
+	SRTT        time.Duration // 平滑后的 RTT，还没有样本时为 0
+	RTO         time.Duration // 当前的重传超时，不包括重传时的退避
+}
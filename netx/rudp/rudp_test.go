@@ -0,0 +1,254 @@
+package rudp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"gopractice/netx"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lossyConn 按固定的规律丢弃和重复发出的数据包，模拟不可靠的网络
+type lossyConn struct {
+	net.PacketConn
+	dropEvery int
+	dupEvery  int
+
+	mu sync.Mutex
+	n  int
+}
+
+func (c *lossyConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.mu.Lock()
+	c.n++
+	n := c.n
+	c.mu.Unlock()
+	if c.dropEvery > 0 && n%c.dropEvery == 0 {
+		return len(p), nil
+	}
+	if c.dupEvery > 0 && n%c.dupEvery == 0 {
+		c.PacketConn.WriteTo(p, addr)
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+func listenPacket(t *testing.T) net.PacketConn {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pc
+}
+
+func TestSeqLess(t *testing.T) {
+	tests := []struct {
+		a, b uint32
+		want bool
+	}{
+		{1, 2, true},
+		{2, 1, false},
+		{1, 1, false},
+		{0xfffffffe, 1, true}, // 回绕
+		{1, 0xfffffffe, false},
+	}
+	for _, tt := range tests {
+		if got := seqLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("seqLess(%#x, %#x) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// TestNetxOverRUDP netx.Server 和 netx.Client 不加修改地运行在 rudp 上
+func TestNetxOverRUDP(t *testing.T) {
+	l, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := netx.NewTCPServer("", netx.HandlerFunc(func(ctx context.Context, conn *netx.Conn) {
+		for {
+			msg, err := conn.Recv(ctx)
+			if err != nil {
+				return
+			}
+			conn.Send(msg)
+		}
+	}))
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+	defer func() {
+		s.Close()
+		if err := <-done; err != netx.ErrServerClosed {
+			t.Errorf("Serve() = %v, want %v", err, netx.ErrServerClosed)
+		}
+	}()
+
+	conn, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := netx.NewClient(conn)
+	defer c.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	big := bytes.Repeat([]byte("0123456789"), 20_000)
+	for _, msg := range [][]byte{[]byte("hello"), big, []byte("world")} {
+		if err := c.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.Recv()
+		if err != nil || !bytes.Equal(got, msg) {
+			t.Fatalf("echo %d bytes = %d bytes, %v", len(msg), len(got), err)
+		}
+	}
+}
+
+func TestLossyLink(t *testing.T) {
+	l := NewListener(&lossyConn{PacketConn: listenPacket(t), dropEvery: 5, dupEvery: 7})
+	defer l.Close()
+	client := NewConn(&lossyConn{PacketConn: listenPacket(t), dropEvery: 4, dupEvery: 6}, l.Addr(), WithWindow(32))
+
+	data := make([]byte, 300_000)
+	rand.New(rand.NewSource(1)).Read(data)
+	writeErr := make(chan error, 1)
+	go func() {
+		_, err := client.Write(data)
+		client.Close()
+		writeErr <- err
+	}()
+
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.SetReadDeadline(time.Now().Add(20 * time.Second))
+	got, err := io.ReadAll(server)
+	if err != nil {
+		t.Fatalf("ReadAll() = %d bytes, %v", len(got), err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("received %d bytes, not equal to the %d bytes sent", len(got), len(data))
+	}
+	if err := <-writeErr; err != nil {
+		t.Fatal(err)
+	}
+
+	if st := client.Stats(); st.Retransmits == 0 || st.SRTT == 0 {
+		t.Errorf("client stats = %+v, want retransmits and an RTT sample", st)
+	}
+	if st := server.(*Conn).Stats(); st.Duplicates == 0 {
+		t.Errorf("server stats = %+v, want duplicates to be dropped", st)
+	}
+}
+
+func TestPeerTimeout(t *testing.T) {
+	silent := listenPacket(t)
+	defer silent.Close()
+	c := NewConn(listenPacket(t), silent.LocalAddr(), WithMaxRetransmits(2))
+	defer c.Close()
+
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	c.SetReadDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.Read(make([]byte, 10)); err != ErrPeerTimeout {
+		t.Errorf("Read() = %v, want %v", err, ErrPeerTimeout)
+	}
+	if _, err := c.Write([]byte("hello")); err != ErrPeerTimeout {
+		t.Errorf("Write() after timeout = %v, want %v", err, ErrPeerTimeout)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	l, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	c, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	_, err = c.Read(make([]byte, 10))
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		t.Errorf("Read() = %v, want timeout", err)
+	}
+
+	c.Close()
+	if _, err := c.Read(make([]byte, 10)); err != net.ErrClosed {
+		t.Errorf("Read() after Close = %v, want %v", err, net.ErrClosed)
+	}
+}
+
+// TestOverSecurePacketConn rudp 运行在加密的 UDP 上，对上层透明
+func TestOverSecurePacketConn(t *testing.T) {
+	key := netx.KeyFromPassphrase("rudp")
+	secure := func() *netx.SecurePacketConn {
+		sc, err := netx.NewSecurePacketConn(listenPacket(t), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sc
+	}
+	l := NewListener(secure())
+	defer l.Close()
+	client := NewConn(secure(), l.Addr())
+	defer client.Close()
+
+	data := make([]byte, 50_000)
+	rand.New(rand.NewSource(2)).Read(data)
+	go func() {
+		client.Write(data)
+		client.Close()
+	}()
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.SetReadDeadline(time.Now().Add(10 * time.Second))
+	got, err := io.ReadAll(server)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("ReadAll() = %d bytes, %v, want the %d bytes sent", len(got), err, len(data))
+	}
+}
+
+// TestLateRetransmit 连接结束后迟到的第一个数据包不会再创建连接
+func TestLateRetransmit(t *testing.T) {
+	pc := listenPacket(t)
+	l := NewListener(pc)
+	defer l.Close()
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9}
+	key := connKey{addr: addr.String(), conv: 1}
+
+	c := l.conn(addr, 1, flagData, 0)
+	if c == nil {
+		t.Fatal("conn() for a new connection = nil")
+	}
+	c.mu.Lock()
+	c.fail(net.ErrClosed)
+	c.mu.Unlock()
+	if c := l.conn(addr, 1, flagData, 0); c != nil {
+		t.Error("conn() after the connection finished created a new connection")
+	}
+
+	// 过期之后同一个 conv 可以再次连接
+	l.mu.Lock()
+	l.dead[key] = time.Now()
+	l.mu.Unlock()
+	if c := l.conn(addr, 1, flagData, 0); c == nil {
+		t.Error("conn() after the tombstone expired = nil")
+	}
+	if n := len(l.backlog); n != 2 {
+		t.Errorf("backlog has %d connections, want 2", n)
+	}
+}
@@ -0,0 +1,75 @@
+package netx
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// ErrFrameTooLarge 消息长度超过允许的最大值，见 FrameScanner、WithMaxFrameSize 和 WithMaxDecompressedSize
+var ErrFrameTooLarge = errors.New("netx: frame too large")
+
+// DefaultMaxFrameSize FrameScanner 默认允许的最大消息长度
+const DefaultMaxFrameSize = bufio.MaxScanTokenSize - HeaderSize
+
+// FrameScanner 按长度前缀拆包的 bufio.SplitFunc
+// 数据不完整时（包括只收到了一部分长度头）请求更多数据，由 bufio.Scanner 在多次 Read 之间累积，
+// 只有完整的消息才会被返回。长度头中的长度超过 maxSize 时直接返回 ErrFrameTooLarge，
+// 不会为了一个非法的长度去缓存大量数据。
+type FrameScanner struct {
+	framer  *Framer
+	maxSize int
+}
+
+// NewFrameScanner 创建一个 FrameScanner，maxSize<=0 时使用 DefaultMaxFrameSize，
+// opts 与 NewFramer 相同，需要和发送方的 Framer 保持一致
+func NewFrameScanner(maxSize int, opts ...FramerOption) *FrameScanner {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxFrameSize
+	}
+	return &FrameScanner{framer: NewFramer(opts...), maxSize: maxSize}
+}
+
+// Split 实现 bufio.SplitFunc，返回的 token 是去掉长度头的消息
+func (s *FrameScanner) Split(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if len(data) < HeaderSize {
+		if atEOF && len(data) > 0 {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+
+	length, err := s.framer.parseHeader(data)
+	if err != nil {
+		return 0, nil, err
+	}
+	if length > s.maxSize {
+		return 0, nil, ErrFrameTooLarge
+	}
+
+	n := HeaderSize + length + s.framer.extraSize()
+	if len(data) < n {
+		if atEOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+	token, err = s.framer.unpack(data[HeaderSize:n])
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, token, nil
+}
+
+// NewScanner 返回一个从 r 中逐个读取消息的 bufio.Scanner，缓冲区足够容纳 maxSize 的消息
+// Scan 返回的消息在下一次 Scan 时会被覆盖，需要保留时应当复制。
+func (s *FrameScanner) NewScanner(r io.Reader) *bufio.Scanner {
+	sc := bufio.NewScanner(r)
+	initial := 4096
+	if s.maxSize+HeaderSize < initial {
+		initial = s.maxSize + HeaderSize
+	}
+	sc.Buffer(make([]byte, 0, initial), s.maxSize+HeaderSize+s.framer.extraSize())
+	sc.Split(s.Split)
+	return sc
+}
@@ -0,0 +1,65 @@
+package netx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFrameScanner(t *testing.T) {
+	msgs := []string{"hello", "", strings.Repeat("x", 5000), "你好，世界"}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		f := NewFramer(WithByteOrder(order))
+		var buf bytes.Buffer
+		for _, m := range msgs {
+			f.WriteFrame(&buf, []byte(m))
+		}
+
+		// 长度头和消息都被拆成一个字节一个字节到达
+		sc := NewFrameScanner(0, WithByteOrder(order)).NewScanner(oneByteReader{&buf})
+		var got []string
+		for sc.Scan() {
+			got = append(got, sc.Text())
+		}
+		if err := sc.Err(); err != nil {
+			t.Fatalf("%v: Err() = %v", order, err)
+		}
+		if len(got) != len(msgs) {
+			t.Fatalf("%v: got %d frames, want %d", order, len(got), len(msgs))
+		}
+		for i := range msgs {
+			if got[i] != msgs[i] {
+				t.Errorf("%v: frame %d = %.16q, want %.16q", order, i, got[i], msgs[i])
+			}
+		}
+	}
+}
+
+func TestFrameScannerErrors(t *testing.T) {
+	tooLarge, _ := Encode(make([]byte, 11))
+	full, _ := Encode([]byte("hello"))
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"too large", tooLarge, ErrFrameTooLarge},
+		// 只有长度头就能判断，不需要等待消息数据
+		{"too large header only", tooLarge[:HeaderSize], ErrFrameTooLarge},
+		{"partial header", full[:2], io.ErrUnexpectedEOF},
+		{"partial payload", full[:len(full)-1], io.ErrUnexpectedEOF},
+		{"invalid length", []byte{0xff, 0xff, 0xff, 0xff}, ErrInvalidLength},
+	}
+
+	for _, tt := range tests {
+		sc := NewFrameScanner(10).NewScanner(bytes.NewReader(tt.data))
+		for sc.Scan() {
+			t.Errorf("%s: unexpected frame %q", tt.name, sc.Bytes())
+		}
+		if err := sc.Err(); err != tt.want {
+			t.Errorf("%s: Err() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package netx
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// SecureOverhead SecurePacketConn 给每个数据报增加的字节数：12 字节的 nonce 和 16 字节的认证标签
+	SecureOverhead = secureNonceSize + secureTagSize
+
+	secureNonceSize = 12
+	secureTagSize   = 16
+
+	// replayWindowSize 重放窗口的大小，比窗口内最大的序号小这么多以上的数据报被当作重放丢弃
+	replayWindowSize = 64
+	// maxSecureDatagram 底层连接上一个数据报的最大长度，ReadFrom 按它分配缓冲区，
+	// 保证完整地读出数据报再解密，p 太小时截断的是解密后的内容
+	maxSecureDatagram = 64 << 10
+	// maxSecurePeers 最多记录重放窗口的发送方数
+	maxSecurePeers = 4096
+	// secureIdleTimeout 发送方超过这么久没有发来数据报时，它的重放窗口才可以被新的发送方替换
+	secureIdleTimeout = 5 * time.Minute
+)
+
+// SecureStats SecurePacketConn 丢弃的数据报数
+type SecureStats struct {
+	AuthFailures uint64 // 格式错误、密钥不一致或者被篡改，解密失败
+	Replays      uint64 // 重复或者太旧，被重放窗口拒绝；本端自己发出的数据报被反射回来也算
+	TooManyPeers uint64 // 来自新的发送方，但重放窗口已满并且都还在使用
+}
+
+// SecurePacketConn 用 AES-GCM 加密每个数据报的 net.PacketConn，双方使用相同的预共享密钥
+// 数据报格式：nonce(12) ciphertext tag(16)。nonce 由本端随机生成的 4 字节发送方编号和 8 字节递增的序号组成，
+// nonce 同时作为附加认证数据，接收方按发送方编号维护 64 个序号的重放窗口，与对方地址无关，换一个地址重放同样会被拒绝。
+// 双方使用同一个密钥，发送方编号等于本端编号的数据报是本端自己发出后被反射回来的，同样丢弃。
+// 解密失败或者重放的数据报被直接丢弃，ReadFrom 继续等待下一个，对方看不到任何响应。
+//
+// 发送方编号是随机的 4 字节，每个 SecurePacketConn（包括重启后新建的）都会生成一个新的编号，序号从 1 开始。
+// 共用一个密钥的发送方越多，两个编号相同的概率越大（约 9000 个时达到 1%），编号相同时 GCM 的 nonce 会重复，
+// 加密和认证都不再可靠，双方的数据报也会互相被当作重放丢弃。发送方很多或者进程经常重启时需要定期更换密钥。
+//
+// 最多为 4096 个发送方维护重放窗口，满了之后只替换超过 5 分钟没有数据报的窗口，否则新的发送方的数据报被丢弃，
+// 记在 SecureStats.TooManyPeers 中。窗口被替换的发送方再次出现时重新开始记录，之前抓到的它的数据报可以被重放一次。
+// SecurePacketConn 可以交给 UDPServer.Serve、rudp.NewListener 或 rudp.NewConn，让上层协议运行在加密的 UDP 上。
+// 标准库没有 DTLS，这里没有密钥协商和前向保密，适合学习和内网使用。
+type SecurePacketConn struct {
+	net.PacketConn
+	aead   cipher.AEAD
+	sender [4]byte
+
+	wmu sync.Mutex
+	seq uint64
+
+	rmu sync.Mutex
+	buf []byte
+
+	mu      sync.Mutex
+	windows map[[4]byte]*replayWindow // 以发送方编号为键
+	stats   SecureStats
+}
+
+// replayWindow 滑动窗口，bits 的第 i 位表示序号 max-i 是否已经收到
+type replayWindow struct {
+	max  uint64
+	bits uint64
+	last time.Time // 最后一次收到通过认证的数据报的时间
+}
+
+// NewSecurePacketConn 用 key 加密 pc 上收发的数据报，key 的长度必须是 16、24 或 32 字节（AES-128/192/256），
+// 用口令作为密钥时先用 KeyFromPassphrase 转换
+func NewSecurePacketConn(pc net.PacketConn, key []byte) (*SecurePacketConn, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	c := &SecurePacketConn{
+		PacketConn: pc,
+		aead:       aead,
+		windows:    make(map[[4]byte]*replayWindow),
+	}
+	if _, err := rand.Read(c.sender[:]); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// KeyFromPassphrase 把口令转换为 32 字节的 AES-256 密钥
+// 只是 SHA-256 摘要，没有加盐和多轮迭代，口令需要足够长。
+func KeyFromPassphrase(passphrase string) []byte {
+	sum := sha256.Sum256([]byte(passphrase))
+	return sum[:]
+}
+
+// WriteTo 加密 p 后发送给 addr，成功时返回 len(p)
+func (c *SecurePacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	c.wmu.Lock()
+	c.seq++
+	var nonce [secureNonceSize]byte
+	copy(nonce[:], c.sender[:])
+	binary.BigEndian.PutUint64(nonce[4:], c.seq)
+	c.wmu.Unlock()
+
+	b := make([]byte, secureNonceSize, SecureOverhead+len(p))
+	copy(b, nonce[:])
+	b = c.aead.Seal(b, nonce[:], p, nonce[:])
+	if _, err := c.PacketConn.WriteTo(b, addr); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// ReadFrom 读取下一个通过认证的数据报，解密后的内容写入 p，p 太小时超出的部分被截断
+func (c *SecurePacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	if c.buf == nil {
+		c.buf = make([]byte, maxSecureDatagram)
+	}
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(c.buf)
+		if err != nil {
+			return 0, addr, err
+		}
+		msg, ok := c.open(c.buf[:n])
+		if !ok {
+			continue
+		}
+		return copy(p, msg), addr, nil
+	}
+}
+
+// Stats 返回丢弃的数据报数
+func (c *SecurePacketConn) Stats() SecureStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// open 解密并检查重放，就地解密，返回的内容引用 b 的内存
+func (c *SecurePacketConn) open(b []byte) ([]byte, bool) {
+	if len(b) < SecureOverhead {
+		c.count(&c.stats.AuthFailures)
+		return nil, false
+	}
+	nonce := b[:secureNonceSize]
+	msg, err := c.aead.Open(b[secureNonceSize:secureNonceSize], nonce, b[secureNonceSize:], nonce)
+	if err != nil {
+		c.count(&c.stats.AuthFailures)
+		return nil, false
+	}
+
+	var sender [4]byte
+	copy(sender[:], nonce[:4])
+	seq := binary.BigEndian.Uint64(nonce[4:])
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sender == c.sender {
+		c.stats.Replays++
+		return nil, false
+	}
+	w := c.windows[sender]
+	if w == nil {
+		if len(c.windows) >= maxSecurePeers && !c.evictIdle(now) {
+			c.stats.TooManyPeers++
+			return nil, false
+		}
+		w = new(replayWindow)
+		c.windows[sender] = w
+	}
+	if !w.accept(seq) {
+		c.stats.Replays++
+		return nil, false
+	}
+	w.last = now
+	return msg, true
+}
+
+// evictIdle 删除最久没有数据报的重放窗口，它空闲的时间不到 secureIdleTimeout 时不删除并返回 false
+func (c *SecurePacketConn) evictIdle(now time.Time) bool {
+	var oldest *[4]byte
+	var last time.Time
+	for sender, w := range c.windows {
+		if oldest == nil || w.last.Before(last) {
+			sender := sender
+			oldest, last = &sender, w.last
+		}
+	}
+	if oldest == nil || now.Sub(last) < secureIdleTimeout {
+		return false
+	}
+	delete(c.windows, *oldest)
+	return true
+}
+
+func (c *SecurePacketConn) count(n *uint64) {
+	c.mu.Lock()
+	*n++
+	c.mu.Unlock()
+}
+
+// accept 序号第一次出现并且没有落在窗口之外时记录它并返回 true，序号从 1 开始
+func (w *replayWindow) accept(seq uint64) bool {
+	if seq == 0 {
+		return false
+	}
+	if seq > w.max {
+		if shift := seq - w.max; shift >= replayWindowSize {
+			w.bits = 1
+		} else {
+			w.bits = w.bits<<shift | 1
+		}
+		w.max = seq
+		return true
+	}
+	d := w.max - seq
+	if d >= replayWindowSize || w.bits&(1<<d) != 0 {
+		return false
+	}
+	w.bits |= 1 << d
+	return true
+}
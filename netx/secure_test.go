@@ -0,0 +1,201 @@
+package netx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReplayWindow(t *testing.T) {
+	var w replayWindow
+	steps := []struct {
+		seq  uint64
+		want bool
+	}{
+		{0, false},
+		{1, true},
+		{3, true},
+		{2, true}, // 乱序
+		{2, false},
+		{3, false},
+		{100, true},
+		{37, true}, // 窗口内最旧的序号
+		{36, false},
+		{37, false},
+		{500, true},
+		{100, false}, // 落在窗口之外
+	}
+	for _, s := range steps {
+		if got := w.accept(s.seq); got != s.want {
+			t.Errorf("accept(%d) = %v, want %v", s.seq, got, s.want)
+		}
+	}
+}
+
+// securePair 返回使用同一个密钥的两端，b 的对方地址是 a
+func securePair(t *testing.T, key []byte) (a, b *SecurePacketConn) {
+	t.Helper()
+	for _, p := range []**SecurePacketConn{&a, &b} {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { pc.Close() })
+		if *p, err = NewSecurePacketConn(pc, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a, b
+}
+
+func readTimeout(pc net.PacketConn, d time.Duration) ([]byte, error) {
+	pc.SetReadDeadline(time.Now().Add(d))
+	buf := make([]byte, 2048)
+	n, _, err := pc.ReadFrom(buf)
+	return buf[:n], err
+}
+
+func TestSecurePacketConn(t *testing.T) {
+	key := KeyFromPassphrase("correct horse battery staple")
+	a, b := securePair(t, key)
+
+	msg := []byte("hello over udp")
+	if n, err := a.WriteTo(msg, b.LocalAddr()); n != len(msg) || err != nil {
+		t.Fatalf("WriteTo() = %d, %v", n, err)
+	}
+	if got, err := readTimeout(b, time.Second); err != nil || !bytes.Equal(got, msg) {
+		t.Fatalf("ReadFrom() = %q, %v, want %q", got, err, msg)
+	}
+
+	// 在底层连接上抓到的数据报不包含明文，原样重放、篡改或者用错误的密钥发送都会被丢弃
+	raw, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	a.WriteTo(msg, raw.LocalAddr())
+	captured, err := readTimeout(raw, time.Second)
+	if err != nil || bytes.Contains(captured, msg) || len(captured) != len(msg)+SecureOverhead {
+		t.Fatalf("captured %q, %v, want %d bytes of ciphertext", captured, err, len(msg)+SecureOverhead)
+	}
+	// 重放窗口只按发送方编号区分，从别的地址重放同样会被拒绝
+	a.PacketConn.WriteTo(captured, b.LocalAddr())
+	raw.WriteTo(captured, b.LocalAddr())
+	tampered := append([]byte(nil), captured...)
+	tampered[len(tampered)-1] ^= 1
+	a.PacketConn.WriteTo(tampered, b.LocalAddr())
+	other, err := NewSecurePacketConn(raw, KeyFromPassphrase("wrong"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.WriteTo(msg, b.LocalAddr())
+
+	// 第一次收到 captured 时它的序号还没有出现过，所以会被接受一次
+	if got, err := readTimeout(b, time.Second); err != nil || !bytes.Equal(got, msg) {
+		t.Fatalf("ReadFrom() = %q, %v, want %q", got, err, msg)
+	}
+	if _, err := readTimeout(b, 100*time.Millisecond); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("ReadFrom() = %v, want the rest to be dropped", err)
+	}
+	if st := b.Stats(); st.Replays != 1 || st.AuthFailures != 2 {
+		t.Errorf("Stats() = %+v, want 1 replay and 2 auth failures", st)
+	}
+}
+
+// sealAs 以 sender 的身份加密序号为 seq 的数据报
+func sealAs(c *SecurePacketConn, sender [4]byte, seq uint64, p []byte) []byte {
+	var nonce [secureNonceSize]byte
+	copy(nonce[:], sender[:])
+	binary.BigEndian.PutUint64(nonce[4:], seq)
+	return c.aead.Seal(nonce[:], nonce[:], p, nonce[:])
+}
+
+// TestSecurePeerLimit 重放窗口满了之后不替换还在使用的窗口，否则攻击者可以清掉某个发送方的窗口再重放它的数据报
+func TestSecurePeerLimit(t *testing.T) {
+	c, err := NewSecurePacketConn(nil, KeyFromPassphrase("peers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	victim := [4]byte{0xff, 0xff, 0xff, 0xff}
+	captured := sealAs(c, victim, 1, []byte("pay"))
+	if _, ok := c.open(append([]byte(nil), captured...)); !ok {
+		t.Fatal("open() rejected the first datagram")
+	}
+	for i := 0; i < maxSecurePeers; i++ {
+		var sender [4]byte
+		binary.BigEndian.PutUint32(sender[:], uint32(i))
+		c.open(sealAs(c, sender, 1, nil))
+	}
+	if _, ok := c.open(append([]byte(nil), captured...)); ok {
+		t.Error("replay accepted after the window table filled up")
+	}
+	if st := c.Stats(); st.TooManyPeers != 1 || st.Replays != 1 {
+		t.Errorf("Stats() = %+v, want 1 rejected peer and 1 replay", st)
+	}
+
+	// 空闲足够久的窗口可以让给新的发送方
+	c.mu.Lock()
+	for sender, w := range c.windows {
+		if sender != victim {
+			w.last = w.last.Add(-secureIdleTimeout)
+			break
+		}
+	}
+	c.mu.Unlock()
+	if _, ok := c.open(sealAs(c, [4]byte{0xff, 0, 0, 0}, 1, nil)); !ok {
+		t.Error("open() rejected a new peer while an idle window exists")
+	}
+	if _, ok := c.open(append([]byte(nil), captured...)); ok {
+		t.Error("replay accepted after evicting an idle window")
+	}
+}
+
+func TestSecurePacketConnBadKey(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	if _, err := NewSecurePacketConn(pc, []byte("short")); err == nil {
+		t.Error("NewSecurePacketConn() with a 5-byte key succeeded")
+	}
+}
+
+// TestSecureReflection 双方共用密钥，把 a 发出的数据报原样发回给 a 不能被接受
+func TestSecureReflection(t *testing.T) {
+	a, b := securePair(t, KeyFromPassphrase("reflect"))
+	if _, err := a.WriteTo([]byte("transfer 100"), b.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	b.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 2048)
+	n, _, err := b.PacketConn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.PacketConn.WriteTo(buf[:n], a.LocalAddr())
+	if got, err := readTimeout(a, 100*time.Millisecond); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("ReadFrom() = %q, %v, want the reflected datagram dropped", got, err)
+	}
+	if st := a.Stats(); st.Replays != 1 {
+		t.Errorf("Stats() = %+v, want 1 replay", st)
+	}
+}
+
+// TestSecureReadFromShortBuffer p 比数据报小时解密之后再截断，数据报不会被当作认证失败丢弃
+func TestSecureReadFromShortBuffer(t *testing.T) {
+	a, b := securePair(t, KeyFromPassphrase("short"))
+	a.WriteTo([]byte("hello world"), b.LocalAddr())
+	b.SetReadDeadline(time.Now().Add(time.Second))
+	p := make([]byte, 5)
+	if n, _, err := b.ReadFrom(p); err != nil || string(p[:n]) != "hello" {
+		t.Errorf("ReadFrom() = %q, %v, want %q", p[:n], err, "hello")
+	}
+	if st := b.Stats(); st.AuthFailures != 0 {
+		t.Errorf("Stats() = %+v, want no auth failures", st)
+	}
+}
@@ -0,0 +1,548 @@
+// Package netx 从 example 中整理出来的可复用的网络编程代码
+package netx
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"gopractice/logx"
+	"gopractice/netx/codec"
+	"gopractice/netx/metrics"
+	"gopractice/netx/ratelimit"
+	"gopractice/netx/workerpool"
+	"net"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrServerClosed Shutdown 之后 Serve 和 ListenAndServe 返回的错误
+var ErrServerClosed = errors.New("netx: Server closed")
+
+// Server tcp 服务端
+// 每个连接在单独的 goroutine 中交给 Handler 处理，Handler 返回后连接会被关闭，
+// Handler 中的 panic 会被恢复并记录到 Logger，不会影响其他连接。
+// 使用 Handler 接口处理连接时见 NewTCPServer。
+type Server struct {
+	// Addr 监听的地址，例如 "127.0.0.1:8001"
+	Addr string
+
+	// Handler 处理一个连接，ctx 在 Handler 返回或者 Server 关闭时被取消
+	Handler func(ctx context.Context, conn net.Conn)
+
+	// BaseContext 返回 Server 的根 Context，为 nil 时使用 context.Background()
+	BaseContext func() context.Context
+
+	// NewContext 从父 Context 派生出可取消的子 Context，为 nil 时使用 context.WithCancel。
+	// contextx/source 中的 Context 与标准库的方法完全一致，替换这个函数就可以让
+	// Server 使用 contextx 的实现，见 netx/ctxnet。
+	NewContext func(parent context.Context) (context.Context, context.CancelFunc)
+
+	// Pool 不为 nil 时连接交给池中的 worker 处理，池满时停止 Accept；
+	// 为 nil 时每个连接启动一个 goroutine。Pool 由调用者负责 Stop。
+	Pool *workerpool.Pool
+
+	// Metrics 不为 nil 时上报连接数、读写字节数和 Handler 耗时，
+	// Handler 中通过 NewClient 收发的消息数也会上报
+	Metrics metrics.Collector
+
+	// Trace 为 true 时 Handler 中通过 NewClient 收发的每个消息都会以 Debug 级别记录到 Logger，
+	// 只需要跟踪单个连接时使用 netx.Trace
+	Trace bool
+
+	// Tracer 不为 nil 时 Handler 中通过 NewClient 收发的每个消息都会交给它，例如 HexDumpTracer 或者 DebugTracer 的返回值
+	Tracer Tracer
+
+	// Codec 消息内容的编码方式，Handler 中通过 NewClient 创建的 Client 的 SendValue 和 RecvValue 默认使用它，
+	// 为 nil 时使用 codec.JSON。切换编码只需要修改这一项，没有设置 Router.Codec 的 Router 也使用它。
+	Codec codec.Codec
+
+	// Logger 为 nil 时不输出日志
+	Logger logx.Logger
+
+	// TLSConfig ServeTLS 和 ListenAndServeTLS 使用的 TLS 配置，可以为 nil
+	TLSConfig *tls.Config
+
+	// ReadTimeout 收到消息的第一个字节之后，读完整个消息的最长时间，0 表示不限制。
+	// 超时配置只对 Handler 中通过 NewClient 收发的消息生效，超时后连接会被关闭。
+	ReadTimeout time.Duration
+
+	// WriteTimeout 发送一个消息的最长时间，0 表示不限制
+	WriteTimeout time.Duration
+
+	// IdleTimeout 等待下一个消息的最长时间，为 0 时使用 ReadTimeout
+	IdleTimeout time.Duration
+
+	// OnTimeout 连接因为超时被关闭之前调用，err 为 ErrIdleTimeout、ErrReadTimeout、ErrWriteTimeout 或 ErrHeartbeatTimeout
+	OnTimeout func(conn net.Conn, err error)
+
+	// HeartbeatInterval 大于 0 时开启心跳，需要与客户端的 WithHeartbeat 一致。
+	// 服务端回复客户端的 PING，连续 HeartbeatMisses 个周期没有收到任何消息时关闭连接；
+	// 与超时配置一样，只对 Handler 中通过 NewClient 收发的消息生效。
+	HeartbeatInterval time.Duration
+
+	// HeartbeatMisses 允许连续错过的心跳次数，为 0 时使用 DefaultHeartbeatMisses
+	HeartbeatMisses int
+
+	// MaxConns 同时处理的最大连接数，0 表示不限制。
+	// 达到上限时默认暂停 Accept，新连接在内核的队列中等待；RejectOverLimit 为 true 时直接关闭新连接。
+	MaxConns int
+
+	// RejectOverLimit 连接数达到 MaxConns 时拒绝新连接，而不是让它们排队
+	RejectOverLimit bool
+
+	// ConnBytesPerSecond 每个连接每秒最多读取和写入的字节数（读写分别计算），0 表示不限制，
+	// 超过时 Read 和 Write 会等待，而不是返回错误
+	ConnBytesPerSecond int
+
+	// ConnFramesPerSecond 每个连接每秒最多接收的消息数，0 表示不限制，
+	// 超过时 Handler 中通过 NewClient 读取消息会返回 ErrFrameRateExceeded
+	ConnFramesPerSecond int
+
+	// BandwidthLimiter 所有连接共享的字节数限制，读和写都消耗令牌，可以为 nil
+	BandwidthLimiter *ratelimit.Limiter
+
+	// ReusePort 大于 1 时 ListenAndServe 开启 SO_REUSEPORT，在 Addr 上打开 ReusePort 个监听，
+	// 每个监听有自己的 Accept 循环，由内核把新连接分配给它们，适合多核机器上大量短连接的场景。
+	// 不支持 SO_REUSEPORT 的平台（例如 Windows）只打开一个监听。
+	ReusePort int
+
+	// BindConnContext 为 true 时连接和 Handler 的 ctx 绑定：从连接读到 EOF 或者其他错误（对方断开、连接被重置）时取消 ctx，
+	// ctx 被取消（包括 Shutdown）时关闭连接，阻塞在读写上的 Handler 和使用 ctx 的下游调用都会尽快返回。
+	// 读到 EOF 之后还要继续写（半关闭）或者 Shutdown 时还要发送消息的 Handler 不能开启。
+	BindConnContext bool
+
+	// EventLog 不为 nil 时接收每个连接的生命周期事件：EventAccepted、TLS 握手完成时的 EventHandshakeDone、
+	// Handler 中通过 NewClient 收到消息时的 EventFrameReceived，以及 Handler 返回时带有原因、耗时和字节数的 EventClosed。
+	// 访问日志使用 NewJSONEventLogger。
+	EventLog EventLogger
+
+	// Auth 不为 nil 时开启认证握手：连接的第一个消息作为认证消息交给 Auth 验证，客户端使用 Client.Authenticate 发送。
+	// 验证通过时 Auth 返回的用户数据保存在 Session 中，Handler 的 ctx 中用 SessionFrom 获取，
+	// 所有会话可以用 Sessions 列出、用 Kick 踢出；Auth 返回错误时把错误信息回复给客户端并关闭连接，不会调用 Handler。
+	Auth func(ctx context.Context, conn net.Conn, msg []byte) (user any, err error)
+
+	// AuthTimeout 等待认证消息的最长时间，为 0 时使用 DefaultAuthTimeout
+	AuthTimeout time.Duration
+
+	// AuthFrameCodec 认证消息和回复的封包方式，为 nil 时使用默认的 Framer，需要与客户端一致
+	AuthFrameCodec FrameCodec
+
+	// AuthMaxSize 认证消息的最大长度，为 0 时使用 DefaultAuthMaxSize。
+	// 认证之前对方还不可信，超过时直接关闭连接，不会为一个很大的长度头分配内存。
+	AuthMaxSize int
+
+	connID    uint64 // 最后分配的连接编号
+	mu        sync.Mutex
+	active    int // 正在处理的连接数
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{} // 已经 Accept 还没有处理完的连接
+	sessions  map[uint64]*Session   // 通过认证的连接，key 为 Session.ID
+	slots     chan struct{}         // MaxConns 大于 0 时限制连接数的信号量
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// ListenAndServe 监听 s.Addr 并开始处理连接
+func (s *Server) ListenAndServe() error {
+	ls, err := s.listen()
+	if err != nil {
+		return err
+	}
+	return s.serveAll(ls, s.Serve)
+}
+
+// Serve 在 l 上接受连接，直到 Shutdown 被调用
+// 可以在多个监听上同时调用，Shutdown 之后返回 ErrServerClosed。
+func (s *Server) Serve(l net.Listener) error {
+	defer l.Close()
+	ctx, err := s.track(l)
+	if err != nil {
+		return err
+	}
+	defer s.untrackListener(l)
+
+	s.logger().Infof("服务端已启动，监听 %s", l.Addr())
+	var tempDelay time.Duration // Accept 临时出错时的等待时间
+	for {
+		if s.slots != nil && !s.RejectOverLimit {
+			// 等待有连接处理完，Shutdown 时 ctx 被取消
+			select {
+			case s.slots <- struct{}{}:
+			case <-ctx.Done():
+				return ErrServerClosed
+			}
+		}
+
+		conn, err := l.Accept()
+		if err != nil {
+			if !s.RejectOverLimit {
+				s.releaseSlot()
+			}
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+			if isTemporary(err) {
+				// 例如文件描述符用完（EMFILE），等待一段时间再重试，避免空转
+				if tempDelay == 0 {
+					tempDelay = 5 * time.Millisecond
+				} else {
+					tempDelay *= 2
+				}
+				if max := time.Second; tempDelay > max {
+					tempDelay = max
+				}
+				s.logger().Errorf("accept failed, err: %v; retrying in %v", err, tempDelay)
+				select {
+				case <-time.After(tempDelay):
+				case <-ctx.Done():
+					return ErrServerClosed
+				}
+				continue
+			}
+			return err
+		}
+		tempDelay = 0
+
+		if s.slots != nil && s.RejectOverLimit {
+			select {
+			case s.slots <- struct{}{}:
+			default:
+				s.logger().Errorf("too many connections, rejected %v", conn.RemoteAddr())
+				if s.Metrics != nil {
+					s.Metrics.IncCounter(metrics.ConnsRejected)
+				}
+				conn.Close()
+				continue
+			}
+		}
+
+		// 与 Shutdown 中的 wg.Wait 互斥，保证 Shutdown 之后不会再有新的 Handler
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			s.releaseSlot()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.wg.Add(1)
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		if s.Pool == nil {
+			go s.serveConn(ctx, conn)
+			continue
+		}
+		if err := s.Pool.Submit(func() { s.serveConn(ctx, conn) }); err != nil {
+			s.untrack(conn)
+			s.releaseSlot()
+			conn.Close()
+			s.wg.Done()
+			return err
+		}
+	}
+}
+
+// ListenAddr 返回正在监听的地址，Serve 之前返回 nil；有多个监听时返回其中一个
+func (s *Server) ListenAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for l := range s.listeners {
+		return l.Addr()
+	}
+	return nil
+}
+
+// listen 打开 s.Addr 上的监听，ReusePort 大于 1 并且平台支持时打开多个
+func (s *Server) listen() ([]net.Listener, error) {
+	if s.ReusePort > 1 {
+		ls, err := ListenReusePort("tcp", s.Addr, s.ReusePort)
+		if err != ErrReusePortUnsupported {
+			return ls, err
+		}
+		s.logger().Infof("当前平台不支持 SO_REUSEPORT，只打开一个监听")
+	}
+	l, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return []net.Listener{l}, nil
+}
+
+// serveAll 在每个监听上运行 serve，都返回之后再返回。
+// 一个监听出错时关闭其他监听，返回这个错误；否则返回 ErrServerClosed。
+func (s *Server) serveAll(ls []net.Listener, serve func(l net.Listener) error) error {
+	if len(ls) == 1 {
+		return serve(ls[0])
+	}
+	errc := make(chan error, len(ls))
+	for _, l := range ls {
+		go func(l net.Listener) {
+			errc <- serve(l)
+		}(l)
+	}
+	var first error
+	for range ls {
+		err := <-errc
+		if err != ErrServerClosed && first == nil {
+			first = err
+			s.closeListeners()
+		}
+	}
+	if first != nil {
+		return first
+	}
+	return ErrServerClosed
+}
+
+// Shutdown 关闭监听，取消所有连接的 Context，并等待所有 Handler 返回。
+// 如果 ctx 先结束，则强制关闭剩下的连接并返回 ctx.Err()，不再等待 Handler 返回。
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.close()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.closeConns()
+		return ctx.Err()
+	}
+}
+
+// Close 立即关闭监听和所有连接，不等待 Handler 返回
+func (s *Server) Close() error {
+	s.close()
+	s.closeConns()
+	return nil
+}
+
+// close 停止 Accept 并取消所有连接的 Context
+func (s *Server) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+}
+
+func (s *Server) closeListeners() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for l := range s.listeners {
+		l.Close()
+	}
+}
+
+func (s *Server) untrackListener(l net.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.listeners, l)
+}
+
+func (s *Server) closeConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+func (s *Server) track(l net.Listener) (context.Context, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrServerClosed
+	}
+
+	if s.listeners == nil {
+		s.listeners = make(map[net.Listener]struct{})
+	}
+	s.listeners[l] = struct{}{}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	if s.MaxConns > 0 && s.slots == nil {
+		s.slots = make(chan struct{}, s.MaxConns)
+	}
+	if s.ctx == nil {
+		// 多个监听共用同一个根 Context
+		base := context.Background()
+		if s.BaseContext != nil {
+			base = s.BaseContext()
+		}
+		s.ctx, s.cancel = s.newContext(base)
+	}
+	return s.ctx, nil
+}
+
+func (s *Server) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) serveConn(parent context.Context, conn net.Conn) {
+	defer s.wg.Done()
+	defer s.releaseSlot()
+	defer s.untrack(conn)
+	defer conn.Close()
+
+	ctx, cancel := s.newContext(parent)
+	defer cancel()
+
+	id := atomic.AddUint64(&s.connID, 1)
+	var ev *connEvents
+	if s.EventLog != nil {
+		ev = newConnEvents(s.EventLog, id, conn)
+		ev.accepted()
+		defer func() {
+			if parent.Err() != nil {
+				ev.fail(ErrServerClosed)
+			}
+			ev.closed()
+		}()
+		if tc, ok := conn.(*tls.Conn); ok {
+			// 正常情况下握手在第一次读写时进行，这里提前握手，以便记录握手的结果
+			if err := tc.HandshakeContext(ctx); err != nil {
+				ev.fail(err)
+				return
+			}
+			state := tc.ConnectionState()
+			ev.handshakeDone(&state, nil)
+		}
+	}
+	if s.BindConnContext {
+		conn = bindContext(ctx, cancel, conn)
+	}
+
+	if s.Metrics != nil {
+		s.Metrics.IncCounter(metrics.ConnsAccepted)
+		s.addActive(1)
+		defer func(start time.Time) {
+			s.Metrics.ObserveLatency(metrics.HandlerLatency, time.Since(start))
+			s.addActive(-1)
+		}(time.Now())
+	}
+	var sc *serverConn
+	if s.Metrics != nil || s.Trace || s.Tracer != nil || s.hasTimeouts() || s.HeartbeatInterval > 0 || s.hasRateLimits() || s.Codec != nil || ev != nil || s.Auth != nil {
+		sc = &serverConn{
+			Conn:      conn,
+			metrics:   metrics.Nop,
+			timeouts:  s.timeouts(),
+			heartbeat: heartbeat{interval: s.HeartbeatInterval, misses: s.HeartbeatMisses},
+			limits:    s.connLimits(),
+			values:    s.Codec,
+			events:    ev,
+			done:      ctx.Done(),
+			stop:      make(chan struct{}),
+		}
+		if s.Metrics != nil {
+			sc.metrics = s.Metrics
+		}
+		if s.Trace {
+			sc.tracer = s.logger()
+		}
+		sc.frameTracer = s.Tracer
+		conn = sc
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			s.logger().Errorf("netx: panic serving %v: %v\n%s", conn.RemoteAddr(), v, debug.Stack())
+			ev.fail(fmt.Errorf("netx: panic: %v", v))
+		}
+	}()
+	if s.Auth != nil {
+		sess, err := s.authenticate(ctx, sc, id)
+		if err != nil {
+			s.logger().Errorf("netx: authenticate %v failed, err: %v", conn.RemoteAddr(), err)
+			ev.fail(err)
+			return
+		}
+		sess.kick = func() {
+			ev.fail(ErrSessionKicked)
+			cancel()
+			conn.Close()
+		}
+		s.addSession(sess)
+		defer s.removeSession(sess)
+		ctx = context.WithValue(ctx, sessionKey{}, sess)
+		ev.handshakeDone(nil, sess)
+	}
+	if s.Handler != nil {
+		if s.Codec != nil {
+			ctx = withCodec(ctx, s.Codec)
+		}
+		s.Handler(ctx, conn)
+	}
+}
+
+// releaseSlot 归还 MaxConns 的名额
+func (s *Server) releaseSlot() {
+	if s.slots != nil {
+		<-s.slots
+	}
+}
+
+// isTemporary 判断 Accept 的错误是否只是暂时的，重试可能成功
+func isTemporary(err error) bool {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return isTemporaryErrno(err)
+}
+
+func (s *Server) hasTimeouts() bool {
+	return s.ReadTimeout > 0 || s.WriteTimeout > 0 || s.IdleTimeout > 0
+}
+
+func (s *Server) timeouts() timeouts {
+	return timeouts{
+		read:      s.ReadTimeout,
+		write:     s.WriteTimeout,
+		idle:      s.IdleTimeout,
+		onTimeout: s.OnTimeout,
+	}
+}
+
+func (s *Server) addActive(delta int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active += delta
+	s.Metrics.SetGauge(metrics.ConnsActive, float64(s.active))
+}
+
+func (s *Server) newContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if s.NewContext != nil {
+		return s.NewContext(parent)
+	}
+	return context.WithCancel(parent)
+}
+
+func (s *Server) logger() logx.Logger {
+	if s.Logger == nil {
+		return logx.Nop
+	}
+	return s.Logger
+}
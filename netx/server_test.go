@@ -0,0 +1,245 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"gopractice/netx/workerpool"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServerShutdownCancelsHandlers(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	exited := make(chan error, 1)
+	s := &Server{
+		Handler: func(ctx context.Context, conn net.Conn) {
+			close(started)
+			<-ctx.Done()
+			exited <- ctx.Err()
+		},
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- s.Serve(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if err := <-exited; err != context.Canceled {
+		t.Errorf("handler ctx.Err() = %v, want %v", err, context.Canceled)
+	}
+	if err := <-serveErr; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve() = %v, want %v", err, ErrServerClosed)
+	}
+}
+
+// 池中只有一个 worker 时，第二个连接要等第一个连接处理完才会被处理
+func TestServerWithPool(t *testing.T) {
+	pool := workerpool.New(1, 0)
+	defer pool.Stop()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handled := make(chan net.Conn, 2)
+	release := make(chan struct{})
+	s := &Server{
+		Pool: pool,
+		Handler: func(ctx context.Context, conn net.Conn) {
+			handled <- conn
+			<-release
+		},
+	}
+	go s.Serve(l)
+	defer s.Shutdown(context.Background())
+
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+	}
+
+	<-handled
+	select {
+	case <-handled:
+		t.Fatal("second connection handled while the pool was full")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-handled
+}
+
+// Handler 不理会 ctx 时，Shutdown 超时后强制关闭连接
+func TestServerShutdownForceClose(t *testing.T) {
+	for _, name := range []string{"Shutdown", "Close"} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		started := make(chan struct{})
+		exited := make(chan error, 1)
+		s := &Server{
+			Handler: func(ctx context.Context, conn net.Conn) {
+				close(started)
+				_, err := conn.Read(make([]byte, 1))
+				exited <- err
+			},
+		}
+		go s.Serve(l)
+
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		<-started
+
+		if name == "Shutdown" {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+				t.Errorf("Shutdown() = %v, want %v", err, context.DeadlineExceeded)
+			}
+		} else {
+			s.Close()
+		}
+
+		select {
+		case err := <-exited:
+			if err == nil {
+				t.Errorf("%s: handler Read succeeded", name)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s: connection was not closed", name)
+		}
+	}
+}
+
+func TestServerBindConnContext(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reading := make(chan struct{}, 2)
+	canceled := make(chan error, 2)
+	s := &Server{
+		BindConnContext: true,
+		Handler: func(ctx context.Context, conn net.Conn) {
+			// 下游调用只关心 ctx，连接由读 goroutine 负责
+			go func() {
+				reading <- struct{}{}
+				io.Copy(io.Discard, conn)
+			}()
+			select {
+			case <-ctx.Done():
+				canceled <- nil
+			case <-time.After(time.Second):
+				canceled <- errors.New("ctx was not canceled")
+			}
+		},
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- s.Serve(l) }()
+
+	// 对方断开时 ctx 被取消
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-reading
+	conn.Close()
+	if err := <-canceled; err != nil {
+		t.Errorf("peer disconnect: %v", err)
+	}
+
+	// Shutdown 取消 ctx 并关闭连接，对方读到 EOF，Shutdown 不需要等到超时
+	conn, err = net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	<-reading
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if err := <-canceled; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("client Read() after Shutdown = %v, want %v", err, io.EOF)
+	}
+	if err := <-serveErr; err != ErrServerClosed {
+		t.Errorf("Serve() = %v, want %v", err, ErrServerClosed)
+	}
+}
+
+func TestServerMaxConns(t *testing.T) {
+	for _, reject := range []bool{false, true} {
+		release := make(chan struct{})
+		handled := make(chan struct{}, 3)
+		s := &Server{
+			MaxConns:        2,
+			RejectOverLimit: reject,
+			Handler: func(ctx context.Context, conn net.Conn) {
+				handled <- struct{}{}
+				<-release
+			},
+		}
+		addr := startTCPServer(t, s)
+
+		var conns []net.Conn
+		for i := 0; i < 3; i++ {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			conns = append(conns, conn)
+		}
+		<-handled
+		<-handled
+		select {
+		case <-handled:
+			t.Fatalf("reject=%v: third connection handled over the limit", reject)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		if reject {
+			// 超过上限的连接被直接关闭
+			conns[2].SetReadDeadline(time.Now().Add(time.Second))
+			if _, err := conns[2].Read(make([]byte, 1)); err == nil {
+				t.Error("rejected connection is still open")
+			}
+			close(release)
+			continue
+		}
+		// 排队的连接在名额空出来之后被处理
+		close(release)
+		select {
+		case <-handled:
+		case <-time.After(time.Second):
+			t.Fatal("queued connection was not handled")
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"gopractice/logx"
+	"gopractice/netx/codec"
+	"gopractice/netx/metrics"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// serverConn Server 交给 Handler 的连接，设置了 Server.Metrics、Server.Trace、Server.Tracer、Server.Codec、Server.EventLog、Server.Auth、超时或者限流时使用
+// 它会统计读写字节数并限制读写速度，在它之上通过 NewClient 创建的 Client 还会统计消息数、
+// 记录消息跟踪日志并应用超时、心跳和消息数限制。
+type serverConn struct {
+	net.Conn
+	metrics     metrics.Collector
+	tracer      logx.Logger
+	frameTracer Tracer
+	timeouts    timeouts
+	heartbeat   heartbeat
+	limits      connLimits
+	values      codec.Codec
+	events      *connEvents
+	unread      []byte // 认证握手时多读到的数据，之后的 Read 先返回它们
+
+	// 限流的等待在连接关闭、Server 关闭或者到达读写超时时结束，不会让 Shutdown 等上几分钟
+	done      <-chan struct{} // 连接的 Context 结束时关闭
+	stop      chan struct{}   // Close 时关闭
+	closeOnce sync.Once
+	rdeadline int64 // 读超时，UnixNano，0 表示没有设置
+	wdeadline int64 // 写超时
+}
+
+func (c *serverConn) Read(b []byte) (int, error) {
+	if len(c.unread) > 0 {
+		n := copy(b, c.unread)
+		c.unread = c.unread[n:]
+		return n, nil
+	}
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.metrics.AddCounter(metrics.BytesRead, int64(n))
+		c.events.addRead(n)
+		// 读之前不知道会读到多少数据，所以读完之后再等待，效果上限制了下一次读取；
+		// 等待被打断时数据已经读到了，照常返回，下一次读取会再次等待
+		c.limits.wait(c.limits.read, n, c.stop, c.done, deadlineOf(&c.rdeadline))
+	}
+	if err != nil && !isTimeout(err) && !errors.Is(err, net.ErrClosed) {
+		// 超时由 Client 判断是否需要关闭连接，连接被本端关闭时原因已经在别处记录
+		c.events.fail(err)
+	}
+	return n, err
+}
+
+func (c *serverConn) Write(b []byte) (int, error) {
+	if err := c.limits.wait(c.limits.write, len(b), c.stop, c.done, deadlineOf(&c.wdeadline)); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.metrics.AddCounter(metrics.BytesWritten, int64(n))
+		c.events.addWritten(n)
+	}
+	return n, err
+}
+
+func (c *serverConn) Close() error {
+	c.closeOnce.Do(func() { close(c.stop) })
+	return c.Conn.Close()
+}
+
+func (c *serverConn) SetDeadline(t time.Time) error {
+	setDeadline(&c.rdeadline, t)
+	setDeadline(&c.wdeadline, t)
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *serverConn) SetReadDeadline(t time.Time) error {
+	setDeadline(&c.rdeadline, t)
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *serverConn) SetWriteDeadline(t time.Time) error {
+	setDeadline(&c.wdeadline, t)
+	return c.Conn.SetWriteDeadline(t)
+}
+
+func setDeadline(p *int64, t time.Time) {
+	var v int64
+	if !t.IsZero() {
+		v = t.UnixNano()
+	}
+	atomic.StoreInt64(p, v)
+}
+
+func deadlineOf(p *int64) time.Time {
+	if v := atomic.LoadInt64(p); v != 0 {
+		return time.Unix(0, v)
+	}
+	return time.Time{}
+}
+
+// CloseWrite 关闭底层连接的写方向，Handler 转发数据时可以通过类型断言半关闭连接
+func (c *serverConn) CloseWrite() error {
+	return closeWrite(c.Conn)
+}
+
+// boundConn 见 Server.BindConnContext，读出错时取消连接的 Context
+type boundConn struct {
+	net.Conn
+	cancel context.CancelFunc
+}
+
+// bindContext 返回读出错时调用 cancel 的连接，ctx 结束时关闭 conn
+// ctx 在 Handler 返回时一定会被取消，所以等待它的 goroutine 不会泄漏。
+func bindContext(ctx context.Context, cancel context.CancelFunc, conn net.Conn) net.Conn {
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	return &boundConn{Conn: conn, cancel: cancel}
+}
+
+func (c *boundConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err != nil && !isTimeout(err) {
+		c.cancel()
+	}
+	return n, err
+}
+
+// CloseWrite 见 serverConn.CloseWrite
+func (c *boundConn) CloseWrite() error {
+	return closeWrite(c.Conn)
+}
+
+// meteredPacketConn 统计发送字节数的 PacketConn，接收的字节数由 UDPServer 统计
+type meteredPacketConn struct {
+	net.PacketConn
+	m metrics.Collector
+}
+
+func (c *meteredPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(b, addr)
+	if n > 0 {
+		c.m.AddCounter(metrics.BytesWritten, int64(n))
+	}
+	return n, err
+}
+
+// collectorOf 返回 conn 上报指标使用的 Collector
+func collectorOf(conn net.Conn) metrics.Collector {
+	if sc, ok := conn.(*serverConn); ok {
+		return sc.metrics
+	}
+	return metrics.Nop
+}
+
+// tracerOf 返回 conn 记录消息跟踪日志使用的 Logger，不需要跟踪时返回 nil
+func tracerOf(conn net.Conn) logx.Logger {
+	if sc, ok := conn.(*serverConn); ok {
+		return sc.tracer
+	}
+	return nil
+}
+
+// frameTracerOf 返回 conn 上的 Client 默认使用的 Tracer
+func frameTracerOf(conn net.Conn) Tracer {
+	if sc, ok := conn.(*serverConn); ok {
+		return sc.frameTracer
+	}
+	return nil
+}
+
+// eventsOf 返回 conn 的生命周期事件，没有设置 Server.EventLog 时返回 nil
+func eventsOf(conn net.Conn) *connEvents {
+	if sc, ok := conn.(*serverConn); ok {
+		return sc.events
+	}
+	return nil
+}
+
+// codecOf 返回 conn 上的 Client 默认使用的消息内容编码
+func codecOf(conn net.Conn) codec.Codec {
+	if sc, ok := conn.(*serverConn); ok && sc.values != nil {
+		return sc.values
+	}
+	return codec.JSON
+}
+
+// codecKey ctx 中保存连接的消息内容编码，Router.Codec 为 nil 时使用
+type codecKey struct{}
+
+func withCodec(ctx context.Context, c codec.Codec) context.Context {
+	return context.WithValue(ctx, codecKey{}, c)
+}
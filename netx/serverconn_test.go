@@ -0,0 +1,155 @@
+package netx
+
+import (
+	"context"
+	"fmt"
+	"gopractice/netx/metrics"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureCollector 按顺序记录所有上报的指标
+type captureCollector struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (c *captureCollector) record(format string, args ...any) {
+	c.mu.Lock()
+	c.events = append(c.events, fmt.Sprintf(format, args...))
+	c.mu.Unlock()
+}
+
+func (c *captureCollector) IncCounter(name string)              { c.record("inc %s", name) }
+func (c *captureCollector) AddCounter(name string, delta int64) { c.record("add %s %d", name, delta) }
+func (c *captureCollector) SetGauge(name string, v float64)     { c.record("gauge %s %v", name, v) }
+func (c *captureCollector) ObserveLatency(name string, d time.Duration) {
+	c.record("latency %s", name)
+}
+
+func (c *captureCollector) Events() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.events...)
+}
+
+func TestServerMetrics(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	col := new(captureCollector)
+	done := make(chan struct{})
+	s := &Server{
+		Metrics: col,
+		Handler: func(ctx context.Context, conn net.Conn) {
+			defer close(done)
+			c := NewClient(conn)
+			msg, err := c.Recv()
+			if err != nil {
+				return
+			}
+			c.Send(msg)
+		},
+	}
+	go s.Serve(l)
+	defer s.Shutdown(context.Background())
+
+	c, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Send([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+	s.Shutdown(context.Background())
+
+	want := []string{
+		"inc " + metrics.ConnsAccepted,
+		"gauge " + metrics.ConnsActive + " 1",
+		"add " + metrics.BytesRead + " 9",
+		"inc " + metrics.FramesRead,
+		"add " + metrics.BytesWritten + " 9",
+		"inc " + metrics.FramesWritten,
+		"latency " + metrics.HandlerLatency,
+		"gauge " + metrics.ConnsActive + " 0",
+	}
+	if got := col.Events(); !reflect.DeepEqual(got, want) {
+		t.Errorf("metric events:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestServerDecodeErrorMetric(t *testing.T) {
+	m := metrics.NewMemory()
+	done := make(chan error, 1)
+	s := &Server{
+		Metrics: m,
+		Handler: func(ctx context.Context, conn net.Conn) {
+			_, err := NewClient(conn).Recv()
+			done <- err
+		},
+	}
+	conn, err := net.Dial("tcp", startTCPServer(t, s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 长度为负数的消息头
+	conn.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	defer conn.Close()
+
+	if err := <-done; err != ErrInvalidLength {
+		t.Fatalf("Recv() = %v, want %v", err, ErrInvalidLength)
+	}
+	if n := m.Counter(metrics.DecodeErrors); n != 1 {
+		t.Errorf("%s = %d, want 1", metrics.DecodeErrors, n)
+	}
+}
+
+func TestUDPServerMetrics(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := metrics.NewMemory()
+	s := &UDPServer{
+		Metrics: m,
+		Handler: func(ctx context.Context, pc net.PacketConn, addr net.Addr, data []byte) {
+			pc.WriteTo(data, addr)
+		},
+	}
+	go s.Serve(pc)
+	defer s.Shutdown(context.Background())
+
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+	conn.Write([]byte("ping"))
+	if _, err := conn.Read(make([]byte, 16)); err != nil {
+		t.Fatal(err)
+	}
+	s.Shutdown(context.Background())
+
+	if got := m.Counter(metrics.PacketsRead); got != 1 {
+		t.Errorf("%s = %d, want 1", metrics.PacketsRead, got)
+	}
+	if got := m.Counter(metrics.BytesRead); got != 4 {
+		t.Errorf("%s = %d, want 4", metrics.BytesRead, got)
+	}
+	if got := m.Counter(metrics.BytesWritten); got != 4 {
+		t.Errorf("%s = %d, want 4", metrics.BytesWritten, got)
+	}
+	if got := len(m.Latencies(metrics.HandlerLatency)); got != 1 {
+		t.Errorf("%d latencies observed, want 1", got)
+	}
+}
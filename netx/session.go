@@ -0,0 +1,180 @@
+package netx
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"time"
+)
+
+// DefaultAuthTimeout Server.AuthTimeout 为 0 时等待认证消息的最长时间
+const DefaultAuthTimeout = 10 * time.Second
+
+// DefaultAuthMaxSize Server.AuthMaxSize 为 0 时认证消息的最大长度
+const DefaultAuthMaxSize = 4 << 10
+
+// authFrameOverhead 认证消息除了内容之外最多还有的字节数：长度头、压缩标志位和校验和
+const authFrameOverhead = 16
+
+// 认证握手的回复：1 字节的状态，成功时后面是 8 字节的会话 ID（小端序），失败时是错误信息
+const (
+	authOK     byte = 0
+	authFailed byte = 1
+)
+
+var (
+	// ErrAuthTimeout 在 Server.AuthTimeout 内没有收到认证消息
+	ErrAuthTimeout = errors.New("netx: authentication timeout")
+	// ErrSessionKicked 会话被 Server.Kick 踢出
+	ErrSessionKicked = errors.New("netx: session kicked")
+	// errInvalidAuthReply 认证回复的格式不正确
+	errInvalidAuthReply = errors.New("netx: invalid authentication reply")
+)
+
+// AuthError 服务端拒绝了认证，Msg 是 Server.Auth 返回的错误信息
+type AuthError struct {
+	Msg string
+}
+
+func (e *AuthError) Error() string {
+	return "netx: authentication failed: " + e.Msg
+}
+
+// Session 通过认证的连接，Handler 中用 SessionFrom 获取
+type Session struct {
+	ID          uint64 // 与 Event.ConnID 相同
+	User        any    // Server.Auth 返回的用户数据
+	ConnectedAt time.Time
+	RemoteAddr  net.Addr
+
+	kick func()
+}
+
+type sessionKey struct{}
+
+// SessionFrom 返回 ctx 所属连接的 Session，没有开启认证时返回 nil, false
+func SessionFrom(ctx context.Context) (*Session, bool) {
+	sess, ok := ctx.Value(sessionKey{}).(*Session)
+	return sess, ok
+}
+
+// Sessions 返回当前所有通过认证、Handler 还没有返回的会话，按 ID 排序
+func (s *Server) Sessions() []*Session {
+	s.mu.Lock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mu.Unlock()
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions
+}
+
+// Session 返回 ID 为 id 的会话，不存在时返回 nil
+func (s *Server) Session(id uint64) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[id]
+}
+
+// Kick 踢出 ID 为 id 的会话：取消 Handler 的 ctx 并关闭连接，会话不存在时返回 false
+func (s *Server) Kick(id uint64) bool {
+	sess := s.Session(id)
+	if sess == nil {
+		return false
+	}
+	sess.kick()
+	return true
+}
+
+// authenticate 读取第一个消息交给 s.Auth 验证，并回复验证结果
+// 读认证消息时多读到的数据放回 sc，之后由 Handler 读取。
+func (s *Server) authenticate(ctx context.Context, sc *serverConn, id uint64) (*Session, error) {
+	max := s.AuthMaxSize
+	if max <= 0 {
+		max = DefaultAuthMaxSize
+	}
+	fc := s.AuthFrameCodec
+	if fc == nil {
+		fc = NewFramer(WithMaxFrameSize(max))
+	}
+	timeout := s.AuthTimeout
+	if timeout == 0 {
+		timeout = DefaultAuthTimeout
+	}
+	rctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	// 自定义的 AuthFrameCodec 不一定限制长度，读取的字节数也限制住，消息没有在限制内读完就是太大了
+	lr := &io.LimitedReader{R: sc, N: int64(max + authFrameOverhead)}
+	r := bufio.NewReader(lr)
+	msg, err := readContext(rctx, sc, func() ([]byte, error) {
+		return fc.ReadFrame(r)
+	})
+	sc.SetReadDeadline(time.Time{})
+	if err == context.DeadlineExceeded && ctx.Err() == nil {
+		err = ErrAuthTimeout
+	}
+	if ((err == io.EOF || err == io.ErrUnexpectedEOF) && lr.N == 0) || (err == nil && len(msg) > max) {
+		err = ErrFrameTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n := r.Buffered(); n > 0 {
+		b, _ := r.Peek(n)
+		sc.unread = append([]byte(nil), b...)
+	}
+
+	user, err := s.Auth(ctx, sc, msg)
+	if err != nil {
+		fc.WriteFrame(sc, append([]byte{authFailed}, err.Error()...))
+		return nil, err
+	}
+	reply := make([]byte, 9)
+	reply[0] = authOK
+	binary.LittleEndian.PutUint64(reply[1:], id)
+	if err := fc.WriteFrame(sc, reply); err != nil {
+		return nil, err
+	}
+	return &Session{ID: id, User: user, ConnectedAt: time.Now(), RemoteAddr: sc.RemoteAddr()}, nil
+}
+
+func (s *Server) addSession(sess *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions == nil {
+		s.sessions = make(map[uint64]*Session)
+	}
+	s.sessions[sess.ID] = sess
+}
+
+func (s *Server) removeSession(sess *Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sess.ID)
+}
+
+// Authenticate 发送认证消息 msg 并等待服务端的回复，成功时返回会话 ID，服务端拒绝时返回 *AuthError。
+// 需要在连接建立后、发送其他消息之前调用，认证消息不带心跳的类型前缀，开启心跳时也应立即调用。
+func (c *Client) Authenticate(ctx context.Context, msg []byte) (uint64, error) {
+	if err := c.writeFrame(msg); err != nil {
+		return 0, err
+	}
+	reply, err := readContext(ctx, c.conn, func() ([]byte, error) {
+		return c.codec.ReadFrame(c.reader)
+	})
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case len(reply) == 9 && reply[0] == authOK:
+		return binary.LittleEndian.Uint64(reply[1:]), nil
+	case len(reply) > 0 && reply[0] == authFailed:
+		return 0, &AuthError{Msg: string(reply[1:])}
+	}
+	return 0, errInvalidAuthReply
+}
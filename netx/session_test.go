@@ -0,0 +1,154 @@
+package netx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startAuthServer 启动一个需要认证的服务端，认证消息为 "token:用户名"，回复 "用户名:消息"
+func startAuthServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	s := NewTCPServer("", HandlerFunc(func(ctx context.Context, conn *Conn) {
+		sess, ok := SessionFrom(ctx)
+		if !ok {
+			t.Error("SessionFrom() found no session")
+			return
+		}
+		for {
+			msg, err := conn.Recv(ctx)
+			if err != nil {
+				return
+			}
+			conn.Send([]byte(sess.User.(string) + ":" + string(msg)))
+		}
+	}))
+	s.Auth = func(ctx context.Context, conn net.Conn, msg []byte) (any, error) {
+		user := strings.TrimPrefix(string(msg), "token:")
+		if user == string(msg) || user == "" {
+			return nil, errors.New("bad token")
+		}
+		return user, nil
+	}
+	s.AuthTimeout = 200 * time.Millisecond
+	return s, startTCPServer(t, s)
+}
+
+func TestServerAuth(t *testing.T) {
+	s, addr := startAuthServer(t)
+
+	c, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	id, err := c.Authenticate(context.Background(), []byte("token:alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := echo(c, "hi"); err != nil || got != "alice:hi" {
+		t.Errorf("echo = %q, %v, want %q", got, err, "alice:hi")
+	}
+	sessions := s.Sessions()
+	if len(sessions) != 1 || sessions[0].ID != id || sessions[0].User != "alice" || s.Session(id) != sessions[0] {
+		t.Errorf("Sessions() = %+v, want the session %d of alice", sessions, id)
+	}
+
+	bad, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bad.Close()
+	var ae *AuthError
+	if _, err := bad.Authenticate(context.Background(), []byte("guest")); !errors.As(err, &ae) || ae.Msg != "bad token" {
+		t.Errorf("Authenticate(guest) = %v, want AuthError", err)
+	}
+	bad.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := bad.Recv(); err == nil {
+		t.Error("Recv() after failed authentication succeeded")
+	}
+
+	// 踢出之后连接被关闭，会话也不再出现在列表中
+	if !s.Kick(id) {
+		t.Fatal("Kick() = false")
+	}
+	c.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := c.Recv(); err == nil {
+		t.Error("Recv() after Kick succeeded")
+	}
+	for deadline := time.Now().Add(time.Second); len(s.Sessions()) != 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("Sessions() = %d sessions after Kick, want 0", len(s.Sessions()))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if s.Kick(id) {
+		t.Error("Kick() of a closed session = true")
+	}
+}
+
+// 认证消息和之后的消息在同一次写入中到达时，之后的消息不会丢失
+func TestServerAuthPipelined(t *testing.T) {
+	_, addr := startAuthServer(t)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var buf bytes.Buffer
+	defaultFramer.WriteFrame(&buf, []byte("token:bob"))
+	defaultFramer.WriteFrame(&buf, []byte("early"))
+	conn.Write(buf.Bytes())
+
+	c := NewClient(conn)
+	c.Conn().SetReadDeadline(time.Now().Add(time.Second))
+	if reply, err := c.Recv(); err != nil || len(reply) != 9 || reply[0] != authOK {
+		t.Fatalf("auth reply = %q, %v", reply, err)
+	}
+	if got, err := c.Recv(); err != nil || string(got) != "bob:early" {
+		t.Errorf("Recv() = %q, %v, want %q", got, err, "bob:early")
+	}
+}
+
+func TestServerAuthTimeout(t *testing.T) {
+	_, addr := startAuthServer(t)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil || isTimeout(err) {
+		t.Errorf("Read() = %v, want the connection closed after AuthTimeout", err)
+	}
+}
+
+// TestServerAuthMaxSize 认证之前的长度头不可信，超过 AuthMaxSize 时直接关闭连接
+func TestServerAuthMaxSize(t *testing.T) {
+	s, addr := startAuthServer(t)
+	s.AuthTimeout = 5 * time.Second
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"2GB header", []byte{0xff, 0xff, 0xff, 0x7f}},
+		{"5KB message", defaultFramer.AppendFrame(nil, append([]byte("token:"), bytes.Repeat([]byte("x"), 5<<10)...))},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			conn.Write(tt.data)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			if _, err := conn.Read(make([]byte, 1)); err == nil || isTimeout(err) {
+				t.Errorf("Read() = %v, want the connection closed", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package netx
+
+import (
+	"runtime"
+	"syscall"
+)
+
+func setsockoptInt(fd uintptr, level, opt, value int) error {
+	return syscall.SetsockoptInt(int(fd), level, opt, value)
+}
+
+func setsockoptInet4Addr(fd uintptr, level, opt int, addr [4]byte) error {
+	return syscall.SetsockoptInet4Addr(int(fd), level, opt, addr)
+}
+
+// setsockoptIPv4Byte 设置 IPPROTO_IP 层的 IP_MULTICAST_TTL 和 IP_MULTICAST_LOOP，
+// BSD 系统（包括 macOS）要求这两个选项的值是 1 个字节，Linux 是 int
+func setsockoptIPv4Byte(fd uintptr, opt, value int) error {
+	if runtime.GOOS == "linux" {
+		return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, opt, value)
+	}
+	return syscall.SetsockoptByte(int(fd), syscall.IPPROTO_IP, opt, byte(value))
+}
@@ -0,0 +1,16 @@
+package netx
+
+import "syscall"
+
+func setsockoptInt(fd uintptr, level, opt, value int) error {
+	return syscall.SetsockoptInt(syscall.Handle(fd), level, opt, value)
+}
+
+func setsockoptInet4Addr(fd uintptr, level, opt int, addr [4]byte) error {
+	return syscall.SetsockoptInet4Addr(syscall.Handle(fd), level, opt, addr)
+}
+
+// setsockoptIPv4Byte Windows 上 IP_MULTICAST_TTL 和 IP_MULTICAST_LOOP 的值是 DWORD
+func setsockoptIPv4Byte(fd uintptr, opt, value int) error {
+	return syscall.SetsockoptInt(syscall.Handle(fd), syscall.IPPROTO_IP, opt, value)
+}
@@ -0,0 +1,138 @@
+package socks5
+
+import (
+	"context"
+	"errors"
+	"gopractice/netx"
+	"io"
+	"net"
+	"time"
+)
+
+// Dialer 经过 SOCKS5 代理建立 tcp 连接，实现了 netx.ContextDialer
+type Dialer struct {
+	// ProxyAddr 代理服务端的地址
+	ProxyAddr string
+
+	// Username 不为空时使用用户名/密码认证，否则不认证
+	Username string
+	Password string
+
+	// Forward 连接代理服务端的方式，为 nil 时使用 net.Dialer，可以是另一个 Dialer 组成代理链
+	Forward netx.ContextDialer
+}
+
+// Dial 经过代理连接 addr
+func (d *Dialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext 经过代理连接 addr，network 只支持 tcp、tcp4 和 tcp6；ctx 同时限制与代理握手的时间
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, errors.New("socks5: unsupported network " + network)
+	}
+	forward := d.Forward
+	if forward == nil {
+		forward = &net.Dialer{}
+	}
+	conn, err := forward.DialContext(ctx, "tcp", d.ProxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// 握手的读写不能直接响应 ctx，ctx 结束时把期限设置为过去的时间让它们返回
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Unix(1, 0))
+		case <-done:
+		}
+	}()
+	err = d.handshake(conn, addr)
+	close(done)
+	<-stopped
+	if ctx.Err() != nil {
+		// 握手的错误是被 ctx 打断造成的，或者握手刚好完成但是期限已经被改掉了
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (d *Dialer) handshake(conn net.Conn, addr string) error {
+	method := methodNoAuth
+	if d.Username != "" {
+		method = methodUserPass
+	}
+	if _, err := conn.Write([]byte{version, 1, method}); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != version {
+		return ErrBadVersion
+	}
+	if reply[1] != method {
+		return ErrNoAcceptableMethod
+	}
+	if method == methodUserPass {
+		if err := d.authenticate(conn); err != nil {
+			return err
+		}
+	}
+
+	req, err := appendAddr([]byte{version, cmdConnect, 0}, addr)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	var hdr [3]byte
+	if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+		return err
+	}
+	if hdr[0] != version {
+		return ErrBadVersion
+	}
+	if hdr[1] != ReplySucceeded {
+		return &ReplyError{Code: hdr[1]}
+	}
+	// 代理服务端绑定的地址，只需要读出来
+	_, err = readAddr(conn)
+	return err
+}
+
+func (d *Dialer) authenticate(conn net.Conn) error {
+	if len(d.Username) > 255 || len(d.Password) > 255 {
+		return ErrAuthFailed
+	}
+	b := append([]byte{authVersion, byte(len(d.Username))}, d.Username...)
+	b = append(append(b, byte(len(d.Password))), d.Password...)
+	if _, err := conn.Write(b); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[1] != 0 {
+		return ErrAuthFailed
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || windows
+
+package socks5
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errnoReplyCode 把连接目标地址时的错误码转换为回复的状态码，没有对应的状态码时 ok 为 false
+func errnoReplyCode(err error) (code byte, ok bool) {
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ReplyConnectionRefused, true
+	case errors.Is(err, syscall.ENETUNREACH):
+		return ReplyNetworkUnreachable, true
+	case errors.Is(err, syscall.EHOSTUNREACH):
+		return ReplyHostUnreachable, true
+	}
+	return 0, false
+}
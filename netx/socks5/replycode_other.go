@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !windows
+
+package socks5
+
+// errnoReplyCode 当前平台没有对应的错误码，由 replyCode 按错误类型判断
+func errnoReplyCode(err error) (code byte, ok bool) {
+	return 0, false
+}
@@ -0,0 +1,259 @@
+package socks5
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"gopractice/netx"
+	"io"
+	"net"
+	"time"
+)
+
+// DefaultHandshakeTimeout 完成认证和 CONNECT 请求的默认最长时间
+const DefaultHandshakeTimeout = 10 * time.Second
+
+// Option NewServer 的可选配置
+type Option func(h *handler)
+
+type handler struct {
+	auth    func(user, password string) bool
+	dial    func(ctx context.Context, network, addr string) (net.Conn, error)
+	timeout time.Duration
+}
+
+// WithAuth 要求客户端使用用户名/密码认证，auth 返回 false 时认证失败；默认不需要认证
+func WithAuth(auth func(user, password string) bool) Option {
+	return func(h *handler) {
+		h.auth = auth
+	}
+}
+
+// WithCredentials 要求客户端使用用户名/密码认证，只接受 credentials 中的用户名和对应的密码
+func WithCredentials(credentials map[string]string) Option {
+	return WithAuth(func(user, password string) bool {
+		p, ok := credentials[user]
+		return ok && p == password
+	})
+}
+
+// WithDial 设置连接目标地址的方式，默认使用 net.Dialer，可以用来限制允许访问的地址
+func WithDial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(h *handler) {
+		h.dial = dial
+	}
+}
+
+// WithHandshakeTimeout 设置完成认证和 CONNECT 请求的最长时间，默认为 DefaultHandshakeTimeout
+func WithHandshakeTimeout(d time.Duration) Option {
+	return func(h *handler) {
+		h.timeout = d
+	}
+}
+
+// NewServer 返回一个 SOCKS5 代理服务端，握手失败的连接会被直接关闭
+// 建立到目标地址的连接后双向转发数据，Shutdown 时正在转发的连接也会被关闭。
+func NewServer(addr string, opts ...Option) *netx.Server {
+	var d net.Dialer
+	h := &handler{dial: d.DialContext, timeout: DefaultHandshakeTimeout}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return &netx.Server{Addr: addr, Handler: h.serve}
+}
+
+func (h *handler) serve(ctx context.Context, conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(h.timeout))
+	br := bufio.NewReader(conn)
+	if err := h.negotiate(br, conn); err != nil {
+		return
+	}
+	target, err := h.readRequest(br, conn)
+	if err != nil {
+		return
+	}
+
+	dctx, cancel := context.WithTimeout(ctx, h.timeout)
+	up, err := h.dial(dctx, "tcp", target)
+	cancel()
+	if err != nil {
+		writeReply(conn, replyCode(err), nil)
+		return
+	}
+	defer up.Close()
+	if err := writeReply(conn, ReplySucceeded, up.LocalAddr()); err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	// 握手时客户端可能已经发送了数据，先把缓冲区中的部分转发过去
+	if n := br.Buffered(); n > 0 {
+		b, _ := br.Peek(n)
+		if _, err := up.Write(b); err != nil {
+			return
+		}
+	}
+	relay(ctx, conn, up)
+}
+
+// negotiate 选择认证方式并完成认证
+func (h *handler) negotiate(br *bufio.Reader, conn net.Conn) error {
+	var hdr [2]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return err
+	}
+	if hdr[0] != version {
+		return ErrBadVersion
+	}
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(br, methods); err != nil {
+		return err
+	}
+
+	want := methodNoAuth
+	if h.auth != nil {
+		want = methodUserPass
+	}
+	method := methodNoAcceptable
+	for _, m := range methods {
+		if m == want {
+			method = m
+		}
+	}
+	if _, err := conn.Write([]byte{version, method}); err != nil {
+		return err
+	}
+	switch method {
+	case methodNoAcceptable:
+		return ErrNoAcceptableMethod
+	case methodUserPass:
+		return h.authenticate(br, conn)
+	}
+	return nil
+}
+
+// authenticate 用户名/密码认证：VER ULEN UNAME PLEN PASSWD，回复 VER STATUS
+func (h *handler) authenticate(br *bufio.Reader, conn net.Conn) error {
+	ver, err := br.ReadByte()
+	if err != nil {
+		return err
+	}
+	if ver != authVersion {
+		return ErrBadVersion
+	}
+	user, err := readString(br)
+	if err != nil {
+		return err
+	}
+	password, err := readString(br)
+	if err != nil {
+		return err
+	}
+	if !h.auth(user, password) {
+		conn.Write([]byte{authVersion, 1})
+		return ErrAuthFailed
+	}
+	_, err = conn.Write([]byte{authVersion, 0})
+	return err
+}
+
+// readRequest 读取 VER CMD RSV ATYP DST.ADDR DST.PORT，不支持的请求回复错误码
+func (h *handler) readRequest(br *bufio.Reader, conn net.Conn) (string, error) {
+	var hdr [3]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return "", err
+	}
+	if hdr[0] != version {
+		return "", ErrBadVersion
+	}
+	target, err := readAddr(br)
+	if err == ErrBadAddress {
+		writeReply(conn, ReplyAddressNotSupported, nil)
+		return "", err
+	}
+	if err != nil {
+		return "", err
+	}
+	if hdr[1] != cmdConnect {
+		writeReply(conn, ReplyCommandNotSupported, nil)
+		return "", &ReplyError{Code: ReplyCommandNotSupported}
+	}
+	return target, nil
+}
+
+// writeReply 回复 VER REP RSV BND.ADDR BND.PORT，bound 为 nil 时使用 0.0.0.0:0
+func writeReply(conn net.Conn, code byte, bound net.Addr) error {
+	addr := "0.0.0.0:0"
+	if bound != nil {
+		addr = bound.String()
+	}
+	b, err := appendAddr([]byte{version, code, 0}, addr)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(b)
+	return err
+}
+
+// replyCode 把连接目标地址的错误转换为回复的状态码
+func replyCode(err error) byte {
+	if code, ok := errnoReplyCode(err); ok {
+		return code
+	}
+	var dnsErr *net.DNSError
+	var ne net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return ReplyHostUnreachable
+	case errors.As(err, &ne) && ne.Timeout():
+		return ReplyTTLExpired
+	}
+	return ReplyGeneralFailure
+}
+
+// relay 双向转发，一个方向读到 EOF 时关闭另一端的写，两个方向都结束或者 ctx 结束时返回
+func relay(ctx context.Context, a, b net.Conn) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			a.Close()
+			b.Close()
+		case <-done:
+		}
+	}()
+
+	errc := make(chan error, 2)
+	copyHalf := func(dst, src net.Conn) {
+		_, err := io.Copy(dst, src)
+		closeWrite(dst)
+		errc <- err
+	}
+	go copyHalf(a, b)
+	go copyHalf(b, a)
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != nil {
+			a.Close()
+			b.Close()
+		}
+	}
+}
+
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+}
+
+func readString(br *bufio.Reader) (string, error) {
+	n, err := br.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(br, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,144 @@
+// Package socks5 最小的 SOCKS5 代理（RFC 1928），支持无认证和用户名/密码认证（RFC 1929），只支持 CONNECT 命令。
+// 服务端使用 NewServer 创建，监听、Accept 和优雅关闭都由 netx.Server 完成；
+// 客户端使用 Dialer 经过代理建立连接，可以交给 netx.DialVia 创建 netx.Client。
+package socks5
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+)
+
+var (
+	// ErrBadVersion 对方不是 SOCKS5 协议
+	ErrBadVersion = errors.New("socks5: bad protocol version")
+	// ErrNoAcceptableMethod 双方没有共同支持的认证方式
+	ErrNoAcceptableMethod = errors.New("socks5: no acceptable authentication method")
+	// ErrAuthFailed 用户名或者密码错误
+	ErrAuthFailed = errors.New("socks5: authentication failed")
+	// ErrBadAddress 地址格式错误，或者域名超过 255 字节
+	ErrBadAddress = errors.New("socks5: bad address")
+)
+
+const (
+	version     = 5
+	authVersion = 1 // 用户名/密码认证子协商的版本
+)
+
+// 认证方式
+const (
+	methodNoAuth       byte = 0x00
+	methodUserPass     byte = 0x02
+	methodNoAcceptable byte = 0xff
+)
+
+const cmdConnect byte = 0x01
+
+// 地址类型
+const (
+	atypIPv4   byte = 0x01
+	atypDomain byte = 0x03
+	atypIPv6   byte = 0x04
+)
+
+// 服务端回复 CONNECT 请求的状态码
+const (
+	ReplySucceeded           byte = 0x00
+	ReplyGeneralFailure      byte = 0x01
+	ReplyNotAllowed          byte = 0x02
+	ReplyNetworkUnreachable  byte = 0x03
+	ReplyHostUnreachable     byte = 0x04
+	ReplyConnectionRefused   byte = 0x05
+	ReplyTTLExpired          byte = 0x06
+	ReplyCommandNotSupported byte = 0x07
+	ReplyAddressNotSupported byte = 0x08
+)
+
+var replyText = map[byte]string{
+	ReplyGeneralFailure:      "general failure",
+	ReplyNotAllowed:          "connection not allowed by ruleset",
+	ReplyNetworkUnreachable:  "network unreachable",
+	ReplyHostUnreachable:     "host unreachable",
+	ReplyConnectionRefused:   "connection refused",
+	ReplyTTLExpired:          "TTL expired",
+	ReplyCommandNotSupported: "command not supported",
+	ReplyAddressNotSupported: "address type not supported",
+}
+
+// ReplyError 代理服务端拒绝了 CONNECT 请求，Code 为回复中的状态码
+type ReplyError struct {
+	Code byte
+}
+
+func (e *ReplyError) Error() string {
+	if s, ok := replyText[e.Code]; ok {
+		return "socks5: " + s
+	}
+	return "socks5: unknown reply code " + strconv.Itoa(int(e.Code))
+}
+
+// appendAddr 按 ATYP、地址、端口（大端序）的格式追加 host:port，host 不是 IP 时作为域名
+func appendAddr(dst []byte, addr string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, ErrBadAddress
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			dst = append(append(dst, atypIPv4), ip4...)
+		} else {
+			dst = append(append(dst, atypIPv6), ip.To16()...)
+		}
+	} else {
+		if len(host) == 0 || len(host) > 255 {
+			return nil, ErrBadAddress
+		}
+		dst = append(append(dst, atypDomain, byte(len(host))), host...)
+	}
+	var p [2]byte
+	binary.BigEndian.PutUint16(p[:], uint16(port))
+	return append(dst, p[:]...), nil
+}
+
+// readAddr 读取 ATYP、地址和端口，返回 host:port；地址类型不支持时返回 ErrBadAddress
+func readAddr(r io.Reader) (string, error) {
+	var atyp [1]byte
+	if _, err := io.ReadFull(r, atyp[:]); err != nil {
+		return "", err
+	}
+	var host string
+	switch atyp[0] {
+	case atypIPv4, atypIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if atyp[0] == atypIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", err
+		}
+		host = ip.String()
+	case atypDomain:
+		var n [1]byte
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			return "", err
+		}
+		b := make([]byte, n[0])
+		if _, err := io.ReadFull(r, b); err != nil {
+			return "", err
+		}
+		host = string(b)
+	default:
+		return "", ErrBadAddress
+	}
+	var p [2]byte
+	if _, err := io.ReadFull(r, p[:]); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(p[:])))), nil
+}
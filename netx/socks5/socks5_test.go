@@ -0,0 +1,199 @@
+package socks5
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"gopractice/netx"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func serve(t *testing.T, s *netx.Server) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return l.Addr().String()
+}
+
+// startEcho 启动一个按 netx 默认封包格式回显消息的服务端
+func startEcho(t *testing.T) string {
+	return serve(t, netx.NewTCPServer("", netx.HandlerFunc(func(ctx context.Context, conn *netx.Conn) {
+		for {
+			msg, err := conn.Recv(ctx)
+			if err != nil {
+				return
+			}
+			conn.Send(msg)
+		}
+	})))
+}
+
+func TestAddr(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1:80", "[::1]:443", "example.com:8080"} {
+		b, err := appendAddr(nil, addr)
+		if err != nil {
+			t.Fatalf("appendAddr(%q) = %v", addr, err)
+		}
+		got, err := readAddr(bytes.NewReader(b))
+		if err != nil || got != addr {
+			t.Errorf("readAddr(appendAddr(%q)) = %q, %v", addr, got, err)
+		}
+	}
+	if _, err := appendAddr(nil, "example.com:http"); err != ErrBadAddress {
+		t.Errorf("appendAddr with named port = %v, want %v", err, ErrBadAddress)
+	}
+}
+
+func TestClientViaProxy(t *testing.T) {
+	echo := startEcho(t)
+	tests := []struct {
+		name   string
+		opts   []Option
+		dialer Dialer
+	}{
+		{"no auth", nil, Dialer{}},
+		{"password", []Option{WithCredentials(map[string]string{"user": "secret"})}, Dialer{Username: "user", Password: "secret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.dialer
+			d.ProxyAddr = serve(t, NewServer("", tt.opts...))
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			// 目标地址用域名，由代理服务端解析
+			_, port, _ := net.SplitHostPort(echo)
+			c, err := netx.DialVia(ctx, &d, net.JoinHostPort("localhost", port))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			for _, msg := range []string{"hello", "world"} {
+				if err := c.Send([]byte(msg)); err != nil {
+					t.Fatal(err)
+				}
+				if got, err := c.Recv(); err != nil || string(got) != msg {
+					t.Fatalf("Recv() = %q, %v, want %q", got, err, msg)
+				}
+			}
+		})
+	}
+}
+
+// TestRelayHalfClose 目标关闭连接后，代理半关闭到客户端的连接，客户端不用先关闭写方向也能读到 EOF
+// Server 设置了超时或者 BindConnContext 时 Handler 拿到的是包装过的连接，同样需要半关闭。
+func TestRelayHalfClose(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("hello"))
+			conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		name      string
+		configure func(s *netx.Server)
+	}{
+		{"plain", func(s *netx.Server) {}},
+		{"timeout", func(s *netx.Server) { s.ReadTimeout = time.Minute }},
+		{"bind context", func(s *netx.Server) { s.BindConnContext = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("")
+			tt.configure(s)
+			d := &Dialer{ProxyAddr: serve(t, s)}
+			conn, err := d.Dial("tcp", l.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			if got, err := io.ReadAll(conn); err != nil || string(got) != "hello" {
+				t.Errorf("ReadAll() = %q, %v, want %q, nil", got, err, "hello")
+			}
+		})
+	}
+}
+
+func TestDialErrors(t *testing.T) {
+	echo := startEcho(t)
+	auth := serve(t, NewServer("", WithCredentials(map[string]string{"user": "secret"})))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().String()
+	l.Close()
+	denyAll := serve(t, NewServer("", WithDial(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("denied")
+	})))
+
+	tests := []struct {
+		name   string
+		dialer Dialer
+		addr   string
+		want   error
+	}{
+		{"wrong password", Dialer{ProxyAddr: auth, Username: "user", Password: "wrong"}, echo, ErrAuthFailed},
+		{"no credentials", Dialer{ProxyAddr: auth}, echo, ErrNoAcceptableMethod},
+		{"refused", Dialer{ProxyAddr: serve(t, NewServer(""))}, closed, &ReplyError{Code: ReplyConnectionRefused}},
+		{"denied", Dialer{ProxyAddr: denyAll}, echo, &ReplyError{Code: ReplyGeneralFailure}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := tt.dialer.Dial("tcp", tt.addr)
+			if err == nil {
+				conn.Close()
+			}
+			var re *ReplyError
+			if want, ok := tt.want.(*ReplyError); ok {
+				if !errors.As(err, &re) || re.Code != want.Code {
+					t.Errorf("Dial() = %v, want %v", err, want)
+				}
+			} else if err != tt.want {
+				t.Errorf("Dial() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestDialContextCancel 代理不响应握手时 ctx 结束后 DialContext 返回
+func TestDialContextCancel(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	d := &Dialer{ProxyAddr: l.Addr().String()}
+	if _, err := d.DialContext(ctx, "tcp", "127.0.0.1:1"); err != context.Canceled {
+		t.Errorf("DialContext() = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,83 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+var (
+	// ErrIdleTimeout 超过 Server.IdleTimeout 没有收到下一个消息
+	ErrIdleTimeout = errors.New("netx: idle timeout")
+	// ErrReadTimeout 超过 Server.ReadTimeout 没有收到完整的消息
+	ErrReadTimeout = errors.New("netx: read timeout")
+	// ErrWriteTimeout 超过 Server.WriteTimeout 没有发送完一个消息
+	ErrWriteTimeout = errors.New("netx: write timeout")
+)
+
+// timeouts 连接的超时配置，见 Server 的 ReadTimeout、WriteTimeout、IdleTimeout 和 OnTimeout
+type timeouts struct {
+	read      time.Duration
+	write     time.Duration
+	idle      time.Duration
+	onTimeout func(conn net.Conn, err error)
+}
+
+// timeoutsOf 返回 conn 的超时配置，不是 Server 交给 Handler 的连接时不限制超时
+func timeoutsOf(conn net.Conn) timeouts {
+	if sc, ok := conn.(*serverConn); ok {
+		return sc.timeouts
+	}
+	return timeouts{}
+}
+
+// waitFrame 在 IdleTimeout 内等待下一个消息的第一个字节，然后把读超时设置为 ReadTimeout
+// 开启心跳时，等待的时间不超过心跳超时。
+func (c *Client) waitFrame(ctx context.Context) error {
+	idle, idleErr := c.timeouts.idle, ErrIdleTimeout
+	if idle == 0 {
+		idle = c.timeouts.read
+	}
+	if hb := c.heartbeat.timeout(); hb > 0 && (idle == 0 || hb < idle) {
+		idle, idleErr = hb, ErrHeartbeatTimeout
+	}
+	if idle > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(idle))
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := c.reader.Peek(1); err != nil {
+			return c.timeoutError(ctx, err, idleErr)
+		}
+	}
+
+	switch {
+	case c.timeouts.read > 0:
+		c.conn.SetReadDeadline(time.Now().Add(c.timeouts.read))
+	case idle > 0:
+		c.conn.SetReadDeadline(time.Time{})
+	default:
+		return nil
+	}
+	// 设置超时可能覆盖了 readContext 在 ctx 结束时设置的超时，所以要在设置之后再检查一次
+	return ctx.Err()
+}
+
+// timeoutError 把超时错误转换为 timeoutErr，调用 OnTimeout 之后关闭连接
+// ctx 结束引起的超时返回 ctx.Err()。
+func (c *Client) timeoutError(ctx context.Context, err, timeoutErr error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		return err
+	}
+	if c.timeouts.onTimeout != nil {
+		c.timeouts.onTimeout(c.conn, timeoutErr)
+	}
+	c.events.fail(timeoutErr)
+	c.conn.Close()
+	return timeoutErr
+}
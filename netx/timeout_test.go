@@ -0,0 +1,158 @@
+package netx
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServerTimeouts(t *testing.T) {
+	tests := []struct {
+		name        string
+		idleTimeout time.Duration
+		readTimeout time.Duration
+		// client 模拟不同的慢客户端
+		client func(c *Client)
+		want   error
+	}{
+		{
+			name:        "idle",
+			idleTimeout: 30 * time.Millisecond,
+			readTimeout: time.Second,
+			client:      func(c *Client) {},
+			want:        ErrIdleTimeout,
+		},
+		{
+			name:        "read",
+			idleTimeout: time.Second,
+			readTimeout: 30 * time.Millisecond,
+			// 长度头中的长度是 10，但只发送了一个字节的消息数据
+			client: func(c *Client) { c.Conn().Write([]byte{10, 0, 0, 0, 'a'}) },
+			want:   ErrReadTimeout,
+		},
+		{
+			name:        "read as idle",
+			readTimeout: 30 * time.Millisecond,
+			client:      func(c *Client) {},
+			want:        ErrIdleTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			hooked := make(chan error, 1)
+			returned := make(chan error, 1)
+			s := &Server{
+				IdleTimeout: tt.idleTimeout,
+				ReadTimeout: tt.readTimeout,
+				OnTimeout:   func(conn net.Conn, err error) { hooked <- err },
+				Handler: func(ctx context.Context, conn net.Conn) {
+					c := NewClient(conn)
+					for {
+						if _, err := c.RecvContext(ctx); err != nil {
+							returned <- err
+							return
+						}
+					}
+				},
+			}
+			go s.Serve(l)
+			defer s.Shutdown(context.Background())
+
+			c, err := Dial(l.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			tt.client(c)
+
+			if err := <-hooked; err != tt.want {
+				t.Errorf("OnTimeout err = %v, want %v", err, tt.want)
+			}
+			if err := <-returned; err != tt.want {
+				t.Errorf("RecvContext() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// 消息持续到达时不会因为 IdleTimeout 断开
+func TestServerTimeoutsActiveConn(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		IdleTimeout:  50 * time.Millisecond,
+		ReadTimeout:  50 * time.Millisecond,
+		WriteTimeout: 50 * time.Millisecond,
+		Handler: func(ctx context.Context, conn net.Conn) {
+			c := NewClient(conn)
+			for {
+				msg, err := c.Recv()
+				if err != nil {
+					return
+				}
+				c.Send(msg)
+			}
+		},
+	}
+	go s.Serve(l)
+	defer s.Shutdown(context.Background())
+
+	c, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for i := 0; i < 5; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if got, err := echo(c, "ping"); err != nil || got != "ping" {
+			t.Fatalf("echo #%d = %q, %v", i, got, err)
+		}
+	}
+}
+
+// 超时配置不影响 ctx 结束时立即返回
+func TestServerTimeoutsShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	returned := make(chan error, 1)
+	s := &Server{
+		IdleTimeout: time.Minute,
+		Handler: func(ctx context.Context, conn net.Conn) {
+			c := NewClient(conn)
+			for {
+				if _, err := c.RecvContext(ctx); err != nil {
+					returned <- err
+					return
+				}
+			}
+		},
+	}
+	go s.Serve(l)
+
+	c, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Send([]byte("warm up"))
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+	if err := <-returned; err != context.Canceled {
+		t.Errorf("RecvContext() = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,105 @@
+package netx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+)
+
+// ServerTLSConfig 加载服务端证书，clientCAFile 不为空时开启双向认证：
+// 客户端必须提供由 clientCAFile 中的 CA 签发的证书
+func ServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if clientCAFile != "" {
+		pool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// ClientTLSConfig 客户端的 TLS 配置，caFile 为空时使用系统的根证书验证服务端，
+// certFile、keyFile 不为空时在双向认证中提供客户端证书。
+// 本地测试使用自签名证书时，可以把返回值的 InsecureSkipVerify 设置为 true 跳过验证。
+func ClientTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// ListenAndServeTLS 监听 s.Addr 并处理 TLS 连接，参数与 ServeTLS 相同
+func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	ls, err := s.listen()
+	if err != nil {
+		return err
+	}
+	return s.serveAll(ls, func(l net.Listener) error {
+		return s.ServeTLS(l, certFile, keyFile)
+	})
+}
+
+// ServeTLS 与 Serve 相同，只是连接经过 TLS 加密，Handler 中收到的是 *tls.Conn，
+// 封包和拆包的方式不变。certFile、keyFile 为空时使用 s.TLSConfig 中的证书，
+// 需要双向认证时在 s.TLSConfig 中设置，见 ServerTLSConfig。
+func (s *Server) ServeTLS(l net.Listener, certFile, keyFile string) error {
+	config := &tls.Config{}
+	if s.TLSConfig != nil {
+		config = s.TLSConfig.Clone()
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			l.Close()
+			return err
+		}
+		config.Certificates = append(config.Certificates, cert)
+	}
+	if len(config.Certificates) == 0 && config.GetCertificate == nil {
+		l.Close()
+		return errors.New("netx: no TLS certificate configured")
+	}
+	return s.Serve(tls.NewListener(l, config))
+}
+
+// DialTLS 使用 TLS 连接 tcp 服务端，config 可以由 ClientTLSConfig 创建
+func DialTLS(addr string, config *tls.Config, opts ...ClientOption) (*Client, error) {
+	conn, err := tls.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn, opts...), nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("netx: no certificate found in " + file)
+	}
+	return pool, nil
+}
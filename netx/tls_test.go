@@ -0,0 +1,183 @@
+package netx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert 测试用的证书、私钥以及它们写入的文件
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+// newTestCert 生成一个由 parent 签发的证书，parent 为 nil 时生成自签名的 CA
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	tc := &testCert{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, name+".pem"),
+		keyFile:  filepath.Join(dir, name+"-key.pem"),
+	}
+	os.WriteFile(tc.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(tc.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return tc
+}
+
+// startTLSEcho 启动一个回显消息的 TLS 服务端
+func startTLSEcho(t *testing.T, config *tls.Config) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		TLSConfig: config,
+		Handler: func(ctx context.Context, conn net.Conn) {
+			c := NewClient(conn)
+			for {
+				msg, err := c.Recv()
+				if err != nil {
+					return
+				}
+				c.Send(msg)
+			}
+		},
+	}
+	go s.ServeTLS(l, "", "")
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return l.Addr().String()
+}
+
+func echo(c *Client, msg string) (string, error) {
+	if err := c.Send([]byte(msg)); err != nil {
+		return "", err
+	}
+	b, err := c.Recv()
+	return string(b), err
+}
+
+func TestServeTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	server := newTestCert(t, "server", ca)
+	config, err := ServerTLSConfig(server.certFile, server.keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startTLSEcho(t, config)
+
+	clientConfig, err := ClientTLSConfig(ca.certFile, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := DialTLS(addr, clientConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if got, err := echo(c, "hello tls"); err != nil || got != "hello tls" {
+		t.Errorf("echo = %q, %v", got, err)
+	}
+
+	// 不信任服务端证书时握手失败，本地测试可以跳过验证
+	if _, err := DialTLS(addr, &tls.Config{}); err == nil {
+		t.Error("DialTLS with unknown CA succeeded")
+	}
+	insecure, err := DialTLS(addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer insecure.Close()
+	if got, err := echo(insecure, "insecure"); err != nil || got != "insecure" {
+		t.Errorf("echo = %q, %v", got, err)
+	}
+}
+
+func TestServeTLSMutual(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	server := newTestCert(t, "server", ca)
+	client := newTestCert(t, "client", ca)
+	config, err := ServerTLSConfig(server.certFile, server.keyFile, ca.certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startTLSEcho(t, config)
+
+	// 没有客户端证书时服务端拒绝连接，TLS 1.3 中客户端在第一次读取时才会发现
+	noCert, _ := ClientTLSConfig(ca.certFile, "", "")
+	if c, err := DialTLS(addr, noCert); err == nil {
+		if _, err := echo(c, "hello"); err == nil {
+			t.Error("server accepted a client without certificate")
+		}
+		c.Close()
+	}
+
+	withCert, err := ClientTLSConfig(ca.certFile, client.certFile, client.keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := DialTLS(addr, withCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if got, err := echo(c, "mutual"); err != nil || got != "mutual" {
+		t.Errorf("echo = %q, %v", got, err)
+	}
+}
+
+func TestServeTLSWithoutCertificate(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&Server{}).ServeTLS(l, "", ""); err == nil {
+		t.Error("ServeTLS without certificate succeeded")
+	}
+}
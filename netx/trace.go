@@ -0,0 +1,147 @@
+package netx
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"gopractice/logx"
+	"net"
+	"os"
+)
+
+// TracePreviewSize 跟踪日志中消息内容预览的最大字节数
+const TracePreviewSize = 64
+
+// FrameConn 按消息收发的连接，*Client 实现了这个接口
+type FrameConn interface {
+	Send(msg []byte) error
+	Recv() ([]byte, error)
+}
+
+// TraceConn 把每个收发的消息以 Debug 级别记录到 Logger 的 FrameConn，
+// 只是包装了 Send 和 Recv，不改变消息的格式。
+type TraceConn struct {
+	FrameConn
+	Logger logx.Logger
+}
+
+// Trace 为单个连接开启消息跟踪，需要对整个服务端开启时使用 Server.Trace
+func Trace(fc FrameConn, l logx.Logger) *TraceConn {
+	return &TraceConn{FrameConn: fc, Logger: l}
+}
+
+func (t *TraceConn) Send(msg []byte) error {
+	if err := t.FrameConn.Send(msg); err != nil {
+		return err
+	}
+	traceFrame(t.Logger, "send", msg)
+	return nil
+}
+
+func (t *TraceConn) Recv() ([]byte, error) {
+	msg, err := t.FrameConn.Recv()
+	if err != nil {
+		return nil, err
+	}
+	traceFrame(t.Logger, "recv", msg)
+	return msg, nil
+}
+
+// traceFrame 记录一个消息的方向、类型、大小以及截断后的内容
+// JSON 消息直接输出文本，其它消息输出十六进制。
+func traceFrame(l logx.Logger, dir string, msg []byte) {
+	if l == nil {
+		return
+	}
+
+	kind := "binary"
+	if json.Valid(msg) {
+		kind = "json"
+	}
+	b, suffix := msg, ""
+	if len(b) > TracePreviewSize {
+		b, suffix = b[:TracePreviewSize], "..."
+	}
+	preview := hex.EncodeToString(b)
+	if kind == "json" {
+		preview = string(b)
+	}
+	l.Debugf("netx: %s %s frame, %d bytes: %s%s", dir, kind, len(msg), preview, suffix)
+}
+
+// Direction 消息的方向
+type Direction uint8
+
+const (
+	DirSend Direction = iota + 1 // 发送
+	DirRecv                      // 接收
+)
+
+func (d Direction) String() string {
+	switch d {
+	case DirSend:
+		return "send"
+	case DirRecv:
+		return "recv"
+	}
+	return "unknown"
+}
+
+// Tracer 在 Client 发送和接收每个消息时调用，payload 是去掉封包格式之后的消息内容，
+// 只在调用期间有效，需要保留时复制一份。Send 和 Recv 可能同时调用，实现需要支持并发。
+type Tracer interface {
+	TraceFrame(conn net.Conn, dir Direction, payload []byte)
+}
+
+// TracerFunc 把普通函数转换为 Tracer
+type TracerFunc func(conn net.Conn, dir Direction, payload []byte)
+
+// TraceFrame 调用 f(conn, dir, payload)
+func (f TracerFunc) TraceFrame(conn net.Conn, dir Direction, payload []byte) {
+	f(conn, dir, payload)
+}
+
+// WithTracer 设置收发消息时调用的 Tracer，为 nil 时不跟踪；在 Server 的 Handler 中默认使用 Server.Tracer
+func WithTracer(t Tracer) ClientOption {
+	return func(c *Client) {
+		c.frameTracer = t
+	}
+}
+
+// Debug 为 true 时 DebugTracer 返回输出十六进制内容的 Tracer，默认由环境变量 NETX_DEBUG=1 开启
+var Debug = os.Getenv("NETX_DEBUG") == "1"
+
+// DebugTracer Debug 为 true 时返回一个 Logger 为 l 的 HexDumpTracer，否则返回 nil，
+// 可以直接赋值给 Server.Tracer 或者传给 WithTracer，不需要在调用的地方判断
+func DebugTracer(l logx.Logger) Tracer {
+	if !Debug {
+		return nil
+	}
+	return &HexDumpTracer{Logger: l}
+}
+
+// HexDumpTracer 以 Debug 级别记录每个消息的方向、对方地址、长度和 hex.Dump 格式的内容
+type HexDumpTracer struct {
+	Logger logx.Logger
+	// MaxBytes 最多输出的字节数，为 0 时使用 TracePreviewSize，小于 0 时不限制
+	MaxBytes int
+}
+
+// TraceFrame 实现 Tracer
+func (t *HexDumpTracer) TraceFrame(conn net.Conn, dir Direction, payload []byte) {
+	if t.Logger == nil {
+		return
+	}
+	max := t.MaxBytes
+	if max == 0 {
+		max = TracePreviewSize
+	}
+	b, suffix := payload, ""
+	if max > 0 && len(b) > max {
+		b, suffix = b[:max], "...\n"
+	}
+	var peer net.Addr
+	if conn != nil {
+		peer = conn.RemoteAddr()
+	}
+	t.Logger.Debugf("netx: %s %v %d bytes\n%s%s", dir, peer, len(payload), hex.Dump(b), suffix)
+}
@@ -0,0 +1,169 @@
+package netx
+
+import (
+	"context"
+	"fmt"
+	"gopractice/logx"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTraceConn(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	rec := new(logx.Recorder)
+	c := Trace(NewClient(client), rec)
+	go func() {
+		s := NewClient(server)
+		msg, _ := s.Recv()
+		s.Send(append(msg, 0xff, 0x00))
+	}()
+
+	if err := c.Send([]byte(`{"name":"kwok"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := rec.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("got %d trace entries, want 2: %v", len(entries), entries)
+	}
+	for i, want := range []string{
+		`send json frame, 15 bytes: {"name":"kwok"}`,
+		"recv binary frame, 17 bytes: 7b226e616d65223a226b776f6b227dff00",
+	} {
+		if entries[i].Level != logx.LevelDebug || !strings.Contains(entries[i].Msg, want) {
+			t.Errorf("entries[%d] = %v, want debug entry containing %q", i, entries[i], want)
+		}
+	}
+}
+
+func TestServerTrace(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := new(logx.Recorder)
+	done := make(chan struct{})
+	s := &Server{
+		Trace:  true,
+		Logger: rec,
+		Handler: func(ctx context.Context, conn net.Conn) {
+			defer close(done)
+			c := NewClient(conn)
+			msg, err := c.Recv()
+			if err != nil {
+				return
+			}
+			c.Send([]byte(strings.Repeat(string(msg), 100)))
+		},
+	}
+	go s.Serve(l)
+	defer s.Shutdown(context.Background())
+
+	c, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Send([]byte("ab"))
+	c.Recv()
+	<-done
+
+	var traces []string
+	for _, e := range rec.Entries() {
+		if strings.HasPrefix(e.Msg, "netx: ") {
+			traces = append(traces, e.Msg)
+		}
+	}
+	if len(traces) != 2 {
+		t.Fatalf("got %d trace entries, want 2: %q", len(traces), traces)
+	}
+	if !strings.Contains(traces[0], "recv binary frame, 2 bytes: 6162") {
+		t.Errorf("inbound trace = %q", traces[0])
+	}
+	// 200 字节的消息只预览前 TracePreviewSize 个字节
+	if !strings.Contains(traces[1], "send binary frame, 200 bytes: ") || !strings.HasSuffix(traces[1], "...") {
+		t.Errorf("outbound trace = %q", traces[1])
+	}
+}
+
+func TestServerTracer(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var events []string
+	s := &Server{
+		Tracer: TracerFunc(func(conn net.Conn, dir Direction, payload []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, fmt.Sprintf("%s %d %s", dir, len(payload), payload))
+		}),
+		Handler: func(ctx context.Context, conn net.Conn) {
+			c := NewClient(conn)
+			if msg, err := c.Recv(); err == nil {
+				c.Send(append(msg, '!'))
+			}
+		},
+	}
+	go s.Serve(l)
+	defer s.Close()
+
+	c, err := Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.Send([]byte("hi"))
+	if _, err := c.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	// 服务端发送之后才调用 Tracer，客户端收到回复时可能还没有记录
+	traced := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return fmt.Sprint(events)
+	}
+	want := "[recv 2 hi send 3 hi!]"
+	for deadline := time.Now().Add(time.Second); traced() != want && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if got := traced(); got != want {
+		t.Errorf("traced %v, want %s", got, want)
+	}
+}
+
+func TestHexDumpTracer(t *testing.T) {
+	Debug = false
+	if tr := DebugTracer(logx.Nop); tr != nil {
+		t.Errorf("DebugTracer() = %v with Debug off, want nil", tr)
+	}
+	Debug = true
+	defer func() { Debug = false }()
+
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	rec := new(logx.Recorder)
+	c := NewClient(client, WithTracer(DebugTracer(rec)))
+	go NewClient(server).Recv()
+	if err := c.Send([]byte("hello, world\x00")); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := rec.Entries()
+	want := "00000000  68 65 6c 6c 6f 2c 20 77  6f 72 6c 64 00           |hello, world.|"
+	if len(entries) != 1 || entries[0].Level != logx.LevelDebug ||
+		!strings.Contains(entries[0].Msg, "send pipe 13 bytes") || !strings.Contains(entries[0].Msg, want) {
+		t.Errorf("entries = %q, want a hex dump containing %q", entries, want)
+	}
+}
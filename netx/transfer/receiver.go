@@ -0,0 +1,144 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"gopractice/netx"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Receiver 接收文件并保存到 Dir
+type Receiver struct {
+	// Dir 保存文件的目录，为空时使用当前目录
+	Dir string
+
+	// MaxSize 允许接收的最大文件大小，0 表示不限制
+	MaxSize int64
+
+	// Accept 不为 nil 时在开始接收之前调用，返回错误时拒绝这个文件，错误信息会发给发送方
+	Accept func(info FileInfo) error
+
+	// Progress 不为 nil 时每收到一块调用一次，开始时以续传的偏移调用一次
+	Progress Progress
+}
+
+// Receive 接收一个文件，校验通过后保存为 Dir 下的 FileInfo.Name，返回文件的元数据
+// 传输中断时收到的数据保留在 Dir 下的 .part 文件中，同一个文件（SHA-256 相同）再次传输时从中断的位置继续。
+func (r *Receiver) Receive(ctx context.Context, fc netx.FrameConn) (FileInfo, error) {
+	msg, err := fc.Recv()
+	if err != nil {
+		return FileInfo{}, err
+	}
+	info, err := decodeMeta(msg)
+	if err != nil {
+		return info, err
+	}
+	switch {
+	case !validName(info.Name):
+		err = ErrBadName
+	case r.MaxSize > 0 && info.Size > r.MaxSize:
+		err = ErrTooLarge
+	case r.Accept != nil:
+		err = r.Accept(info)
+	}
+	if err != nil {
+		reject(fc, err)
+		return info, err
+	}
+
+	final := filepath.Join(r.Dir, info.Name)
+	part := final + "." + info.SHA256[:16] + ".part"
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		reject(fc, err)
+		return info, err
+	}
+	defer f.Close()
+	off, h, err := resumeState(f, info.Size)
+	if err != nil {
+		reject(fc, err)
+		return info, err
+	}
+	if err := fc.Send(encodeOffset(frameResume, off, nil)); err != nil {
+		return info, err
+	}
+	r.progress(off, info.Size)
+
+	for off < info.Size {
+		if err := ctx.Err(); err != nil {
+			return info, err
+		}
+		msg, err := fc.Recv()
+		if err != nil {
+			return info, err
+		}
+		chunkOff, data, err := decodeOffset(frameChunk, msg)
+		if err == nil && (chunkOff != off || len(data) == 0 || off+int64(len(data)) > info.Size) {
+			err = ErrUnexpectedFrame
+		}
+		if err != nil {
+			reject(fc, err)
+			return info, err
+		}
+		if _, err := f.WriteAt(data, off); err != nil {
+			reject(fc, err)
+			return info, err
+		}
+		h.Write(data)
+		off += int64(len(data))
+		r.progress(off, info.Size)
+	}
+
+	if hex.EncodeToString(h.Sum(nil)) != info.SHA256 {
+		// 数据已经损坏，删除之后下次从头开始
+		f.Close()
+		os.Remove(part)
+		reject(fc, ErrChecksumMismatch)
+		return info, ErrChecksumMismatch
+	}
+	if err := f.Close(); err != nil {
+		reject(fc, err)
+		return info, err
+	}
+	if err := os.Rename(part, final); err != nil {
+		reject(fc, err)
+		return info, err
+	}
+	return info, fc.Send([]byte{frameAck})
+}
+
+// resumeState 返回 .part 文件中已经收到的字节数，以及这部分内容的 SHA-256 状态
+// 文件比元数据中的大小还大时说明不是同一次传输，从头开始。
+func resumeState(f *os.File, size int64) (int64, hash.Hash, error) {
+	h := sha256.New()
+	st, err := f.Stat()
+	if err != nil {
+		return 0, nil, err
+	}
+	off := st.Size()
+	if off > size {
+		if err := f.Truncate(0); err != nil {
+			return 0, nil, err
+		}
+		off = 0
+	}
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, off)); err != nil {
+		return 0, nil, err
+	}
+	return off, h, nil
+}
+
+func (r *Receiver) progress(done, total int64) {
+	if r.Progress != nil {
+		r.Progress(done, total)
+	}
+}
+
+// reject 告诉发送方接收失败的原因，发送失败时连接已经断开，忽略错误
+func reject(fc netx.FrameConn, err error) {
+	fc.Send(append([]byte{frameAck}, err.Error()...))
+}
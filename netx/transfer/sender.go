@@ -0,0 +1,119 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"gopractice/netx"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Sender 发送文件
+type Sender struct {
+	// ChunkSize 每块的字节数，为 0 时使用 DefaultChunkSize
+	ChunkSize int
+
+	// Progress 不为 nil 时每发送一块调用一次，开始时以续传的偏移调用一次
+	Progress Progress
+}
+
+// SendFile 使用默认配置发送 path 指向的文件，文件名为 path 的最后一个元素
+func SendFile(ctx context.Context, fc netx.FrameConn, path string) error {
+	return new(Sender).SendFile(ctx, fc, path)
+}
+
+// SendFile 发送 path 指向的文件，文件名为 path 的最后一个元素
+func (s *Sender) SendFile(ctx context.Context, fc netx.FrameConn, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return s.Send(ctx, fc, filepath.Base(path), f, st.Size())
+}
+
+// Send 发送 r 中 size 字节的内容，接收方保存为 name
+// 先读一遍 r 计算 SHA-256，再从接收方回复的偏移开始发送，接收方校验通过后返回 nil，
+// 接收方拒绝或者校验失败时返回 *RejectError。
+func (s *Sender) Send(ctx context.Context, fc netx.FrameConn, name string, r io.ReaderAt, size int64) error {
+	if !validName(name) {
+		return ErrBadName
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(r, 0, size)); err != nil {
+		return err
+	}
+	chunkSize := s.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	meta, err := encodeMeta(FileInfo{Name: name, Size: size, SHA256: hex.EncodeToString(h.Sum(nil)), ChunkSize: chunkSize})
+	if err != nil {
+		return err
+	}
+	if err := fc.Send(meta); err != nil {
+		return err
+	}
+
+	msg, err := fc.Recv()
+	if err != nil {
+		return err
+	}
+	if err := ackError(msg); err != nil {
+		// 接收方在开始之前就拒绝了，例如文件太大
+		return err
+	}
+	off, _, err := decodeOffset(frameResume, msg)
+	if err != nil {
+		return err
+	}
+	if off < 0 || off > size {
+		return ErrUnexpectedFrame
+	}
+	s.progress(off, size)
+
+	buf := make([]byte, chunkSize)
+	for off < size {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := r.ReadAt(buf, off)
+		if n == 0 && err != nil {
+			return err
+		}
+		if err := fc.Send(encodeOffset(frameChunk, off, buf[:n])); err != nil {
+			return err
+		}
+		off += int64(n)
+		s.progress(off, size)
+	}
+
+	msg, err = fc.Recv()
+	if err != nil {
+		return err
+	}
+	if len(msg) == 0 || msg[0] != frameAck {
+		return ErrUnexpectedFrame
+	}
+	return ackError(msg)
+}
+
+func (s *Sender) progress(done, total int64) {
+	if s.Progress != nil {
+		s.Progress(done, total)
+	}
+}
+
+// ackError 返回确认消息中的错误，msg 不是确认消息或者确认成功时返回 nil
+func ackError(msg []byte) error {
+	if len(msg) > 1 && msg[0] == frameAck {
+		return &RejectError{Msg: string(msg[1:])}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Package transfer 基于 netx 消息的分块文件传输协议，支持断点续传
+// 发送方先发送元数据（文件名、大小、SHA-256 和块大小），接收方回复已经收到的字节数，
+// 发送方从这个偏移开始按固定大小的块发送，每块带上偏移；接收方收齐后校验 SHA-256 并回复结果。
+// 接收方把没有收完的数据保存在 .part 文件中，连接断开后重新传输同一个文件时从中断的位置继续。
+//
+// 传输在任意 netx.FrameConn 上进行，例如 *netx.Client 或者 netx.Trace 的返回值；
+// 阻塞在读取上时不会响应 ctx，需要立即中止时关闭连接。
+package transfer
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultChunkSize Sender.ChunkSize 为 0 时每块的字节数
+const DefaultChunkSize = 64 << 10
+
+var (
+	// ErrChecksumMismatch 收到的文件与元数据中的 SHA-256 不一致
+	ErrChecksumMismatch = errors.New("transfer: checksum mismatch")
+	// ErrBadName 文件名为空或者包含路径
+	ErrBadName = errors.New("transfer: bad file name")
+	// ErrTooLarge 文件超过 Receiver.MaxSize
+	ErrTooLarge = errors.New("transfer: file too large")
+	// ErrUnexpectedFrame 收到的消息不符合协议
+	ErrUnexpectedFrame = errors.New("transfer: unexpected frame")
+)
+
+// RejectError 接收方拒绝了文件或者校验失败，Msg 是接收方的错误信息
+type RejectError struct {
+	Msg string
+}
+
+func (e *RejectError) Error() string {
+	return "transfer: rejected by receiver: " + e.Msg
+}
+
+// Progress 传输进度回调，done 包括续传之前已经收到的字节数
+type Progress func(done, total int64)
+
+// FileInfo 文件的元数据
+type FileInfo struct {
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"` // 十六进制
+	ChunkSize int    `json:"chunk_size"`
+}
+
+// 每个消息的第一个字节是类型
+const (
+	frameMeta   byte = 1 // 发送方 -> 接收方：JSON 编码的 FileInfo
+	frameResume byte = 2 // 接收方 -> 发送方：8 字节的起始偏移
+	frameChunk  byte = 3 // 发送方 -> 接收方：8 字节的偏移和数据
+	frameAck    byte = 4 // 接收方 -> 发送方：空表示成功，否则为错误信息
+)
+
+const offsetSize = 8
+
+func encodeMeta(info FileInfo) ([]byte, error) {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{frameMeta}, b...), nil
+}
+
+func decodeMeta(msg []byte) (FileInfo, error) {
+	var info FileInfo
+	if len(msg) == 0 || msg[0] != frameMeta {
+		return info, ErrUnexpectedFrame
+	}
+	if err := json.Unmarshal(msg[1:], &info); err != nil {
+		return info, err
+	}
+	if info.Size < 0 || info.ChunkSize <= 0 {
+		return info, ErrUnexpectedFrame
+	}
+	// SHA256 的前缀会用在 .part 文件名中，必须是 32 字节的十六进制，统一成小写和校验时的格式一致
+	sum, err := hex.DecodeString(info.SHA256)
+	if err != nil || len(sum) != 32 {
+		return info, ErrUnexpectedFrame
+	}
+	info.SHA256 = hex.EncodeToString(sum)
+	return info, nil
+}
+
+func encodeOffset(typ byte, off int64, data []byte) []byte {
+	b := make([]byte, 1+offsetSize, 1+offsetSize+len(data))
+	b[0] = typ
+	binary.LittleEndian.PutUint64(b[1:], uint64(off))
+	return append(b, data...)
+}
+
+func decodeOffset(typ byte, msg []byte) (int64, []byte, error) {
+	if len(msg) < 1+offsetSize || msg[0] != typ {
+		return 0, nil, ErrUnexpectedFrame
+	}
+	return int64(binary.LittleEndian.Uint64(msg[1:])), msg[1+offsetSize:], nil
+}
+
+// validName 文件名只能是一个路径元素，不能逃出接收目录
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." &&
+		!strings.ContainsAny(name, `/\`) && filepath.Base(name) == name
+}
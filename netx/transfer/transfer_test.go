@@ -0,0 +1,198 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"gopractice/netx"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// clientPair 返回通过 tcp 连接的两个 Client，net.Pipe 没有缓冲，一方拒绝时另一方可能还在发送
+func clientPair(t *testing.T) (*netx.Client, *netx.Client) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	a, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close(); b.Close() })
+	return netx.NewClient(a), netx.NewClient(b)
+}
+
+// transfer 在一对新连接上用 s 发送 data，返回双方的错误
+func transfer(t *testing.T, s *Sender, r *Receiver, fc func(netx.FrameConn) netx.FrameConn, data []byte) (sendErr, recvErr error) {
+	t.Helper()
+	a, b := clientPair(t)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := r.Receive(context.Background(), b)
+		errc <- err
+	}()
+	var conn netx.FrameConn = a
+	if fc != nil {
+		conn = fc(a)
+	}
+	sendErr = s.Send(context.Background(), conn, "data.bin", bytes.NewReader(data), int64(len(data)))
+	return sendErr, <-errc
+}
+
+func randomData(t *testing.T, n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestTransfer(t *testing.T) {
+	data := randomData(t, 100<<10+123)
+	dir := t.TempDir()
+	var last [2]int64
+	s := &Sender{ChunkSize: 8 << 10}
+	r := &Receiver{Dir: dir, Progress: func(done, total int64) { last = [2]int64{done, total} }}
+	if sendErr, recvErr := transfer(t, s, r, nil, data); sendErr != nil || recvErr != nil {
+		t.Fatalf("transfer errors: send %v, receive %v", sendErr, recvErr)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "data.bin"))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("received %d bytes, %v, want the original %d bytes", len(got), err, len(data))
+	}
+	if last != [2]int64{int64(len(data)), int64(len(data))} {
+		t.Errorf("last progress = %v", last)
+	}
+	if parts, _ := filepath.Glob(filepath.Join(dir, "*.part")); len(parts) != 0 {
+		t.Errorf("leftover part files %v", parts)
+	}
+}
+
+// brokenConn 发送 n 个消息之后断开连接
+type brokenConn struct {
+	*netx.Client
+	n int
+}
+
+var errBroken = errors.New("connection broken")
+
+func (c *brokenConn) Send(msg []byte) error {
+	if c.n == 0 {
+		c.Close()
+		return errBroken
+	}
+	c.n--
+	return c.Client.Send(msg)
+}
+
+func TestTransferResume(t *testing.T) {
+	data := randomData(t, 50<<10)
+	dir := t.TempDir()
+	s := &Sender{ChunkSize: 4 << 10}
+	r := &Receiver{Dir: dir}
+
+	// 元数据和 5 块之后断开
+	sendErr, recvErr := transfer(t, s, r, func(fc netx.FrameConn) netx.FrameConn {
+		return &brokenConn{Client: fc.(*netx.Client), n: 6}
+	}, data)
+	if sendErr != errBroken || recvErr == nil {
+		t.Fatalf("interrupted transfer errors: send %v, receive %v", sendErr, recvErr)
+	}
+
+	var first int64 = -1
+	s.Progress = func(done, total int64) {
+		if first < 0 {
+			first = done
+		}
+	}
+	if sendErr, recvErr := transfer(t, s, r, nil, data); sendErr != nil || recvErr != nil {
+		t.Fatalf("resumed transfer errors: send %v, receive %v", sendErr, recvErr)
+	}
+	if first != 5*4<<10 {
+		t.Errorf("resumed at %d, want %d", first, 5*4<<10)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "data.bin")); !bytes.Equal(got, data) {
+		t.Error("resumed file differs from the original")
+	}
+}
+
+func TestTransferReject(t *testing.T) {
+	data := randomData(t, 10<<10)
+	r := &Receiver{Dir: t.TempDir(), MaxSize: 1 << 10}
+	sendErr, recvErr := transfer(t, new(Sender), r, nil, data)
+	var re *RejectError
+	if !errors.As(sendErr, &re) || re.Msg != ErrTooLarge.Error() || recvErr != ErrTooLarge {
+		t.Errorf("transfer errors: send %v, receive %v, want ErrTooLarge", sendErr, recvErr)
+	}
+
+	a, _ := clientPair(t)
+	if err := new(Sender).Send(context.Background(), a, "../escape", bytes.NewReader(data), 1); err != ErrBadName {
+		t.Errorf("Send(../escape) = %v, want ErrBadName", err)
+	}
+}
+
+// TestDecodeMeta SHA256 的前缀会拼进 .part 文件名，不是 32 字节的十六进制时拒绝
+func TestDecodeMeta(t *testing.T) {
+	sum := strings.Repeat("ab", 32)
+	tests := []struct {
+		sha256 string
+		want   string
+		err    error
+	}{
+		{sum, sum, nil},
+		{strings.ToUpper(sum), sum, nil},
+		{"../../../../tmp/evil" + strings.Repeat("0", 44), "", ErrUnexpectedFrame},
+		{"zz" + sum[2:], "", ErrUnexpectedFrame},
+		{sum[:62], "", ErrUnexpectedFrame},
+		{"", "", ErrUnexpectedFrame},
+	}
+	for _, tt := range tests {
+		msg, err := encodeMeta(FileInfo{Name: "f", Size: 1, SHA256: tt.sha256, ChunkSize: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := decodeMeta(msg)
+		if err != tt.err || (err == nil && info.SHA256 != tt.want) {
+			t.Errorf("decodeMeta(sha256 %q) = %q, %v, want %q, %v", tt.sha256, info.SHA256, err, tt.want, tt.err)
+		}
+	}
+}
+
+// .part 文件中的数据已经损坏时校验失败，删除 .part 文件，下次从头开始
+func TestTransferCorruptPart(t *testing.T) {
+	data := randomData(t, 20<<10)
+	dir := t.TempDir()
+	s := &Sender{ChunkSize: 4 << 10}
+	r := &Receiver{Dir: dir}
+	sendErr, _ := transfer(t, s, r, func(fc netx.FrameConn) netx.FrameConn {
+		return &brokenConn{Client: fc.(*netx.Client), n: 3}
+	}, data)
+	if sendErr != errBroken {
+		t.Fatalf("interrupted transfer: %v", sendErr)
+	}
+	parts, _ := filepath.Glob(filepath.Join(dir, "*.part"))
+	if len(parts) != 1 {
+		t.Fatalf("part files = %v, want 1", parts)
+	}
+	os.WriteFile(parts[0], bytes.Repeat([]byte{0}, 8<<10), 0o644)
+
+	sendErr, recvErr := transfer(t, s, r, nil, data)
+	var re *RejectError
+	if !errors.As(sendErr, &re) || recvErr != ErrChecksumMismatch {
+		t.Fatalf("transfer errors: send %v, receive %v, want ErrChecksumMismatch", sendErr, recvErr)
+	}
+	if sendErr, recvErr := transfer(t, s, r, nil, data); sendErr != nil || recvErr != nil {
+		t.Fatalf("retry errors: send %v, receive %v", sendErr, recvErr)
+	}
+}
@@ -0,0 +1,177 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"gopractice/logx"
+	"gopractice/netx/metrics"
+	"gopractice/netx/workerpool"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultPacketSize UDPServer 默认的读缓冲大小，能容纳任意一个 UDP 数据报
+const DefaultPacketSize = 64 * 1024
+
+// UDPServer udp 服务端
+// 每个数据报交给 Handler 处理，回复可以通过 pc.WriteTo 发给 addr。
+type UDPServer struct {
+	// Addr 监听的地址，例如 "0.0.0.0:3000"
+	Addr string
+
+	// Handler 处理一个数据报，data 在 Handler 返回后不会被复用
+	Handler func(ctx context.Context, pc net.PacketConn, addr net.Addr, data []byte)
+
+	// PacketSize 读缓冲的大小，为 0 时使用 DefaultPacketSize，超出的部分会被截断
+	PacketSize int
+
+	// Pool 不为 nil 时数据报交给池中的 worker 并发处理，池满时停止读取；
+	// 为 nil 时在读取的 goroutine 中依次处理。Pool 由调用者负责 Stop。
+	Pool *workerpool.Pool
+
+	// Metrics 不为 nil 时上报收到的数据报数、读写字节数和 Handler 耗时
+	Metrics metrics.Collector
+
+	// Logger 为 nil 时不输出日志
+	Logger logx.Logger
+
+	mu     sync.Mutex
+	pc     net.PacketConn
+	cancel context.CancelFunc
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// ListenAndServe 监听 s.Addr 并开始处理数据报
+func (s *UDPServer) ListenAndServe() error {
+	pc, err := net.ListenPacket("udp", s.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(pc)
+}
+
+// Serve 从 pc 读取数据报，直到 Shutdown 被调用，之后返回 ErrServerClosed
+func (s *UDPServer) Serve(pc net.PacketConn) error {
+	defer pc.Close()
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.pc = pc
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	size := s.PacketSize
+	if size <= 0 {
+		size = DefaultPacketSize
+	}
+	buf := make([]byte, size)
+	out := pc
+	if s.Metrics != nil {
+		out = &meteredPacketConn{PacketConn: pc, m: s.Metrics}
+	}
+	s.logger().Infof("服务端已启动，监听 %s", pc.LocalAddr())
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				s.logger().Errorf("读取数据失败 %v", err)
+				continue
+			}
+			return err
+		}
+
+		// 与 Shutdown 中的 wg.Wait 互斥，保证 Shutdown 之后不会再有新的 Handler
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return ErrServerClosed
+		}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		if s.Metrics != nil {
+			s.Metrics.IncCounter(metrics.PacketsRead)
+			s.Metrics.AddCounter(metrics.BytesRead, int64(n))
+		}
+
+		data := append([]byte(nil), buf[:n]...)
+		task := func() {
+			defer s.wg.Done()
+			if s.Metrics != nil {
+				defer func(start time.Time) {
+					s.Metrics.ObserveLatency(metrics.HandlerLatency, time.Since(start))
+				}(time.Now())
+			}
+			if s.Handler != nil {
+				s.Handler(ctx, out, addr, data)
+			}
+		}
+		if s.Pool == nil {
+			task()
+			continue
+		}
+		if err := s.Pool.Submit(task); err != nil {
+			s.wg.Done()
+			return err
+		}
+	}
+}
+
+// Shutdown 关闭连接，取消 Handler 的 Context，并等待正在处理的数据报处理完。
+// 如果 ctx 先结束，则返回 ctx.Err()。
+func (s *UDPServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	if s.pc != nil {
+		s.pc.Close()
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LocalAddr 返回正在监听的地址，Serve 之前返回 nil
+func (s *UDPServer) LocalAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pc == nil {
+		return nil
+	}
+	return s.pc.LocalAddr()
+}
+
+func (s *UDPServer) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *UDPServer) logger() logx.Logger {
+	if s.Logger == nil {
+		return logx.Nop
+	}
+	return s.Logger
+}
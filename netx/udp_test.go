@@ -0,0 +1,61 @@
+package netx
+
+import (
+	"context"
+	"errors"
+	"gopractice/netx/workerpool"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUDPServerWithPool(t *testing.T) {
+	pool := workerpool.New(4, 16)
+	defer pool.Stop()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &UDPServer{
+		Pool: pool,
+		Handler: func(ctx context.Context, pc net.PacketConn, addr net.Addr, data []byte) {
+			msgs, _ := DecodePacket(data)
+			var reply []byte
+			for _, m := range msgs {
+				reply = AppendFrame(reply, m)
+			}
+			pc.WriteTo(reply, addr)
+		},
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- s.Serve(pc) }()
+
+	conn, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	req := AppendFrame(AppendFrame(nil, []byte("hello")), []byte("server"))
+	if _, err := conn.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := DecodePacket(buf[:n])
+	if err != nil || len(msgs) != 2 || string(msgs[0]) != "hello" || string(msgs[1]) != "server" {
+		t.Errorf("reply = %q, %v", msgs, err)
+	}
+
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-serveErr; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve() = %v, want %v", err, ErrServerClosed)
+	}
+}
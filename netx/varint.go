@@ -0,0 +1,108 @@
+package netx
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// FrameCodec 消息的封包和拆包方式，Framer、VarintFramer 都实现了这个接口，
+// 服务端和客户端只依赖这个接口，更换封包方式时不需要修改处理逻辑。
+type FrameCodec interface {
+	// WriteFrame 把 payload 封包后写入 w
+	WriteFrame(w io.Writer, payload []byte) error
+	// ReadFrame 从 r 中读取一个完整的消息
+	ReadFrame(r *bufio.Reader) ([]byte, error)
+}
+
+// VarintFramer 使用 varint 编码长度头的封包方式
+// 长度小于 128 的消息只需要 1 个字节的头，比固定 4 字节的 Framer 更省流量。
+type VarintFramer struct {
+	maxSize int // 为 0 时使用 DefaultMaxMessageSize
+}
+
+// VarintOption 创建 VarintFramer 时的可选配置
+type VarintOption func(f *VarintFramer)
+
+// WithVarintMaxSize 设置 ReadFrame 允许的最大消息长度，n<=0 时使用 DefaultMaxMessageSize
+func WithVarintMaxSize(n int) VarintOption {
+	return func(f *VarintFramer) {
+		f.maxSize = n
+	}
+}
+
+// NewVarintFramer 创建一个 VarintFramer
+func NewVarintFramer(opts ...VarintOption) *VarintFramer {
+	f := &VarintFramer{}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// WriteFrame 把 payload 封包后一次性写入 w
+func (f *VarintFramer) WriteFrame(w io.Writer, payload []byte) error {
+	if int64(len(payload)) > maxFrameLen {
+		return ErrInvalidLength
+	}
+	_, err := w.Write(f.AppendFrame(make([]byte, 0, binary.MaxVarintLen32+len(payload)), payload))
+	return err
+}
+
+// ReadFrame 从 r 中读取一个完整的消息
+// 长度头本身也可能被拆开到达，ReadUvarint 会逐个字节读取直到得到完整的长度。
+// 长度超过 WithVarintMaxSize 时在分配内存之前返回 ErrFrameTooLarge。
+func (f *VarintFramer) ReadFrame(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		if err != io.EOF && err != io.ErrUnexpectedEOF {
+			// varint 超过了 64 位
+			err = ErrInvalidLength
+		}
+		return nil, err
+	}
+	if length > maxFrameLen {
+		return nil, ErrInvalidLength
+	}
+	if length > uint64(maxSizeOr(f.maxSize)) {
+		return nil, ErrFrameTooLarge
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return payload, nil
+}
+
+// AppendFrame 把 payload 封包后追加到 dst
+func (f *VarintFramer) AppendFrame(dst, payload []byte) []byte {
+	var h [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(h[:], uint64(len(payload)))
+	dst = append(dst, h[:n]...)
+	return append(dst, payload...)
+}
+
+// DecodePacket 解出一个 UDP 数据报中的所有消息，返回的消息引用 b 的内存
+func (f *VarintFramer) DecodePacket(b []byte) ([][]byte, error) {
+	var msgs [][]byte
+	for len(b) > 0 {
+		length, n := binary.Uvarint(b)
+		if n == 0 {
+			return msgs, ErrTruncated
+		}
+		if n < 0 || length > maxFrameLen {
+			return msgs, ErrInvalidLength
+		}
+		b = b[n:]
+		if uint64(len(b)) < length {
+			return msgs, ErrTruncated
+		}
+		msgs = append(msgs, b[:length:length])
+		b = b[length:]
+	}
+	return msgs, nil
+}
@@ -0,0 +1,97 @@
+package netx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestVarintFramer(t *testing.T) {
+	f := NewVarintFramer()
+	msgs := []string{"hi", "", strings.Repeat("x", 300), strings.Repeat("y", 70000)}
+
+	var buf bytes.Buffer
+	for _, m := range msgs {
+		if err := f.WriteFrame(&buf, []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 短消息只需要 1 个字节的头
+	if buf.Bytes()[0] != 2 {
+		t.Errorf("header of a 2 byte message = %x, want 02", buf.Bytes()[0])
+	}
+
+	// 每次只到达一个字节，300 字节消息的两字节长度头也会被拆开
+	r := bufio.NewReader(oneByteReader{bytes.NewReader(buf.Bytes())})
+	for i, want := range msgs {
+		got, err := f.ReadFrame(r)
+		if err != nil || string(got) != want {
+			t.Fatalf("ReadFrame #%d = %d bytes, %v, want %d bytes", i, len(got), err, len(want))
+		}
+	}
+	if _, err := f.ReadFrame(r); err != io.EOF {
+		t.Errorf("ReadFrame at end = %v, want io.EOF", err)
+	}
+
+	got, err := f.DecodePacket(buf.Bytes())
+	if err != nil || len(got) != len(msgs) {
+		t.Errorf("DecodePacket() = %d messages, %v", len(got), err)
+	}
+}
+
+func TestVarintFramerErrors(t *testing.T) {
+	f := NewVarintFramer()
+	// 长度头只到达了一半
+	partial := []byte{0xac}
+	if _, err := f.ReadFrame(bufio.NewReader(bytes.NewReader(partial))); err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadFrame(partial varint) = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if _, err := f.DecodePacket(partial); err != ErrTruncated {
+		t.Errorf("DecodePacket(partial varint) = %v, want %v", err, ErrTruncated)
+	}
+
+	overflow := bytes.Repeat([]byte{0xff}, 11)
+	if _, err := f.ReadFrame(bufio.NewReader(bytes.NewReader(overflow))); err != ErrInvalidLength {
+		t.Errorf("ReadFrame(overflow) = %v, want %v", err, ErrInvalidLength)
+	}
+	if _, err := f.DecodePacket(overflow); err != ErrInvalidLength {
+		t.Errorf("DecodePacket(overflow) = %v, want %v", err, ErrInvalidLength)
+	}
+}
+
+// 服务端和客户端只需要在创建 Client 时选择 FrameCodec，处理逻辑不变
+func TestClientFrameCodec(t *testing.T) {
+	for _, fc := range []FrameCodec{NewFramer(), NewFramer(WithByteOrder(binary.BigEndian)), NewVarintFramer()} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &Server{Handler: func(ctx context.Context, conn net.Conn) {
+			c := NewClient(conn, WithFrameCodec(fc))
+			for {
+				msg, err := c.Recv()
+				if err != nil {
+					return
+				}
+				c.Send(msg)
+			}
+		}}
+		go s.Serve(l)
+
+		c, err := Dial(l.Addr().String(), WithFrameCodec(fc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Send([]byte("hello"))
+		if msg, err := c.Recv(); err != nil || string(msg) != "hello" {
+			t.Errorf("%T: Recv() = %q, %v", fc, msg, err)
+		}
+		c.Close()
+		s.Shutdown(context.Background())
+	}
+}
@@ -0,0 +1,199 @@
+// Package workerpool 有界的 goroutine 池，TCP 和 UDP 服务端共用
+// 池中有固定数量的 worker 和固定长度的任务队列，队列满时 Submit 会阻塞，
+// 由此把压力反馈给调用者（例如停止 Accept 或停止读取数据报）。
+package workerpool
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrStopped Stop 之后提交任务返回的错误
+var ErrStopped = errors.New("workerpool: pool stopped")
+
+// Option 创建 Pool 时的可选配置
+type Option func(p *Pool)
+
+// WithPanicHandler 任务 panic 时调用 h，默认忽略 panic
+// 无论是否设置，panic 都只影响当前任务，worker 会继续执行后续任务。
+func WithPanicHandler(h func(v any)) Option {
+	return func(p *Pool) {
+		p.panicHandler = h
+	}
+}
+
+// Pool 有界的 goroutine 池
+type Pool struct {
+	tasks        chan func()
+	urgent       chan func()   // 高优先级的任务，worker 优先执行
+	slots        chan struct{} // 两个队列共用的容量，提交前占用一个位置，worker 取出任务后释放；队列长度为 0 时为 nil
+	panicHandler func(v any)
+	wg           sync.WaitGroup
+	stop         chan struct{} // Stop 时关闭，唤醒等待队列空位的 Submit 和空闲的 worker
+
+	// mu 保护 stopped：Submit 占到位置之后持有读锁检查并发送，这时发送不会阻塞；
+	// Stop 持有写锁设置 stopped，之后不会再有任务进入队列，worker 取完队列中的任务就可以退出
+	mu      sync.RWMutex
+	stopped bool
+}
+
+// New 创建有 size 个 worker、队列长度为 queue 的池，size 小于 1 时按 1 处理
+// queue 是普通任务和高优先级任务合计最多排队的数量。
+func New(size, queue int, opts ...Option) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	if queue < 0 {
+		queue = 0
+	}
+
+	p := &Pool{tasks: make(chan func(), queue), urgent: make(chan func(), queue), stop: make(chan struct{})}
+	if queue > 0 {
+		p.slots = make(chan struct{}, queue)
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.wg.Add(size)
+	for i := 0; i < size; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// Submit 提交一个任务，队列满时阻塞直到有空位
+func (p *Pool) Submit(task func()) error {
+	return p.submit(p.tasks, task)
+}
+
+// SubmitPriority 按优先级提交一个任务，只区分两级：prio 大于 0 的任务进入高优先级队列，其余进入普通队列，
+// 同一级内按提交的顺序执行，prio 的具体大小不影响顺序。空闲的 worker 总是先执行高优先级队列中的任务，
+// 两个队列共用 New 的 queue 个位置，其余与 Submit 相同。
+// 使用 contextx 时 prio 通常来自 source.PriorityFrom(ctx)。
+func (p *Pool) SubmitPriority(prio int, task func()) error {
+	if prio > 0 {
+		return p.submit(p.urgent, task)
+	}
+	return p.submit(p.tasks, task)
+}
+
+func (p *Pool) submit(queue chan func(), task func()) error {
+	if p.slots == nil {
+		// 没有队列时直接交给空闲的 worker，发送成功说明 worker 已经收到，不会丢失
+		select {
+		case queue <- task:
+			return nil
+		case <-p.stop:
+			return ErrStopped
+		}
+	}
+	select {
+	case p.slots <- struct{}{}:
+	case <-p.stop:
+		return ErrStopped
+	}
+	return p.enqueue(queue, task)
+}
+
+// enqueue 把任务放入队列，调用前已经占到了一个位置，发送不会阻塞
+func (p *Pool) enqueue(queue chan func(), task func()) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.stopped {
+		<-p.slots
+		return ErrStopped
+	}
+	queue <- task
+	return nil
+}
+
+// TrySubmit 尝试提交一个任务，队列满或者已经 Stop 时返回 false
+func (p *Pool) TrySubmit(task func()) bool {
+	if p.slots == nil {
+		select {
+		case <-p.stop:
+			return false
+		default:
+		}
+		select {
+		case p.tasks <- task:
+			return true
+		default:
+			return false
+		}
+	}
+	select {
+	case p.slots <- struct{}{}:
+		return p.enqueue(p.tasks, task) == nil
+	default:
+		return false
+	}
+}
+
+// Stop 不再接受新的任务，等待队列中的任务和正在执行的任务全部完成，可以多次调用
+// 阻塞在 Submit 上的调用者返回 ErrStopped；任务中向同一个池提交也不会让 Stop 卡住。
+func (p *Pool) Stop() {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.stop)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+	for {
+		// 先检查高优先级队列，为空时再同时等待两个队列
+		select {
+		case task := <-p.urgent:
+			p.run(task)
+			continue
+		default:
+		}
+
+		select {
+		case task := <-p.urgent:
+			p.run(task)
+		case task := <-p.tasks:
+			p.run(task)
+		case <-p.stop:
+			p.drain()
+			return
+		}
+	}
+}
+
+// drain Stop 之后执行队列中剩下的任务，Stop 设置 stopped 之后队列只会变少
+func (p *Pool) drain() {
+	for {
+		select {
+		case task := <-p.urgent:
+			p.run(task)
+		default:
+			select {
+			case task := <-p.urgent:
+				p.run(task)
+			case task := <-p.tasks:
+				p.run(task)
+			default:
+				return
+			}
+		}
+	}
+}
+
+func (p *Pool) run(task func()) {
+	if p.slots != nil {
+		<-p.slots
+	}
+	defer func() {
+		if v := recover(); v != nil && p.panicHandler != nil {
+			p.panicHandler(v)
+		}
+	}()
+	task()
+}
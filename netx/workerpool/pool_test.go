@@ -0,0 +1,222 @@
+package workerpool
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackpressure(t *testing.T) {
+	p := New(1, 1)
+	defer p.Stop()
+
+	block := make(chan struct{})
+	running := make(chan struct{})
+	// 第一个任务占住唯一的 worker，第二个任务占住队列
+	p.Submit(func() {
+		close(running)
+		<-block
+	})
+	<-running
+	if !p.TrySubmit(func() {}) {
+		t.Fatal("TrySubmit() = false with an empty queue")
+	}
+	if p.TrySubmit(func() {}) {
+		t.Fatal("TrySubmit() = true with a full queue")
+	}
+
+	submitted := make(chan struct{})
+	go func() {
+		p.Submit(func() {})
+		close(submitted)
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("Submit did not block with a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(block)
+	select {
+	case <-submitted:
+	case <-time.After(time.Second):
+		t.Fatal("Submit still blocked after the queue drained")
+	}
+}
+
+func TestStopDrainsQueue(t *testing.T) {
+	p := New(2, 10)
+
+	var done int32
+	for i := 0; i < 10; i++ {
+		p.Submit(func() {
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&done, 1)
+		})
+	}
+	p.Stop()
+
+	if n := atomic.LoadInt32(&done); n != 10 {
+		t.Errorf("%d tasks finished before Stop returned, want 10", n)
+	}
+	if err := p.Submit(func() {}); err != ErrStopped {
+		t.Errorf("Submit after Stop = %v, want %v", err, ErrStopped)
+	}
+	if p.TrySubmit(func() {}) {
+		t.Error("TrySubmit after Stop = true")
+	}
+	p.Stop()
+}
+
+func TestPanicIsolation(t *testing.T) {
+	panics := make(chan any, 1)
+	p := New(1, 1, WithPanicHandler(func(v any) { panics <- v }))
+
+	p.Submit(func() { panic("boom") })
+	ran := make(chan struct{})
+	p.Submit(func() { close(ran) })
+	p.Stop()
+
+	if v := <-panics; v != "boom" {
+		t.Errorf("panic handler got %v, want boom", v)
+	}
+	select {
+	case <-ran:
+	default:
+		t.Error("task after the panicking one did not run")
+	}
+}
+
+func TestSubmitPriority(t *testing.T) {
+	p := New(1, 10)
+	defer p.Stop()
+
+	block := make(chan struct{})
+	running := make(chan struct{})
+	p.Submit(func() {
+		close(running)
+		<-block
+	})
+	<-running
+
+	// worker 被占住时排队的任务，高优先级的先执行
+	var mu sync.Mutex
+	var order []int
+	record := func(i int) func() {
+		return func() {
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+		}
+	}
+	p.SubmitPriority(0, record(1))
+	p.SubmitPriority(-1, record(2))
+	p.SubmitPriority(1, record(3))
+	p.SubmitPriority(2, record(4))
+	close(block)
+	p.Stop()
+
+	want := []int{3, 4, 1, 2}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+	if err := p.SubmitPriority(1, func() {}); err != ErrStopped {
+		t.Errorf("SubmitPriority() after Stop = %v", err)
+	}
+}
+
+// TestPriorityCapacity 高优先级队列和普通队列共用 queue 个位置
+func TestPriorityCapacity(t *testing.T) {
+	p := New(1, 2)
+	defer p.Stop()
+
+	block := make(chan struct{})
+	running := make(chan struct{})
+	p.Submit(func() {
+		close(running)
+		<-block
+	})
+	<-running
+	p.SubmitPriority(1, func() {})
+	p.Submit(func() {})
+	if p.TrySubmit(func() {}) {
+		t.Fatal("TrySubmit() = true with both queues holding 2 tasks")
+	}
+
+	submitted := make(chan struct{})
+	go func() {
+		p.SubmitPriority(1, func() {})
+		close(submitted)
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("SubmitPriority did not block with a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(block)
+	select {
+	case <-submitted:
+	case <-time.After(time.Second):
+		t.Fatal("SubmitPriority still blocked after the queue drained")
+	}
+}
+
+// TestStopWithBlockedSubmit 队列满时阻塞的 Submit 不会卡住 Stop 和 TrySubmit，
+// 任务中向同一个池提交也不会让 Stop 死锁
+func TestStopWithBlockedSubmit(t *testing.T) {
+	p := New(1, 1)
+	block := make(chan struct{})
+	running := make(chan struct{})
+	resubmit := make(chan error, 1)
+	p.Submit(func() {
+		close(running)
+		<-block
+		resubmit <- p.Submit(func() {})
+	})
+	<-running
+	p.Submit(func() {})
+	submitted := make(chan error, 1)
+	go func() { submitted <- p.Submit(func() {}) }()
+	time.Sleep(20 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		p.Stop()
+		close(stopped)
+	}()
+	select {
+	case err := <-submitted:
+		if err != ErrStopped {
+			t.Errorf("blocked Submit() = %v, want ErrStopped", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Submit still blocked after Stop")
+	}
+	tried := make(chan bool, 1)
+	go func() { tried <- p.TrySubmit(func() {}) }()
+	select {
+	case ok := <-tried:
+		if ok {
+			t.Error("TrySubmit() = true during Stop")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("TrySubmit blocked during Stop")
+	}
+
+	close(block)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not return")
+	}
+	if err := <-resubmit; err != nil && err != ErrStopped {
+		t.Errorf("Submit() from a task = %v", err)
+	}
+}
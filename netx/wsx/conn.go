@@ -0,0 +1,388 @@
+package wsx
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MessageType 数据消息的类型
+type MessageType int
+
+const (
+	// TextMessage 文本消息，内容必须是合法的 UTF-8
+	TextMessage MessageType = opText
+	// BinaryMessage 二进制消息
+	BinaryMessage MessageType = opBinary
+)
+
+// 关闭帧中的状态码，见 RFC 6455 7.4.1
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005 // 关闭帧中没有状态码，不能出现在发送的关闭帧中
+	CloseInvalidPayload  = 1007
+	CloseMessageTooBig   = 1009
+)
+
+var (
+	// ErrProtocol 对方发送的帧不符合协议，连接会以 CloseProtocolError 关闭
+	ErrProtocol = errors.New("wsx: protocol error")
+	// ErrInvalidUTF8 文本消息不是合法的 UTF-8，连接会以 CloseInvalidPayload 关闭
+	ErrInvalidUTF8 = errors.New("wsx: invalid UTF-8 in text message")
+	// ErrMessageTooLarge 消息超过了 SetReadLimit 设置的大小，连接会以 CloseMessageTooBig 关闭
+	ErrMessageTooLarge = errors.New("wsx: message too large")
+	// ErrClosed 已经发送了关闭帧，不能再发送消息
+	ErrClosed = errors.New("wsx: close frame already sent")
+)
+
+// CloseError 收到对方的关闭帧时 ReadMessage 返回的错误
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("wsx: closed with code %d %q", e.Code, e.Reason)
+}
+
+// DefaultReadLimit 默认的最大消息长度
+const DefaultReadLimit = 1 << 20
+
+// closeTimeout Close 等待对方回复关闭帧的最长时间
+const closeTimeout = time.Second
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+
+	finBit  = 0x80
+	maskBit = 0x80
+
+	// maxControlPayload 控制帧（close、ping、pong）的最大长度
+	maxControlPayload = 125
+)
+
+// Conn 握手成功之后的 WebSocket 连接
+// WriteMessage、Ping 可以在多个 goroutine 中同时调用，ReadMessage 同一时间只能有一个 goroutine 调用。
+// ReadMessage 会自动回复 ping，并在收到关闭帧时完成关闭握手。
+type Conn struct {
+	conn      net.Conn
+	br        *bufio.Reader
+	client    bool // 客户端发送的帧需要加掩码，服务端不能加
+	readLimit int64
+
+	wmu       sync.Mutex
+	closeSent bool
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, client bool) *Conn {
+	return &Conn{conn: conn, br: br, client: client, readLimit: DefaultReadLimit}
+}
+
+// SetReadLimit 设置最大消息长度，分片消息按所有分片的总长度计算
+func (c *Conn) SetReadLimit(n int64) {
+	c.readLimit = n
+}
+
+// NetConn 返回底层的连接
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
+
+// ReadMessage 读取一个完整的数据消息，分片的消息会被拼接起来。
+// 收到关闭帧时回复关闭帧并关闭连接，返回 *CloseError；
+// 对方违反协议时发送对应状态码的关闭帧，关闭连接并返回 ErrProtocol 等错误。
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var typ MessageType
+	var msg []byte
+	for {
+		f, err := c.readFrame(c.readLimit - int64(len(msg)))
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+
+		switch f.opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, f.payload); err != nil && err != ErrClosed {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, c.handleClose(f.payload)
+		case opText, opBinary:
+			if typ != 0 {
+				return 0, nil, c.fail(fmt.Errorf("%w: new message before the previous one finished", ErrProtocol))
+			}
+			typ = MessageType(f.opcode)
+		case opContinuation:
+			if typ == 0 {
+				return 0, nil, c.fail(fmt.Errorf("%w: unexpected continuation frame", ErrProtocol))
+			}
+		default:
+			return 0, nil, c.fail(fmt.Errorf("%w: unknown opcode %#x", ErrProtocol, f.opcode))
+		}
+
+		msg = append(msg, f.payload...)
+		if f.fin {
+			if typ == TextMessage && !utf8.Valid(msg) {
+				return 0, nil, c.fail(ErrInvalidUTF8)
+			}
+			if msg == nil {
+				msg = []byte{}
+			}
+			return typ, msg, nil
+		}
+	}
+}
+
+// WriteMessage 把 data 作为一个完整的帧发送，不会分片
+func (c *Conn) WriteMessage(typ MessageType, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return fmt.Errorf("wsx: invalid message type %d", typ)
+	}
+	return c.writeFrame(byte(typ), data)
+}
+
+// Ping 发送 ping，对方回复的 pong 由 ReadMessage 读取并忽略，data 不能超过 125 字节
+func (c *Conn) Ping(data []byte) error {
+	if len(data) > maxControlPayload {
+		return fmt.Errorf("%w: ping payload too large", ErrProtocol)
+	}
+	return c.writeFrame(opPing, data)
+}
+
+// WriteClose 发送关闭帧，之后不能再发送消息，对方回复的关闭帧由 ReadMessage 读取。
+// 有其他 goroutine 在调用 ReadMessage 时使用它发起关闭握手。
+func (c *Conn) WriteClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > maxControlPayload {
+		return fmt.Errorf("%w: close reason too long", ErrProtocol)
+	}
+	return c.writeFrame(opClose, payload)
+}
+
+// Close 发起关闭握手：发送关闭帧，等待对方回复关闭帧（最多 1 秒）后关闭连接。
+// 不能与 ReadMessage 同时调用。
+func (c *Conn) Close(code int, reason string) error {
+	defer c.conn.Close()
+	if err := c.WriteClose(code, reason); err != nil {
+		return err
+	}
+	c.conn.SetReadDeadline(time.Now().Add(closeTimeout))
+	for {
+		f, err := c.readFrame(c.readLimit)
+		if err != nil {
+			return err
+		}
+		if f.opcode == opClose {
+			return nil
+		}
+	}
+}
+
+// handleClose 处理收到的关闭帧：还没有发送过关闭帧时回复相同的状态码，然后关闭连接
+func (c *Conn) handleClose(payload []byte) error {
+	ce := &CloseError{Code: CloseNoStatus}
+	switch {
+	case len(payload) == 1:
+		return c.fail(fmt.Errorf("%w: invalid close payload", ErrProtocol))
+	case len(payload) >= 2:
+		ce.Code = int(binary.BigEndian.Uint16(payload))
+		ce.Reason = string(payload[2:])
+		if !validCloseCode(ce.Code) {
+			return c.fail(fmt.Errorf("%w: invalid close code %d", ErrProtocol, ce.Code))
+		}
+		if !utf8.ValidString(ce.Reason) {
+			return c.fail(ErrInvalidUTF8)
+		}
+	}
+
+	var reply []byte
+	if ce.Code != CloseNoStatus {
+		reply = payload[:2]
+	}
+	if err := c.writeFrame(opClose, reply); err != nil && err != ErrClosed {
+		c.conn.Close()
+		return err
+	}
+	c.conn.Close()
+	return ce
+}
+
+// validCloseCode 判断收到的关闭帧中的状态码是否合法，见 RFC 6455 7.4：
+// 1004 保留，1005、1006、1015 只在本地表示关闭原因，不能出现在关闭帧中，
+// 1000~2999 中其余没有定义的由协议保留，3000~4999 供库和应用使用。
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	}
+	return code >= 3000 && code <= 4999
+}
+
+// fail 对方违反协议时发送对应的关闭帧并关闭连接，其他错误原样返回
+func (c *Conn) fail(err error) error {
+	code := 0
+	switch {
+	case errors.Is(err, ErrProtocol):
+		code = CloseProtocolError
+	case errors.Is(err, ErrInvalidUTF8):
+		code = CloseInvalidPayload
+	case errors.Is(err, ErrMessageTooLarge):
+		code = CloseMessageTooBig
+	default:
+		return err
+	}
+	c.WriteClose(code, "")
+	c.conn.Close()
+	return err
+}
+
+type frame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+// readFrame 读取一个帧并去掉掩码，数据帧的长度超过 limit 时返回 ErrMessageTooLarge
+func (c *Conn) readFrame(limit int64) (frame, error) {
+	var h [2]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		return frame{}, err
+	}
+	f := frame{fin: h[0]&finBit != 0, opcode: h[0] & 0x0f}
+	if h[0]&0x70 != 0 {
+		return frame{}, fmt.Errorf("%w: reserved bits set", ErrProtocol)
+	}
+	masked := h[1]&maskBit != 0
+	if masked == c.client {
+		return frame{}, fmt.Errorf("%w: frame masking mismatch", ErrProtocol)
+	}
+
+	n := uint64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return frame{}, unexpectedEOF(err)
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return frame{}, unexpectedEOF(err)
+		}
+		n = binary.BigEndian.Uint64(b[:])
+		if n>>63 != 0 {
+			return frame{}, fmt.Errorf("%w: invalid payload length", ErrProtocol)
+		}
+	}
+	if f.opcode >= opClose {
+		if !f.fin || n > maxControlPayload {
+			return frame{}, fmt.Errorf("%w: invalid control frame", ErrProtocol)
+		}
+	} else if int64(n) > limit {
+		return frame{}, ErrMessageTooLarge
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, key[:]); err != nil {
+			return frame{}, unexpectedEOF(err)
+		}
+	}
+	f.payload = make([]byte, n)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return frame{}, unexpectedEOF(err)
+	}
+	if masked {
+		maskBytes(key, f.payload)
+	}
+	return f, nil
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrClosed
+	}
+	if opcode == opClose {
+		c.closeSent = true
+	}
+	b, err := appendFrame(make([]byte, 0, 14+len(payload)), true, opcode, payload, c.client)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(b)
+	return err
+}
+
+// appendFrame 把一个帧追加到 dst，mask 为 true 时使用随机的掩码
+func appendFrame(dst []byte, fin bool, opcode byte, payload []byte, mask bool) ([]byte, error) {
+	b0 := opcode
+	if fin {
+		b0 |= finBit
+	}
+	var b1 byte
+	if mask {
+		b1 = maskBit
+	}
+	n := len(payload)
+	switch {
+	case n <= 125:
+		dst = append(dst, b0, b1|byte(n))
+	case n <= 0xffff:
+		dst = append(dst, b0, b1|126, byte(n>>8), byte(n))
+	default:
+		dst = append(dst, b0, b1|127)
+		var l [8]byte
+		binary.BigEndian.PutUint64(l[:], uint64(n))
+		dst = append(dst, l[:]...)
+	}
+	if !mask {
+		return append(dst, payload...), nil
+	}
+
+	var key [4]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return nil, err
+	}
+	dst = append(dst, key[:]...)
+	start := len(dst)
+	dst = append(dst, payload...)
+	maskBytes(key, dst[start:])
+	return dst, nil
+}
+
+// maskBytes 用 key 对 b 做异或，加掩码和去掉掩码是同一个操作
+func maskBytes(key [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,181 @@
+// Package wsx 直接在 net.Conn 上实现的最小 WebSocket（RFC 6455）
+// 包括 HTTP Upgrade 握手、帧的掩码处理、ping/pong 和关闭握手，不支持扩展（例如压缩）和子协议协商。
+// 服务端使用 NewServer 创建，监听、Accept 和优雅关闭都由 netx.Server 完成。
+package wsx
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gopractice/netx"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrBadHandshake 握手请求或者响应不符合 WebSocket 协议
+var ErrBadHandshake = errors.New("wsx: bad handshake")
+
+const (
+	// HandshakeTimeout Upgrade 读取握手请求、发送响应的最长时间
+	HandshakeTimeout = 10 * time.Second
+	// MaxHandshakeSize Upgrade 读取的握手请求的最大长度，包括请求行和所有头部
+	MaxHandshakeSize = 8 << 10
+)
+
+// acceptGUID 计算 Sec-WebSocket-Accept 时拼接在 key 后面的固定字符串
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Handler 处理一个握手成功的 WebSocket 连接，返回后连接会被关闭
+type Handler interface {
+	ServeWS(ctx context.Context, c *Conn)
+}
+
+// HandlerFunc 把普通函数转换为 Handler
+type HandlerFunc func(ctx context.Context, c *Conn)
+
+// ServeWS 调用 f(ctx, c)
+func (f HandlerFunc) ServeWS(ctx context.Context, c *Conn) {
+	f(ctx, c)
+}
+
+// NewServer 返回一个处理 WebSocket 连接的 netx.Server，握手失败的连接会被直接关闭
+func NewServer(addr string, h Handler) *netx.Server {
+	return &netx.Server{
+		Addr: addr,
+		Handler: func(ctx context.Context, conn net.Conn) {
+			c, err := Upgrade(conn)
+			if err != nil {
+				return
+			}
+			h.ServeWS(ctx, c)
+		},
+	}
+}
+
+// Upgrade 读取客户端的 HTTP Upgrade 请求并完成握手，请求不合法时回复 400 并返回 ErrBadHandshake
+// 握手需要在 HandshakeTimeout 内完成，请求超过 MaxHandshakeSize 时返回 ErrBadHandshake，
+// 避免慢速或者超大的请求一直占用连接。握手成功后清除连接的超时。
+func Upgrade(conn net.Conn) (*Conn, error) {
+	if err := conn.SetDeadline(time.Now().Add(HandshakeTimeout)); err != nil {
+		return nil, err
+	}
+	// 握手之后的帧还要从 br 读取，所以握手完成后去掉长度限制，而不是换一个 Reader
+	lr := &io.LimitedReader{R: conn, N: MaxHandshakeSize}
+	br := bufio.NewReader(lr)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		if lr.N == 0 {
+			return nil, fmt.Errorf("%w: request larger than %d bytes", ErrBadHandshake, MaxHandshakeSize)
+		}
+		return nil, err
+	}
+	lr.N = math.MaxInt64
+	key, err := checkRequest(req)
+	if err != nil {
+		fmt.Fprintf(conn, "HTTP/1.1 400 Bad Request\r\nConnection: close\r\nContent-Length: %d\r\n\r\n%s", len(err.Error()), err)
+		return nil, err
+	}
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(resp)); err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+	return newConn(conn, br, false), nil
+}
+
+// checkRequest 检查握手请求，返回 Sec-WebSocket-Key
+func checkRequest(req *http.Request) (string, error) {
+	switch {
+	case req.Method != http.MethodGet:
+		return "", fmt.Errorf("%w: method %s", ErrBadHandshake, req.Method)
+	case !headerContains(req.Header, "Connection", "upgrade"):
+		return "", fmt.Errorf("%w: missing Connection: Upgrade", ErrBadHandshake)
+	case !headerContains(req.Header, "Upgrade", "websocket"):
+		return "", fmt.Errorf("%w: missing Upgrade: websocket", ErrBadHandshake)
+	case req.Header.Get("Sec-WebSocket-Version") != "13":
+		return "", fmt.Errorf("%w: unsupported version %q", ErrBadHandshake, req.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if b, err := base64.StdEncoding.DecodeString(key); err != nil || len(b) != 16 {
+		return "", fmt.Errorf("%w: invalid Sec-WebSocket-Key %q", ErrBadHandshake, key)
+	}
+	return key, nil
+}
+
+// headerContains 判断逗号分隔的头部中是否有 token，不区分大小写
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Dial 连接 WebSocket 服务端，path 为请求的路径，例如 "/"
+// 客户端发送的帧都会加上掩码，主要用于测试和 example。
+func Dial(addr, path string) (*Conn, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ClientHandshake(conn, addr, path)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// ClientHandshake 在已经建立的连接上发送握手请求，并检查服务端的响应
+func ClientHandshake(conn net.Conn, host, path string) (*Conn, error) {
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + host + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("%w: status %s", ErrBadHandshake, resp.Status)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, fmt.Errorf("%w: invalid Sec-WebSocket-Accept", ErrBadHandshake)
+	}
+	return newConn(conn, br, true), nil
+}
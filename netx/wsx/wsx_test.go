@@ -0,0 +1,187 @@
+package wsx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer 在随机端口上启动 echo 服务端，返回地址和服务端 ReadMessage 的最后一个错误
+func startServer(t *testing.T, limit int64) (string, <-chan error) {
+	t.Helper()
+	errc := make(chan error, 1)
+	s := NewServer("", HandlerFunc(func(ctx context.Context, c *Conn) {
+		if limit > 0 {
+			c.SetReadLimit(limit)
+		}
+		for {
+			typ, msg, err := c.ReadMessage()
+			if err != nil {
+				errc <- err
+				return
+			}
+			c.WriteMessage(typ, msg)
+		}
+	}))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+	return l.Addr().String(), errc
+}
+
+func TestEcho(t *testing.T) {
+	addr, errc := startServer(t, 0)
+	c, err := Dial(addr, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	big := bytes.Repeat([]byte("x"), 70000) // 需要 8 字节长度
+	tests := []struct {
+		typ MessageType
+		msg []byte
+	}{
+		{TextMessage, []byte("hello")},
+		{BinaryMessage, []byte{}},
+		{BinaryMessage, bytes.Repeat([]byte{0xff}, 300)},
+		{BinaryMessage, big},
+	}
+	for _, tt := range tests {
+		if err := c.WriteMessage(tt.typ, tt.msg); err != nil {
+			t.Fatal(err)
+		}
+		typ, msg, err := c.ReadMessage()
+		if err != nil || typ != tt.typ || !bytes.Equal(msg, tt.msg) {
+			t.Errorf("echo %d bytes = %d, %d bytes, %v", len(tt.msg), typ, len(msg), err)
+		}
+	}
+
+	// 分片的消息中间插入 ping，服务端回复 pong 并拼接出完整的消息
+	var raw []byte
+	raw, _ = appendFrame(raw, false, opText, []byte("hel"), true)
+	raw, _ = appendFrame(raw, true, opPing, []byte("p"), true)
+	raw, _ = appendFrame(raw, true, opContinuation, []byte("lo"), true)
+	c.conn.Write(raw)
+	f, err := c.readFrame(DefaultReadLimit)
+	if err != nil || f.opcode != opPong || string(f.payload) != "p" {
+		t.Errorf("readFrame() = %+v, %v, want pong", f, err)
+	}
+	if typ, msg, err := c.ReadMessage(); err != nil || typ != TextMessage || string(msg) != "hello" {
+		t.Errorf("ReadMessage() = %d, %q, %v", typ, msg, err)
+	}
+
+	// 关闭握手
+	if err := c.Close(CloseNormal, "bye"); err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+	var ce *CloseError
+	if err := <-errc; !errors.As(err, &ce) || ce.Code != CloseNormal || ce.Reason != "bye" {
+		t.Errorf("server ReadMessage() = %v, want close 1000 bye", err)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame func() []byte
+		code  int
+		err   error
+	}{
+		{"unmasked", func() []byte {
+			b, _ := appendFrame(nil, true, opText, []byte("a"), false)
+			return b
+		}, CloseProtocolError, ErrProtocol},
+		{"continuation", func() []byte {
+			b, _ := appendFrame(nil, true, opContinuation, []byte("a"), true)
+			return b
+		}, CloseProtocolError, ErrProtocol},
+		{"invalid utf8", func() []byte {
+			b, _ := appendFrame(nil, true, opText, []byte{0xff}, true)
+			return b
+		}, CloseInvalidPayload, ErrInvalidUTF8},
+		{"too large", func() []byte {
+			b, _ := appendFrame(nil, true, opBinary, make([]byte, 11), true)
+			return b
+		}, CloseMessageTooBig, ErrMessageTooLarge},
+		{"reserved close code", func() []byte {
+			b, _ := appendFrame(nil, true, opClose, []byte{0x03, 0xee}, true) // 1006
+			return b
+		}, CloseProtocolError, ErrProtocol},
+		{"close code out of range", func() []byte {
+			b, _ := appendFrame(nil, true, opClose, []byte{0x13, 0x88}, true) // 5000
+			return b
+		}, CloseProtocolError, ErrProtocol},
+	}
+	for _, tt := range tests {
+		addr, errc := startServer(t, 10)
+		c, err := Dial(addr, "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.conn.Write(tt.frame())
+		c.conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, _, err = c.ReadMessage()
+		var ce *CloseError
+		if !errors.As(err, &ce) || ce.Code != tt.code {
+			t.Errorf("%s: client ReadMessage() = %v, want close %d", tt.name, err, tt.code)
+		}
+		if err := <-errc; !errors.Is(err, tt.err) {
+			t.Errorf("%s: server ReadMessage() = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestBadHandshake(t *testing.T) {
+	addr, _ := startServer(t, 0)
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: x\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(string(body), "Upgrade") {
+		t.Errorf("response = %s %q, want 400", resp.Status, body)
+	}
+
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey() = %q, want the value from RFC 6455", got)
+	}
+}
+
+func TestUpgradeLimits(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	go func() {
+		io.WriteString(client, "GET / HTTP/1.1\r\nHost: x\r\nX-Padding: "+strings.Repeat("a", 2*MaxHandshakeSize))
+	}()
+	if _, err := Upgrade(server); !errors.Is(err, ErrBadHandshake) {
+		t.Errorf("Upgrade(oversized request) = %v, want ErrBadHandshake", err)
+	}
+	server.Close()
+
+	for _, code := range []int{1004, 1005, 1006, 1015, 2000, 5000} {
+		if validCloseCode(code) {
+			t.Errorf("validCloseCode(%d) = true", code)
+		}
+	}
+	for _, code := range []int{CloseNormal, CloseMessageTooBig, 1014, 3000, 4999} {
+		if !validCloseCode(code) {
+			t.Errorf("validCloseCode(%d) = false", code)
+		}
+	}
+}
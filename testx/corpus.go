@@ -0,0 +1,42 @@
+// Package testx 测试相关的辅助工具
+package testx
+
+import "testing"
+
+// Seed 模糊测试的一组种子输入，对应 (str string, value rune, n int) 形式的参数
+type Seed struct {
+	Str   string
+	Value rune
+	N     int
+}
+
+// Seeds 返回一组有代表性的种子输入
+// 覆盖了空串、多字节字符、组合字符、emoji、非法 UTF-8 以及 n 越界等情况，
+// 多个模糊测试共用这份语料可以保持一致，也不用在每个测试里重复 f.Add。
+func Seeds() []Seed {
+	return []Seed{
+		{"Hello, world!", 'A', 8},
+		{"", 'A', 0},
+		{"Hello", 'A', -1},
+		{"Hello", 'A', 100},
+		{"你好，世界", '世', 2},
+		{"été", 'A', 1},
+		{"🇨🇳🇺🇸", 'A', 1},
+		{"\xff\xfe\xfd", 'A', 1},
+	}
+}
+
+// AddSeeds 把 Seeds 依次添加到参数为 (string, rune, int) 的模糊测试中
+func AddSeeds(f *testing.F) {
+	AddSeedsFunc(f, func(s Seed) []any {
+		return []any{s.Str, s.Value, s.N}
+	})
+}
+
+// AddSeedsFunc 用于参数形式不同的模糊测试，由 args 把种子转换成 f.Add 的参数
+func AddSeedsFunc(f *testing.F, args func(s Seed) []any) {
+	f.Helper()
+	for _, s := range Seeds() {
+		f.Add(args(s)...)
+	}
+}
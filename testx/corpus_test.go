@@ -0,0 +1,37 @@
+package testx
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestSeeds(t *testing.T) {
+	seeds := Seeds()
+	if len(seeds) == 0 {
+		t.Fatal("Seeds() returned no seeds")
+	}
+	if want := (Seed{"Hello, world!", 'A', 8}); seeds[0] != want {
+		t.Errorf("Seeds()[0] = %v, want %v", seeds[0], want)
+	}
+	// 每次调用返回的内容一致
+	if !reflect.DeepEqual(seeds, Seeds()) {
+		t.Error("Seeds() is not deterministic")
+	}
+}
+
+func FuzzAddSeeds(f *testing.F) {
+	AddSeeds(f)
+
+	f.Fuzz(func(t *testing.T, str string, value rune, n int) {
+		// 种子输入对应的子测试名为 seed#0、seed#1 ...，按顺序与 Seeds 一一对应
+		var i int
+		if _, err := fmt.Sscanf(path.Base(t.Name()), "seed#%d", &i); err != nil {
+			return
+		}
+		if got, want := (Seed{str, value, n}), Seeds()[i]; got != want {
+			t.Errorf("seed #%d = %v, want %v", i, got, want)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package example
 
 import (
+	"gopractice/testx"
 	"strings"
 	"testing"
 	"unicode/utf8"
@@ -14,6 +15,8 @@ import (
 // 4. 重新执行第一步的命令
 // 5. 一般要求模糊测试运行至少几分钟
 func FuzzBasicOverwriteString(f *testing.F) {
+	testx.AddSeeds(f)
+
 	f.Fuzz(func(t *testing.T, str string, value rune, n int) {
 		OverwriteString(str, value, n)
 	})
@@ -21,7 +24,7 @@ func FuzzBasicOverwriteString(f *testing.F) {
 
 // 功能性测试
 func FuzzOverwriteStringSuffix(f *testing.F) {
-	f.Add("Hello, world!", 'A', 8)
+	testx.AddSeeds(f)
 
 	f.Fuzz(func(t *testing.T, str string, value rune, n int) {
 		result := OverwriteString(str, value, n)
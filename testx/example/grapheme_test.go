@@ -0,0 +1,64 @@
+package example
+
+import (
+	"gopractice/testx"
+	"strings"
+	"testing"
+
+	"github.com/rivo/uniseg"
+)
+
+// 国旗、带肤色的 emoji、组合字符等由多个 rune 组成，但用户看到的只是一个字符（字素簇）。
+// 按 rune 覆盖 "前 n 个字符" 会把这些字符拆开，留下半个国旗或者孤立的组合符号。
+
+func TestOverwriteGraphemes(t *testing.T) {
+	tests := []struct {
+		str  string
+		n    int
+		want string
+	}{
+		// 🇨🇳 由两个区域指示符组成，要作为一个整体被替换
+		{"🇨🇳🇺🇸", 1, "A🇺🇸"},
+		{"🇨🇳 hi", 2, "AAhi"},
+		// e + 组合重音符
+		{"été", 1, "Até"},
+		{"👍🏽ok", 1, "Aok"},
+		{"Hello", 10, "AAAAA"},
+		{"Hello", -1, "Hello"},
+		{"", 3, ""},
+	}
+
+	for _, tt := range tests {
+		if got := OverwriteGraphemes(tt.str, 'A', tt.n); got != tt.want {
+			t.Errorf("OverwriteGraphemes(%q, 'A', %d) = %q, want %q", tt.str, tt.n, got, tt.want)
+		}
+	}
+}
+
+func FuzzOverwriteGraphemes(f *testing.F) {
+	testx.AddSeeds(f)
+
+	f.Fuzz(func(t *testing.T, str string, value rune, n int) {
+		OverwriteGraphemes(str, value, n)
+	})
+}
+
+// OverwriteGraphemes 用 value 覆盖 str 的前 n 个用户可见字符（字素簇）
+// n 大于字符数时覆盖全部字符，n<=0 时原样返回。
+func OverwriteGraphemes(str string, value rune, n int) string {
+	if n <= 0 {
+		return str
+	}
+
+	var b strings.Builder
+	b.Grow(len(str))
+	g := uniseg.NewGraphemes(str)
+	for i := 0; g.Next(); i++ {
+		if i < n {
+			b.WriteRune(value)
+		} else {
+			b.WriteString(g.Str())
+		}
+	}
+	return b.String()
+}
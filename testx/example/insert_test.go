@@ -0,0 +1,98 @@
+package example
+
+import (
+	"gopractice/testx"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// 插入与覆盖是两种不同的操作，边界情况也不一样：
+// at=0 插在开头、at 等于字符数时插在末尾、count<=0 时不做任何修改。
+
+// 运行 go test -fuzz FuzzInsertString 执行模糊测试
+func FuzzInsertString(f *testing.F) {
+	// 种子中的 n 同时作为插入位置和插入个数
+	testx.AddSeedsFunc(f, func(s testx.Seed) []any {
+		return []any{s.Str, s.Value, s.N, s.N}
+	})
+
+	f.Fuzz(func(t *testing.T, str string, value rune, at, count int) {
+		// count 过大时会分配大量内存，这不是我们要找的问题
+		if count > 1<<10 {
+			t.Skip()
+		}
+		InsertString(str, value, at, count)
+	})
+}
+
+func TestInsertString(t *testing.T) {
+	tests := []struct {
+		str   string
+		value rune
+		at    int
+		count int
+		want  string
+	}{
+		{"Hello, world!", 'A', 5, 3, "HelloAAA, world!"},
+		{"Hello", 'A', 0, 2, "AAHello"},
+		{"Hello", 'A', 5, 2, "HelloAA"},
+		{"Hello", 'A', 100, 1, "HelloA"},
+		{"Hello", 'A', -3, 1, "AHello"},
+		{"Hello", 'A', 2, 0, "Hello"},
+		{"Hello", 'A', 2, -1, "Hello"},
+		{"你好世界", '，', 2, 1, "你好，世界"},
+		{"", 'A', 0, 3, "AAA"},
+	}
+
+	for _, tt := range tests {
+		got := InsertString(tt.str, tt.value, tt.at, tt.count)
+		if got != tt.want {
+			t.Errorf("InsertString(%q, %q, %d, %d) = %q, want %q", tt.str, tt.value, tt.at, tt.count, got, tt.want)
+			continue
+		}
+
+		// 插入位置前后的字符保持不变，长度增加 count
+		n := tt.count
+		if n < 0 {
+			n = 0
+		}
+		if utf8.RuneCountInString(got) != utf8.RuneCountInString(tt.str)+n {
+			t.Errorf("InsertString(%q, ...) rune count = %d, want %d", tt.str, utf8.RuneCountInString(got), utf8.RuneCountInString(tt.str)+n)
+		}
+		at := clampIndex(tt.at, utf8.RuneCountInString(tt.str))
+		src, dst := []rune(tt.str), []rune(got)
+		if string(dst[:at]) != string(src[:at]) || string(dst[at+n:]) != string(src[at:]) {
+			t.Errorf("InsertString(%q, ...) = %q, surrounding runes changed", tt.str, got)
+		}
+	}
+}
+
+// InsertString 在第 at 个字符处插入 count 个 value
+// at 会被限制在 [0, 字符数] 范围内，count<=0 时原样返回。
+// 如果我们运行InsertString("Hello, world!", 'A', 5, 3)，正确的输出是："HelloAAA, world!"。
+func InsertString(str string, value rune, at, count int) string {
+	if count <= 0 {
+		return str
+	}
+
+	runes := []rune(str)
+	at = clampIndex(at, len(runes))
+
+	var b strings.Builder
+	b.Grow(len(str) + count*utf8.UTFMax)
+	b.WriteString(string(runes[:at]))
+	b.WriteString(strings.Repeat(string(value), count))
+	b.WriteString(string(runes[at:]))
+	return b.String()
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
@@ -0,0 +1,91 @@
+package example
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestOverwriteInts(t *testing.T) {
+	tests := []struct {
+		s    []int
+		n    int
+		want []int
+	}{
+		{[]int{1, 2, 3, 4}, 2, []int{0, 0, 3, 4}},
+		{[]int{1, 2, 3, 4}, 4, []int{0, 0, 0, 0}},
+		{[]int{1, 2, 3, 4}, 10, []int{0, 0, 0, 0}},
+		{[]int{1, 2, 3, 4}, 0, []int{1, 2, 3, 4}},
+		{[]int{1, 2, 3, 4}, -1, []int{1, 2, 3, 4}},
+		{[]int{}, 3, []int{}},
+	}
+
+	for _, tt := range tests {
+		src := make([]int, len(tt.s))
+		copy(src, tt.s)
+		got := Overwrite(tt.s, 0, tt.n)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Overwrite(%v, 0, %d) = %v, want %v", src, tt.n, got, tt.want)
+		}
+		// 不修改传入的切片
+		if !reflect.DeepEqual(tt.s, src) {
+			t.Errorf("Overwrite(%v, 0, %d) modified its input to %v", src, tt.n, tt.s)
+		}
+	}
+}
+
+func TestOverwriteStrings(t *testing.T) {
+	got := Overwrite([]string{"a", "b", "c"}, "x", 2)
+	want := []string{"x", "x", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Overwrite = %v, want %v", got, want)
+	}
+}
+
+func TestOverwriteRunes(t *testing.T) {
+	if got := string(OverwriteRunes([]rune("你好世界"), 'A', 2)); got != "AA世界" {
+		t.Errorf("OverwriteRunes = %q, want %q", got, "AA世界")
+	}
+}
+
+func FuzzOverwriteBytes(f *testing.F) {
+	f.Add([]byte("Hello, world!"), byte('A'), 8)
+
+	f.Fuzz(func(t *testing.T, b []byte, value byte, n int) {
+		result := OverwriteBytes(b, value, n)
+		if len(result) != len(b) {
+			t.Fatalf("OverwriteBytes changed the length from %d to %d", len(b), len(result))
+		}
+		if n > 0 && n < len(b) && !bytes.Equal(result[n:], b[n:]) {
+			t.Fatalf("OverwriteBytes modified too many bytes! Expected %q, got %q.", b[n:], result[n:])
+		}
+	})
+}
+
+// Overwrite 用 value 覆盖 s 的前 min(n, len(s)) 个元素，n 为负数时视为 0
+// 返回的是一个新的切片，不会修改 s 本身。
+func Overwrite[T any](s []T, value T, n int) []T {
+	if n < 0 {
+		n = 0
+	}
+	if n > len(s) {
+		n = len(s)
+	}
+
+	result := make([]T, len(s))
+	copy(result, s)
+	for i := 0; i < n; i++ {
+		result[i] = value
+	}
+	return result
+}
+
+// OverwriteRunes 按字符覆盖
+func OverwriteRunes(r []rune, value rune, n int) []rune {
+	return Overwrite(r, value, n)
+}
+
+// OverwriteBytes 按字节覆盖
+func OverwriteBytes(b []byte, value byte, n int) []byte {
+	return Overwrite(b, value, n)
+}
@@ -0,0 +1,49 @@
+package example
+
+import (
+	"gopractice/testx/prop"
+	"testing"
+	"unicode/utf8"
+)
+
+// 基于性质的测试：与模糊测试检查同样的性质，但输入由生成器描述，在普通的 go test 中运行，
+// 失败时反例会被自动缩小，例如对 OverwriteString02 检查字符数不变，会得到 {Str:"\u0080", Value:97, N:1} 这样最简单的反例。
+
+type overwriteInput struct {
+	Str   string
+	Value rune
+	N     int
+}
+
+var overwriteInputs = prop.Struct[overwriteInput](map[string]any{
+	"Str":   prop.String(),
+	"Value": prop.Rune(),
+	"N":     prop.Int(-5, 60),
+})
+
+func TestOverwriteRunesProperty(t *testing.T) {
+	prop.ForAll(t, overwriteInputs, func(in overwriteInput) bool {
+		src := []rune(in.Str)
+		got := OverwriteRunes(src, in.Value, in.N)
+		if len(got) != len(src) {
+			return false
+		}
+		for i := range got {
+			if i < in.N && got[i] != in.Value || i >= in.N && got[i] != src[i] {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestInsertStringProperty(t *testing.T) {
+	prop.ForAll(t, overwriteInputs, func(in overwriteInput) bool {
+		got := InsertString(in.Str, in.Value, 0, in.N)
+		want := utf8.RuneCountInString(in.Str)
+		if in.N > 0 {
+			want += in.N
+		}
+		return utf8.RuneCountInString(got) == want
+	})
+}
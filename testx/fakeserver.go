@@ -0,0 +1,246 @@
+package testx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stepTimeout 每个 Expect 等待客户端数据的最长时间，以及 Wait 等待连接的最长时间
+const stepTimeout = 5 * time.Second
+
+// Step 脚本中的一步，由 Expect、Respond 等函数创建
+type Step struct {
+	name string
+	run  func(c net.Conn, r *bufio.Reader) error
+}
+
+// Script 一个连接上依次执行的步骤
+type Script []Step
+
+// errStop 连接已经被 Close 或者 Reset 步骤关闭，后面的步骤不再执行
+var errStop = errors.New("connection closed by script")
+
+// Expect 读取 len(b) 个字节，与 b 不一致时测试失败
+func Expect(b []byte) Step {
+	return Step{fmt.Sprintf("Expect(%q)", b), func(c net.Conn, r *bufio.Reader) error {
+		got := make([]byte, len(b))
+		if _, err := io.ReadFull(r, got); err != nil {
+			return err
+		}
+		if !bytes.Equal(got, b) {
+			return fmt.Errorf("got %q", got)
+		}
+		return nil
+	}}
+}
+
+// ExpectFrame 读取一个消息，消息格式与 netx 默认的 Framer 相同：4 字节小端序的长度加上内容，
+// 内容与 payload 不一致时测试失败。开启了校验和、压缩等选项时用 Expect 比较完整的字节。
+func ExpectFrame(payload []byte) Step {
+	return Step{fmt.Sprintf("ExpectFrame(%q)", payload), func(c net.Conn, r *bufio.Reader) error {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return err
+		}
+		n := binary.LittleEndian.Uint32(header[:])
+		if int(n) != len(payload) {
+			return fmt.Errorf("got a frame of %d bytes", n)
+		}
+		got := make([]byte, n)
+		if _, err := io.ReadFull(r, got); err != nil {
+			return err
+		}
+		if !bytes.Equal(got, payload) {
+			return fmt.Errorf("got frame %q", got)
+		}
+		return nil
+	}}
+}
+
+// Respond 向客户端写入 b
+func Respond(b []byte) Step {
+	return Step{fmt.Sprintf("Respond(%q)", b), func(c net.Conn, r *bufio.Reader) error {
+		_, err := c.Write(b)
+		return err
+	}}
+}
+
+// RespondFrame 向客户端写入一个消息，格式与 ExpectFrame 相同
+func RespondFrame(payload []byte) Step {
+	return Step{fmt.Sprintf("RespondFrame(%q)", payload), func(c net.Conn, r *bufio.Reader) error {
+		b := make([]byte, 4+len(payload))
+		binary.LittleEndian.PutUint32(b, uint32(len(payload)))
+		copy(b[4:], payload)
+		_, err := c.Write(b)
+		return err
+	}}
+}
+
+// Delay 等待 d，用于测试客户端的超时
+func Delay(d time.Duration) Step {
+	return Step{fmt.Sprintf("Delay(%v)", d), func(c net.Conn, r *bufio.Reader) error {
+		time.Sleep(d)
+		return nil
+	}}
+}
+
+// Close 正常关闭连接，客户端读到 io.EOF，后面的步骤不再执行
+func Close() Step {
+	return Step{"Close()", func(c net.Conn, r *bufio.Reader) error {
+		c.Close()
+		return errStop
+	}}
+}
+
+// Reset 关闭连接并发送 RST，客户端读写时返回 connection reset 错误，后面的步骤不再执行
+func Reset() Step {
+	return Step{"Reset()", func(c net.Conn, r *bufio.Reader) error {
+		if tc, ok := c.(*net.TCPConn); ok {
+			tc.SetLinger(0)
+		}
+		c.Close()
+		return errStop
+	}}
+}
+
+// FakeServer 按脚本应答的 tcp 服务端，用于测试 netx.Client 的收发、超时和重连。
+// 第 i 个连接执行第 i 个脚本，脚本执行完之后关闭连接；不一致、出错或者多出来的连接都会记录下来，
+// 在 Wait 或者测试结束时通过 t.Fatalf 报告。
+type FakeServer struct {
+	t       testing.TB
+	l       net.Listener
+	scripts []Script
+	done    []chan struct{}
+	wg      sync.WaitGroup
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	errs   []string
+	closed bool
+}
+
+// NewFakeServer 在 127.0.0.1 的随机端口上启动 FakeServer，测试结束时自动关闭
+func NewFakeServer(t testing.TB, scripts ...Script) *FakeServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("FakeServer: %v", err)
+	}
+	s := &FakeServer{t: t, l: l, scripts: scripts, done: make([]chan struct{}, len(scripts)), conns: make(map[net.Conn]struct{})}
+	for i := range s.done {
+		s.done[i] = make(chan struct{})
+	}
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Addr 返回监听的地址
+func (s *FakeServer) Addr() string {
+	return s.l.Addr().String()
+}
+
+func (s *FakeServer) serve() {
+	defer s.wg.Done()
+	for i := 0; ; i++ {
+		c, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		if i >= len(s.scripts) {
+			s.errorf("unexpected connection #%d", i)
+			c.Close()
+			continue
+		}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func(i int) {
+			defer s.wg.Done()
+			defer close(s.done[i])
+			s.run(i, c)
+		}(i)
+	}
+}
+
+func (s *FakeServer) run(i int, c net.Conn) {
+	defer func() {
+		c.Close()
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+	}()
+	r := bufio.NewReader(c)
+	for j, step := range s.scripts[i] {
+		c.SetDeadline(time.Now().Add(stepTimeout))
+		if err := step.run(c, r); err != nil {
+			if err != errStop {
+				s.errorf("connection #%d step %d %s: %v", i, j, step.name, err)
+			}
+			return
+		}
+	}
+}
+
+func (s *FakeServer) errorf(format string, args ...any) {
+	s.mu.Lock()
+	s.errs = append(s.errs, fmt.Sprintf(format, args...))
+	s.mu.Unlock()
+}
+
+// Wait 等待所有脚本执行完，有连接没有到来或者脚本执行失败时调用 t.Fatalf
+func (s *FakeServer) Wait() {
+	s.t.Helper()
+	timeout := time.After(stepTimeout)
+	for i, done := range s.done {
+		select {
+		case <-done:
+		case <-timeout:
+			s.errorf("connection #%d never finished its script", i)
+			s.report()
+			return
+		}
+	}
+	s.report()
+}
+
+// Close 关闭监听和所有连接，没有执行完的脚本会报告为错误，可以多次调用
+func (s *FakeServer) Close() {
+	s.t.Helper()
+	s.mu.Lock()
+	closed := s.closed
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	if closed {
+		return
+	}
+	s.l.Close()
+	s.wg.Wait()
+	s.report()
+}
+
+// report 报告并清空记录的错误
+func (s *FakeServer) report() {
+	s.t.Helper()
+	s.mu.Lock()
+	errs := s.errs
+	s.errs = nil
+	s.mu.Unlock()
+	if len(errs) != 0 {
+		s.t.Fatalf("FakeServer: %d errors:\n%s", len(errs), strings.Join(errs, "\n"))
+	}
+}
@@ -0,0 +1,99 @@
+package testx
+
+import (
+	"errors"
+	"fmt"
+	"gopractice/netx"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFakeServer(t *testing.T) {
+	s := NewFakeServer(t,
+		Script{ExpectFrame([]byte("ping")), RespondFrame([]byte("pong")), Close()},
+		Script{Expect([]byte("raw")), Respond([]byte("ok")), Delay(10 * time.Millisecond), Reset()},
+	)
+	c, err := netx.Dial(s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Send([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.Recv(); string(got) != "pong" || err != nil {
+		t.Fatalf("Recv() = %q, %v", got, err)
+	}
+	if _, err := c.Recv(); err != io.EOF {
+		t.Errorf("Recv() after Close() = %v, want io.EOF", err)
+	}
+
+	// 重连之后执行第二个脚本
+	conn, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("raw"))
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); string(buf) != "ok" || err != nil {
+		t.Fatalf("read %q, %v", buf, err)
+	}
+	if _, err := conn.Read(buf); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("Read() after Reset() = %v, want connection reset", err)
+	}
+	s.Wait()
+}
+
+// fatalT 记录 Fatalf，在 finish 时手动运行清理函数
+type fatalT struct {
+	testing.TB
+	cleanups []func()
+	fatals   []string
+}
+
+func (t *fatalT) Helper()          {}
+func (t *fatalT) Cleanup(f func()) { t.cleanups = append(t.cleanups, f) }
+func (t *fatalT) Fatalf(format string, args ...any) {
+	t.fatals = append(t.fatals, fmt.Sprintf(format, args...))
+}
+
+func (t *fatalT) finish() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func TestFakeServerMismatch(t *testing.T) {
+	ft := new(fatalT)
+	s := NewFakeServer(ft, Script{ExpectFrame([]byte("ping"))})
+	c, err := netx.Dial(s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Send([]byte("pong"))
+	s.Wait()
+	c.Close()
+	if len(ft.fatals) != 1 || !strings.Contains(ft.fatals[0], `step 0 ExpectFrame("ping"): got frame "pong"`) {
+		t.Errorf("fatals = %q", ft.fatals)
+	}
+
+	// 多出来的连接和没有执行完的脚本
+	ft = new(fatalT)
+	s = NewFakeServer(ft, Script{Expect([]byte("x"))})
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", s.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+	}
+	time.Sleep(20 * time.Millisecond)
+	ft.finish()
+	if len(ft.fatals) != 1 || !strings.Contains(ft.fatals[0], "unexpected connection #1") || !strings.Contains(ft.fatals[0], "connection #0 step 0") {
+		t.Errorf("fatals = %q", ft.fatals)
+	}
+}
@@ -0,0 +1,233 @@
+package testx
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// FuzzStruct 用结构体作为模糊测试的参数：原生的模糊测试只接受基本类型的语料，
+// 这里语料是一个 []byte，每次运行时由 DecodeStruct 按字段顺序解码成 T 再交给 fn，
+// 这样协议消息之类的结构体可以直接模糊测试。种子用 AddStruct 添加。
+//
+//	testx.AddStruct(f, dataReq{Name: "kwok"})
+//	testx.FuzzStruct(f, func(t *testing.T, req dataReq) { ... })
+func FuzzStruct[T any](f *testing.F, fn func(t *testing.T, v T)) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var v T
+		DecodeStruct(data, &v)
+		fn(t, v)
+	})
+}
+
+// AddStruct 把 vs 用 EncodeStruct 编码之后添加为 FuzzStruct 的种子
+func AddStruct[T any](f *testing.F, vs ...T) {
+	f.Helper()
+	for _, v := range vs {
+		f.Add(EncodeStruct(v))
+	}
+}
+
+// maxFuzzLen 解码出的切片、字符串和 map 的最大长度，避免一个很大的长度前缀导致分配大量内存
+const maxFuzzLen = 1 << 16
+
+// DecodeStruct 把 data 解码到 v 指向的值，v 必须是非 nil 的指针，解码之前先把 *v 置为零值，复用同一个值时不会留下上次的数据。
+// 按字段顺序依次读取：bool 和整数、浮点数按大小读取小端序的字节，字符串、切片和 map 先读 uvarint 的长度，
+// 指针先读一个字节，为 0 时保持 nil，长度为 0 的切片和 map 也保持 nil。未导出的字段以及 chan、func、interface 类型的字段保持零值。
+// 任意 data 都能解码，数据不够时剩下的字段为零值，因此适合把模糊测试生成的字节转换为结构体。
+func DecodeStruct(data []byte, v any) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		panic(fmt.Sprintf("testx: DecodeStruct of non-pointer %T", v))
+	}
+	rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+	d := fuzzDecoder{data: data}
+	d.decode(rv.Elem())
+}
+
+// EncodeStruct 按 DecodeStruct 的格式编码 v，DecodeStruct 解码之后得到与 v 相同的值，
+// 用于把有代表性的消息转换为种子语料
+func EncodeStruct(v any) []byte {
+	var e fuzzEncoder
+	e.encode(reflect.ValueOf(v))
+	return e.buf
+}
+
+type fuzzDecoder struct {
+	data []byte
+}
+
+// next 读取 n 个字节，不够时用 0 补齐
+func (d *fuzzDecoder) next(n int) []byte {
+	b := make([]byte, 8)
+	m := copy(b[:n], d.data)
+	d.data = d.data[m:]
+	return b
+}
+
+func (d *fuzzDecoder) length() int {
+	n, m := binary.Uvarint(d.data)
+	if m <= 0 {
+		d.data = nil
+		return 0
+	}
+	d.data = d.data[m:]
+	if n > maxFuzzLen {
+		n = maxFuzzLen
+	}
+	return int(n)
+}
+
+func (d *fuzzDecoder) decode(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(d.next(1)[0]&1 == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size := int(v.Type().Size())
+		u := binary.LittleEndian.Uint64(d.next(size))
+		// 按大小做符号扩展
+		shift := 64 - 8*size
+		v.SetInt(int64(u<<shift) >> shift)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetUint(binary.LittleEndian.Uint64(d.next(int(v.Type().Size()))))
+	case reflect.Float32:
+		v.SetFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(d.next(4)))))
+	case reflect.Float64:
+		v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(d.next(8))))
+	case reflect.String:
+		n := d.length()
+		if n > len(d.data) {
+			n = len(d.data)
+		}
+		v.SetString(string(d.data[:n]))
+		d.data = d.data[n:]
+	case reflect.Slice:
+		n := d.length()
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if n > len(d.data) {
+				n = len(d.data)
+			}
+			v.SetBytes(append([]byte(nil), d.data[:n]...))
+			d.data = d.data[n:]
+			return
+		}
+		// 元素个数不超过剩余的字节数
+		if n > len(d.data) {
+			n = len(d.data)
+		}
+		if n == 0 {
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < n; i++ {
+			d.decode(s.Index(i))
+		}
+		v.Set(s)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			d.decode(v.Index(i))
+		}
+	case reflect.Map:
+		n := d.length()
+		if n > len(d.data) {
+			n = len(d.data)
+		}
+		if n == 0 {
+			return
+		}
+		m := reflect.MakeMapWithSize(v.Type(), n)
+		for i := 0; i < n; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			val := reflect.New(v.Type().Elem()).Elem()
+			d.decode(key)
+			d.decode(val)
+			m.SetMapIndex(key, val)
+		}
+		v.Set(m)
+	case reflect.Pointer:
+		if d.next(1)[0] == 0 {
+			return
+		}
+		p := reflect.New(v.Type().Elem())
+		d.decode(p.Elem())
+		v.Set(p)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				d.decode(v.Field(i))
+			}
+		}
+	}
+}
+
+type fuzzEncoder struct {
+	buf []byte
+}
+
+func (e *fuzzEncoder) fixed(u uint64, size int) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], u)
+	e.buf = append(e.buf, b[:size]...)
+}
+
+func (e *fuzzEncoder) length(n int) {
+	var b [binary.MaxVarintLen64]byte
+	e.buf = append(e.buf, b[:binary.PutUvarint(b[:], uint64(n))]...)
+}
+
+func (e *fuzzEncoder) encode(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.buf = append(e.buf, 1)
+		} else {
+			e.buf = append(e.buf, 0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.fixed(uint64(v.Int()), int(v.Type().Size()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.fixed(v.Uint(), int(v.Type().Size()))
+	case reflect.Float32:
+		e.fixed(uint64(math.Float32bits(float32(v.Float()))), 4)
+	case reflect.Float64:
+		e.fixed(math.Float64bits(v.Float()), 8)
+	case reflect.String:
+		e.length(v.Len())
+		e.buf = append(e.buf, v.String()...)
+	case reflect.Slice:
+		e.length(v.Len())
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			e.buf = append(e.buf, v.Bytes()...)
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			e.encode(v.Index(i))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			e.encode(v.Index(i))
+		}
+	case reflect.Map:
+		e.length(v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			e.encode(iter.Key())
+			e.encode(iter.Value())
+		}
+	case reflect.Pointer:
+		if v.IsNil() {
+			e.buf = append(e.buf, 0)
+			return
+		}
+		e.buf = append(e.buf, 1)
+		e.encode(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				e.encode(v.Field(i))
+			}
+		}
+	}
+}
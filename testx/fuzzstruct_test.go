@@ -0,0 +1,73 @@
+package testx
+
+import (
+	"gopractice/netx/codec"
+	"reflect"
+	"testing"
+)
+
+// dataReq 与 netx/example 中的消息相同
+type dataReq struct {
+	Name string `json:"name"`
+}
+
+type fuzzMsg struct {
+	ID     int64
+	Small  int8
+	Flags  uint16
+	Ratio  float32
+	Admin  bool
+	Tags   []string
+	Avatar []byte
+	Scores map[string]uint8
+	Parent *dataReq
+	Pair   [2]int32
+	hidden int
+}
+
+func TestStructRoundTrip(t *testing.T) {
+	for _, v := range []fuzzMsg{
+		{},
+		{ID: -1, Small: -128, Flags: 0xffff, Ratio: 1.5, Admin: true, Tags: []string{"a", ""}, Avatar: []byte{0, 1},
+			Scores: map[string]uint8{"x": 9}, Parent: &dataReq{"kwok"}, Pair: [2]int32{-5, 7}},
+	} {
+		var got fuzzMsg
+		DecodeStruct(EncodeStruct(v), &got)
+		if !reflect.DeepEqual(got, v) {
+			t.Errorf("DecodeStruct(EncodeStruct(%+v)) = %+v", v, got)
+		}
+	}
+
+	// 任意数据都能解码，不够的字段为零值
+	var got fuzzMsg
+	DecodeStruct([]byte{0xff, 0xff}, &got)
+	if got.ID != 0xffff || got.Tags != nil || got.Parent != nil {
+		t.Errorf("DecodeStruct of 2 bytes = %+v", got)
+	}
+	DecodeStruct(append(make([]byte, 16), 0xff, 0xff, 0xff, 0xff, 0x0f, 1, 2), &got)
+	if len(got.Tags) > 2 {
+		t.Errorf("huge length prefix decoded %d tags", len(got.Tags))
+	}
+
+	// 复用同一个值时，上次解码的切片、map、指针和未导出的字段不会留下来
+	got = fuzzMsg{Tags: []string{"old"}, Scores: map[string]uint8{"old": 1}, Parent: &dataReq{"old"}, hidden: 1}
+	DecodeStruct(nil, &got)
+	if !reflect.DeepEqual(got, fuzzMsg{}) {
+		t.Errorf("DecodeStruct(nil) into a used value = %+v, want the zero value", got)
+	}
+}
+
+// 直接对 dataReq 做模糊测试：MsgPack 编码再解码之后不变
+func FuzzDataReq(f *testing.F) {
+	AddStruct(f, dataReq{}, dataReq{Name: "kwok"}, dataReq{Name: "你好\xff"})
+	FuzzStruct(f, func(t *testing.T, req dataReq) {
+		b, err := codec.MsgPack.Encode(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got dataReq
+		if err := codec.MsgPack.Decode(b, &got); err != nil || got != req {
+			t.Fatalf("MsgPack round trip of %+v = %+v, %v", req, got, err)
+		}
+	})
+}
@@ -0,0 +1,250 @@
+// Package netpipe 提供内存中的 net.Conn 连接对，用于不绑定端口测试 netx 的封包和服务端处理逻辑。
+// 与 net.Pipe 相比，可以给每个方向设置缓冲区，以及把一次 Write 拆成多次 Read 才能读完的小段，模拟 tcp 的半包。
+package netpipe
+
+import (
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Option New 的可选配置
+type Option func(o *options)
+
+type options struct {
+	buffer [2]int // 客户端到服务端、服务端到客户端的缓冲区大小
+	split  int
+}
+
+// WithBuffer 设置两个方向的缓冲区大小，默认为 0，
+// 为 0 时与 net.Pipe 相同，Write 等到对方读完所有数据才返回；大于 0 时缓冲区写满之前 Write 不会阻塞。
+func WithBuffer(clientToServer, serverToClient int) Option {
+	return func(o *options) {
+		o.buffer = [2]int{clientToServer, serverToClient}
+	}
+}
+
+// WithWriteSplit 把每次 Write 的数据拆成最多 n 字节的小段，每次 Read 最多读出一段，n <= 0 时不拆分
+func WithWriteSplit(n int) Option {
+	return func(o *options) {
+		o.split = n
+	}
+}
+
+// New 返回一对相连的 net.Conn，client 写入的数据从 server 读出，反之亦然。
+// 两端都支持读写截止时间，超时返回 os.ErrDeadlineExceeded；一端关闭之后另一端读完剩余数据后返回 io.EOF，写入返回 io.ErrClosedPipe。
+func New(opts ...Option) (client, server net.Conn) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	c2s := newPipe(o.buffer[0], o.split)
+	s2c := newPipe(o.buffer[1], o.split)
+	client = &conn{r: s2c, w: c2s, local: addr("client"), remote: addr("server")}
+	server = &conn{r: c2s, w: s2c, local: addr("server"), remote: addr("client")}
+	return client, server
+}
+
+type addr string
+
+func (addr) Network() string  { return "pipe" }
+func (a addr) String() string { return string(a) }
+
+// pipe 一个方向的数据
+type pipe struct {
+	wmu sync.Mutex // 保证每次 Write 的数据连续
+
+	mu        sync.Mutex
+	segs      [][]byte // 写入但还没有读出的数据，每段最多 split 字节
+	n         int      // segs 的总字节数
+	size      int
+	split     int
+	rclosed   bool // 读的一端已经关闭
+	wclosed   bool // 写的一端已经关闭
+	rdeadline time.Time
+	wdeadline time.Time
+	// changed 状态变化时关闭并换成新的，通知所有等待的读写
+	changed chan struct{}
+}
+
+func newPipe(size, split int) *pipe {
+	return &pipe{size: size, split: split, changed: make(chan struct{})}
+}
+
+// notifyLocked 通知等待的一方重新检查状态
+func (p *pipe) notifyLocked() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// waitLocked 释放锁等待状态变化，deadline 不为零值时最多等到 deadline
+func (p *pipe) waitLocked(deadline time.Time) error {
+	ch := p.changed
+	if deadline.IsZero() {
+		p.mu.Unlock()
+		<-ch
+		p.mu.Lock()
+		return nil
+	}
+	d := time.Until(deadline)
+	if d <= 0 {
+		return os.ErrDeadlineExceeded
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	p.mu.Unlock()
+	defer p.mu.Lock()
+	select {
+	case <-ch:
+		return nil
+	case <-t.C:
+		return os.ErrDeadlineExceeded
+	}
+}
+
+func expired(deadline time.Time) bool {
+	return !deadline.IsZero() && !time.Now().Before(deadline)
+}
+
+func (p *pipe) read(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		switch {
+		case p.rclosed:
+			return 0, io.ErrClosedPipe
+		case expired(p.rdeadline):
+			return 0, os.ErrDeadlineExceeded
+		case len(b) == 0:
+			return 0, nil
+		case p.n > 0:
+			n := copy(b, p.segs[0])
+			if n == len(p.segs[0]) {
+				p.segs = p.segs[1:]
+			} else {
+				p.segs[0] = p.segs[0][n:]
+			}
+			p.n -= n
+			p.notifyLocked()
+			return n, nil
+		case p.wclosed:
+			return 0, io.EOF
+		}
+		if err := p.waitLocked(p.rdeadline); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (p *pipe) write(b []byte) (int, error) {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	written := 0
+	for {
+		switch {
+		case p.wclosed, p.rclosed:
+			return written - p.unreadLocked(), io.ErrClosedPipe
+		case expired(p.wdeadline):
+			return written - p.unreadLocked(), os.ErrDeadlineExceeded
+		case written == len(b) && (p.size > 0 || p.n == 0):
+			return written, nil
+		}
+		if free := p.size - p.n; written < len(b) && (free > 0 || p.size == 0) {
+			n := len(b) - written
+			if p.size > 0 && n > free {
+				n = free
+			}
+			for n > 0 {
+				seg := n
+				if p.split > 0 && seg > p.split {
+					seg = p.split
+				}
+				p.segs = append(p.segs, append([]byte(nil), b[written:written+seg]...))
+				p.n += seg
+				written += seg
+				n -= seg
+			}
+			p.notifyLocked()
+			continue
+		}
+		if err := p.waitLocked(p.wdeadline); err != nil {
+			return written - p.unreadLocked(), err
+		}
+	}
+}
+
+// unreadLocked 没有缓冲区时 Write 失败，丢弃还没有被读走的数据，返回丢弃的字节数
+func (p *pipe) unreadLocked() int {
+	if p.size > 0 {
+		return 0
+	}
+	n := p.n
+	p.segs, p.n = nil, 0
+	return n
+}
+
+func (p *pipe) closeRead() {
+	p.mu.Lock()
+	p.rclosed = true
+	p.notifyLocked()
+	p.mu.Unlock()
+}
+
+func (p *pipe) closeWrite() {
+	p.mu.Lock()
+	p.wclosed = true
+	p.notifyLocked()
+	p.mu.Unlock()
+}
+
+// conn 连接的一端，从 r 读，向 w 写
+type conn struct {
+	r, w          *pipe
+	local, remote addr
+	once          sync.Once
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	return c.r.read(b)
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	return c.w.write(b)
+}
+
+// Close 关闭两个方向，之后本端的读写都返回 io.ErrClosedPipe
+func (c *conn) Close() error {
+	c.once.Do(func() {
+		c.r.closeRead()
+		c.w.closeWrite()
+	})
+	return nil
+}
+
+func (c *conn) LocalAddr() net.Addr  { return c.local }
+func (c *conn) RemoteAddr() net.Addr { return c.remote }
+
+func (c *conn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+func (c *conn) SetReadDeadline(t time.Time) error {
+	c.r.mu.Lock()
+	c.r.rdeadline = t
+	c.r.notifyLocked()
+	c.r.mu.Unlock()
+	return nil
+}
+
+func (c *conn) SetWriteDeadline(t time.Time) error {
+	c.w.mu.Lock()
+	c.w.wdeadline = t
+	c.w.notifyLocked()
+	c.w.mu.Unlock()
+	return nil
+}
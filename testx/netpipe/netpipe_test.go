@@ -0,0 +1,109 @@
+package netpipe
+
+import (
+	"bytes"
+	"errors"
+	"gopractice/netx"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPipe(t *testing.T) {
+	client, server := New()
+	go func() {
+		client.Write([]byte("hello"))
+		client.Close()
+	}()
+	got, err := io.ReadAll(server)
+	if err != nil || string(got) != "hello" {
+		t.Errorf("ReadAll() = %q, %v", got, err)
+	}
+	if _, err := server.Write([]byte("x")); err != io.ErrClosedPipe {
+		t.Errorf("Write() after the peer closed = %v", err)
+	}
+	server.Close()
+	if _, err := server.Read(make([]byte, 1)); err != io.ErrClosedPipe {
+		t.Errorf("Read() after Close = %v", err)
+	}
+	if client.LocalAddr().String() != "client" || client.RemoteAddr().String() != "server" {
+		t.Errorf("addrs = %v, %v", client.LocalAddr(), client.RemoteAddr())
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	client, server := New()
+	defer client.Close()
+	server.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	if _, err := server.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Read() = %v, want os.ErrDeadlineExceeded", err)
+	}
+
+	// 没有缓冲区时对方不读，Write 超时，返回值不包括没有读走的数据
+	client.SetWriteDeadline(time.Now().Add(20 * time.Millisecond))
+	if n, err := client.Write([]byte("abc")); n != 0 || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Write() = %d, %v, want 0, os.ErrDeadlineExceeded", n, err)
+	}
+
+	// 等待中清除截止时间之后继续等待
+	server.SetReadDeadline(time.Now().Add(time.Hour))
+	done := make(chan error, 1)
+	go func() {
+		_, err := server.Read(make([]byte, 1))
+		done <- err
+	}()
+	server.SetReadDeadline(time.Time{})
+	client.SetWriteDeadline(time.Time{})
+	client.Write([]byte("x"))
+	if err := <-done; err != nil {
+		t.Errorf("Read() = %v", err)
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	client, server := New(WithBuffer(4, 0))
+	defer server.Close()
+	if n, err := client.Write([]byte("abcd")); n != 4 || err != nil {
+		t.Fatalf("Write() into the buffer = %d, %v", n, err)
+	}
+	client.SetWriteDeadline(time.Now().Add(20 * time.Millisecond))
+	if n, err := client.Write([]byte("ef")); n != 0 || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Write() into a full buffer = %d, %v", n, err)
+	}
+	client.Close()
+	if got, err := io.ReadAll(server); string(got) != "abcd" || err != nil {
+		t.Errorf("ReadAll() = %q, %v, want the buffered data", got, err)
+	}
+}
+
+func TestWriteSplit(t *testing.T) {
+	client, server := New(WithBuffer(64, 64), WithWriteSplit(3))
+	client.Write([]byte("abcdefg"))
+	var reads []string
+	buf := make([]byte, 16)
+	for len(reads) < 3 {
+		n, err := server.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reads = append(reads, string(buf[:n]))
+	}
+	if reads[0] != "abc" || reads[1] != "def" || reads[2] != "g" {
+		t.Errorf("reads = %q", reads)
+	}
+
+	// netx 的封包在半包的情况下也能正确读出
+	a, b := netx.NewClient(client), netx.NewClient(server)
+	msg := bytes.Repeat([]byte("0123456789"), 10)
+	go func() {
+		for i := 0; i < 3; i++ {
+			a.Send(msg)
+		}
+	}()
+	for i := 0; i < 3; i++ {
+		if got, err := b.Recv(); err != nil || !bytes.Equal(got, msg) {
+			t.Fatalf("Recv() = %q, %v", got, err)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package prop
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"unicode/utf8"
+)
+
+// tree 生成的值以及缩小它的候选值，候选值按从小到大的顺序排列，同样可以继续缩小
+type tree[T any] struct {
+	value   T
+	shrinks func() []tree[T]
+}
+
+func leaf[T any](v T) tree[T] {
+	return tree[T]{value: v, shrinks: func() []tree[T] { return nil }}
+}
+
+// Gen 生成 T 类型的随机值，同时知道怎样把一个值缩小，Map、SliceOf 等组合出来的生成器也能自动缩小
+type Gen[T any] struct {
+	run func(r *rand.Rand, size int) tree[T]
+}
+
+// Const 总是生成 v
+func Const[T any](v T) Gen[T] {
+	return Gen[T]{func(r *rand.Rand, size int) tree[T] { return leaf(v) }}
+}
+
+// Int 生成 [lo, hi] 之间的整数，向最接近 0 的值缩小
+func Int(lo, hi int) Gen[int] {
+	if lo > hi {
+		panic("prop: Int with lo > hi")
+	}
+	target := 0
+	if lo > 0 {
+		target = lo
+	} else if hi < 0 {
+		target = hi
+	}
+	return Gen[int]{func(r *rand.Rand, size int) tree[int] {
+		v := lo + int(r.Int63n(int64(hi-lo)+1))
+		return intTree(target, v)
+	}}
+}
+
+// intTree 依次尝试 target 以及 v 和 target 之间不断二分的值
+func intTree(target, v int) tree[int] {
+	return tree[int]{value: v, shrinks: func() []tree[int] {
+		var s []tree[int]
+		for d := v - target; d != 0; d /= 2 {
+			s = append(s, intTree(target, v-d))
+		}
+		return s
+	}}
+}
+
+// Bool 生成 true 或 false，true 缩小为 false
+func Bool() Gen[bool] {
+	return Gen[bool]{func(r *rand.Rand, size int) tree[bool] {
+		if r.Intn(2) == 0 {
+			return leaf(false)
+		}
+		return tree[bool]{value: true, shrinks: func() []tree[bool] { return []tree[bool]{leaf(false)} }}
+	}}
+}
+
+// Rune 生成合法的 Unicode 字符，多数是可打印的 ASCII 字符，向 'a' 缩小
+func Rune() Gen[rune] {
+	return Gen[rune]{func(r *rand.Rand, size int) tree[rune] {
+		var v rune
+		switch r.Intn(4) {
+		case 0:
+			// 任意字符，跳过代理区
+			for v = rune(r.Intn(utf8.MaxRune + 1)); !utf8.ValidRune(v); v = rune(r.Intn(utf8.MaxRune + 1)) {
+			}
+		case 1:
+			v = rune(0x80 + r.Intn(0x800-0x80))
+		default:
+			v = rune(' ' + r.Intn('~'-' '+1))
+		}
+		return mapTree(intTree('a', int(v)), func(i int) rune { return rune(i) })
+	}}
+}
+
+// String 生成由 Rune 组成的字符串，长度不超过 size
+func String() Gen[string] {
+	return Map(SliceOf(Rune()), func(rs []rune) string { return string(rs) })
+}
+
+// Bytes 生成长度不超过 size 的字节切片
+func Bytes() Gen[[]byte] {
+	return SliceOf(Map(Int(0, 255), func(i int) byte { return byte(i) }))
+}
+
+// OneOf 从 vs 中随机选一个，向前面的值缩小
+func OneOf[T any](vs ...T) Gen[T] {
+	return Map(Int(0, len(vs)-1), func(i int) T { return vs[i] })
+}
+
+// Map 用 f 转换 g 生成的值，缩小时缩小转换前的值
+func Map[T, U any](g Gen[T], f func(T) U) Gen[U] {
+	return Gen[U]{func(r *rand.Rand, size int) tree[U] {
+		return mapTree(g.run(r, size), f)
+	}}
+}
+
+func mapTree[T, U any](t tree[T], f func(T) U) tree[U] {
+	return tree[U]{value: f(t.value), shrinks: func() []tree[U] {
+		ts := t.shrinks()
+		us := make([]tree[U], len(ts))
+		for i, c := range ts {
+			us[i] = mapTree(c, f)
+		}
+		return us
+	}}
+}
+
+// SliceOf 生成长度不超过 size 的切片，缩小时先删除元素，再逐个缩小元素
+func SliceOf[T any](g Gen[T]) Gen[[]T] {
+	return Gen[[]T]{func(r *rand.Rand, size int) tree[[]T] {
+		n := r.Intn(size + 1)
+		elems := make([]tree[T], n)
+		for i := range elems {
+			elems[i] = g.run(r, size)
+		}
+		return sliceTree(elems)
+	}}
+}
+
+func sliceTree[T any](elems []tree[T]) tree[[]T] {
+	v := make([]T, len(elems))
+	for i, e := range elems {
+		v[i] = e.value
+	}
+	return tree[[]T]{value: v, shrinks: func() []tree[[]T] {
+		var s []tree[[]T]
+		// 删除一半、四分之一……直到一个元素
+		for k := len(elems); k > 0; k /= 2 {
+			for i := 0; i+k <= len(elems); i += k {
+				rest := append(append([]tree[T](nil), elems[:i]...), elems[i+k:]...)
+				s = append(s, sliceTree(rest))
+			}
+		}
+		for i, e := range elems {
+			for _, c := range e.shrinks() {
+				next := append([]tree[T](nil), elems...)
+				next[i] = c
+				s = append(s, sliceTree(next))
+			}
+		}
+		return s
+	}}
+}
+
+// anyGen 隐藏了类型参数的生成器，用于 Struct
+type anyGen interface {
+	runAny(r *rand.Rand, size int) tree[any]
+	elemType() reflect.Type
+}
+
+func (g Gen[T]) runAny(r *rand.Rand, size int) tree[any] {
+	return mapTree(g.run(r, size), func(v T) any { return v })
+}
+
+func (g Gen[T]) elemType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// Struct 生成 T 类型的结构体，fields 为导出字段的名字到 Gen 的映射，没有列出的字段为零值，
+// 缩小时逐个缩小字段。字段不存在、未导出或者类型不一致时 panic。
+//
+//	prop.Struct[point](map[string]any{"X": prop.Int(-10, 10), "Y": prop.Int(-10, 10)})
+func Struct[T any](fields map[string]any) Gen[T] {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("prop: Struct of non-struct type %s", typ))
+	}
+	// 按名字排序，保证同一个种子生成相同的值
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	gens := make([]anyGen, len(names))
+	for i, name := range names {
+		f, ok := typ.FieldByName(name)
+		if !ok || !f.IsExported() {
+			panic(fmt.Sprintf("prop: %s has no exported field %s", typ, name))
+		}
+		g, ok := fields[name].(anyGen)
+		if !ok || g.elemType() != f.Type {
+			panic(fmt.Sprintf("prop: field %s.%s needs a Gen[%s]", typ, name, f.Type))
+		}
+		gens[i] = g
+	}
+	return Gen[T]{func(r *rand.Rand, size int) tree[T] {
+		vals := make([]tree[any], len(gens))
+		for i, g := range gens {
+			vals[i] = g.runAny(r, size)
+		}
+		return structTree[T](names, vals)
+	}}
+}
+
+func structTree[T any](names []string, vals []tree[any]) tree[T] {
+	var v T
+	rv := reflect.ValueOf(&v).Elem()
+	for i, name := range names {
+		f := rv.FieldByName(name)
+		if vals[i].value == nil {
+			f.Set(reflect.Zero(f.Type()))
+		} else {
+			f.Set(reflect.ValueOf(vals[i].value))
+		}
+	}
+	return tree[T]{value: v, shrinks: func() []tree[T] {
+		var s []tree[T]
+		for i, f := range vals {
+			for _, c := range f.shrinks() {
+				next := append([]tree[any](nil), vals...)
+				next[i] = c
+				s = append(s, structTree[T](names, next))
+			}
+		}
+		return s
+	}}
+}
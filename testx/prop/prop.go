@@ -0,0 +1,101 @@
+// Package prop 基于性质的测试：用 Gen 随机生成大量输入，检查每个输入都满足给定的性质，
+// 不满足时自动把反例缩小到尽量简单，并输出可以复现的随机数种子。
+// 与 testx/example/fuzzing_test.go 中的模糊测试相比，输入可以是任意结构，不需要 go test -fuzz，
+// 适合在普通的 go test 中检查“往返编码不变”“结果长度不变”这类性质。
+package prop
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// SeedEnv 设置之后使用它的值作为随机数种子，用于复现失败时输出的反例
+const SeedEnv = "PROP_SEED"
+
+// maxShrinks 缩小反例的最大步数
+const maxShrinks = 1000
+
+// Config ForAllConfig 的配置
+type Config struct {
+	Runs    int   // 检查的输入个数，默认 100
+	MaxSize int   // 切片、字符串等的最大长度，随着检查的进行从 0 增加到 MaxSize，默认 50
+	Seed    int64 // 随机数种子，为 0 时使用 SeedEnv 或者当前时间
+}
+
+// ForAll 使用默认配置检查 g 生成的输入都满足 prop
+func ForAll[T any](t testing.TB, g Gen[T], prop func(T) bool) {
+	t.Helper()
+	ForAllConfig(t, Config{}, g, prop)
+}
+
+// ForAllConfig 检查 g 生成的 cfg.Runs 个输入都满足 prop，prop 返回 false 或者 panic 都算作不满足。
+// 不满足时把反例缩小之后通过 t.Fatalf 报告，同时输出随机数种子，设置 PROP_SEED 重新运行可以得到同样的反例。
+func ForAllConfig[T any](t testing.TB, cfg Config, g Gen[T], prop func(T) bool) {
+	t.Helper()
+	if cfg.Runs <= 0 {
+		cfg.Runs = 100
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = 50
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = defaultSeed()
+	}
+	r := rand.New(rand.NewSource(seed))
+	for i := 0; i < cfg.Runs; i++ {
+		v := g.run(r, i*cfg.MaxSize/cfg.Runs)
+		ok, msg := check(prop, v.value)
+		if ok {
+			continue
+		}
+		shrunk, steps := shrink(prop, v)
+		if _, m := check(prop, shrunk.value); m != "" {
+			msg = m
+		}
+		t.Fatalf("prop: falsified after %d runs (seed %d, rerun with %s=%d)\noriginal: %#v\nshrunk (%d steps): %#v%s",
+			i+1, seed, SeedEnv, seed, v.value, steps, shrunk.value, msg)
+	}
+}
+
+func defaultSeed() int64 {
+	if s := os.Getenv(SeedEnv); s != "" {
+		if seed, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return seed
+		}
+	}
+	return time.Now().UnixNano()
+}
+
+// check 运行 prop，panic 时返回 false 和 panic 的内容
+func check[T any](prop func(T) bool, v T) (ok bool, msg string) {
+	defer func() {
+		if e := recover(); e != nil {
+			ok, msg = false, fmt.Sprintf("\npanic: %v", e)
+		}
+	}()
+	return prop(v), ""
+}
+
+// shrink 反复选择第一个仍然不满足 prop 的候选值，直到所有候选值都满足，返回最终的反例和缩小的步数
+func shrink[T any](prop func(T) bool, t tree[T]) (tree[T], int) {
+	steps := 0
+	for steps < maxShrinks {
+		next := false
+		for _, c := range t.shrinks() {
+			if ok, _ := check(prop, c.value); !ok {
+				t, next = c, true
+				steps++
+				break
+			}
+		}
+		if !next {
+			break
+		}
+	}
+	return t, steps
+}
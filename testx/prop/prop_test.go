@@ -0,0 +1,93 @@
+package prop
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fatalT 记录 Fatalf 的内容
+type fatalT struct {
+	testing.TB
+	msg string
+}
+
+func (t *fatalT) Helper() {}
+func (t *fatalT) Fatalf(format string, args ...any) {
+	t.msg = fmt.Sprintf(format, args...)
+}
+
+func falsify[T any](t *testing.T, g Gen[T], prop func(T) bool) string {
+	t.Helper()
+	ft := new(fatalT)
+	ForAllConfig(ft, Config{Seed: 1}, g, prop)
+	if ft.msg == "" {
+		t.Fatal("property was not falsified")
+	}
+	return ft.msg
+}
+
+func TestShrinkInt(t *testing.T) {
+	msg := falsify(t, Int(-1000, 1000), func(i int) bool { return i < 100 })
+	if !strings.Contains(msg, "shrunk") || !strings.HasSuffix(msg, ": 100") {
+		t.Errorf("message = %q, want the counterexample shrunk to 100", msg)
+	}
+	if !strings.Contains(msg, "PROP_SEED=1") {
+		t.Errorf("message = %q, want the seed", msg)
+	}
+	msg = falsify(t, Int(-1000, -1), func(i int) bool { return i > -50 })
+	if !strings.HasSuffix(msg, ": -50") {
+		t.Errorf("message = %q, want -50", msg)
+	}
+}
+
+func TestShrinkSlice(t *testing.T) {
+	msg := falsify(t, SliceOf(Int(0, 100)), func(s []int) bool { return len(s) < 3 })
+	if !strings.HasSuffix(msg, "[]int{0, 0, 0}") {
+		t.Errorf("message = %q, want []int{0, 0, 0}", msg)
+	}
+	msg = falsify(t, String(), func(s string) bool { return !strings.ContainsRune(s, 'z') })
+	if !strings.HasSuffix(msg, `"z"`) {
+		t.Errorf("message = %q, want \"z\"", msg)
+	}
+}
+
+type point struct {
+	X, Y int
+	Name string
+}
+
+func TestStruct(t *testing.T) {
+	g := Struct[point](map[string]any{"X": Int(0, 100), "Y": Int(0, 100)})
+	msg := falsify(t, g, func(p point) bool { return p.X+p.Y < 50 })
+	if !strings.Contains(msg, "prop.point{X:") || !strings.Contains(msg, `Name:""`) {
+		t.Errorf("message = %q", msg)
+	}
+	ForAll(t, g, func(p point) bool { return p.X >= 0 && p.X <= 100 && p.Name == "" })
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Struct with a mismatched field type did not panic")
+		}
+	}()
+	Struct[point](map[string]any{"Name": Int(0, 1)})
+}
+
+func TestPanicAndSeed(t *testing.T) {
+	msg := falsify(t, Int(0, 10), func(i int) bool {
+		if i > 5 {
+			panic("too big")
+		}
+		return true
+	})
+	if !strings.Contains(msg, "panic: too big") || !strings.Contains(msg, "): 6") {
+		t.Errorf("message = %q", msg)
+	}
+	// 同一个种子得到同样的反例
+	prop := func(s []byte) bool { return len(s) < 10 }
+	if a, b := falsify(t, Bytes(), prop), falsify(t, Bytes(), prop); a != b {
+		t.Errorf("messages with the same seed differ:\n%s\n%s", a, b)
+	}
+	ForAll(t, OneOf("a", "b"), func(s string) bool { return s == "a" || s == "b" })
+	ForAll(t, Bool(), func(bool) bool { return true })
+}
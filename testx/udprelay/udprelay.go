@@ -0,0 +1,200 @@
+// Package udprelay 提供在两个 UDP 端点之间转发数据报的本地中继，转发时按设置的概率丢包、重复、乱序和延迟，
+// 用于在糟糕的网络条件下测试 rudp 以及 UDP 的示例。随机数使用固定的种子，数据报到达的顺序相同时结果可以复现。
+package udprelay
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Policy 转发时模拟的网络条件，对两个方向同时生效
+type Policy struct {
+	Loss      float64       // 丢包的概率
+	Duplicate float64       // 多发送一份的概率
+	Reorder   float64       // 推迟到同一方向的下一个数据报之后发送的概率
+	Latency   time.Duration // 固定延迟
+	Jitter    time.Duration // 在 Latency 之上额外的随机延迟，取值为 [0, Jitter)
+	Seed      int64         // 随机数种子
+}
+
+// reorderWait 被推迟的数据报最多等待下一个数据报的时间，超时后单独发送
+const reorderWait = 50 * time.Millisecond
+
+// Stats 中继的统计信息
+type Stats struct {
+	Received   int // 收到的数据报
+	Forwarded  int // 转发出去的数据报，包括重复的
+	Dropped    int
+	Duplicated int
+	Reordered  int
+}
+
+// Relay UDP 中继，客户端把数据报发给 Addr，中继转发给 target，target 的回复转发给最近一个发来数据报的客户端
+type Relay struct {
+	pc     net.PacketConn
+	target *net.UDPAddr
+
+	mu     sync.Mutex
+	policy Policy
+	rng    *rand.Rand
+	client net.Addr
+	held   map[bool]*heldPacket // 按方向保存被推迟的数据报，true 为发往 target 的方向
+	stats  Stats
+	closed bool
+
+	done chan struct{}
+}
+
+type heldPacket struct {
+	b     []byte
+	to    net.Addr
+	timer *time.Timer
+}
+
+// New 在 127.0.0.1 的随机端口上启动中继，把数据报转发给 target
+func New(target string, p Policy) (*Relay, error) {
+	taddr, err := net.ResolveUDPAddr("udp", target)
+	if err != nil {
+		return nil, err
+	}
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	r := &Relay{
+		pc:     pc,
+		target: taddr,
+		policy: p,
+		rng:    rand.New(rand.NewSource(p.Seed)),
+		held:   make(map[bool]*heldPacket),
+		done:   make(chan struct{}),
+	}
+	go r.serve()
+	return r, nil
+}
+
+// Addr 返回中继监听的地址，客户端把它当作服务端的地址
+func (r *Relay) Addr() net.Addr {
+	return r.pc.LocalAddr()
+}
+
+// SetPolicy 修改网络条件，随机数从新的种子重新开始，例如先正常建立连接再开始丢包
+func (r *Relay) SetPolicy(p Policy) {
+	r.mu.Lock()
+	r.policy = p
+	r.rng = rand.New(rand.NewSource(p.Seed))
+	r.mu.Unlock()
+}
+
+// Stats 返回统计信息
+func (r *Relay) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// Close 停止转发，还在延迟中的数据报会被丢弃
+func (r *Relay) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	err := r.pc.Close()
+	<-r.done
+	return err
+}
+
+func (r *Relay) serve() {
+	defer close(r.done)
+	buf := make([]byte, 64<<10)
+	for {
+		n, from, err := r.pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		b := append([]byte(nil), buf[:n]...)
+		up := !sameAddr(from, r.target)
+		r.mu.Lock()
+		to := net.Addr(r.target)
+		if up {
+			r.client = from
+		} else {
+			to = r.client
+		}
+		if to != nil {
+			r.handleLocked(b, to, up)
+		}
+		r.mu.Unlock()
+	}
+}
+
+// handleLocked 按 policy 处理一个数据报，每个数据报固定抽取 4 个随机数，保证结果只取决于种子和到达顺序
+func (r *Relay) handleLocked(b []byte, to net.Addr, up bool) {
+	p := r.policy
+	lost := r.rng.Float64() < p.Loss
+	dup := r.rng.Float64() < p.Duplicate
+	reorder := r.rng.Float64() < p.Reorder
+	jitter := time.Duration(0)
+	if p.Jitter > 0 {
+		jitter = time.Duration(r.rng.Int63n(int64(p.Jitter)))
+	} else {
+		r.rng.Int63()
+	}
+	r.stats.Received++
+	if lost {
+		r.stats.Dropped++
+		return
+	}
+	delay := p.Latency + jitter
+
+	prev := r.held[up]
+	if reorder && prev == nil {
+		r.stats.Reordered++
+		h := &heldPacket{b: b, to: to}
+		h.timer = time.AfterFunc(reorderWait, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.held[up] == h {
+				delete(r.held, up)
+				r.sendLocked(h.b, h.to, delay)
+			}
+		})
+		r.held[up] = h
+		if dup {
+			r.stats.Duplicated++
+			r.sendLocked(b, to, delay)
+		}
+		return
+	}
+
+	r.sendLocked(b, to, delay)
+	if dup {
+		r.stats.Duplicated++
+		r.sendLocked(b, to, delay)
+	}
+	if prev != nil {
+		prev.timer.Stop()
+		delete(r.held, up)
+		r.sendLocked(prev.b, prev.to, delay)
+	}
+}
+
+func (r *Relay) sendLocked(b []byte, to net.Addr, delay time.Duration) {
+	if r.closed {
+		return
+	}
+	r.stats.Forwarded++
+	if delay <= 0 {
+		r.pc.WriteTo(b, to)
+		return
+	}
+	time.AfterFunc(delay, func() {
+		r.pc.WriteTo(b, to)
+	})
+}
+
+func sameAddr(a net.Addr, b *net.UDPAddr) bool {
+	ua, ok := a.(*net.UDPAddr)
+	return ok && ua.Port == b.Port && ua.IP.Equal(b.IP)
+}
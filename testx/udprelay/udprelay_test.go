@@ -0,0 +1,101 @@
+package udprelay
+
+import (
+	"bytes"
+	"gopractice/netx/rudp"
+	"io"
+	"math/rand"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func listenPacket(t *testing.T) net.PacketConn {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	return pc
+}
+
+// relayOnce 通过中继发送 n 个数据报，返回中继的统计和 sink 收到的数据报
+func relayOnce(t *testing.T, p Policy, n int) (Stats, []byte) {
+	sink := listenPacket(t)
+	r, err := New(sink.LocalAddr().String(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	client := listenPacket(t)
+	for i := 0; i < n; i++ {
+		client.WriteTo([]byte{byte(i)}, r.Addr())
+		// 等中继处理完再发下一个，到达中继的顺序固定
+		for deadline := time.Now().Add(time.Second); r.Stats().Received <= i && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	var got []byte
+	buf := make([]byte, 16)
+	for {
+		sink.SetReadDeadline(time.Now().Add(3 * reorderWait))
+		n, _, err := sink.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		got = append(got, buf[:n]...)
+	}
+	return r.Stats(), got
+}
+
+func TestPolicyReproducible(t *testing.T) {
+	p := Policy{Loss: 0.2, Duplicate: 0.1, Reorder: 0.1, Seed: 1}
+	st, got := relayOnce(t, p, 100)
+	if st.Received != 100 || st.Dropped == 0 || st.Duplicated == 0 || st.Reordered == 0 {
+		t.Errorf("Stats() = %+v", st)
+	}
+	if len(got) != st.Forwarded {
+		t.Errorf("sink received %d datagrams, relay forwarded %d", len(got), st.Forwarded)
+	}
+	st2, got2 := relayOnce(t, p, 100)
+	if st2 != st || !bytes.Equal(got, got2) {
+		t.Errorf("second run = %+v %v, first run = %+v %v", st2, got2, st, got)
+	}
+
+	if st, got := relayOnce(t, Policy{}, 10); st.Forwarded != 10 || !reflect.DeepEqual(got, []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Errorf("relay without a policy = %+v, %v", st, got)
+	}
+}
+
+func TestRUDPOverRelay(t *testing.T) {
+	l := rudp.NewListener(listenPacket(t))
+	defer l.Close()
+	r, err := New(l.Addr().String(), Policy{Loss: 0.1, Duplicate: 0.05, Reorder: 0.1, Latency: time.Millisecond, Jitter: 2 * time.Millisecond, Seed: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	client := rudp.NewConn(listenPacket(t), r.Addr())
+
+	data := make([]byte, 100_000)
+	rand.New(rand.NewSource(1)).Read(data)
+	go func() {
+		client.Write(data)
+		client.Close()
+	}()
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.SetReadDeadline(time.Now().Add(20 * time.Second))
+	got, err := io.ReadAll(server)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("ReadAll() = %d bytes, %v, want the %d bytes sent", len(got), err, len(data))
+	}
+	if st := r.Stats(); st.Dropped == 0 || st.Reordered == 0 {
+		t.Errorf("Stats() = %+v", st)
+	}
+}